@@ -50,6 +50,26 @@ export interface Alerts {
     [key: string]: Alert[];
 }
 
+export interface AudioProcessing {
+    declick?: boolean;
+    highpass?: number | null;
+    lowpass?: number | null;
+    noiseGate?: boolean;
+    noiseReduction?: boolean;
+    silenceThreshold?: number | null;
+    trimSilence?: boolean;
+}
+
+export interface AudioProfile {
+    bitrate?: number;
+    codec?: 'aac' | 'mp3' | 'opus';
+    container?: 'mp3' | 'mp4' | 'ogg' | 'webm';
+    filters?: string;
+    mono?: boolean;
+    name?: string;
+    sampleRate?: number;
+}
+
 export interface AdminEvent {
     authenticated?: boolean;
     config?: Config;
@@ -83,20 +103,29 @@ export interface Config {
 
 export interface Dirwatch {
     id?: string;
+    archiveDirectory?: string;
     delay?: number;
     deleteAfter?: boolean;
     directory?: string;
     disabled?: boolean;
     extension?: string;
+    failedDirectory?: string;
     frequency?: number;
     mask?: string;
     order?: number;
+    pollInterval?: number;
+    polling?: boolean;
     siteId?: number;
     systemId?: number;
     talkgroupId?: number;
     type?: string;
 }
 
+export interface DownsampleTier {
+    days?: number;
+    profile?: string;
+}
+
 export interface Downstream {
     id?: string;
     apikey?: string;
@@ -145,32 +174,86 @@ export interface LogsQueryOptions {
 }
 
 export interface Options {
+    archiveDays?: number;
+    archiveTablespace?: string;
     audioConversion?: 0 | 1 | 2 | 3;
+    audioProfile?: string;
+    audioProfiles?: AudioProfile[];
     autoPopulate?: boolean;
+    backupAudio?: boolean;
+    backupKeep?: number;
     branding?: string;
+    conversationGap?: number;
+    conversationPatches?: boolean;
     dimmerDelay?: number;
     disableDuplicateDetection?: boolean;
+    downsampleTiers?: DownsampleTier[];
     duplicateDetectionTimeFrame?: number;
+    duplicateHoldTime?: number;
+    duplicateResolution?: string;
     email?: string;
+    fingerprintDetection?: FingerprintDetection;
     keypadBeeps?: string;
     maxClients?: number;
     playbackGoesLive?: boolean;
     pruneDays?: number;
+    qualityGates?: QualityGates;
+    quarantine?: boolean;
+    quarantineDays?: number;
+    retentionRules?: RetentionRule[];
     showListenersCount?: boolean;
+    showStatistics?: boolean;
+    smtp?: Smtp;
     sortTalkgroups?: boolean;
     time12hFormat?: boolean;
 }
 
+export interface FingerprintDetection {
+    action?: 'off' | 'drop' | 'link';
+    similarity?: number;
+    timeFrame?: number;
+}
+
+export interface QualityGates {
+    action?: 'flag' | 'reject';
+    maxErrors?: number;
+    maxRmsLevel?: number;
+    maxSpikes?: number;
+    minDuration?: number;
+    minRmsLevel?: number;
+}
+
+export interface RetentionRule {
+    days?: number;
+    group?: string;
+    scope?: 'group' | 'system' | 'tag' | 'talkgroup';
+    system?: number;
+    tag?: string;
+    talkgroup?: number;
+}
+
 export interface Site {
     id?: number | null;
     label?: string;
     order?: number;
+    priority?: number;
     siteRef?: number;
 }
 
+export interface Smtp {
+    from?: string;
+    host?: string;
+    password?: string;
+    port?: number;
+    security?: 'none' | 'starttls' | 'tls';
+    username?: string;
+}
+
 export interface System {
     id?: number | null;
     alert?: string;
+    audioProcessing?: AudioProcessing;
+    audioProfile?: string;
     autoPopulate?: boolean;
     blacklists?: string;
     delay?: number;
@@ -195,6 +278,8 @@ export interface Tag {
 export interface Talkgroup {
     id?: number | null;
     alert?: string;
+    audioProcessing?: AudioProcessing;
+    audioProfile?: string;
     delay?: number;
     frequency?: number | null;
     groupIds?: number[];
@@ -218,6 +303,7 @@ export interface Unit {
 
 enum url {
     alerts = 'alerts',
+    audioProfiles = 'audio-profiles',
     config = 'config',
     login = 'login',
     logout = 'logout',
@@ -237,6 +323,8 @@ declare global {
 export class RdioScannerAdminService implements OnDestroy {
     Alerts: Alerts | undefined;
 
+    AudioProfiles: AudioProfile[] | undefined;
+
     event = new EventEmitter<AdminEvent>();
 
     get authenticated() {
@@ -368,6 +456,18 @@ export class RdioScannerAdminService implements OnDestroy {
         }
     }
 
+    async loadAudioProfiles(): Promise<void> {
+        try {
+            this.AudioProfiles = await firstValueFrom(this.ngHttpClient.get<AudioProfile[]>(
+                this.getUrl(url.audioProfiles),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+        }
+    }
+
     async login(password: string): Promise<boolean> {
         try {
             const res = await firstValueFrom(this.ngHttpClient.post<{
@@ -503,6 +603,18 @@ export class RdioScannerAdminService implements OnDestroy {
         });
     }
 
+    newAudioProcessingForm(processing?: AudioProcessing): FormGroup {
+        return this.ngFormBuilder.group({
+            declick: this.ngFormBuilder.control(processing?.declick || false),
+            highpass: this.ngFormBuilder.control(processing?.highpass, Validators.min(0)),
+            lowpass: this.ngFormBuilder.control(processing?.lowpass, Validators.min(0)),
+            noiseGate: this.ngFormBuilder.control(processing?.noiseGate || false),
+            noiseReduction: this.ngFormBuilder.control(processing?.noiseReduction || false),
+            silenceThreshold: this.ngFormBuilder.control(processing?.silenceThreshold, [Validators.min(-90), Validators.max(-1)]),
+            trimSilence: this.ngFormBuilder.control(processing?.trimSilence || false),
+        });
+    }
+
     newConfigForm(config?: Config): FormGroup {
         return this.ngFormBuilder.group({
             access: this.ngFormBuilder.array(config?.access?.map((access) => this.newAccessForm(access)) || []),
@@ -520,14 +632,18 @@ export class RdioScannerAdminService implements OnDestroy {
     newDirwatchForm(dirwatch?: Dirwatch): FormGroup {
         return this.ngFormBuilder.group({
             id: this.ngFormBuilder.control(dirwatch?.id),
+            archiveDirectory: this.ngFormBuilder.control(dirwatch?.archiveDirectory),
             delay: this.ngFormBuilder.control(typeof dirwatch?.delay === 'number' ? Math.max(2000, dirwatch?.delay) : 2000),
             deleteAfter: this.ngFormBuilder.control(dirwatch?.deleteAfter),
             directory: this.ngFormBuilder.control(dirwatch?.directory, [Validators.required, this.validateDirectory()]),
             disabled: this.ngFormBuilder.control(dirwatch?.disabled),
             extension: this.ngFormBuilder.control(dirwatch?.extension, this.validateExtension()),
+            failedDirectory: this.ngFormBuilder.control(dirwatch?.failedDirectory),
             frequency: this.ngFormBuilder.control(dirwatch?.frequency, Validators.min(1)),
             mask: this.ngFormBuilder.control(dirwatch?.mask, this.validateMask()),
             order: this.ngFormBuilder.control(dirwatch?.order),
+            pollInterval: this.ngFormBuilder.control(typeof dirwatch?.pollInterval === 'number' ? Math.max(1000, dirwatch?.pollInterval) : 5000, Validators.min(1000)),
+            polling: this.ngFormBuilder.control(dirwatch?.polling),
             siteId: this.ngFormBuilder.control(dirwatch?.siteId),
             systemId: this.ngFormBuilder.control(dirwatch?.systemId, this.validateDirwatchSystemId()),
             talkgroupId: this.ngFormBuilder.control(dirwatch?.talkgroupId, this.validateDirwatchTalkgroupId()),
@@ -558,18 +674,54 @@ export class RdioScannerAdminService implements OnDestroy {
 
     newOptionsForm(options?: Options): FormGroup {
         return this.ngFormBuilder.group({
+            archiveDays: this.ngFormBuilder.control(options?.archiveDays, [Validators.required, Validators.min(1)]),
+            archiveTablespace: this.ngFormBuilder.control(options?.archiveTablespace),
             audioConversion: this.ngFormBuilder.control(options?.audioConversion),
+            audioProfile: this.ngFormBuilder.control(options?.audioProfile),
+            audioProfiles: this.ngFormBuilder.control(options?.audioProfiles || []),
             autoPopulate: this.ngFormBuilder.control(options?.autoPopulate),
+            backupAudio: this.ngFormBuilder.control(options?.backupAudio),
+            backupKeep: this.ngFormBuilder.control(options?.backupKeep, [Validators.required, Validators.min(1)]),
             branding: this.ngFormBuilder.control(options?.branding),
+            conversationGap: this.ngFormBuilder.control(options?.conversationGap ?? 10000, Validators.min(0)),
+            conversationPatches: this.ngFormBuilder.control(options?.conversationPatches),
             dimmerDelay: this.ngFormBuilder.control(options?.dimmerDelay, [Validators.required, Validators.min(0)]),
             disableDuplicateDetection: this.ngFormBuilder.control(options?.disableDuplicateDetection),
+            downsampleTiers: this.ngFormBuilder.control(options?.downsampleTiers || []),
             duplicateDetectionTimeFrame: this.ngFormBuilder.control(options?.duplicateDetectionTimeFrame, [Validators.required, Validators.min(0)]),
+            duplicateHoldTime: this.ngFormBuilder.control(options?.duplicateHoldTime, Validators.min(0)),
+            duplicateResolution: this.ngFormBuilder.control(options?.duplicateResolution || 'first'),
             email: this.ngFormBuilder.control(options?.email),
+            fingerprintDetection: this.ngFormBuilder.group({
+                action: this.ngFormBuilder.control(options?.fingerprintDetection?.action || 'off'),
+                similarity: this.ngFormBuilder.control(options?.fingerprintDetection?.similarity || 90, [Validators.min(1), Validators.max(100)]),
+                timeFrame: this.ngFormBuilder.control(options?.fingerprintDetection?.timeFrame ?? 3000, Validators.min(0)),
+            }),
             keypadBeeps: this.ngFormBuilder.control(options?.keypadBeeps, Validators.required),
             maxClients: this.ngFormBuilder.control(options?.maxClients, [Validators.required, Validators.min(1)]),
             playbackGoesLive: this.ngFormBuilder.control(options?.playbackGoesLive),
             pruneDays: this.ngFormBuilder.control(options?.pruneDays, [Validators.required, Validators.min(0)]),
+            qualityGates: this.ngFormBuilder.group({
+                action: this.ngFormBuilder.control(options?.qualityGates?.action || 'flag'),
+                maxErrors: this.ngFormBuilder.control(options?.qualityGates?.maxErrors || 0, Validators.min(0)),
+                maxRmsLevel: this.ngFormBuilder.control(options?.qualityGates?.maxRmsLevel || 0, Validators.max(0)),
+                maxSpikes: this.ngFormBuilder.control(options?.qualityGates?.maxSpikes || 0, Validators.min(0)),
+                minDuration: this.ngFormBuilder.control(options?.qualityGates?.minDuration || 0, Validators.min(0)),
+                minRmsLevel: this.ngFormBuilder.control(options?.qualityGates?.minRmsLevel || 0, Validators.max(0)),
+            }),
+            quarantine: this.ngFormBuilder.control(options?.quarantine),
+            quarantineDays: this.ngFormBuilder.control(options?.quarantineDays, [Validators.required, Validators.min(1)]),
+            retentionRules: this.ngFormBuilder.control(options?.retentionRules || []),
             showListenersCount: this.ngFormBuilder.control(options?.showListenersCount),
+            showStatistics: this.ngFormBuilder.control(options?.showStatistics),
+            smtp: this.ngFormBuilder.group({
+                from: this.ngFormBuilder.control(options?.smtp?.from),
+                host: this.ngFormBuilder.control(options?.smtp?.host),
+                password: this.ngFormBuilder.control(options?.smtp?.password),
+                port: this.ngFormBuilder.control(options?.smtp?.port || 25, [Validators.min(1), Validators.max(65535)]),
+                security: this.ngFormBuilder.control(options?.smtp?.security || 'none'),
+                username: this.ngFormBuilder.control(options?.smtp?.username),
+            }),
             sortTalkgroups: this.ngFormBuilder.control(options?.sortTalkgroups),
             time12hFormat: this.ngFormBuilder.control(options?.time12hFormat),
         });
@@ -580,6 +732,7 @@ export class RdioScannerAdminService implements OnDestroy {
             id: this.ngFormBuilder.control(site?.id),
             label: this.ngFormBuilder.control(site?.label, Validators.required),
             order: this.ngFormBuilder.control(site?.order),
+            priority: this.ngFormBuilder.control(site?.priority || 0, Validators.min(0)),
             siteRef: this.ngFormBuilder.control(site?.siteRef, [Validators.required, Validators.min(1), this.validateSiteRef()]),
         });
     }
@@ -588,6 +741,8 @@ export class RdioScannerAdminService implements OnDestroy {
         return this.ngFormBuilder.group({
             id: this.ngFormBuilder.control(system?.id),
             alert: this.ngFormBuilder.control(system?.alert),
+            audioProcessing: this.newAudioProcessingForm(system?.audioProcessing),
+            audioProfile: this.ngFormBuilder.control(system?.audioProfile || ''),
             autoPopulate: this.ngFormBuilder.control(system?.autoPopulate),
             blacklists: this.ngFormBuilder.control(system?.blacklists, this.validateBlacklists()),
             delay: this.ngFormBuilder.control(system?.delay),
@@ -616,6 +771,8 @@ export class RdioScannerAdminService implements OnDestroy {
         return this.ngFormBuilder.group({
             id: this.ngFormBuilder.control(talkgroup?.id),
             alert: this.ngFormBuilder.control(talkgroup?.alert),
+            audioProcessing: this.newAudioProcessingForm(talkgroup?.audioProcessing),
+            audioProfile: this.ngFormBuilder.control(talkgroup?.audioProfile || ''),
             delay: this.ngFormBuilder.control(talkgroup?.delay),
             frequency: this.ngFormBuilder.control(talkgroup?.frequency, Validators.min(0)),
             groupIds: this.ngFormBuilder.control(talkgroup?.groupIds, [Validators.required, this.validateGroup()]),
@@ -755,7 +912,7 @@ export class RdioScannerAdminService implements OnDestroy {
                 return null;
             }
 
-            if (control.value.startsWith('\\')) {
+            if (control.value.startsWith('\\') && !control.parent?.get('polling')?.value) {
                 return { network: true }
             }
 
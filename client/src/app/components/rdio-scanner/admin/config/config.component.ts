@@ -107,6 +107,8 @@ export class RdioScannerAdminConfigComponent implements OnDestroy, OnInit {
     async ngOnInit(): Promise<void> {
         await this.adminService.loadAlerts();
 
+        await this.adminService.loadAudioProfiles();
+
         this.config = await this.adminService.getConfig();
 
         this.reset();
@@ -108,10 +108,16 @@ export class RdioScannerAdminDirwatchComponent implements OnChanges {
     }
 
     private registerOnChanges(control: FormGroup): void {
+        const directory = control.get('directory') as FormControl;
         const mask = control.get('mask') as FormControl;
+        const polling = control.get('polling') as FormControl;
         const type = control.get('type') as FormControl;
 
         mask.valueChanges.subscribe(() => this.validateIds(control));
+        polling.valueChanges.subscribe(() => {
+            directory.updateValueAndValidity();
+            directory.markAsTouched();
+        });
         type.valueChanges.subscribe(() => this.validateIds(control));
     }
 
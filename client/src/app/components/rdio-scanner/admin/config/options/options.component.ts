@@ -19,6 +19,7 @@
 
 import { Component, Input, ChangeDetectionStrategy } from '@angular/core';
 import { FormGroup } from '@angular/forms';
+import { RdioScannerAdminService } from '../../admin.service';
 
 @Component({
     selector: 'rdio-scanner-admin-options',
@@ -29,7 +30,13 @@ import { FormGroup } from '@angular/forms';
 export class RdioScannerAdminOptionsComponent {
     @Input() form: FormGroup | null = null;
 
+    get audioProfiles(): string[] {
+        return (this.adminService.AudioProfiles || []).map((profile) => profile.name || '');
+    }
+
     get controls() {
         return this.form ? this.form.controls : {};
     }
+
+    constructor(private adminService: RdioScannerAdminService) { }
 }
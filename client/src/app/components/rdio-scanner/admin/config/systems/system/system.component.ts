@@ -46,6 +46,10 @@ export class RdioScannerAdminSystemComponent {
         return Object.keys(this.adminService.Alerts || {});
     }
 
+    get audioProfiles(): string[] {
+        return (this.adminService.AudioProfiles || []).map((profile) => profile.name || '');
+    }
+
     get sites(): FormGroup[] {
         const sites = this.form.get('sites') as FormArray | null;
         return (sites?.controls as FormGroup[]) || [];
@@ -39,6 +39,10 @@ export class RdioScannerAdminTalkgroupComponent {
         return Object.keys(this.adminService.Alerts || {});
     }
 
+    get audioProfiles(): string[] {
+        return (this.adminService.AudioProfiles || []).map((profile) => profile.name || '');
+    }
+
     get groups(): Group[] {
         return (this.form?.root.get('groups')?.value as Group[]) || [];
     }
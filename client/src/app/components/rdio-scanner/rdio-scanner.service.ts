@@ -31,6 +31,7 @@ import {
     RdioScannerCategoryStatus,
     RdioScannerCategoryType,
     RdioScannerConfig,
+    RdioScannerConversation,
     RdioScannerEvent,
     RdioScannerLivefeed,
     RdioScannerLivefeedMap,
@@ -54,6 +55,7 @@ enum WebsocketCallFlag {
 enum WebsocketCommand {
     Call = 'CAL',
     Config = 'CFG',
+    Conversation = 'CNV',
     Expired = 'XPR',
     ListCall = 'LCL',
     ListenersCount = 'LSC',
@@ -106,6 +108,7 @@ export class RdioScannerService implements OnDestroy {
     private oscillatorContext: AudioContext | undefined;
 
     private playbackList: RdioScannerPlaybackList | undefined;
+    private conversationPending: number | undefined;
     private playbackPending: number | undefined;
     private playbackRefreshing = false;
 
@@ -389,34 +392,21 @@ export class RdioScannerService implements OnDestroy {
             return;
         }
 
-        if (this.skipDelay) {
-            this.skipDelay.unsubscribe();
-
-            this.skipDelay = undefined;
-        }
-
-        this.playbackPending = id;
-
-        this.stop();
+        this.preparePlayback(id);
 
-        if (this.livefeedMode === RdioScannerLivefeedMode.Offline) {
-            this.livefeedMode = RdioScannerLivefeedMode.Playback;
-
-            if (this.livefeedMapPriorToHoldSystem) {
-                this.holdSystem({ resubscribe: false });
-            }
+        this.getCall(id, WebsocketCallFlag.Play);
+    }
 
-            if (this.livefeedMapPriorToHoldTalkgroup) {
-                this.holdTalkgroup({ resubscribe: false });
-            }
+    loadAndPlayConversation(conversationId: number, callId: number): void {
+        if (!conversationId || !callId) {
+            return;
+        }
 
-            this.event.emit({ livefeedMode: this.livefeedMode, playbackPending: id });
+        this.preparePlayback(callId);
 
-        } else if (this.livefeedMode === RdioScannerLivefeedMode.Playback) {
-            this.event.emit({ playbackPending: id });
-        }
+        this.conversationPending = conversationId;
 
-        this.getCall(id, WebsocketCallFlag.Play);
+        this.sendtoWebsocket(WebsocketCommand.Conversation, `${conversationId}`, WebsocketCallFlag.Play);
     }
 
     ngOnDestroy(): void {
@@ -828,6 +818,14 @@ export class RdioScannerService implements OnDestroy {
         this.instanceId = this.router.parseUrl(this.router.url).queryParams['id'] || this.instanceId;
     }
 
+    private isTransmissionHeard(id?: number): boolean {
+        if (!id) {
+            return false;
+        }
+
+        return this.call?.id === id || this.callPrevious?.id === id || this.callQueue.some((call) => call.id === id);
+    }
+
     private openWebsocket(): void {
         const websocketUrl = window.location.href.replace(/^http/, 'ws');
 
@@ -876,7 +874,7 @@ export class RdioScannerService implements OnDestroy {
 
                             this.queue(this.transformCall(call), { priority: true });
 
-                        } else {
+                        } else if (!this.isTransmissionHeard(call.linkedTo)) {
                             this.queue(this.transformCall(call));
                         }
                     }
@@ -920,6 +918,24 @@ export class RdioScannerService implements OnDestroy {
                     break;
                 }
 
+                case WebsocketCommand.Conversation:
+                    if (message[1] !== null) {
+                        const conversation: RdioScannerConversation = message[1];
+
+                        if (conversation.id === this.conversationPending) {
+                            this.conversationPending = undefined;
+                            this.playbackPending = undefined;
+
+                            conversation.calls.slice().reverse().forEach((call) => {
+                                this.callQueue.unshift(this.transformCall(call));
+                            });
+
+                            this.queue(this.callQueue.shift() as RdioScannerCall, { priority: true });
+                        }
+                    }
+
+                    break;
+
                 case WebsocketCommand.Expired:
                     this.event.emit({ auth: true, expired: true });
 
@@ -1111,6 +1127,35 @@ export class RdioScannerService implements OnDestroy {
         });
     }
 
+    private preparePlayback(id: number): void {
+        if (this.skipDelay) {
+            this.skipDelay.unsubscribe();
+
+            this.skipDelay = undefined;
+        }
+
+        this.playbackPending = id;
+
+        this.stop();
+
+        if (this.livefeedMode === RdioScannerLivefeedMode.Offline) {
+            this.livefeedMode = RdioScannerLivefeedMode.Playback;
+
+            if (this.livefeedMapPriorToHoldSystem) {
+                this.holdSystem({ resubscribe: false });
+            }
+
+            if (this.livefeedMapPriorToHoldTalkgroup) {
+                this.holdTalkgroup({ resubscribe: false });
+            }
+
+            this.event.emit({ livefeedMode: this.livefeedMode, playbackPending: id });
+
+        } else if (this.livefeedMode === RdioScannerLivefeedMode.Playback) {
+            this.event.emit({ playbackPending: id });
+        }
+    }
+
     private readLivefeedMap(): void {
         try {
             let lfm: { [key: number]: { [key: number]: boolean } } = {};
@@ -45,13 +45,18 @@ export interface RdioScannerCall {
     };
     audioName?: string;
     audioType?: string;
+    conversation?: number;
     dateTime: Date;
     delayed: boolean;
+    duration?: number;
+    flags?: string[];
     frequencies?: RdioScannerCallFrequency[];
     frequency?: number;
     groupsData?: RdioScannerGroupData[];
     id: number;
+    linkedTo?: number;
     patches: number[];
+    peaks?: number[];
     source?: number;
     sources?: RdioScannerCallSource[];
     system: number;
@@ -108,6 +113,16 @@ export interface RdioScannerConfig {
     time12hFormat: boolean;
 }
 
+export interface RdioScannerConversation {
+    id: number;
+    callCount: number;
+    calls: RdioScannerCall[];
+    dateTime: Date;
+    endTime: Date;
+    system: number;
+    talkgroup: number;
+}
+
 export interface RdioScannerEvent {
     auth?: boolean;
     categories?: RdioScannerCategory[];
@@ -168,6 +183,7 @@ export interface RdioScannerOscillatorData {
 
 export interface RdioScannerPlaybackList {
     count: number;
+    cursor?: string;
     dateStart: Date;
     dateStop: Date;
     options: RdioScannerSearchOptions;
@@ -175,15 +191,25 @@ export interface RdioScannerPlaybackList {
 }
 
 export interface RdioScannerSearchOptions {
+    conversation?: number;
+    cursor?: string;
     date?: Date;
+    dateEnd?: Date;
     group?: string;
+    label?: string;
     limit: number;
+    maxDuration?: number;
+    maxFrequency?: number;
+    minDuration?: number;
+    minFrequency?: number;
     offset: number;
+    patch?: number;
+    site?: number;
     sort: number;
     system?: number;
     tag?: string;
     talkgroup?: number;
-    unit?: number;
+    unit?: number | number[];
 }
 
 export interface RdioScannerSystem {
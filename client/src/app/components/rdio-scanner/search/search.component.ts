@@ -84,7 +84,7 @@ export class RdioScannerSearchComponent implements OnDestroy {
             system: number;
             tag: number;
             talkgroup: number;
-            unit: number;
+            unit: number | null;
         }>({
             date: null,
             group: -1,
@@ -92,7 +92,7 @@ export class RdioScannerSearchComponent implements OnDestroy {
             system: -1,
             tag: -1,
             talkgroup: -1,
-            unit: -1,
+            unit: null,
         });
 
         this.eventSubscription = this.rdioScannerService.event.subscribe((event: RdioScannerEvent) => this.eventHandler(event));
@@ -122,6 +122,10 @@ export class RdioScannerSearchComponent implements OnDestroy {
         this.rdioScannerService.loadAndPlay(id);
     }
 
+    playConversation(conversationId: number, callId: number): void {
+        this.rdioScannerService.loadAndPlayConversation(conversationId, callId);
+    }
+
     refreshFilters(): void {
         if (!this.config) {
             return;
@@ -223,7 +227,7 @@ export class RdioScannerSearchComponent implements OnDestroy {
             system: -1,
             tag: -1,
             talkgroup: -1,
-            unit: -1,
+            unit: null,
         });
 
         this.paginator?.firstPage();
@@ -284,6 +288,12 @@ export class RdioScannerSearchComponent implements OnDestroy {
             }
         }
 
+        const unit = this.form.get('unit')?.value;
+
+        if (unit && unit > 0) {
+            options.unit = unit;
+        }
+
         this.resultsPending = true;
 
         this.form.disable();
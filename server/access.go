@@ -120,6 +120,49 @@ func (access *Access) HasAccess(call *Call) bool {
 	return false
 }
 
+// Scope returns the sql condition restricting the systems and talkgroups, as
+// aliased s and t, to the ones of the access along with its arguments, or an
+// empty string if all of them are accessible.
+func (access *Access) Scope() (string, []any) {
+	switch v := access.Systems.(type) {
+	case []any:
+		a := []string{}
+		args := []any{}
+		for _, scope := range v {
+			switch v := scope.(type) {
+			case map[string]any:
+				id, ok := v["id"].(float64)
+				if !ok {
+					continue
+				}
+				switch talkgroups := v["talkgroups"].(type) {
+				case []any:
+					refs := []uint{}
+					for _, ref := range talkgroups {
+						if ref, ok := ref.(float64); ok {
+							refs = append(refs, uint(ref))
+						}
+					}
+					in, inArgs := sqlIn(refs)
+					a = append(a, fmt.Sprintf(`(s."systemRef" = ? AND t."talkgroupRef" IN %s)`, in))
+					args = append(append(args, uint(id)), inArgs...)
+				case string:
+					if talkgroups == "*" {
+						a = append(a, `s."systemRef" = ?`)
+						args = append(args, uint(id))
+					}
+				}
+			}
+		}
+		if len(a) == 0 {
+			return "1 = 0", nil
+		}
+		return fmt.Sprintf("(%s)", strings.Join(a, " OR ")), args
+	}
+
+	return "", nil
+}
+
 func (access *Access) HasExpired() bool {
 	if access.Expiration > 0 {
 		return time.Unix(int64(access.Expiration), 0).Before(time.Now())
@@ -222,7 +265,7 @@ func (accesses *Accesses) IsRestricted() bool {
 	return len(accesses.List) > 0
 }
 
-func (accesses *Accesses) Read(db *Database) error {
+func (accesses *Accesses) Read(db Storage) error {
 	var (
 		err   error
 		query string
@@ -237,7 +280,7 @@ func (accesses *Accesses) Read(db *Database) error {
 	formatError := errorFormatter("accesses", "read")
 
 	query = `SELECT "accessId", "code", "expiration", "ident", "limit", "order", "systems" FROM "accesses"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -287,13 +330,13 @@ func (accesses *Accesses) Remove(access *Access) (*Accesses, bool) {
 	return accesses, removed
 }
 
-func (accesses *Accesses) Write(db *Database) error {
+func (accesses *Accesses) Write(db Storage) error {
 	var (
 		accessIds = []uint64{}
 		err       error
 		query     string
 		rows      *sql.Rows
-		tx        *sql.Tx
+		tx        *StorageTx
 	)
 
 	accesses.mutex.Lock()
@@ -301,7 +344,7 @@ func (accesses *Accesses) Write(db *Database) error {
 
 	formatError := errorFormatter("accesses", "write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -336,13 +379,11 @@ func (accesses *Accesses) Write(db *Database) error {
 	}
 
 	if len(accessIds) > 0 {
-		if b, err := json.Marshal(accessIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "accesses" WHERE "accessId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
+		in, args := sqlIn(accessIds)
+		query = fmt.Sprintf(`DELETE FROM "accesses" WHERE "accessId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
 		}
 	}
 
@@ -357,21 +398,21 @@ func (accesses *Accesses) Write(db *Database) error {
 		}
 
 		if access.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "accesses" WHERE "accessId" = %d`, access.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "accesses" WHERE "accessId" = ?`
+			if err = tx.QueryRow(query, access.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "accesses" ("code", "expiration", "ident", "limit", "order", "systems") VALUES ('%s', %d, '%s', %d, %d, '%s')`, escapeQuotes(access.Code), access.Expiration, escapeQuotes(access.Ident), access.Limit, access.Order, systems)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "accesses" ("code", "expiration", "ident", "limit", "order", "systems") VALUES (?, ?, ?, ?, ?, ?)`
+			if _, err = tx.Exec(query, access.Code, access.Expiration, access.Ident, access.Limit, access.Order, systems); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "accesses" SET "code" = '%s', "expiration" = %d, "ident" = '%s', "limit" = %d, "order" = %d, "systems" = '%s' WHERE "accessId" = %d`, escapeQuotes(access.Code), access.Expiration, escapeQuotes(access.Ident), access.Limit, access.Order, systems, access.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "accesses" SET "code" = ?, "expiration" = ?, "ident" = ?, "limit" = ?, "order" = ?, "systems" = ? WHERE "accessId" = ?`
+			if _, err = tx.Exec(query, access.Code, access.Expiration, access.Ident, access.Limit, access.Order, systems, access.Id); err != nil {
 				break
 			}
 		}
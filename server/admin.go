@@ -24,8 +24,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -92,6 +94,92 @@ func (admin *Admin) AlertsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (admin *Admin) AudioProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		t := admin.GetAuthorization(r)
+		if !admin.ValidateToken(t) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if b, err := json.Marshal(admin.Controller.Options.ListAudioProfiles()); err == nil {
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) BackupsHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.backupshandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if name := r.URL.Query().Get("name"); len(name) > 0 {
+			if filename, ok := admin.Controller.Backups.GetPath(name); ok {
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+				http.ServeFile(w, r, filename)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+
+		files, err := admin.Controller.Backups.List()
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(files); err == nil {
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case http.MethodPost:
+		m := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		audio := admin.Controller.Options.BackupAudio
+		if v, ok := m["audio"].(bool); ok {
+			audio = v
+		}
+
+		file, err := admin.Controller.Backups.Create(audio, admin.Controller.Options.BackupKeep)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(file); err == nil {
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (admin *Admin) BroadcastConfig() {
 	if b, err := json.Marshal(admin.GetConfig()); err == nil {
 		for conn := range admin.Conns {
@@ -315,6 +403,93 @@ func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (admin *Admin) DiscoveriesHandler(w http.ResponseWriter, r *http.Request) {
+	var systemRef, talkgroupRef uint
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.discoverieshandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if i, err := strconv.Atoi(r.URL.Query().Get("system")); err == nil && i > 0 {
+		systemRef = uint(i)
+	}
+
+	if i, err := strconv.Atoi(r.URL.Query().Get("talkgroup")); err == nil && i > 0 {
+		talkgroupRef = uint(i)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		discoveries, err := admin.Controller.Discoveries.List(admin.Controller.Database, systemRef)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		b, err := json.Marshal(discoveries)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	case http.MethodDelete:
+		if err := admin.Controller.Discoveries.Clear(admin.Controller.Database, systemRef, talkgroupRef); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) DownsamplingHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.downsamplinghandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		admin.Controller.Downsampler.Start()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := admin.Controller.Downsampler.Report(admin.Controller.Database)
+	if err != nil {
+		logError(err)
+		w.WriteHeader(http.StatusExpectationFailed)
+		return
+	}
+
+	if b, err := json.Marshal(report); err == nil {
+		w.Write(b)
+	} else {
+		w.WriteHeader(http.StatusExpectationFailed)
+	}
+}
+
 func (admin *Admin) GetAuthorization(r *http.Request) string {
 	return r.Header.Get("Authorization")
 }
@@ -333,6 +508,185 @@ func (admin *Admin) GetConfig() map[string]any {
 	}
 }
 
+func (admin *Admin) IncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.incidentshandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var (
+			b   []byte
+			err error
+		)
+
+		if id, e := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64); e == nil {
+			if r.URL.Query().Has("export") {
+				w.Header().Set("Content-Type", "application/zip")
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="incident-%d.zip"`, id))
+
+				if err = admin.Controller.Incidents.Export(id, w, admin.Controller.Database); err != nil {
+					logError(err)
+				}
+				return
+			}
+
+			var incident *Incident
+			if incident, err = admin.Controller.Incidents.GetIncident(id, admin.Controller.Database); err == nil {
+				b, err = json.Marshal(incident)
+			}
+
+		} else {
+			var incidents []*Incident
+			if incidents, err = admin.Controller.Incidents.List(admin.Controller.Database); err == nil {
+				b, err = json.Marshal(incidents)
+			}
+		}
+
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	case http.MethodPost:
+		m := map[string]any{}
+		err := json.NewDecoder(r.Body).Decode(&m)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		incident, err := admin.Controller.Incidents.Process(NewIncidentRequest().FromMap(m), admin.Controller.Database)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		b, err := json.Marshal(incident)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) IngestHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		b, err := json.Marshal(admin.Controller.Ingester.Stats())
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.ingesthandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) JobsHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.jobshandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var (
+			b   []byte
+			err error
+		)
+
+		if r.URL.Query().Has("history") {
+			var runs []*SchedulerRun
+			if runs, err = admin.Controller.Scheduler.History(r.URL.Query().Get("name"), admin.Controller.Database); err == nil {
+				b, err = json.Marshal(runs)
+			}
+
+		} else {
+			b, err = json.Marshal(admin.Controller.Scheduler.List())
+		}
+
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	case http.MethodPost:
+		m := map[string]any{}
+		err := json.NewDecoder(r.Body).Decode(&m)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		name, _ := m["name"].(string)
+
+		switch m["action"] {
+		case "disable":
+			err = admin.Controller.Scheduler.SetEnabled(name, false)
+		case "enable":
+			err = admin.Controller.Scheduler.SetEnabled(name, true)
+		case "run":
+			err = admin.Controller.Scheduler.Trigger(name)
+		case "schedule":
+			schedule, _ := m["schedule"].(string)
+			err = admin.Controller.Scheduler.SetSchedule(name, schedule)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(admin.Controller.Scheduler.List()); err == nil {
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (admin *Admin) LogsHandler(w http.ResponseWriter, r *http.Request) {
 	t := admin.GetAuthorization(r)
 	if !admin.ValidateToken(t) {
@@ -542,6 +896,91 @@ func (admin *Admin) PasswordHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (admin *Admin) QuarantineHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.quarantinehandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		groups, err := admin.Controller.Quarantine.List(admin.Controller.Database)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		b, err := json.Marshal(groups)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	case http.MethodPost:
+		m := map[string]any{}
+		err := json.NewDecoder(r.Body).Decode(&m)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		count, err := admin.Controller.Quarantine.Process(NewQuarantineRequest().FromMap(m), admin.Controller.Database)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		b, err := json.Marshal(map[string]any{"count": count})
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) RetentionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		t := admin.GetAuthorization(r)
+		if !admin.ValidateToken(t) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		reports, err := admin.Controller.Retention.Report(admin.Controller.Database)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.retentionhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(reports); err == nil {
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (admin *Admin) SendConfig(w http.ResponseWriter) {
 	var m map[string]any
 	_, docker := os.LookupEnv("DOCKER")
@@ -601,6 +1040,36 @@ func (admin *Admin) Start() error {
 	return nil
 }
 
+func (admin *Admin) StatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		t := admin.GetAuthorization(r)
+		if !admin.ValidateToken(t) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		statisticsOptions := NewStatisticsOptions().FromQuery(r.URL.Query().Get)
+
+		results, err := admin.Controller.Statistics.Compute(statisticsOptions, nil, true, admin.Controller.Database)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.statisticshandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(results); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (admin *Admin) UserAddHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
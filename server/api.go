@@ -21,11 +21,13 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -93,11 +95,131 @@ func (api *Api) CallUploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// CallsHandler searches the calls accessible to the listener with the same
+// filters as the search panel, comma separated units included, paging with
+// the cursor of the previous results. When listeners access is restricted,
+// the access code is given with the code parameter.
+func (api *Api) CallsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		client := &Client{Controller: api.Controller}
+
+		if api.Controller.Accesses.IsRestricted() {
+			if a, ok := api.Controller.Accesses.GetAccess(r.URL.Query().Get("code")); ok && !a.HasExpired() {
+				client.Access = a
+
+			} else {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("Invalid access code\n"))
+				return
+			}
+		}
+
+		client.Scope(api.Controller.Groups, api.Controller.Options, api.Controller.Systems, api.Controller.Tags)
+
+		m := map[string]any{}
+
+		for key := range r.URL.Query() {
+			value := r.URL.Query().Get(key)
+
+			switch key {
+			case "code":
+				continue
+
+			case "cursor", "date", "dateEnd", "group", "label", "tag":
+				m[key] = value
+
+			case "unit":
+				units := []any{}
+				for _, s := range strings.Split(value, ",") {
+					if f, err := strconv.ParseFloat(s, 64); err == nil {
+						units = append(units, f)
+					}
+				}
+				m[key] = units
+
+			default:
+				if f, err := strconv.ParseFloat(value, 64); err == nil {
+					m[key] = f
+				}
+			}
+		}
+
+		searchResults, err := api.Controller.Calls.Search(NewCallSearchOptions().fromMap(m), client)
+		if err != nil {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+			return
+		}
+
+		if b, err := json.Marshal(searchResults); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+
+		} else {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Unsupported method\n"))
+	}
+}
+
+// ConversationHandler returns a conversation with its calls. When listeners
+// access is restricted, the access code is given with the code parameter.
+func (api *Api) ConversationHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var access *Access
+
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			api.exitWithError(w, http.StatusBadRequest, "Invalid conversation id")
+			return
+		}
+
+		if api.Controller.Accesses.IsRestricted() {
+			if a, ok := api.Controller.Accesses.GetAccess(r.URL.Query().Get("code")); ok && !a.HasExpired() {
+				access = a
+
+			} else {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("Invalid access code\n"))
+				return
+			}
+		}
+
+		conversation, err := api.Controller.Conversations.GetConversation(id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Conversation not found\n"))
+			return
+		}
+
+		if access != nil {
+			conversation.Filter(access)
+		}
+
+		if b, err := json.Marshal(conversation); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+
+		} else {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Unsupported method\n"))
+	}
+}
+
 func (api *Api) HandleCall(key string, call *Call, w http.ResponseWriter) {
 	msg := []byte(fmt.Sprintf("Invalid API key for system %v talkgroup %v.\n", call.System, call.Talkgroup))
 
 	if apikey, ok := api.Controller.Apikeys.GetApikey(key); ok {
 		if apikey.HasAccess(call) {
+			call.Source = apikey.Source()
 			api.Controller.Ingest <- call
 
 		} else {
@@ -115,6 +237,96 @@ func (api *Api) HandleCall(key string, call *Call, w http.ResponseWriter) {
 	w.Write([]byte("Call imported successfully.\n"))
 }
 
+func (api *Api) IncidentHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			api.exitWithError(w, http.StatusBadRequest, "Invalid incident id")
+			return
+		}
+
+		incident, err := api.Controller.Incidents.GetIncident(id, api.Controller.Database)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Incident not found\n"))
+			return
+		}
+
+		if access, ok := api.Controller.Accesses.GetAccess(r.URL.Query().Get("code")); !ok || access.HasExpired() || !incident.IsSharedWith(access) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Invalid access code\n"))
+			return
+		}
+
+		calls := []*Call{}
+
+		for _, callId := range incident.CallIds {
+			if call, err := api.Controller.Calls.GetCall(callId); err == nil {
+				calls = append(calls, call)
+			}
+		}
+
+		if b, err := json.Marshal(map[string]any{"calls": calls, "incident": incident}); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+
+		} else {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Unsupported method\n"))
+	}
+}
+
+// StatisticsHandler returns the activity of the talkgroups accessible to the
+// listener, when allowed by the options.
+func (api *Api) StatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var access *Access
+
+		if !api.Controller.Options.ShowStatistics {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Statistics are not available\n"))
+			return
+		}
+
+		if api.Controller.Accesses.IsRestricted() {
+			if a, ok := api.Controller.Accesses.GetAccess(r.URL.Query().Get("code")); ok && !a.HasExpired() {
+				access = a
+
+			} else {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("Invalid access code\n"))
+				return
+			}
+		}
+
+		statisticsOptions := NewStatisticsOptions().FromQuery(r.URL.Query().Get)
+
+		results, err := api.Controller.Statistics.Compute(statisticsOptions, access, false, api.Controller.Database)
+		if err != nil {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+			return
+		}
+
+		if b, err := json.Marshal(results); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+
+		} else {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Unsupported method\n"))
+	}
+}
+
 func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -183,6 +395,44 @@ func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+func (api *Api) UnitsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var access *Access
+
+		if api.Controller.Accesses.IsRestricted() {
+			if a, ok := api.Controller.Accesses.GetAccess(r.URL.Query().Get("code")); ok && !a.HasExpired() {
+				access = a
+
+			} else {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("Invalid access code\n"))
+				return
+			}
+		}
+
+		searchOptions := NewUnitActivitySearchOptions().FromQuery(r.URL.Query().Get)
+
+		searchResults, err := api.Controller.UnitActivities.Search(searchOptions, access, api.Controller.Database)
+		if err != nil {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+			return
+		}
+
+		if b, err := json.Marshal(searchResults); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+
+		} else {
+			api.exitWithError(w, http.StatusExpectationFailed, err.Error())
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Unsupported method\n"))
+	}
+}
+
 func (api *Api) exitWithError(w http.ResponseWriter, status int, message string) {
 	api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api: %s", message))
 
@@ -26,7 +26,6 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strings"
 	"sync"
 )
 
@@ -128,6 +127,14 @@ func (apikey *Apikey) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
+// Source returns the ingest source recorded with the calls of the key.
+func (apikey *Apikey) Source() string {
+	if len(apikey.Ident) > 0 {
+		return fmt.Sprintf("api:%s", apikey.Ident)
+	}
+	return fmt.Sprintf("api:%d", apikey.Id)
+}
+
 type Apikeys struct {
 	List  []*Apikey
 	mutex sync.Mutex
@@ -169,7 +176,7 @@ func (apikeys *Apikeys) GetApikey(key string) (apikey *Apikey, ok bool) {
 	return nil, false
 }
 
-func (apikeys *Apikeys) Read(db *Database) error {
+func (apikeys *Apikeys) Read(db Storage) error {
 	var (
 		err   error
 		query string
@@ -184,7 +191,7 @@ func (apikeys *Apikeys) Read(db *Database) error {
 	formatError := apikeys.errorFormatter("read")
 
 	query = `SELECT "apikeyId", "disabled", "ident", "key", "order", "systems" FROM "apikeys"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -218,13 +225,13 @@ func (apikeys *Apikeys) Read(db *Database) error {
 	return nil
 }
 
-func (apikeys *Apikeys) Write(db *Database) error {
+func (apikeys *Apikeys) Write(db Storage) error {
 	var (
 		apikeyIds = []uint64{}
 		err       error
 		query     string
 		rows      *sql.Rows
-		tx        *sql.Tx
+		tx        *StorageTx
 	)
 
 	apikeys.mutex.Lock()
@@ -232,7 +239,7 @@ func (apikeys *Apikeys) Write(db *Database) error {
 
 	formatError := apikeys.errorFormatter("write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -267,13 +274,11 @@ func (apikeys *Apikeys) Write(db *Database) error {
 	}
 
 	if len(apikeyIds) > 0 {
-		if b, err := json.Marshal(apikeyIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "apikeys" WHERE "apikeyId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
+		in, args := sqlIn(apikeyIds)
+		query = fmt.Sprintf(`DELETE FROM "apikeys" WHERE "apikeyId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
 		}
 	}
 
@@ -290,21 +295,21 @@ func (apikeys *Apikeys) Write(db *Database) error {
 		}
 
 		if apikey.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "apikeys" WHERE "apikeyId" = %d`, apikey.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "apikeys" WHERE "apikeyId" = ?`
+			if err = tx.QueryRow(query, apikey.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "apikeys" ("disabled", "ident", "key", "order", "systems") VALUES (%t, '%s', '%s', %d, '%s')`, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, systems)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "apikeys" ("disabled", "ident", "key", "order", "systems") VALUES (?, ?, ?, ?, ?)`
+			if _, err = tx.Exec(query, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, systems); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "apikeys" SET "disabled" = %t, "ident" = '%s', "key" = '%s', "order" = %d, "systems" = '%s' WHERE "apikeyId" = %d`, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, systems, apikey.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "apikeys" SET "disabled" = ?, "ident" = ?, "key" = ?, "order" = ?, "systems" = ? WHERE "apikeyId" = ?`
+			if _, err = tx.Exec(query, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, systems, apikey.Id); err != nil {
 				break
 			}
 		}
@@ -0,0 +1,206 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const AudioProcessingSilenceThreshold = -50
+
+// AudioProcessing is the optional clean up stage applied before the audio is
+// encoded. It is set on systems and talkgroups, the talkgroup settings
+// replacing the system ones.
+type AudioProcessing struct {
+	DeClick          bool `json:"declick,omitempty"`
+	HighPass         uint `json:"highpass,omitempty"`
+	LowPass          uint `json:"lowpass,omitempty"`
+	NoiseGate        bool `json:"noiseGate,omitempty"`
+	NoiseReduction   bool `json:"noiseReduction,omitempty"`
+	SilenceThreshold int  `json:"silenceThreshold,omitempty"`
+	TrimSilence      bool `json:"trimSilence,omitempty"`
+}
+
+var audioProcessingSilenceRegexp = regexp.MustCompile(`silence_start: (-?[0-9.e-]+)[\s\S]*?silence_end: ([0-9.]+)`)
+
+func NewAudioProcessing() *AudioProcessing {
+	return &AudioProcessing{}
+}
+
+// ParseAudioProcessing decodes the value stored in the database, an empty
+// value meaning no processing.
+func ParseAudioProcessing(s string) *AudioProcessing {
+	var m map[string]any
+
+	if len(s) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil
+	}
+
+	return NewAudioProcessing().FromMap(m).orNil()
+}
+
+// ResolveAudioProcessing returns the processing of the talkgroup, or of its
+// system when the talkgroup has none.
+func ResolveAudioProcessing(call *Call) *AudioProcessing {
+	if call.Talkgroup != nil && call.Talkgroup.AudioProcessing != nil {
+		return call.Talkgroup.AudioProcessing
+	}
+
+	if call.System != nil && call.System.AudioProcessing != nil {
+		return call.System.AudioProcessing
+	}
+
+	return nil
+}
+
+func (processing *AudioProcessing) FromMap(m map[string]any) *AudioProcessing {
+	switch v := m["declick"].(type) {
+	case bool:
+		processing.DeClick = v
+	}
+
+	switch v := m["highpass"].(type) {
+	case float64:
+		processing.HighPass = uint(v)
+	}
+
+	switch v := m["lowpass"].(type) {
+	case float64:
+		processing.LowPass = uint(v)
+	}
+
+	switch v := m["noiseGate"].(type) {
+	case bool:
+		processing.NoiseGate = v
+	}
+
+	switch v := m["noiseReduction"].(type) {
+	case bool:
+		processing.NoiseReduction = v
+	}
+
+	switch v := m["silenceThreshold"].(type) {
+	case float64:
+		processing.SilenceThreshold = int(v)
+	}
+
+	switch v := m["trimSilence"].(type) {
+	case bool:
+		processing.TrimSilence = v
+	}
+
+	return processing
+}
+
+// Filters returns the ffmpeg filter chain of the processing stage. Leading
+// silence is measured with silencedetect so that TrimmedOffset can tell how
+// much audio was removed from the start.
+func (processing *AudioProcessing) Filters() []string {
+	filters := []string{}
+
+	if processing.HighPass > 0 {
+		filters = append(filters, fmt.Sprintf("highpass=f=%d", processing.HighPass))
+	}
+
+	if processing.LowPass > 0 {
+		filters = append(filters, fmt.Sprintf("lowpass=f=%d", processing.LowPass))
+	}
+
+	if processing.DeClick {
+		filters = append(filters, "adeclick")
+	}
+
+	if processing.NoiseReduction {
+		filters = append(filters, "afftdn")
+	}
+
+	if processing.NoiseGate {
+		filters = append(filters, "agate=threshold=0.01:ratio=4:attack=5:release=150")
+	}
+
+	if processing.TrimSilence {
+		threshold := processing.threshold()
+
+		filters = append(filters,
+			fmt.Sprintf("silencedetect=n=%ddB:d=0.05", threshold),
+			fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%ddB", threshold),
+			"areverse",
+			fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%ddB", threshold),
+			"areverse",
+		)
+	}
+
+	return filters
+}
+
+// String returns the value stored in the database.
+func (processing *AudioProcessing) String() string {
+	if processing == nil {
+		return ""
+	}
+
+	if b, err := json.Marshal(processing); err == nil {
+		return string(b)
+	}
+
+	return ""
+}
+
+// TrimmedOffset returns the seconds of leading silence removed, read from the
+// silencedetect output of the ffmpeg run.
+func (processing *AudioProcessing) TrimmedOffset(stderr string) float32 {
+	if !processing.TrimSilence {
+		return 0
+	}
+
+	if m := audioProcessingSilenceRegexp.FindStringSubmatch(stderr); len(m) == 3 {
+		if start, err := strconv.ParseFloat(m[1], 32); err == nil && start <= 0.01 {
+			if end, err := strconv.ParseFloat(m[2], 32); err == nil && end > 0 {
+				return float32(end)
+			}
+		}
+	}
+
+	return 0
+}
+
+func (processing *AudioProcessing) orNil() *AudioProcessing {
+	if len(processing.Filters()) == 0 {
+		return nil
+	}
+
+	return processing
+}
+
+func (processing *AudioProcessing) threshold() int {
+	if processing.SilenceThreshold < 0 {
+		return processing.SilenceThreshold
+	}
+
+	return AudioProcessingSilenceThreshold
+}
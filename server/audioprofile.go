@@ -0,0 +1,310 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AudioCodecAac  = "aac"
+	AudioCodecMp3  = "mp3"
+	AudioCodecOpus = "opus"
+
+	AudioContainerMp3  = "mp3"
+	AudioContainerMp4  = "mp4"
+	AudioContainerOgg  = "ogg"
+	AudioContainerWebm = "webm"
+
+	AudioProfileDefault = "aac-32k"
+)
+
+type AudioProfile struct {
+	Bitrate    uint   `json:"bitrate"`
+	Codec      string `json:"codec"`
+	Container  string `json:"container"`
+	Filters    string `json:"filters,omitempty"`
+	Mono       bool   `json:"mono"`
+	Name       string `json:"name"`
+	SampleRate uint   `json:"sampleRate,omitempty"`
+}
+
+var AudioProfilesBuiltin = []*AudioProfile{
+	{Name: "aac-32k", Codec: AudioCodecAac, Container: AudioContainerMp4, Bitrate: 32},
+	{Name: "aac-64k", Codec: AudioCodecAac, Container: AudioContainerMp4, Bitrate: 64},
+	{Name: "mp3-32k", Codec: AudioCodecMp3, Container: AudioContainerMp3, Bitrate: 32, Mono: true, SampleRate: 22050},
+	{Name: "opus-16k", Codec: AudioCodecOpus, Container: AudioContainerOgg, Bitrate: 16, Mono: true},
+	{Name: "opus-16k-webm", Codec: AudioCodecOpus, Container: AudioContainerWebm, Bitrate: 16, Mono: true},
+	{Name: "opus-24k", Codec: AudioCodecOpus, Container: AudioContainerOgg, Bitrate: 24, Mono: true},
+}
+
+func NewAudioProfile() *AudioProfile {
+	return &AudioProfile{}
+}
+
+func (profile *AudioProfile) FromMap(m map[string]any) *AudioProfile {
+	switch v := m["bitrate"].(type) {
+	case float64:
+		profile.Bitrate = uint(v)
+	}
+
+	switch v := m["codec"].(type) {
+	case string:
+		profile.Codec = strings.ToLower(v)
+	}
+
+	switch v := m["container"].(type) {
+	case string:
+		profile.Container = strings.ToLower(v)
+	}
+
+	switch v := m["filters"].(type) {
+	case string:
+		profile.Filters = v
+	}
+
+	switch v := m["mono"].(type) {
+	case bool:
+		profile.Mono = v
+	}
+
+	switch v := m["name"].(type) {
+	case string:
+		profile.Name = strings.TrimSpace(v)
+	}
+
+	switch v := m["sampleRate"].(type) {
+	case float64:
+		profile.SampleRate = uint(v)
+	}
+
+	return profile
+}
+
+// Args returns the ffmpeg output arguments, filters being the normalization
+// filters that must run before the profile ones.
+func (profile *AudioProfile) Args(filters []string) []string {
+	args := []string{}
+
+	if chain := profile.Chain(filters); len(chain) > 0 {
+		args = append(args, "-af", strings.Join(chain, ","))
+	}
+
+	return append(args, profile.EncoderArgs()...)
+}
+
+// Chain returns the filters followed by the ones of the profile.
+func (profile *AudioProfile) Chain(filters []string) []string {
+	chain := append([]string{}, filters...)
+
+	if len(profile.Filters) > 0 {
+		chain = append(chain, profile.Filters)
+	}
+
+	return chain
+}
+
+// EncoderArgs returns the ffmpeg arguments encoding an output with the
+// profile to the standard output.
+func (profile *AudioProfile) EncoderArgs() []string {
+	args := []string{}
+
+	if profile.Mono {
+		args = append(args, "-ac", "1")
+	}
+
+	if profile.SampleRate > 0 {
+		args = append(args, "-ar", fmt.Sprintf("%d", profile.SampleRate))
+	}
+
+	switch profile.Codec {
+	case AudioCodecMp3:
+		args = append(args, "-c:a", "libmp3lame")
+	case AudioCodecOpus:
+		args = append(args, "-c:a", "libopus", "-application", "voip")
+	default:
+		args = append(args, "-c:a", "aac")
+	}
+
+	if profile.Bitrate > 0 {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", profile.Bitrate))
+	}
+
+	switch profile.Container {
+	case AudioContainerMp3:
+		args = append(args, "-f", "mp3")
+	case AudioContainerOgg:
+		args = append(args, "-f", "ogg")
+	case AudioContainerWebm:
+		args = append(args, "-f", "webm")
+	default:
+		args = append(args, "-movflags", "frag_keyframe+empty_moov", "-f", "ipod")
+	}
+
+	return append(args, "-")
+}
+
+func (profile *AudioProfile) Extension() string {
+	switch profile.Container {
+	case AudioContainerMp3:
+		return "mp3"
+	case AudioContainerOgg:
+		if profile.Codec == AudioCodecOpus {
+			return "opus"
+		}
+		return "ogg"
+	case AudioContainerWebm:
+		return "webm"
+	default:
+		return "m4a"
+	}
+}
+
+func (profile *AudioProfile) IsValid() error {
+	if len(profile.Name) == 0 {
+		return errors.New("audio profile has no name")
+	}
+
+	switch profile.Codec {
+	case AudioCodecAac:
+		if profile.Container != AudioContainerMp4 {
+			return fmt.Errorf("audio profile %s: codec %s requires the %s container", profile.Name, profile.Codec, AudioContainerMp4)
+		}
+	case AudioCodecMp3:
+		if profile.Container != AudioContainerMp3 {
+			return fmt.Errorf("audio profile %s: codec %s requires the %s container", profile.Name, profile.Codec, AudioContainerMp3)
+		}
+	case AudioCodecOpus:
+		if profile.Container != AudioContainerOgg && profile.Container != AudioContainerWebm {
+			return fmt.Errorf("audio profile %s: codec %s requires the %s or %s container", profile.Name, profile.Codec, AudioContainerOgg, AudioContainerWebm)
+		}
+		switch profile.SampleRate {
+		case 0, 8000, 12000, 16000, 24000, 48000:
+		default:
+			return fmt.Errorf("audio profile %s: unsupported sample rate %d for codec %s", profile.Name, profile.SampleRate, profile.Codec)
+		}
+	default:
+		return fmt.Errorf("audio profile %s: unknown codec %s", profile.Name, profile.Codec)
+	}
+
+	return nil
+}
+
+func (profile *AudioProfile) Mime() string {
+	switch profile.Container {
+	case AudioContainerMp3:
+		return "audio/mpeg"
+	case AudioContainerOgg:
+		return "audio/ogg"
+	case AudioContainerWebm:
+		return "audio/webm"
+	default:
+		return "audio/mp4"
+	}
+}
+
+type AudioProfiles struct {
+	List []*AudioProfile
+}
+
+func NewAudioProfiles() *AudioProfiles {
+	return &AudioProfiles{List: []*AudioProfile{}}
+}
+
+func (profiles *AudioProfiles) FromMap(f []any) *AudioProfiles {
+	profiles.List = []*AudioProfile{}
+
+	for _, v := range f {
+		switch m := v.(type) {
+		case map[string]any:
+			profile := NewAudioProfile().FromMap(m)
+
+			if _, ok := profiles.getBuiltin(profile.Name); ok {
+				continue
+			}
+
+			if profile.IsValid() == nil {
+				profiles.List = append(profiles.List, profile)
+			}
+		}
+	}
+
+	return profiles
+}
+
+// GetProfile looks up a profile by name, custom profiles first then built-in
+// ones.
+func (profiles *AudioProfiles) GetProfile(name string) (*AudioProfile, bool) {
+	for _, profile := range profiles.List {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+
+	return profiles.getBuiltin(name)
+}
+
+// Resolve returns the profile of the talkgroup, falling back to the one of
+// its system, then to the global one.
+func (profiles *AudioProfiles) Resolve(call *Call, global string) *AudioProfile {
+	names := []string{}
+
+	if call.Talkgroup != nil {
+		names = append(names, call.Talkgroup.AudioProfile)
+	}
+
+	if call.System != nil {
+		names = append(names, call.System.AudioProfile)
+	}
+
+	names = append(names, global)
+
+	for _, name := range names {
+		if len(name) == 0 {
+			continue
+		}
+
+		if profile, ok := profiles.GetProfile(name); ok {
+			return profile
+		}
+	}
+
+	profile, _ := profiles.getBuiltin(AudioProfileDefault)
+
+	return profile
+}
+
+func (profiles *AudioProfiles) MarshalJSON() ([]byte, error) {
+	return json.Marshal(profiles.List)
+}
+
+func (profiles *AudioProfiles) getBuiltin(name string) (*AudioProfile, bool) {
+	for _, profile := range AudioProfilesBuiltin {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+
+	return nil, false
+}
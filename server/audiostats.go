@@ -0,0 +1,307 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	AudioStatsFloor = -120
+
+	QualityGateActionFlag   = "flag"
+	QualityGateActionReject = "reject"
+
+	QualityFlagErrors = "errors"
+	QualityFlagLoud   = "loud"
+	QualityFlagQuiet  = "quiet"
+	QualityFlagShort  = "short"
+	QualityFlagSpikes = "spikes"
+)
+
+// AudioStats are measured on the audio as received, before any conversion.
+type AudioStats struct {
+	Duration   uint
+	PeakLevel  float32
+	RmsLevel   float32
+	SampleRate uint
+}
+
+var (
+	audioStatsPeakRegexp       = regexp.MustCompile(`Peak level dB: (-?[0-9.]+|-inf)`)
+	audioStatsRmsRegexp        = regexp.MustCompile(`RMS level dB: (-?[0-9.]+|-inf)`)
+	audioStatsSamplesRegexp    = regexp.MustCompile(`Number of samples: ([0-9]+)`)
+	audioStatsSampleRateRegexp = regexp.MustCompile(`Audio: [^,]+, ([0-9]+) Hz`)
+)
+
+// ParseAstats reads the overall section printed by the ffmpeg astats filter.
+func ParseAstats(stderr string) (*AudioStats, error) {
+	stats := &AudioStats{}
+
+	m := audioStatsSampleRateRegexp.FindStringSubmatch(stderr)
+	if len(m) != 2 {
+		return nil, errors.New("no sample rate")
+	}
+
+	if i, err := strconv.Atoi(m[1]); err == nil && i > 0 {
+		stats.SampleRate = uint(i)
+	} else {
+		return nil, errors.New("invalid sample rate")
+	}
+
+	i := strings.LastIndex(stderr, "Overall")
+	if i < 0 {
+		return nil, errors.New("no overall statistics")
+	}
+
+	overall := stderr[i:]
+
+	if m := audioStatsPeakRegexp.FindStringSubmatch(overall); len(m) == 2 {
+		stats.PeakLevel = parseDecibels(m[1])
+	}
+
+	if m := audioStatsRmsRegexp.FindStringSubmatch(overall); len(m) == 2 {
+		stats.RmsLevel = parseDecibels(m[1])
+	}
+
+	if m := audioStatsSamplesRegexp.FindStringSubmatch(overall); len(m) == 2 {
+		if n, err := strconv.ParseUint(m[1], 10, 64); err == nil {
+			stats.Duration = uint(n * 1000 / uint64(stats.SampleRate))
+		}
+	}
+
+	return stats, nil
+}
+
+// ParseWav measures uncompressed wave files without ffmpeg.
+func ParseWav(audio []byte) (*AudioStats, error) {
+	samples, channels, rate, err := DecodeWav(audio)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		peak float64
+		sum  float64
+	)
+
+	for _, v := range samples {
+		peak = math.Max(peak, math.Abs(v))
+		sum += v * v
+	}
+
+	return &AudioStats{
+		Duration:   uint(uint64(len(samples)) / uint64(channels) * 1000 / uint64(rate)),
+		PeakLevel:  toDecibels(peak),
+		RmsLevel:   toDecibels(math.Sqrt(sum / float64(len(samples)))),
+		SampleRate: rate,
+	}, nil
+}
+
+// DecodeWav returns the interleaved samples of an uncompressed wave file,
+// scaled to the -1 to 1 range.
+func DecodeWav(audio []byte) ([]float64, uint, uint, error) {
+	var (
+		bits     uint16
+		channels uint16
+		data     []byte
+		format   uint16
+		rate     uint32
+	)
+
+	if len(audio) < 12 || !bytes.Equal(audio[0:4], []byte("RIFF")) || !bytes.Equal(audio[8:12], []byte("WAVE")) {
+		return nil, 0, 0, errors.New("not a wave file")
+	}
+
+	for i := 12; i+8 <= len(audio); {
+		id := string(audio[i : i+4])
+		size := int(binary.LittleEndian.Uint32(audio[i+4 : i+8]))
+		start := i + 8
+		end := min(start+size, len(audio))
+
+		switch id {
+		case "fmt ":
+			if end-start < 16 {
+				return nil, 0, 0, errors.New("invalid fmt chunk")
+			}
+			format = binary.LittleEndian.Uint16(audio[start:])
+			channels = binary.LittleEndian.Uint16(audio[start+2:])
+			rate = binary.LittleEndian.Uint32(audio[start+4:])
+			bits = binary.LittleEndian.Uint16(audio[start+14:])
+			if format == 0xfffe && end-start >= 26 {
+				format = binary.LittleEndian.Uint16(audio[start+24:])
+			}
+		case "data":
+			data = audio[start:end]
+		}
+
+		i = start + size + size%2
+	}
+
+	if channels == 0 || rate == 0 || data == nil {
+		return nil, 0, 0, errors.New("incomplete wave file")
+	}
+
+	width := int(bits / 8)
+
+	sample := func(b []byte) (float64, bool) {
+		switch {
+		case format == 1 && width == 1:
+			return (float64(b[0]) - 128) / 128, true
+		case format == 1 && width == 2:
+			return float64(int16(binary.LittleEndian.Uint16(b))) / 32768, true
+		case format == 1 && width == 3:
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / 8388608, true
+		case format == 1 && width == 4:
+			return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648, true
+		case format == 3 && width == 4:
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), true
+		}
+		return 0, false
+	}
+
+	samples := make([]float64, 0, max(len(data)/max(width, 1), 0))
+
+	for i := 0; i+width <= len(data) && width > 0; i += width {
+		v, ok := sample(data[i : i+width])
+		if !ok {
+			return nil, 0, 0, errors.New("unsupported wave encoding")
+		}
+
+		samples = append(samples, v)
+	}
+
+	if len(samples) == 0 {
+		return nil, 0, 0, errors.New("no samples")
+	}
+
+	return samples, uint(channels), uint(rate), nil
+}
+
+type QualityGates struct {
+	Action      string `json:"action"`
+	MaxErrors   uint   `json:"maxErrors"`
+	MaxRmsLevel int    `json:"maxRmsLevel"`
+	MaxSpikes   uint   `json:"maxSpikes"`
+	MinDuration uint   `json:"minDuration"`
+	MinRmsLevel int    `json:"minRmsLevel"`
+}
+
+func NewQualityGates() *QualityGates {
+	return &QualityGates{Action: QualityGateActionFlag}
+}
+
+func (gates *QualityGates) FromMap(m map[string]any) *QualityGates {
+	switch v := m["action"].(type) {
+	case string:
+		if v == QualityGateActionReject {
+			gates.Action = v
+		} else {
+			gates.Action = QualityGateActionFlag
+		}
+	}
+
+	switch v := m["maxErrors"].(type) {
+	case float64:
+		gates.MaxErrors = uint(v)
+	}
+
+	switch v := m["maxRmsLevel"].(type) {
+	case float64:
+		gates.MaxRmsLevel = int(v)
+	}
+
+	switch v := m["maxSpikes"].(type) {
+	case float64:
+		gates.MaxSpikes = uint(v)
+	}
+
+	switch v := m["minDuration"].(type) {
+	case float64:
+		gates.MinDuration = uint(v)
+	}
+
+	switch v := m["minRmsLevel"].(type) {
+	case float64:
+		gates.MinRmsLevel = int(v)
+	}
+
+	return gates
+}
+
+// Check returns the gates the call fails. Levels are in dBFS, a zero value
+// disabling the gate.
+func (gates *QualityGates) Check(call *Call) []string {
+	var errorCount, spikeCount uint
+
+	flags := []string{}
+
+	if gates.MinDuration > 0 && call.Duration > 0 && call.Duration < gates.MinDuration {
+		flags = append(flags, QualityFlagShort)
+	}
+
+	if call.SampleRate > 0 {
+		if gates.MinRmsLevel < 0 && call.RmsLevel < float32(gates.MinRmsLevel) {
+			flags = append(flags, QualityFlagQuiet)
+		}
+
+		if gates.MaxRmsLevel < 0 && call.RmsLevel > float32(gates.MaxRmsLevel) {
+			flags = append(flags, QualityFlagLoud)
+		}
+	}
+
+	for _, f := range call.Frequencies {
+		errorCount += f.Errors
+		spikeCount += f.Spikes
+	}
+
+	if gates.MaxErrors > 0 && errorCount > gates.MaxErrors {
+		flags = append(flags, QualityFlagErrors)
+	}
+
+	if gates.MaxSpikes > 0 && spikeCount > gates.MaxSpikes {
+		flags = append(flags, QualityFlagSpikes)
+	}
+
+	return flags
+}
+
+func parseDecibels(s string) float32 {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return float32(math.Max(f, AudioStatsFloor))
+	}
+
+	return AudioStatsFloor
+}
+
+func toDecibels(v float64) float32 {
+	if v <= 0 {
+		return AudioStatsFloor
+	}
+
+	return float32(math.Max(20*math.Log10(v), AudioStatsFloor))
+}
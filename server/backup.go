@@ -0,0 +1,742 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+const (
+	BackupDir        = "backups"
+	BackupExtDump    = ".json.gz"
+	BackupExtSqlite  = ".db"
+	BackupPrefix     = "rdio-scanner-"
+	BackupTimeFormat = "20060102-150405"
+)
+
+// BackupAudioColumns are the audio blobs left empty when backing up without
+// audio.
+var BackupAudioColumns = map[string]string{
+	"calls":            "audio",
+	"quarantinedCalls": "audio",
+}
+
+type BackupFile struct {
+	Name      string
+	Size      int64
+	Timestamp time.Time
+}
+
+func (file *BackupFile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"dateTime": file.Timestamp.Format(time.RFC3339),
+		"name":     file.Name,
+		"size":     file.Size,
+	})
+}
+
+// backupHeader is the first line of a logical dump.
+type backupHeader struct {
+	Audio   bool   `json:"audio"`
+	DbType  string `json:"dbType"`
+	Version string `json:"version"`
+}
+
+// backupTable precedes the rows of a table in a logical dump, each row being
+// a json array of the column values.
+type backupTable struct {
+	Blobs   []string `json:"blobs"`
+	Columns []string `json:"columns"`
+	Table   string   `json:"table"`
+}
+
+// Backups produces consistent snapshots of the database while the server is
+// running: an online copy with the SQLite backup API, or a logical dump read
+// from a single repeatable read transaction for MySQL/MariaDB and
+// PostgreSQL.
+type Backups struct {
+	controller *Controller
+	database   *Database
+	mutex      sync.Mutex
+}
+
+func NewBackups(controller *Controller, database *Database) *Backups {
+	return &Backups{
+		controller: controller,
+		database:   database,
+		mutex:      sync.Mutex{},
+	}
+}
+
+// Create writes a new backup in the backups folder and removes the oldest
+// ones beyond the number to keep.
+func (backups *Backups) Create(audio bool, keep uint) (*BackupFile, error) {
+	var (
+		db  = backups.database
+		err error
+	)
+
+	backups.mutex.Lock()
+	defer backups.mutex.Unlock()
+
+	formatError := errorFormatter("backups", "create")
+
+	dir := db.Config.GetPath(BackupDir)
+	if err = os.MkdirAll(dir, 0770); err != nil {
+		return nil, formatError(err, "")
+	}
+
+	name := BackupPrefix + time.Now().Format(BackupTimeFormat)
+
+	switch db.Config.DbType {
+	case DbTypeSqlite:
+		name += BackupExtSqlite
+	default:
+		name += BackupExtDump
+	}
+
+	filename := filepath.Join(dir, name)
+	tmp := filename + ".tmp"
+
+	switch db.Config.DbType {
+	case DbTypeSqlite:
+		err = backupSqlite(db, tmp, audio)
+	default:
+		err = backupDump(db, tmp, audio)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return nil, formatError(err, "")
+	}
+
+	if err = os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return nil, formatError(err, "")
+	}
+
+	if err = backups.rotate(keep); err != nil {
+		return nil, formatError(err, "")
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	if backups.controller != nil {
+		backups.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("backup %s created", name))
+	}
+
+	return &BackupFile{Name: name, Size: info.Size(), Timestamp: info.ModTime()}, nil
+}
+
+// GetPath returns the path of a backup of the backups folder.
+func (backups *Backups) GetPath(name string) (string, bool) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, BackupPrefix) {
+		return "", false
+	}
+
+	filename := filepath.Join(backups.database.Config.GetPath(BackupDir), name)
+
+	if info, err := os.Stat(filename); err != nil || info.IsDir() {
+		return "", false
+	}
+
+	return filename, true
+}
+
+// List returns the backups of the backups folder, newest first.
+func (backups *Backups) List() ([]*BackupFile, error) {
+	files := []*BackupFile{}
+
+	entries, err := os.ReadDir(backups.database.Config.GetPath(BackupDir))
+	if os.IsNotExist(err) {
+		return files, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("backups.list: %v", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if entry.IsDir() || !strings.HasPrefix(name, BackupPrefix) || !(strings.HasSuffix(name, BackupExtSqlite) || strings.HasSuffix(name, BackupExtDump)) {
+			continue
+		}
+
+		if info, err := entry.Info(); err == nil {
+			files = append(files, &BackupFile{Name: name, Size: info.Size(), Timestamp: info.ModTime()})
+		}
+	}
+
+	sort.Slice(files, func(i int, j int) bool {
+		return files[i].Name > files[j].Name
+	})
+
+	return files, nil
+}
+
+// Restore replaces the content of the database with a backup. It must only be
+// used while the server is stopped. A dump taken with an older schema gets its
+// pending migrations applied on the next start.
+func (backups *Backups) Restore(filename string) error {
+	db := backups.database
+
+	backups.mutex.Lock()
+	defer backups.mutex.Unlock()
+
+	formatError := errorFormatter("backups", "restore")
+
+	if _, err := os.Stat(filename); err != nil {
+		return formatError(err, "")
+	}
+
+	switch {
+	case strings.HasSuffix(filename, BackupExtSqlite):
+		if db.Config.DbType != DbTypeSqlite {
+			return formatError(fmt.Errorf("%s is a sqlite backup, cannot restore to %s", filepath.Base(filename), db.Config.DbType), "")
+		}
+
+		if err := restoreSqlite(db, filename); err != nil {
+			return formatError(err, "")
+		}
+
+	case strings.HasSuffix(filename, BackupExtDump):
+		if err := restoreDump(db, filename); err != nil {
+			return formatError(err, "")
+		}
+
+	default:
+		return formatError(errors.New("unknown backup format"), "")
+	}
+
+	return nil
+}
+
+func (backups *Backups) rotate(keep uint) error {
+	if keep == 0 {
+		return nil
+	}
+
+	files, err := backups.List()
+	if err != nil {
+		return err
+	}
+
+	for i := int(keep); i < len(files); i++ {
+		if err = os.Remove(filepath.Join(backups.database.Config.GetPath(BackupDir), files[i].Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func backupDump(db *Database, filename string, audio bool) error {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+		tx    *sql.Tx
+	)
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	w := bufio.NewWriter(gz)
+	enc := json.NewEncoder(w)
+
+	if tx, err = db.Sql.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}); err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = enc.Encode(backupHeader{Audio: audio, DbType: db.Config.DbType, Version: Version}); err != nil {
+		return err
+	}
+
+	// the migrations records go along so that the restored rows are
+	// migrated from the schema they were dumped with
+	for _, table := range append(schemaTables(db.Config.DbType), "schemaMigrations") {
+		query = fmt.Sprintf(`SELECT * FROM "%s"`, table)
+		if rows, err = tx.Query(query); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+
+		reader := newTableRows(rows)
+
+		header := backupTable{Blobs: []string{}, Columns: reader.Columns, Table: table}
+		for i, column := range reader.Columns {
+			if reader.Blobs[i] {
+				header.Blobs = append(header.Blobs, column)
+			}
+		}
+
+		if err = enc.Encode(header); err != nil {
+			rows.Close()
+			return err
+		}
+
+		for {
+			var ok bool
+
+			if ok, err = reader.Next(); err != nil || !ok {
+				break
+			}
+
+			if !audio {
+				for i, column := range reader.Columns {
+					if BackupAudioColumns[table] == column {
+						reader.Values[i] = []byte{}
+					}
+				}
+			}
+
+			if err = enc.Encode(reader.Values); err != nil {
+				break
+			}
+		}
+
+		rows.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if err = gz.Close(); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func backupSqlite(db *Database, filename string, audio bool) error {
+	conn, err := db.Sql.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(interface {
+			NewBackup(string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("sqlite online backup not supported")
+		}
+
+		backup, err := c.NewBackup(filename)
+		if err != nil {
+			return err
+		}
+
+		if _, err = backup.Step(-1); err != nil {
+			backup.Finish()
+			return err
+		}
+
+		return backup.Finish()
+	})
+	if err != nil || audio {
+		return err
+	}
+
+	dst, err := sql.Open("sqlite", fmt.Sprintf("file:%s", filename))
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	for table, column := range BackupAudioColumns {
+		query := fmt.Sprintf(`UPDATE "%s" SET "%s" = x''`, table, column)
+		if _, err = dst.Exec(query); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	_, err = dst.Exec("VACUUM")
+
+	return err
+}
+
+func restoreDump(db *Database, filename string) error {
+	var (
+		err    error
+		header backupHeader
+		table  *backupTable
+		tables = []*backupTable{}
+		tx     *StorageTx
+	)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(gz)
+	dec.UseNumber()
+
+	if err = dec.Decode(&header); err != nil {
+		return err
+	}
+
+	if dbFamily(header.DbType) != dbFamily(db.Config.DbType) {
+		return fmt.Errorf("%s is a %s backup, cannot restore to %s", filepath.Base(filename), header.DbType, db.Config.DbType)
+	}
+
+	if tx, err = db.begin(false); err != nil {
+		return err
+	}
+
+	names := schemaTables(db.Config.DbType)
+	slices.Reverse(names)
+
+	for _, name := range names {
+		query := fmt.Sprintf(`DELETE FROM "%s"`, name)
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	var (
+		callId     int
+		migrations map[uint]*schemaMigrationRecord
+		query      string
+	)
+
+	for {
+		var raw json.RawMessage
+
+		if err = dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if len(raw) > 0 && raw[0] == '{' {
+			table = &backupTable{}
+			if err = json.Unmarshal(raw, table); err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			tables = append(tables, table)
+
+			// dumps without the migrations records keep the ones of the
+			// database
+			if table.Table == "schemaMigrations" {
+				migrations = map[uint]*schemaMigrationRecord{}
+
+				query = `DELETE FROM "schemaMigrations"`
+				if _, err = tx.Exec(query); err != nil {
+					tx.Rollback()
+					return fmt.Errorf("%s in %s", err, query)
+				}
+			}
+
+			columns := make([]string, len(table.Columns))
+			placeholders := make([]string, len(table.Columns))
+			for i, column := range table.Columns {
+				columns[i] = fmt.Sprintf(`"%s"`, column)
+				placeholders[i] = "?"
+			}
+
+			if columns, placeholders, callId, err = partitionTimestamp(tx, table.Table, columns, placeholders); err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			query = fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table.Table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+			continue
+		}
+
+		if table == nil {
+			tx.Rollback()
+			return errors.New("row without table")
+		}
+
+		values := []any{}
+
+		d := json.NewDecoder(strings.NewReader(string(raw)))
+		d.UseNumber()
+		if err = d.Decode(&values); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		for i, v := range values {
+			switch v := v.(type) {
+			case json.Number:
+				if n, err := v.Int64(); err == nil {
+					values[i] = n
+				} else if n, err := v.Float64(); err == nil {
+					values[i] = n
+				}
+			case string:
+				if i < len(table.Columns) && slices.Contains(table.Blobs, table.Columns[i]) {
+					if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+						values[i] = b
+					}
+				}
+			}
+		}
+
+		if table.Table == "schemaMigrations" {
+			version, record := restoreMigration(table.Columns, values)
+			migrations[version] = record
+		}
+
+		if callId >= 0 && callId < len(values) {
+			values = append(values, values[callId])
+		}
+
+		if _, err = tx.Exec(query, values...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	if migrations != nil {
+		if err = NewSchemaMigrator(db).verify(migrations); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if db.Config.DbType == DbTypePostgresql {
+		for _, table := range tables {
+			if len(table.Columns) == 0 {
+				continue
+			}
+
+			if err = resetSequence(tx, table.Table, table.Columns[0]); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// restoreMigration reads a row of the migrations records of a dump.
+func restoreMigration(columns []string, values []any) (uint, *schemaMigrationRecord) {
+	var (
+		record  = &schemaMigrationRecord{}
+		version uint
+	)
+
+	for i, column := range columns {
+		if i >= len(values) {
+			break
+		}
+
+		switch v := values[i].(type) {
+		case int64:
+			switch column {
+			case "timestamp":
+				record.timestamp = v
+			case "version":
+				version = uint(v)
+			}
+
+		case string:
+			switch column {
+			case "checksum":
+				record.checksum = v
+			case "name":
+				record.name = v
+			}
+		}
+	}
+
+	return version, record
+}
+
+// resetSequence moves the PostgreSQL sequence of a primary key past the ids
+// inserted explicitly.
+func resetSequence(tx *StorageTx, table string, pk string) error {
+	query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence(?, ?), COALESCE(MAX("%s"), 0) + 1, false) FROM "%s"`, pk, table)
+	if _, err := tx.Exec(query, fmt.Sprintf(`"%s"`, table), pk); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	return nil
+}
+
+func restoreSqlite(db *Database, filename string) error {
+	conn, err := db.Sql.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(interface {
+			NewRestore(string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("sqlite online restore not supported")
+		}
+
+		backup, err := c.NewRestore(filename)
+		if err != nil {
+			return err
+		}
+
+		if _, err = backup.Step(-1); err != nil {
+			backup.Finish()
+			return err
+		}
+
+		return backup.Finish()
+	})
+}
+
+// tableRows scans the rows of a table into values normalized across the
+// database drivers: blobs as bytes, numbers as int64 or float64 and text as
+// strings.
+type tableRows struct {
+	Blobs    []bool
+	Bools    []bool
+	Columns  []string
+	Values   []any
+	numbers  []bool
+	pointers []any
+	rows     *sql.Rows
+}
+
+func newTableRows(rows *sql.Rows) *tableRows {
+	reader := &tableRows{rows: rows}
+
+	reader.Columns, _ = rows.Columns()
+	types, _ := rows.ColumnTypes()
+
+	reader.Blobs, reader.Bools, reader.numbers = columnKinds(types)
+
+	reader.Values = make([]any, len(reader.Columns))
+	reader.pointers = make([]any, len(reader.Columns))
+	for i := range reader.Values {
+		reader.pointers[i] = &reader.Values[i]
+	}
+
+	return reader
+}
+
+func (reader *tableRows) Next() (bool, error) {
+	if !reader.rows.Next() {
+		return false, reader.rows.Err()
+	}
+
+	if err := reader.rows.Scan(reader.pointers...); err != nil {
+		return false, err
+	}
+
+	for i, v := range reader.Values {
+		b, ok := v.([]byte)
+
+		switch {
+		case !ok || reader.Blobs[i]:
+		case reader.numbers[i]:
+			s := string(b)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				reader.Values[i] = n
+			} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+				reader.Values[i] = f
+			} else {
+				reader.Values[i] = s
+			}
+		default:
+			reader.Values[i] = string(b)
+		}
+	}
+
+	return true, nil
+}
+
+// columnKinds tells which columns hold blobs, booleans and numbers.
+func columnKinds(types []*sql.ColumnType) ([]bool, []bool, []bool) {
+	blobs := make([]bool, len(types))
+	bools := make([]bool, len(types))
+	numbers := make([]bool, len(types))
+
+	for i, t := range types {
+		name := strings.ToUpper(t.DatabaseTypeName())
+
+		switch {
+		case strings.Contains(name, "BLOB"), strings.Contains(name, "BYTEA"), strings.Contains(name, "BINARY"):
+			blobs[i] = true
+		case strings.Contains(name, "BOOL"):
+			bools[i] = true
+		case strings.Contains(name, "INT"), strings.Contains(name, "DECIMAL"), strings.Contains(name, "NUMERIC"), strings.Contains(name, "DOUBLE"), strings.Contains(name, "FLOAT"), strings.Contains(name, "REAL"):
+			numbers[i] = true
+		}
+	}
+
+	return blobs, bools, numbers
+}
+
+// dbFamily groups the database types sharing the same dialect.
+func dbFamily(dbType string) string {
+	switch dbType {
+	case DbTypeMariadb, DbTypeMysql:
+		return DbTypeMysql
+	}
+
+	return dbType
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupsRestore(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		audio bool
+		dump  bool
+	}{
+		{name: "sqlite", audio: true},
+		{name: "sqlite without audio"},
+		{name: "dump", audio: true, dump: true},
+		{name: "dump without audio", dump: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewController(&Config{BaseDir: t.TempDir(), DbFile: "rdio-scanner.db", DbType: DbTypeSqlite})
+			defer controller.Database.Close()
+
+			db := controller.Database
+			audio := []byte("audio")
+
+			exec := func(query string, args ...any) {
+				if _, err := db.Exec(query, args...); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			exec(`INSERT INTO "tags" ("tagId", "label") VALUES (100, 'Fire')`)
+			exec(`INSERT INTO "systems" ("systemId", "label", "systemRef") VALUES (100, 'County''s', 1)`)
+			exec(`INSERT INTO "talkgroups" ("talkgroupId", "label", "name", "systemId", "tagId", "talkgroupRef") VALUES (100, 'Dispatch', 'Fire Dispatch', 100, 100, 1)`)
+			exec(`INSERT INTO "calls" ("callId", "audio", "audioFilename", "audioMime", "systemId", "talkgroupId", "timestamp") VALUES (100, ?, 'call.wav', 'audio/wav', 100, 100, 1)`, audio)
+
+			backups := controller.Backups
+			if tc.dump {
+				// Create takes online snapshots of sqlite, the dumps being
+				// the backups of the other databases
+				dir := db.(*Database).Config.GetPath(BackupDir)
+				if err := os.MkdirAll(dir, 0770); err != nil {
+					t.Fatal(err)
+				}
+				if err := backupDump(db.(*Database), filepath.Join(dir, BackupPrefix+"dump"+BackupExtDump), tc.audio); err != nil {
+					t.Fatal(err)
+				}
+			} else if _, err := backups.Create(tc.audio, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := backups.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("%d backups listed, want 1", len(files))
+			}
+
+			exec(`DELETE FROM "calls"`)
+			exec(`UPDATE "systems" SET "label" = 'changed'`)
+
+			filename, ok := backups.GetPath(files[0].Name)
+			if !ok {
+				t.Fatalf("backup %s not found", files[0].Name)
+			}
+
+			if err := backups.Restore(filename); err != nil {
+				t.Fatal(err)
+			}
+
+			var label string
+			if err := db.QueryRow(`SELECT "label" FROM "systems" WHERE "systemId" = 100`).Scan(&label); err != nil {
+				t.Fatal(err)
+			}
+			if label != "County's" {
+				t.Errorf("system label is %q, want %q", label, "County's")
+			}
+
+			var restored []byte
+			if err := db.QueryRow(`SELECT "audio" FROM "calls" WHERE "callId" = 100`).Scan(&restored); err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool][]byte{true: audio, false: {}}[tc.audio]; !bytes.Equal(restored, want) {
+				t.Errorf("call audio is %q, want %q", restored, want)
+			}
+
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM "schemaMigrations"`).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count == 0 {
+				t.Error("schema migrations records not restored")
+			}
+		})
+	}
+}
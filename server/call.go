@@ -22,6 +22,7 @@ package main
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -67,19 +68,38 @@ type CallUnit struct {
 }
 
 type Call struct {
-	Id            uint64
-	Audio         []byte
-	AudioFilename string
-	AudioMime     string
-	Delayed       bool
-	Frequencies   []CallFrequency
-	Meta          CallMeta
-	Patches       []uint
-	SiteRef       uint
-	System        *System
-	Talkgroup     *Talkgroup
-	Timestamp     time.Time
-	Units         []CallUnit
+	Id             uint64
+	Audio          []byte
+	AudioFilename  string
+	AudioMime      string
+	AudioProfile   string
+	ConversationId uint64
+	Delayed        bool
+	Duration       uint
+	Flags          []string
+	Fingerprint    Fingerprint
+	Frequencies    []CallFrequency
+	LinkedCallId   uint64
+	Meta           CallMeta
+	Patches        []uint
+	PeakLevel      float32
+	Peaks          Peaks
+	RmsLevel       float32
+	SampleRate     uint
+	SiteRef        uint
+	Simulcast      *SimulcastDecision
+	Source         string
+	System         *System
+	Talkgroup      *Talkgroup
+	Timestamp      time.Time
+	Trimmed        float32
+	Units          []CallUnit
+	discovered     bool
+	ingested       func(err error)
+	populate       bool
+	quarantineId   uint64
+	replace        bool
+	resolved       bool
 }
 
 func NewCall() *Call {
@@ -90,6 +110,7 @@ func NewCall() *Call {
 			UnitLabels:      []string{},
 			UnitRefs:        []uint{},
 		},
+		Flags:   []string{},
 		Patches: []uint{},
 		Units:   []CallUnit{},
 	}
@@ -134,6 +155,22 @@ func (call *Call) MarshalJSON() ([]byte, error) {
 		"patches":   call.Patches,
 	}
 
+	if len(call.AudioProfile) > 0 {
+		callMap["audioProfile"] = call.AudioProfile
+	}
+
+	if call.ConversationId > 0 {
+		callMap["conversation"] = call.ConversationId
+	}
+
+	if call.Duration > 0 {
+		callMap["duration"] = call.Duration
+	}
+
+	if len(call.Flags) > 0 {
+		callMap["flags"] = call.Flags
+	}
+
 	if len(call.Frequencies) > 0 {
 		freqs := []map[string]any{}
 		for _, f := range call.Frequencies {
@@ -154,6 +191,18 @@ func (call *Call) MarshalJSON() ([]byte, error) {
 		callMap["frequencies"] = freqs
 	}
 
+	if call.LinkedCallId > 0 {
+		callMap["linkedTo"] = call.LinkedCallId
+	}
+
+	if len(call.Peaks) > 0 {
+		callMap["peaks"] = call.Peaks
+	}
+
+	if call.Simulcast != nil {
+		callMap["simulcast"] = call.Simulcast
+	}
+
 	if call.SiteRef > 0 {
 		callMap["site"] = call.SiteRef
 	}
@@ -166,6 +215,10 @@ func (call *Call) MarshalJSON() ([]byte, error) {
 		callMap["talkgroup"] = call.Talkgroup.TalkgroupRef
 	}
 
+	if call.Trimmed > 0 {
+		callMap["trimmed"] = call.Trimmed
+	}
+
 	if len(call.Units) > 0 {
 		sources := []map[string]any{}
 		for _, unit := range call.Units {
@@ -180,6 +233,33 @@ func (call *Call) MarshalJSON() ([]byte, error) {
 	return json.Marshal(callMap)
 }
 
+func (call *Call) SetStats(stats *AudioStats) {
+	call.Duration = stats.Duration
+	call.PeakLevel = stats.PeakLevel
+	call.RmsLevel = stats.RmsLevel
+	call.SampleRate = stats.SampleRate
+}
+
+// Trim records the seconds of audio removed from the start of the call and
+// moves its timestamp and the unit and frequency offsets accordingly.
+func (call *Call) Trim(offset float32) {
+	if offset <= 0 {
+		return
+	}
+
+	call.Trimmed = offset
+
+	call.Timestamp = call.Timestamp.Add(time.Duration(float64(offset) * float64(time.Second)))
+
+	for i := range call.Frequencies {
+		call.Frequencies[i].Offset = max(call.Frequencies[i].Offset-offset, 0)
+	}
+
+	for i := range call.Units {
+		call.Units[i].Offset = max(call.Units[i].Offset-offset, 0)
+	}
+}
+
 func (call *Call) ToJson() (string, error) {
 	if b, err := json.Marshal(call); err == nil {
 		return string(b), nil
@@ -188,6 +268,9 @@ func (call *Call) ToJson() (string, error) {
 	}
 }
 
+// callsPruneExemption keeps the calls attached to an open incident.
+const callsPruneExemption = `"callId" NOT IN (SELECT ic."callId" FROM "incidentCalls" AS ic LEFT JOIN "incidents" AS i ON i."incidentId" = ic."incidentId" WHERE i."closed" = false)`
+
 type Calls struct {
 	controller *Controller
 	mutex      sync.Mutex
@@ -200,7 +283,7 @@ func NewCalls(controller *Controller) *Calls {
 	}
 }
 
-func (calls *Calls) CheckDuplicate(call *Call, msTimeFrame uint, db *Database) (bool, error) {
+func (calls *Calls) CheckDuplicate(call *Call, msTimeFrame uint, db Storage) (bool, error) {
 	var count uint64
 
 	calls.mutex.Lock()
@@ -212,8 +295,8 @@ func (calls *Calls) CheckDuplicate(call *Call, msTimeFrame uint, db *Database) (
 	from := call.Timestamp.Add(-d)
 	to := call.Timestamp.Add(d)
 
-	query := fmt.Sprintf(`SELECT COUNT(*) FROM "calls" WHERE ("timestamp" BETWEEN %d and %d) AND "systemId" = %d AND "talkgroupId" = %d`, from.UnixMilli(), to.UnixMilli(), call.System.Id, call.Talkgroup.Id)
-	if err := db.Sql.QueryRow(query).Scan(&count); err != nil {
+	query := `SELECT COUNT(*) FROM "calls" WHERE ("timestamp" BETWEEN ? AND ?) AND "systemId" = ? AND "talkgroupId" = ?`
+	if err := db.QueryRow(query, from.UnixMilli(), to.UnixMilli(), call.System.Id, call.Talkgroup.Id).Scan(&count); err != nil {
 		return false, formatError(err, query)
 	}
 
@@ -225,9 +308,12 @@ func (calls *Calls) GetCall(id uint64) (*Call, error) {
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
+		tx    *StorageTx
 
+		flags       string
 		patch       string
+		peaks       string
+		simulcast   string
 		systemId    uint64
 		talkgroupId uint64
 		timestamp   int64
@@ -238,26 +324,31 @@ func (calls *Calls) GetCall(id uint64) (*Call, error) {
 
 	formatError := errorFormatter("calls", "getcall")
 
-	if tx, err = calls.controller.Database.Sql.Begin(); err != nil {
+	db := calls.controller.Database
+
+	if tx, err = db.Begin(); err != nil {
 		return nil, formatError(err, "")
 	}
 
 	call := Call{Id: id}
 
-	if calls.controller.Database.Config.DbType == DbTypePostgresql {
-		query = fmt.Sprintf(`SELECT c."audio", c."audioFilename", c."audioMime", c."siteRef", c."timestamp", STRING_AGG(CAST(COALESCE(cpt."talkgroupRef", 0) AS text), ','), c."siteRef", sy."systemId", t."talkgroupId" FROM "calls" AS c LEFT JOIN "callPatches" AS cp on cp."callId" = c."callId" LEFT JOIN "talkgroups" AS cpt ON cpt."talkgroupId" = cp."talkgroupId" LEFT JOIN "systems" AS sy ON sy."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" WHERE c."callId" = %d GROUP BY c."callId"`, id)
-
-	} else {
-		query = fmt.Sprintf(`SELECT c."audio", c."audioFilename", c."audioMime", c."siteRef", c."timestamp", GROUP_CONCAT(COALESCE(cpt."talkgroupRef", 0)), c."siteRef", sy."systemId", t."talkgroupId" FROM "calls" AS c LEFT JOIN "callPatches" AS cp on cp."callId" = c."callId" LEFT JOIN "talkgroups" AS cpt ON cpt."talkgroupId" = cp."talkgroupId" LEFT JOIN "systems" AS sy ON sy."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" WHERE c."callId" = %d GROUP BY c."callId"`, id)
-	}
+	query = fmt.Sprintf(`SELECT c."audio", c."audioFilename", c."audioMime", c."audioProfile", c."conversationId", c."duration", c."flags", c."linkedCallId", c."peakLevel", c."peaks", c."rmsLevel", c."sampleRate", c."simulcast", c."timestamp", c."trimmed", COALESCE((SELECT %s FROM "callPatches" AS cp LEFT JOIN "talkgroups" AS cpt ON cpt."talkgroupId" = cp."talkgroupId" WHERE cp."callId" = c."callId"), ''), c."siteRef", sy."systemId", t."talkgroupId" FROM "calls" AS c LEFT JOIN "systems" AS sy ON sy."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" WHERE c."callId" = ?`, db.Dialect().StringAgg(`COALESCE(cpt."talkgroupRef", 0)`))
 
-	if err = tx.QueryRow(query).Scan(&call.Audio, &call.AudioFilename, &call.AudioMime, &patch, &timestamp, &patch, &call.SiteRef, &systemId, &talkgroupId); err != nil && err != sql.ErrNoRows {
+	if err = tx.QueryRow(query, id).Scan(&call.Audio, &call.AudioFilename, &call.AudioMime, &call.AudioProfile, &call.ConversationId, &call.Duration, &flags, &call.LinkedCallId, &call.PeakLevel, &peaks, &call.RmsLevel, &call.SampleRate, &simulcast, &timestamp, &call.Trimmed, &patch, &call.SiteRef, &systemId, &talkgroupId); err != nil && err != sql.ErrNoRows {
 		tx.Rollback()
 		return nil, formatError(err, query)
 	}
 
 	call.Timestamp = time.UnixMilli(timestamp)
 
+	if len(flags) > 0 {
+		call.Flags = strings.Split(flags, ",")
+	}
+
+	call.Peaks = ParsePeaks(peaks)
+
+	call.Simulcast = ParseSimulcastDecision(simulcast)
+
 	if len(patch) > 0 {
 		for _, s := range strings.Split(patch, ",") {
 			if i, err := strconv.Atoi(s); err == nil && i > 0 {
@@ -280,8 +371,14 @@ func (calls *Calls) GetCall(id uint64) (*Call, error) {
 		return nil, formatError(fmt.Errorf("cannot retrieve talkgroup id %d for call id %d", talkgroupId, call.Id), "")
 	}
 
-	query = fmt.Sprintf(`SELECT "dbm", "errors", "frequency", "offset", "spikes" FROM "callFrequencies" WHERE "callId" = %d`, id)
-	if rows, err = tx.Query(query); err != nil {
+	// the details of partitioned calls are read from the partition of the call
+	where, args := `"callId" = ?`, []any{id}
+	if calls.controller.Partitions.IsPartitioned() {
+		where, args = `"callId" = ? AND "timestamp" = ?`, []any{id, timestamp}
+	}
+
+	query = fmt.Sprintf(`SELECT "dbm", "errors", "frequency", "offset", "spikes" FROM "callFrequencies" WHERE %s`, where)
+	if rows, err = tx.Query(query, args...); err != nil {
 		tx.Rollback()
 		return nil, formatError(err, query)
 	}
@@ -303,8 +400,8 @@ func (calls *Calls) GetCall(id uint64) (*Call, error) {
 		return nil, formatError(err, query)
 	}
 
-	query = fmt.Sprintf(`SELECT "offset", "unitRef" FROM "callUnits" WHERE "callId" = %d`, id)
-	if rows, err = tx.Query(query); err != nil {
+	query = fmt.Sprintf(`SELECT "offset", "unitRef" FROM "callUnits" WHERE %s`, where)
+	if rows, err = tx.Query(query, args...); err != nil {
 		tx.Rollback()
 		return nil, formatError(err, query)
 	}
@@ -329,15 +426,151 @@ func (calls *Calls) GetCall(id uint64) (*Call, error) {
 	return &call, nil
 }
 
-func (calls *Calls) Prune(db *Database, pruneDays uint) error {
+// CountPrunable returns the number of calls matching the condition that
+// would be removed by Prune.
+func (calls *Calls) CountPrunable(db Storage, where string, args []any) (uint, error) {
+	var count uint
+
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM "calls" WHERE %s AND %s`, where, callsPruneExemption)
+	if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s in %s", err, query)
+	}
+
+	return count, nil
+}
+
+// Prune removes the calls matching the condition, except the ones attached to
+// an open incident, in batches so that the database is not locked for the
+// whole operation.
+func (calls *Calls) Prune(db Storage, where string, args []any, batchSize uint) (uint, error) {
+	var count uint
+
+	for {
+		n, err := calls.pruneBatch(db, where, args, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		count += n
+
+		if n < batchSize {
+			break
+		}
+	}
+
+	return count, nil
+}
+
+func (calls *Calls) pruneBatch(db Storage, where string, args []any, batchSize uint) (uint, error) {
+	var (
+		callIds = []uint64{}
+		err     error
+		query   string
+		rows    *sql.Rows
+	)
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	formatError := errorFormatter("calls", "prune")
+
+	query = fmt.Sprintf(`SELECT "callId" FROM "calls" WHERE %s AND %s LIMIT ?`, where, callsPruneExemption)
+	if rows, err = db.Query(query, append(append([]any{}, args...), batchSize)...); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var callId uint64
+		if err = rows.Scan(&callId); err != nil {
+			break
+		}
+		callIds = append(callIds, callId)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return 0, formatError(err, "")
+	}
+
+	if len(callIds) == 0 {
+		return 0, nil
+	}
+
+	in, ids := sqlIn(callIds)
+
+	// the rows referencing partitioned calls are not cascaded
+	if calls.controller.Partitions.IsPartitioned() {
+		for _, table := range append(append([]string{}, PartitionCallTables...), PartitionReferenceTables...) {
+			query = fmt.Sprintf(`DELETE FROM "%s" WHERE "callId" IN %s`, table, in)
+			if _, err = db.Exec(query, ids...); err != nil {
+				return 0, formatError(err, query)
+			}
+		}
+	}
+
+	query = fmt.Sprintf(`DELETE FROM "calls" WHERE "callId" IN %s`, in)
+	if _, err = db.Exec(query, ids...); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	return uint(len(callIds)), nil
+}
+
+// ReplaceAudio overwrites the audio of a stored call, leaving its details
+// untouched.
+func (calls *Calls) ReplaceAudio(call *Call, db Storage) error {
 	calls.mutex.Lock()
 	defer calls.mutex.Unlock()
 
-	timestamp := time.Now().Add(-24 * time.Hour * time.Duration(pruneDays)).UnixMilli()
-	query := fmt.Sprintf(`DELETE FROM "calls" WHERE "timestamp" < %d`, timestamp)
+	query := `UPDATE "calls" SET "audio" = ?, "audioFilename" = ?, "audioMime" = ?, "audioProfile" = ? WHERE "callId" = ?`
+	if _, err := db.Exec(query, call.Audio, call.AudioFilename, call.AudioMime, call.AudioProfile, call.Id); err != nil {
+		return errorFormatter("calls", "replaceaudio")(err, query)
+	}
+
+	return nil
+}
+
+// ReplaceCall overwrites a stored call with a better copy of the same
+// transmission, keeping its id.
+func (calls *Calls) ReplaceCall(call *Call, db Storage) error {
+	var (
+		err   error
+		query string
+		tx    *StorageTx
+	)
 
-	if _, err := db.Sql.Exec(query); err != nil {
-		return fmt.Errorf("%s in %s", err, query)
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	formatError := errorFormatter("calls", "replacecall")
+
+	if tx, err = db.Begin(); err != nil {
+		return formatError(err, "")
+	}
+
+	query = `UPDATE "calls" SET "audio" = ?, "audioFilename" = ?, "audioMime" = ?, "audioProfile" = ?, "duration" = ?, "fingerprint" = ?, "flags" = ?, "peakLevel" = ?, "peaks" = ?, "rmsLevel" = ?, "sampleRate" = ?, "simulcast" = ?, "siteRef" = ?, "source" = ?, "timestamp" = ?, "trimmed" = ? WHERE "callId" = ?`
+	if _, err = tx.Exec(query, call.Audio, call.AudioFilename, call.AudioMime, call.AudioProfile, call.Duration, call.Fingerprint.String(), strings.Join(call.Flags, ","), call.PeakLevel, call.Peaks.String(), call.RmsLevel, call.SampleRate, call.Simulcast.String(), call.SiteRef, call.Source, call.Timestamp.UnixMilli(), call.Trimmed, call.Id); err != nil {
+		tx.Rollback()
+		return formatError(err, query)
+	}
+
+	for _, table := range []string{"callFrequencies", "callPatches", "callUnits"} {
+		query = fmt.Sprintf(`DELETE FROM "%s" WHERE "callId" = ?`, table)
+		if _, err = tx.Exec(query, call.Id); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
+		}
+	}
+
+	if query, err = calls.writeDetails(tx, call); err != nil {
+		tx.Rollback()
+		return formatError(err, query)
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return formatError(err, "")
 	}
 
 	return nil
@@ -350,6 +583,7 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 	)
 
 	var (
+		args []any
 		err  error
 		rows *sql.Rows
 
@@ -375,41 +609,20 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 	}
 
 	if client.Access != nil {
-		switch v := client.Access.Systems.(type) {
-		case []any:
-			a := []string{}
-			for _, scope := range v {
-				var c string
-				switch v := scope.(type) {
-				case map[string]any:
-					switch v["talkgroups"].(type) {
-					case []any:
-						b := strings.ReplaceAll(fmt.Sprintf("%v", v["talkgroups"]), " ", ", ")
-						b = strings.ReplaceAll(b, "[", "(")
-						b = strings.ReplaceAll(b, "]", ")")
-						c = fmt.Sprintf(`(s."systemRef" = %d AND t."talkgroupRef" IN %v)`, v["id"], b)
-					case string:
-						if v["talkgroups"] == "*" {
-							c = fmt.Sprintf(`s."systemRef" = %d`, v["id"])
-						}
-					}
-				}
-				if len(c) > 0 {
-					a = append(a, c)
-				}
-			}
-			where = fmt.Sprintf("(%s)", strings.Join(a, " OR "))
+		if scope, scopeArgs := client.Access.Scope(); len(scope) > 0 {
+			where += fmt.Sprintf(" AND %s", scope)
+			args = append(args, scopeArgs...)
 		}
 	}
 
 	switch v := searchOptions.System.(type) {
 	case uint:
-		a := []string{
-			fmt.Sprintf(`s."systemRef" = %d`, v),
-		}
+		a := []string{`s."systemRef" = ?`}
+		args = append(args, v)
 		switch v := searchOptions.Talkgroup.(type) {
 		case uint:
-			a = append(a, fmt.Sprintf(`t."talkgroupRef" = %d`, v))
+			a = append(a, `t."talkgroupRef" = ?`)
+			args = append(args, v)
 		}
 		where += fmt.Sprintf(" AND (%s)", strings.Join(a, " AND "))
 	}
@@ -418,8 +631,9 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 	case string:
 		a := []string{}
 		for id, m := range client.GroupsMap[v] {
-			in := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(fmt.Sprintf("%v", m), " ", ", "), "[", "("), "]", ")")
-			a = append(a, fmt.Sprintf(`(s."systemRef" = %d AND t."talkgroupRef" IN %s)`, id, in))
+			in, inArgs := sqlIn(m)
+			a = append(a, fmt.Sprintf(`(s."systemRef" = ? AND t."talkgroupRef" IN %s)`, in))
+			args = append(append(args, id), inArgs...)
 		}
 		if len(a) > 0 {
 			where += fmt.Sprintf(" AND (%s)", strings.Join(a, " OR "))
@@ -430,23 +644,82 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 	case string:
 		a := []string{}
 		for id, m := range client.TagsMap[v] {
-			in := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(fmt.Sprintf("%v", m), " ", ", "), "[", "("), "]", ")")
-			a = append(a, fmt.Sprintf(`(s."systemRef" = %d AND t."talkgroupRef" IN %s)`, id, in))
+			in, inArgs := sqlIn(m)
+			a = append(a, fmt.Sprintf(`(s."systemRef" = ? AND t."talkgroupRef" IN %s)`, in))
+			args = append(append(args, id), inArgs...)
 		}
 		if len(a) > 0 {
 			where += fmt.Sprintf(" AND (%s)", strings.Join(a, " OR "))
 		}
 	}
 
-	query = fmt.Sprintf(`SELECT c."timestamp" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s ORDER BY c."timestamp" ASC`, where)
-	if err = db.Sql.QueryRow(query).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
+	switch v := searchOptions.MinDuration.(type) {
+	case uint:
+		where += ` AND c."duration" >= ?`
+		args = append(args, v)
+	}
+
+	switch v := searchOptions.MaxDuration.(type) {
+	case uint:
+		where += ` AND c."duration" <= ?`
+		args = append(args, v)
+	}
+
+	switch v := searchOptions.Conversation.(type) {
+	case uint64:
+		where += ` AND c."conversationId" = ?`
+		args = append(args, v)
+	}
+
+	switch v := searchOptions.Site.(type) {
+	case uint:
+		where += ` AND c."siteRef" = ?`
+		args = append(args, v)
+	}
+
+	switch v := searchOptions.Label.(type) {
+	case string:
+		label := sqlContains(strings.ToLower(v))
+		where += fmt.Sprintf(` AND (%s OR %s)`, db.Dialect().Like(`LOWER(t."label")`), db.Dialect().Like(`LOWER(t."name")`))
+		args = append(args, label, label)
+	}
+
+	switch v := searchOptions.Unit.(type) {
+	case []uint:
+		in, inArgs := sqlIn(v)
+		where += fmt.Sprintf(` AND c."callId" IN (SELECT "callId" FROM "callUnits" WHERE "unitRef" IN %s)`, in)
+		args = append(args, inArgs...)
+	}
+
+	switch v := searchOptions.Patch.(type) {
+	case uint:
+		where += ` AND c."callId" IN (SELECT cp."callId" FROM "callPatches" AS cp LEFT JOIN "talkgroups" AS pt ON pt."talkgroupId" = cp."talkgroupId" WHERE pt."systemId" = c."systemId" AND pt."talkgroupRef" = ?)`
+		args = append(args, v)
+	}
+
+	if minFrequency, ok := searchOptions.MinFrequency.(uint); ok {
+		if maxFrequency, ok := searchOptions.MaxFrequency.(uint); ok {
+			where += ` AND c."callId" IN (SELECT "callId" FROM "callFrequencies" WHERE "frequency" BETWEEN ? AND ?)`
+			args = append(args, minFrequency, maxFrequency)
+		} else {
+			where += ` AND c."callId" IN (SELECT "callId" FROM "callFrequencies" WHERE "frequency" >= ?)`
+			args = append(args, minFrequency)
+		}
+
+	} else if maxFrequency, ok := searchOptions.MaxFrequency.(uint); ok {
+		where += ` AND c."callId" IN (SELECT "callId" FROM "callFrequencies" WHERE "frequency" <= ?)`
+		args = append(args, maxFrequency)
+	}
+
+	query = fmt.Sprintf(`SELECT c."timestamp" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s ORDER BY c."timestamp" ASC LIMIT 1`, where)
+	if err = db.QueryRow(query, args...).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
 	searchResults.DateStart = time.UnixMilli(timestamp)
 
-	query = fmt.Sprintf(`SELECT c."timestamp" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s ORDER BY c."timestamp" DESC`, where)
-	if err = db.Sql.QueryRow(query).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
+	query = fmt.Sprintf(`SELECT c."timestamp" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s ORDER BY c."timestamp" DESC LIMIT 1`, where)
+	if err = db.QueryRow(query, args...).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
@@ -470,7 +743,11 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 			stop  time.Time
 		)
 
-		if order == ascOrder {
+		if end, ok := searchOptions.DateEnd.(time.Time); ok {
+			start = v
+			stop = end
+
+		} else if order == ascOrder {
 			start = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), 0, 0, time.UTC)
 			stop = start.Add(time.Hour*24 - time.Millisecond)
 
@@ -479,7 +756,14 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 			stop = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), 0, 0, time.UTC)
 		}
 
-		where += fmt.Sprintf(` AND (c."timestamp" BETWEEN %d AND %d)`, start.UnixMilli(), stop.UnixMilli())
+		where += ` AND (c."timestamp" BETWEEN ? AND ?)`
+		args = append(args, start.UnixMilli(), stop.UnixMilli())
+
+	default:
+		if end, ok := searchOptions.DateEnd.(time.Time); ok {
+			where += ` AND c."timestamp" <= ?`
+			args = append(args, end.UnixMilli())
+		}
 	}
 
 	switch v := searchOptions.Limit.(type) {
@@ -494,19 +778,33 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 		offset = v
 	}
 
-	query = fmt.Sprintf(`SELECT COUNT(*) FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s`, where)
-	if err = db.Sql.QueryRow(query).Scan(&searchResults.Count); err != nil && err != sql.ErrNoRows {
-		return nil, formatError(err, query)
+	cursor, paged := searchOptions.Cursor.(callsSearchCursor)
+
+	if !paged {
+		query = fmt.Sprintf(`SELECT COUNT(*) FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s`, where)
+		if err = db.QueryRow(query, args...).Scan(&searchResults.Count); err != nil && err != sql.ErrNoRows {
+			return nil, formatError(err, query)
+		}
+
+	} else {
+		if order == ascOrder {
+			where += ` AND (c."timestamp" > ? OR (c."timestamp" = ? AND c."callId" > ?))`
+		} else {
+			where += ` AND (c."timestamp" < ? OR (c."timestamp" = ? AND c."callId" < ?))`
+		}
+		args = append(args, cursor.timestamp, cursor.timestamp, cursor.id)
+
+		offset = 0
 	}
 
-	query = fmt.Sprintf(`SELECT c."callId", c."timestamp", s."systemRef", t."talkgroupRef" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s ORDER BY c."timestamp" %s LIMIT %d OFFSET %d`, where, order, limit, offset)
-	if rows, err = db.Sql.Query(query); err != nil && err != sql.ErrNoRows {
+	query = fmt.Sprintf(`SELECT c."callId", c."conversationId", c."duration", c."timestamp", s."systemRef", t."talkgroupRef" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" LEFT JOIN "delayed" AS d ON d."callId" = c."callId" WHERE %s ORDER BY c."timestamp" %s, c."callId" %s LIMIT ? OFFSET ?`, where, order, order)
+	if rows, err = db.Query(query, append(args, limit, offset)...); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
 	for rows.Next() {
 		searchResult := CallsSearchResult{}
-		if err = rows.Scan(&searchResult.Id, &timestamp, &searchResult.System, &searchResult.Talkgroup); err != nil {
+		if err = rows.Scan(&searchResult.Id, &searchResult.Conversation, &searchResult.Duration, &timestamp, &searchResult.System, &searchResult.Talkgroup); err != nil {
 			break
 		}
 
@@ -521,15 +819,19 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 		return nil, formatError(err, "")
 	}
 
+	if n := len(searchResults.Results); n > 0 && uint(n) == limit {
+		last := searchResults.Results[n-1]
+		searchResults.Cursor = callsSearchCursor{id: last.Id, timestamp: last.Timestamp.UnixMilli()}.String()
+	}
+
 	return searchResults, err
 }
 
-func (calls *Calls) WriteCall(call *Call, db *Database) (uint64, error) {
+func (calls *Calls) WriteCall(call *Call, db Storage) (uint64, error) {
 	var (
 		err   error
 		query string
-		res   sql.Result
-		tx    *sql.Tx
+		tx    *StorageTx
 	)
 
 	calls.mutex.Lock()
@@ -537,80 +839,108 @@ func (calls *Calls) WriteCall(call *Call, db *Database) (uint64, error) {
 
 	formatError := errorFormatter("calls", "writecall")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return 0, formatError(err, "")
 	}
 
-	if db.Config.DbType == DbTypePostgresql {
-		query = fmt.Sprintf(`INSERT INTO "calls" ("audio", "audioFilename", "audioMime", "siteRef", "systemId", "talkgroupId", "timestamp") VALUES ($1, '%s', '%s', %d, %d, %d, %d) RETURNING "callId"`, call.AudioFilename, call.AudioMime, call.SiteRef, call.System.Id, call.Talkgroup.Id, call.Timestamp.UnixMilli())
-
-		err = tx.QueryRow(query, call.Audio).Scan(&call.Id)
-
-	} else {
-		query = fmt.Sprintf(`INSERT INTO "calls" ("audio", "audioFilename", "audioMime", "siteRef", "systemId", "talkgroupId", "timestamp") VALUES (?, '%s', '%s', %d, %d, %d, %d)`, call.AudioFilename, call.AudioMime, call.SiteRef, call.System.Id, call.Talkgroup.Id, call.Timestamp.UnixMilli())
-
-		if res, err = tx.Exec(query, call.Audio); err == nil {
-			if id, err := res.LastInsertId(); err == nil {
-				call.Id = uint64(id)
-			}
-		}
+	query = `INSERT INTO "calls" ("audio", "audioFilename", "audioMime", "audioProfile", "conversationId", "duration", "fingerprint", "flags", "linkedCallId", "peakLevel", "peaks", "rmsLevel", "sampleRate", "simulcast", "siteRef", "source", "systemId", "talkgroupId", "timestamp", "trimmed") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if call.Id, err = tx.Insert(query, "callId", call.Audio, call.AudioFilename, call.AudioMime, call.AudioProfile, call.ConversationId, call.Duration, call.Fingerprint.String(), strings.Join(call.Flags, ","), call.LinkedCallId, call.PeakLevel, call.Peaks.String(), call.RmsLevel, call.SampleRate, call.Simulcast.String(), call.SiteRef, call.Source, call.System.Id, call.Talkgroup.Id, call.Timestamp.UnixMilli(), call.Trimmed); err != nil {
+		tx.Rollback()
+		return 0, formatError(err, query)
 	}
 
-	if err != nil {
+	if query, err = calls.writeDetails(tx, call); err != nil {
 		tx.Rollback()
 		return 0, formatError(err, query)
 	}
 
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, formatError(err, "")
+	}
+
+	return uint64(call.Id), nil
+}
+
+// WritePeaks stores the waveform of a call recorded before peaks were
+// computed at ingest.
+func (calls *Calls) WritePeaks(call *Call, db Storage) error {
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	formatError := errorFormatter("calls", "writepeaks")
+
+	query := `UPDATE "calls" SET "peaks" = ? WHERE "callId" = ?`
+
+	if _, err := db.Exec(query, call.Peaks.String(), call.Id); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
+
+func (calls *Calls) writeDetails(tx *StorageTx, call *Call) (string, error) {
+	var (
+		args  []any
+		err   error
+		query string
+	)
+
+	partitioned := calls.controller.Partitions.IsPartitioned()
+	timestamp := call.Timestamp.UnixMilli()
+
 	for _, freq := range call.Frequencies {
-		query = fmt.Sprintf(`INSERT INTO "callFrequencies" ("callId", "dbm", "errors", "frequency", "offset", "spikes") VALUES (%d, %d, %d, %d, %f, %d)`, call.Id, freq.Dbm, freq.Errors, freq.Frequency, freq.Offset, freq.Spikes)
-		if _, err = tx.Exec(query); err != nil {
-			tx.Rollback()
-			return 0, formatError(err, query)
+		query, args = partitionInsert(partitioned, "callFrequencies", []string{"callId", "dbm", "errors", "frequency", "offset", "spikes"}, timestamp, call.Id, freq.Dbm, freq.Errors, freq.Frequency, freq.Offset, freq.Spikes)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return query, err
 		}
 	}
 
 	for _, ref := range call.Patches {
 		var talkgroupId sql.NullInt64
-		query = fmt.Sprintf(`SELECT "talkgroupId" FROM "talkgroups" WHERE "systemId" = %d and "talkgroupRef" = %d`, call.System.Id, ref)
-		if err = tx.QueryRow(query).Scan(&talkgroupId); err != nil && err != sql.ErrNoRows {
-			tx.Rollback()
-			return 0, formatError(err, query)
+		query = `SELECT "talkgroupId" FROM "talkgroups" WHERE "systemId" = ? AND "talkgroupRef" = ?`
+		if err = tx.QueryRow(query, call.System.Id, ref).Scan(&talkgroupId); err != nil && err != sql.ErrNoRows {
+			return query, err
 		}
 		if !talkgroupId.Valid {
 			continue
 		}
-		query = fmt.Sprintf(`INSERT INTO "callPatches" ("callId", "talkgroupId") VALUES (%d, %d)`, call.Id, talkgroupId.Int64)
-		if _, err = tx.Exec(query); err != nil {
-			tx.Rollback()
-			return 0, formatError(err, query)
+		query, args = partitionInsert(partitioned, "callPatches", []string{"callId", "talkgroupId"}, timestamp, call.Id, talkgroupId.Int64)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return query, err
 		}
 	}
 
 	for _, unit := range call.Units {
-		query = fmt.Sprintf(`INSERT INTO "callUnits" ("callId", "offset", "unitRef") VALUES (%d, %f, %d)`, call.Id, unit.Offset, unit.UnitRef)
-		if _, err = tx.Exec(query); err != nil {
-			tx.Rollback()
-			return 0, formatError(err, query)
+		query, args = partitionInsert(partitioned, "callUnits", []string{"callId", "offset", "unitRef"}, timestamp, call.Id, unit.Offset, unit.UnitRef)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return query, err
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return 0, formatError(err, "")
-	}
-
-	return uint64(call.Id), nil
+	return "", nil
 }
 
 type CallsSearchOptions struct {
-	Date      any `json:"date,omitempty"`
-	Group     any `json:"group,omitempty"`
-	Limit     any `json:"limit,omitempty"`
-	Offset    any `json:"offset,omitempty"`
-	Sort      any `json:"sort,omitempty"`
-	System    any `json:"system,omitempty"`
-	Tag       any `json:"tag,omitempty"`
-	Talkgroup any `json:"talkgroup,omitempty"`
+	Conversation any `json:"conversation,omitempty"`
+	Cursor       any `json:"cursor,omitempty"`
+	Date         any `json:"date,omitempty"`
+	DateEnd      any `json:"dateEnd,omitempty"`
+	Group        any `json:"group,omitempty"`
+	Label        any `json:"label,omitempty"`
+	Limit        any `json:"limit,omitempty"`
+	MaxDuration  any `json:"maxDuration,omitempty"`
+	MaxFrequency any `json:"maxFrequency,omitempty"`
+	MinDuration  any `json:"minDuration,omitempty"`
+	MinFrequency any `json:"minFrequency,omitempty"`
+	Offset       any `json:"offset,omitempty"`
+	Patch        any `json:"patch,omitempty"`
+	Site         any `json:"site,omitempty"`
+	Sort         any `json:"sort,omitempty"`
+	System       any `json:"system,omitempty"`
+	Tag          any `json:"tag,omitempty"`
+	Talkgroup    any `json:"talkgroup,omitempty"`
+	Unit         any `json:"unit,omitempty"`
 }
 
 func NewCallSearchOptions() *CallsSearchOptions {
@@ -618,6 +948,18 @@ func NewCallSearchOptions() *CallsSearchOptions {
 }
 
 func (searchOptions *CallsSearchOptions) fromMap(m map[string]any) *CallsSearchOptions {
+	switch v := m["conversation"].(type) {
+	case float64:
+		searchOptions.Conversation = uint64(v)
+	}
+
+	switch v := m["cursor"].(type) {
+	case string:
+		if cursor, ok := parseCallsSearchCursor(v); ok {
+			searchOptions.Cursor = cursor
+		}
+	}
+
 	switch v := m["date"].(type) {
 	case string:
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
@@ -625,21 +967,65 @@ func (searchOptions *CallsSearchOptions) fromMap(m map[string]any) *CallsSearchO
 		}
 	}
 
+	switch v := m["dateEnd"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateEnd = t
+		}
+	}
+
 	switch v := m["group"].(type) {
 	case string:
 		searchOptions.Group = v
 	}
 
+	switch v := m["label"].(type) {
+	case string:
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			searchOptions.Label = v
+		}
+	}
+
 	switch v := m["limit"].(type) {
 	case float64:
 		searchOptions.Limit = uint(v)
 	}
 
+	switch v := m["maxDuration"].(type) {
+	case float64:
+		searchOptions.MaxDuration = uint(v)
+	}
+
+	switch v := m["maxFrequency"].(type) {
+	case float64:
+		searchOptions.MaxFrequency = uint(v)
+	}
+
+	switch v := m["minDuration"].(type) {
+	case float64:
+		searchOptions.MinDuration = uint(v)
+	}
+
+	switch v := m["minFrequency"].(type) {
+	case float64:
+		searchOptions.MinFrequency = uint(v)
+	}
+
 	switch v := m["offset"].(type) {
 	case float64:
 		searchOptions.Offset = uint(v)
 	}
 
+	switch v := m["patch"].(type) {
+	case float64:
+		searchOptions.Patch = uint(v)
+	}
+
+	switch v := m["site"].(type) {
+	case float64:
+		searchOptions.Site = uint(v)
+	}
+
 	switch v := m["sort"].(type) {
 	case float64:
 		searchOptions.Sort = int(v)
@@ -660,18 +1046,65 @@ func (searchOptions *CallsSearchOptions) fromMap(m map[string]any) *CallsSearchO
 		searchOptions.Talkgroup = uint(v)
 	}
 
+	switch v := m["unit"].(type) {
+	case float64:
+		searchOptions.Unit = []uint{uint(v)}
+	case []any:
+		units := []uint{}
+		for _, f := range v {
+			switch v := f.(type) {
+			case float64:
+				units = append(units, uint(v))
+			}
+		}
+		if len(units) > 0 {
+			searchOptions.Unit = units
+		}
+	}
+
 	return searchOptions
 }
 
+// callsSearchCursor is the position of the last call of a page of search
+// results, from which the next page starts without the cost of an offset.
+type callsSearchCursor struct {
+	id        uint64
+	timestamp int64
+}
+
+func parseCallsSearchCursor(s string) (cursor callsSearchCursor, ok bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, false
+	}
+
+	if n, err := fmt.Sscanf(string(b), "%d.%d", &cursor.timestamp, &cursor.id); err != nil || n != 2 {
+		return cursor, false
+	}
+
+	return cursor, true
+}
+
+func (cursor callsSearchCursor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cursor.String())
+}
+
+func (cursor callsSearchCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d.%d", cursor.timestamp, cursor.id))
+}
+
 type CallsSearchResult struct {
-	Id        uint64    `json:"id"`
-	System    uint      `json:"system"`
-	Talkgroup uint      `json:"talkgroup"`
-	Timestamp time.Time `json:"dateTime"`
+	Id           uint64    `json:"id"`
+	Conversation uint64    `json:"conversation,omitempty"`
+	Duration     uint      `json:"duration,omitempty"`
+	System       uint      `json:"system"`
+	Talkgroup    uint      `json:"talkgroup"`
+	Timestamp    time.Time `json:"dateTime"`
 }
 
 type CallsSearchResults struct {
 	Count     uint                `json:"count"`
+	Cursor    string              `json:"cursor,omitempty"`
 	DateStart time.Time           `json:"dateStart"`
 	DateStop  time.Time           `json:"dateStop"`
 	Options   *CallsSearchOptions `json:"options"`
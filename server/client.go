@@ -189,12 +189,16 @@ func (client *Client) GetRemoteAddr() string {
 	return GetRemoteAddr(client.request)
 }
 
-func (client *Client) SendConfig(groups *Groups, options *Options, systems *Systems, tags *Tags) {
+func (client *Client) Scope(groups *Groups, options *Options, systems *Systems, tags *Tags) {
 	client.SystemsMap = systems.GetScopedSystems(client, groups, tags, options.SortTalkgroups)
 	client.GroupsData = groups.GetGroupsData(&client.SystemsMap)
 	client.GroupsMap = groups.GetGroupsMap(&client.SystemsMap)
 	client.TagsData = tags.GetTagsData(&client.SystemsMap)
 	client.TagsMap = tags.GetTagsMap(&client.SystemsMap)
+}
+
+func (client *Client) SendConfig(groups *Groups, options *Options, systems *Systems, tags *Tags) {
+	client.Scope(groups, options, systems, tags)
 
 	var payload = map[string]any{
 		"alerts":             Alerts,
@@ -38,22 +38,35 @@ import (
 
 const (
 	COMMAND_ARG            = "cmd"
+	COMMAND_ARG_AUDIO      = "+audio"
 	COMMAND_ARG_CODE       = "+code"
 	COMMAND_ARG_EXPIRATION = "+expiration"
 	COMMAND_ARG_IDENT      = "+ident"
 	COMMAND_ARG_IN         = "+in"
+	COMMAND_ARG_JOB        = "+job"
 	COMMAND_ARG_LIMIT      = "+limit"
 	COMMAND_ARG_OUT        = "+out"
 	COMMAND_ARG_PASSWORD   = "+password"
 	COMMAND_ARG_SYSTEMS    = "+systems"
+	COMMAND_ARG_TARGET     = "+target"
 	COMMAND_ARG_TOKEN      = "+token"
 	COMMAND_ARG_URL        = "+url"
+	COMMAND_ARG_VERSION    = "+version"
 	COMMAND_ADMIN_PASSWORD = "admin-password"
+	COMMAND_BACKUP         = "backup"
 	COMMAND_CONFIG_GET     = "config-get"
 	COMMAND_CONFIG_SET     = "config-set"
+	COMMAND_DB_COPY        = "db-copy"
+	COMMAND_DB_PARTITION   = "db-partition"
+	COMMAND_DISCOVERIES    = "discoveries"
 	COMMAND_HELP           = "help"
+	COMMAND_JOB_RUN        = "job-run"
+	COMMAND_JOBS           = "jobs"
 	COMMAND_LOGIN          = "login"
 	COMMAND_LOGOUT         = "logout"
+	COMMAND_MIGRATE_DOWN   = "migrate-down"
+	COMMAND_MIGRATE_STATUS = "migrate-status"
+	COMMAND_RESTORE        = "restore"
 	COMMAND_USER_ADD       = "user-add"
 	COMMAND_USER_REMOVE    = "user-remove"
 
@@ -63,21 +76,26 @@ const (
 
 type Command struct {
 	app        string
+	audio      string
 	code       string
 	command    string
+	config     *Config
 	expiration string
 	ident      string
 	in         string
+	job        string
 	limit      string
 	out        string
 	password   string
 	systems    string
+	target     string
 	token      string
 	tokenFile  string
 	url        string
+	version    string
 }
 
-func NewCommand(baseDir string) *Command {
+func NewCommand(config *Config) *Command {
 	app, _ := os.Executable()
 	pass := os.Getenv("RDIO_ADMIN_PASSWORD")
 
@@ -88,8 +106,9 @@ func NewCommand(baseDir string) *Command {
 	return &Command{
 		app:       filepath.Base(app),
 		command:   COMMAND_HELP,
+		config:    config,
 		password:  pass,
-		tokenFile: baseDir + filepath.Base(app) + ".token",
+		tokenFile: config.BaseDir + filepath.Base(app) + ".token",
 		url:       COMMAND_DEF_URL,
 	}
 }
@@ -107,6 +126,9 @@ func (command *Command) Do(action string) {
 
 	for i < len(os.Args) {
 		switch os.Args[i] {
+		case COMMAND_ARG_AUDIO:
+			command.audio = readVal()
+
 		case COMMAND_ARG_CODE:
 			command.code = readVal()
 
@@ -119,6 +141,9 @@ func (command *Command) Do(action string) {
 		case COMMAND_ARG_IN:
 			command.in = readVal()
 
+		case COMMAND_ARG_JOB:
+			command.job = readVal()
+
 		case COMMAND_ARG_LIMIT:
 			command.limit = readVal()
 
@@ -134,6 +159,9 @@ func (command *Command) Do(action string) {
 		case COMMAND_ARG_SYSTEMS:
 			command.systems = readVal()
 
+		case COMMAND_ARG_TARGET:
+			command.target = readVal()
+
 		case COMMAND_ARG_TOKEN:
 			command.tokenFile = readVal()
 
@@ -143,6 +171,9 @@ func (command *Command) Do(action string) {
 			if !regexp.MustCompile(`^https?://`).Match([]byte(command.url)) {
 				command.exitWithError(errors.New("invalid URL"))
 			}
+
+		case COMMAND_ARG_VERSION:
+			command.version = readVal()
 		}
 
 		i++
@@ -157,21 +188,48 @@ func (command *Command) Do(action string) {
 	}
 
 	switch action {
+	case COMMAND_BACKUP:
+		command.backup()
+
 	case COMMAND_CONFIG_GET:
 		command.configGet()
 
 	case COMMAND_CONFIG_SET:
 		command.configSet()
 
+	case COMMAND_DB_COPY:
+		command.dbCopy()
+
+	case COMMAND_DB_PARTITION:
+		command.dbPartition()
+
+	case COMMAND_DISCOVERIES:
+		command.discoveries()
+
+	case COMMAND_JOB_RUN:
+		command.jobRun()
+
+	case COMMAND_JOBS:
+		command.jobs()
+
 	case COMMAND_LOGIN:
 		command.login()
 
 	case COMMAND_LOGOUT:
 		command.logout()
 
+	case COMMAND_MIGRATE_DOWN:
+		command.migrateDown()
+
+	case COMMAND_MIGRATE_STATUS:
+		command.migrateStatus()
+
 	case COMMAND_ADMIN_PASSWORD:
 		command.adminPassword()
 
+	case COMMAND_RESTORE:
+		command.restore()
+
 	case COMMAND_USER_ADD:
 		command.userAdd()
 
@@ -198,10 +256,26 @@ func (command *Command) printUsage() {
 	fmt.Printf("\nAvailable Commands:\n\n")
 	fmt.Printf("  %-11s – Change administrator password.\n\n", COMMAND_ADMIN_PASSWORD)
 	fmt.Printf("    %-11s %s%s -%s %s %s <password>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_ADMIN_PASSWORD, COMMAND_ARG_PASSWORD)
+	fmt.Printf("  %-11s – Back up the database in the backups folder of the server.\n\n", COMMAND_BACKUP)
+	fmt.Printf("    %-11s %s%s -%s %s\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_BACKUP)
+	fmt.Printf("    %-11s Optional:\n\n", "")
+	fmt.Printf("      %-11s %-11s <true|false>          – Include the audio of the calls.\n\n", "", COMMAND_ARG_AUDIO)
 	fmt.Printf("  %-11s – Retrieve server's configuration.\n\n", COMMAND_CONFIG_GET)
 	fmt.Printf("    %-11s %s%s -%s %s %s <file.json>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_CONFIG_GET, COMMAND_ARG_OUT)
 	fmt.Printf("  %-11s – Set server's configuration.\n\n", COMMAND_CONFIG_SET)
 	fmt.Printf("    %-11s %s%s -%s %s %s <file.json>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_CONFIG_SET, COMMAND_ARG_IN)
+	fmt.Printf("  %-11s – Copy the database to another one of any type, the server being stopped.\n\n", COMMAND_DB_COPY)
+	fmt.Printf("    %-11s %s%s -%s %s %s <file.ini>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_DB_COPY, COMMAND_ARG_TARGET)
+	fmt.Printf("  %-11s – Partition the calls by month on PostgreSQL, moving them in resumable batches, then list the partitions.\n\n", COMMAND_DB_PARTITION)
+	fmt.Printf("    %-11s %s%s -%s %s\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_DB_PARTITION)
+	fmt.Printf("  %-11s – Report talkgroups seen in traffic but rejected or auto-populated.\n\n", COMMAND_DISCOVERIES)
+	fmt.Printf("    %-11s %s%s -%s %s\n", "", prompt, command.app, COMMAND_ARG, COMMAND_DISCOVERIES)
+	fmt.Printf("    %-11s %s%s -%s %s %s <file.json>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_DISCOVERIES, COMMAND_ARG_OUT)
+	fmt.Printf("  %-11s – Run a scheduled job now.\n\n", COMMAND_JOB_RUN)
+	fmt.Printf("    %-11s %s%s -%s %s %s <name>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_JOB_RUN, COMMAND_ARG_JOB)
+	fmt.Printf("  %-11s – List the scheduled jobs and their last run, or the run history of a job.\n\n", COMMAND_JOBS)
+	fmt.Printf("    %-11s %s%s -%s %s\n", "", prompt, command.app, COMMAND_ARG, COMMAND_JOBS)
+	fmt.Printf("    %-11s %s%s -%s %s %s <name>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_JOBS, COMMAND_ARG_JOB)
 	fmt.Printf("  %-11s – Login to server.\n\n", COMMAND_LOGIN)
 	if runtime.GOOS != "windows" {
 		fmt.Printf("    %-11s $ RDIO_ADMIN_PASSWORD=<password> ./%s -%s %s\n", "", command.app, COMMAND_ARG, COMMAND_LOGIN)
@@ -209,6 +283,12 @@ func (command *Command) printUsage() {
 	fmt.Printf("    %-11s %s%s -%s %s %s <password>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_LOGIN, COMMAND_ARG_PASSWORD)
 	fmt.Printf("  %-11s – Logout from server.\n\n", COMMAND_LOGOUT)
 	fmt.Printf("    %-11s %s%s -%s %s\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_LOGOUT)
+	fmt.Printf("  %-11s – Roll back the database schema to a version, the server being stopped.\n\n", COMMAND_MIGRATE_DOWN)
+	fmt.Printf("    %-11s %s%s -%s %s %s <version>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_MIGRATE_DOWN, COMMAND_ARG_VERSION)
+	fmt.Printf("  %-11s – Report the database schema version and its migrations.\n\n", COMMAND_MIGRATE_STATUS)
+	fmt.Printf("    %-11s %s%s -%s %s\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_MIGRATE_STATUS)
+	fmt.Printf("  %-11s – Restore a backup to the database, the server being stopped.\n\n", COMMAND_RESTORE)
+	fmt.Printf("    %-11s %s%s -%s %s %s <backup file>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_RESTORE, COMMAND_ARG_IN)
 	fmt.Printf("  %-11s – Add a user access.\n\n", COMMAND_USER_ADD)
 	fmt.Printf("    %-11s %s%s -%s %s %s <ident> %s <code>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_USER_ADD, COMMAND_ARG_IDENT, COMMAND_ARG_CODE)
 	fmt.Printf("    %-11s Optional:\n\n", "")
@@ -240,6 +320,41 @@ func (command *Command) adminPassword() {
 	}
 }
 
+func (command *Command) backup() {
+	m := map[string]any{}
+
+	if command.audio != "" {
+		if v, err := strconv.ParseBool(command.audio); err == nil {
+			m["audio"] = v
+		} else {
+			command.exitWithError(fmt.Sprintf("Invalid value for %s", COMMAND_ARG_AUDIO))
+		}
+	}
+
+	if body, err := command.writeBody(m); err == nil {
+		if res, err := command.submit(http.MethodPost, "/api/admin/backups", body, true); err == nil {
+			if res.StatusCode == http.StatusOK {
+				if data, err := command.readBody(res.Body); err == nil {
+					switch v := data.(type) {
+					case map[string]any:
+						fmt.Printf("Backup %v created (%v bytes).\n", v["name"], v["size"])
+					default:
+						command.exitWithError(errors.New("invalid response"))
+					}
+				} else {
+					command.exitWithError(err)
+				}
+			} else {
+				command.exitWithError(errors.New(res.Status))
+			}
+		} else {
+			command.exitWithError(err)
+		}
+	} else {
+		command.exitWithError(err)
+	}
+}
+
 func (command *Command) configGet() {
 	if command.out == "" {
 		command.exitWithError(fmt.Sprintf("Missing %s <file.json> arguments.", COMMAND_ARG_OUT))
@@ -302,6 +417,209 @@ func (command *Command) configSet() {
 	}
 }
 
+func (command *Command) dbCopy() {
+	if command.target == "" {
+		command.exitWithError(fmt.Sprintf("Missing %s <file.ini> arguments.", COMMAND_ARG_TARGET))
+	}
+
+	target, err := NewDatabaseConfig(command.config.BaseDir, command.target)
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	source := command.config
+
+	if source.DbType == target.DbType {
+		if source.DbType == DbTypeSqlite && source.GetDbFilePath() == target.GetDbFilePath() ||
+			source.DbType != DbTypeSqlite && source.DbHost == target.DbHost && source.DbPort == target.DbPort && source.DbName == target.DbName {
+			command.exitWithError(errors.New("the source and target databases are the same"))
+		}
+	}
+
+	dbCopy := NewDatabaseCopy(NewDatabase(source), NewDatabase(target))
+
+	dbCopy.Progress = func(table string, copied uint, total uint) {
+		fmt.Printf("%s: %d/%d\n", table, copied, total)
+	}
+
+	counts, err := dbCopy.Run()
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	mismatch := false
+
+	fmt.Printf("\n%-20s %-10s %-10s\n", "TABLE", "SOURCE", "TARGET")
+	for _, count := range counts {
+		fmt.Printf("%-20s %-10d %-10d\n", count.Table, count.Source, count.Target)
+
+		if count.Source != count.Target {
+			mismatch = true
+		}
+	}
+
+	if mismatch {
+		command.exitWithError(errors.New("the row counts of the target database do not match the source"))
+	}
+
+	fmt.Printf("\nDatabase copied from %s to %s.\n", source.DbType, target.DbType)
+}
+
+func (command *Command) dbPartition() {
+	if command.config.DbType != DbTypePostgresql {
+		command.exitWithError(errors.New("partitioning is only available on postgresql"))
+	}
+
+	var (
+		db         = NewDatabase(command.config)
+		partitions = NewPartitions(nil)
+	)
+
+	if err := NewSchemaMigrator(db).Apply(PartitionMigrationName); err != nil {
+		command.exitWithError(err)
+	}
+
+	if err := partitions.Move(db); err != nil {
+		command.exitWithError(err)
+	}
+
+	if err := partitions.Maintain(db); err != nil {
+		command.exitWithError(err)
+	}
+
+	list, err := partitions.List(db)
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	fmt.Printf("%-20s %-25s %-25s %s\n", "PARTITION", "FROM", "TO", "TABLESPACE")
+	for _, partition := range list {
+		from, to, tablespace := "-", "-", "-"
+		if !partition.Default {
+			from = time.UnixMilli(partition.From).UTC().Format(time.RFC3339)
+			to = time.UnixMilli(partition.To).UTC().Format(time.RFC3339)
+		}
+		if partition.Tablespace != "" {
+			tablespace = partition.Tablespace
+		}
+
+		fmt.Printf("%-20s %-25s %-25s %s\n", partition.Name, from, to, tablespace)
+	}
+}
+
+func (command *Command) discoveries() {
+	if res, err := command.submit(http.MethodGet, "/api/admin/discoveries", nil, true); err == nil {
+		if res.StatusCode == http.StatusOK {
+			if data, err := command.readBody(res.Body); err == nil {
+				switch v := data.(type) {
+				case []any:
+					if command.out != "" {
+						if f, err := os.Create(command.out); err == nil {
+							j := json.NewEncoder(f)
+							j.SetIndent("", "  ")
+							j.Encode(v)
+							fmt.Printf("Discovery report saved to %s.\n", command.out)
+						} else {
+							command.exitWithError(err)
+						}
+
+					} else {
+						fmt.Printf("%-8s %-10s %-9s %-6s %-25s %-25s %s\n", "SYSTEM", "TALKGROUP", "STATUS", "CALLS", "FIRST SEEN", "LAST SEEN", "LABEL")
+						for _, d := range v {
+							switch d := d.(type) {
+							case map[string]any:
+								fmt.Printf("%-8v %-10v %-9v %-6v %-25v %-25v %v\n", d["system"], d["talkgroup"], d["status"], d["count"], d["firstSeen"], d["lastSeen"], d["talkgroupLabel"])
+							}
+						}
+					}
+				default:
+					command.exitWithError(errors.New("invalid response"))
+				}
+			} else {
+				command.exitWithError(err)
+			}
+		} else {
+			command.exitWithError(errors.New(res.Status))
+		}
+	} else {
+		command.exitWithError(err)
+	}
+}
+
+func (command *Command) jobRun() {
+	if command.job == "" {
+		command.exitWithError(fmt.Sprintf("Missing %s <name> arguments.", COMMAND_ARG_JOB))
+	}
+
+	if body, err := command.writeBody(map[string]any{"action": "run", "name": command.job}); err == nil {
+		if res, err := command.submit(http.MethodPost, "/api/admin/jobs", body, true); err == nil {
+			if res.StatusCode == http.StatusOK {
+				fmt.Printf("Job %s started.\n", command.job)
+			} else {
+				command.exitWithError(errors.New(res.Status))
+			}
+		} else {
+			command.exitWithError(err)
+		}
+	} else {
+		command.exitWithError(err)
+	}
+}
+
+func (command *Command) jobs() {
+	url := "/api/admin/jobs"
+	if command.job != "" {
+		url = fmt.Sprintf("%s?history&name=%s", url, command.job)
+	}
+
+	if res, err := command.submit(http.MethodGet, url, nil, true); err == nil {
+		if res.StatusCode == http.StatusOK {
+			if data, err := command.readBody(res.Body); err == nil {
+				switch v := data.(type) {
+				case []any:
+					if command.job != "" {
+						fmt.Printf("%-25s %-7s %-8s %-10s %s\n", "DATE", "MANUAL", "STATUS", "DURATION", "ERROR")
+						for _, d := range v {
+							switch d := d.(type) {
+							case map[string]any:
+								fmt.Printf("%-25v %-7v %-8v %-10v %v\n", d["dateTime"], d["manual"], d["status"], fmt.Sprintf("%vms", d["duration"]), d["error"])
+							}
+						}
+
+					} else {
+						dash := func(v any) any {
+							if v == nil || v == "" {
+								return "-"
+							}
+							return v
+						}
+
+						fmt.Printf("%-12s %-8s %-15s %-25s %-8s %-25s %s\n", "NAME", "ENABLED", "SCHEDULE", "LAST RUN", "STATUS", "NEXT RUN", "DESCRIPTION")
+						for _, d := range v {
+							switch d := d.(type) {
+							case map[string]any:
+								status := d["lastStatus"]
+								if d["running"] == true {
+									status = "running"
+								}
+								fmt.Printf("%-12v %-8v %-15v %-25v %-8v %-25v %v\n", d["name"], d["enabled"], d["schedule"], dash(d["lastRun"]), dash(status), dash(d["nextRun"]), d["description"])
+							}
+						}
+					}
+				default:
+					command.exitWithError(errors.New("invalid response"))
+				}
+			} else {
+				command.exitWithError(err)
+			}
+		} else {
+			command.exitWithError(errors.New(res.Status))
+		}
+	} else {
+		command.exitWithError(err)
+	}
+}
+
 func (command *Command) login() {
 	if body, err := command.writeBody(map[string]any{"password": command.password}); err == nil {
 		if res, err := command.submit(http.MethodPost, "/api/admin/login", body, false); err == nil {
@@ -355,6 +673,73 @@ func (command *Command) logout() {
 	}
 }
 
+func (command *Command) migrateDown() {
+	if command.version == "" {
+		command.exitWithError(fmt.Sprintf("Missing %s <version> arguments.", COMMAND_ARG_VERSION))
+	}
+
+	version, err := strconv.ParseUint(command.version, 10, 32)
+	if err != nil {
+		command.exitWithError(fmt.Sprintf("Invalid number for %s", COMMAND_ARG_VERSION))
+	}
+
+	db := openDatabase(command.config)
+
+	if err = NewSchemaMigrator(db).Rollback(uint(version)); err != nil {
+		command.exitWithError(err)
+	}
+
+	// the calls are moved back out of their partitions
+	if err = NewPartitions(nil).Move(db); err != nil {
+		command.exitWithError(err)
+	}
+
+	fmt.Printf("Database schema rolled back to version %d.\n", version)
+}
+
+func (command *Command) migrateStatus() {
+	migrator := NewSchemaMigrator(openDatabase(command.config))
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	fmt.Printf("Database schema version %d.\n\n", version)
+
+	fmt.Printf("%-8s %-25s %-9s %-25s %s\n", "VERSION", "NAME", "STATUS", "APPLIED", "CHECKSUM")
+	for _, status := range statuses {
+		applied := "-"
+		if !status.AppliedAt.IsZero() {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Printf("%-8d %-25s %-9s %-25s %s\n", status.Version, status.Name, status.Status, applied, status.Checksum[:min(12, len(status.Checksum))])
+	}
+}
+
+func (command *Command) restore() {
+	if command.in == "" {
+		command.exitWithError(fmt.Sprintf("Missing %s <backup file> arguments.", COMMAND_ARG_IN))
+	}
+
+	filename := command.in
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		filename = filepath.Join(command.config.GetPath(BackupDir), command.in)
+	}
+
+	if err := NewBackups(nil, NewDatabase(command.config)).Restore(filename); err != nil {
+		command.exitWithError(err)
+	}
+
+	fmt.Printf("Backup %s restored.\n", filepath.Base(filename))
+}
+
 func (command *Command) userAdd() {
 	if command.ident == "" {
 		command.exitWithError(fmt.Sprintf("Missing %s <ident> arguments.", COMMAND_ARG_IDENT))
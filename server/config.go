@@ -29,6 +29,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -38,6 +39,9 @@ const (
 	DbTypeMysql      string = "mysql"
 	DbTypePostgresql string = "postgresql"
 	DbTypeSqlite     string = "sqlite"
+
+	DbPortMariadb    uint = 3306
+	DbPortPostgresql uint = 5432
 )
 
 type Config struct {
@@ -50,6 +54,8 @@ type Config struct {
 	DbName           string
 	DbUsername       string
 	DbPassword       string
+	FFMpegTimeout    uint
+	IngestWorkers    uint
 	Listen           string
 	SslAutoCert      string
 	SslCaCertFile    string
@@ -68,8 +74,9 @@ func NewConfig() *Config {
 		defaultDbType           = DbTypeSqlite
 		defaultDbFile           = "rdio-scanner.db"
 		defaultDbHost           = "localhost"
-		defaultDbPortMariaDb    = uint(3306)
-		defaultDbPortPostgreSql = uint(5432)
+		defaultDbPortMariaDb    = DbPortMariadb
+		defaultDbPortPostgreSql = DbPortPostgresql
+		defaultFFMpegTimeout    = uint(IngestDefaultTimeout / time.Second)
 		defaultListen           = ":3000"
 	)
 
@@ -104,6 +111,8 @@ func NewConfig() *Config {
 	flag.StringVar(&config.DbType, "db_type", defaultDbType, fmt.Sprintf("database type, one of %s, %s, %s, %s", DbTypeSqlite, DbTypeMariadb, DbTypeMysql, DbTypePostgresql))
 	flag.StringVar(&config.DbUsername, "db_user", "", "database user name")
 	flag.StringVar(&config.ConfigFile, "config", defaultConfigFile, "server config file")
+	flag.UintVar(&config.FFMpegTimeout, "ffmpeg_timeout", defaultFFMpegTimeout, "maximum seconds allowed for an audio conversion, 0 for no limit")
+	flag.UintVar(&config.IngestWorkers, "ingest_workers", 0, "number of concurrent ingest workers, 0 for one per cpu")
 	flag.StringVar(&config.Listen, "listen", defaultListen, "listening address")
 	flag.StringVar(&config.newAdminPassword, "admin_password", "", "change admin password")
 	flag.StringVar(&config.SslAutoCert, "ssl_auto_cert", "", "domain name for Let's Encrypt automatic certificate")
@@ -132,37 +141,14 @@ func NewConfig() *Config {
 
 	default:
 		if cfg, err := ini.Load(config.GetConfigFilePath()); err == nil {
-			if v := cfg.Section("").Key("db_file").String(); len(v) > 0 {
-				config.DbFile = v
-			}
-
-			if v := cfg.Section("").Key("db_host").String(); len(v) > 0 {
-				config.DbHost = v
-			}
-
-			if v := cfg.Section("").Key("db_name").String(); len(v) > 0 {
-				config.DbName = v
-			}
+			config.readDatabaseSection(cfg.Section(""))
 
-			if v := cfg.Section("").Key("db_pass").String(); len(v) > 0 {
-				config.DbPassword = v
-			}
-
-			if v := cfg.Section("").Key("db_type").String(); len(v) > 0 {
-				config.DbType = v
-			}
-
-			if config.DbPort, err = cfg.Section("").Key("db_port").Uint(); err != nil {
-				switch config.DbType {
-				case DbTypePostgresql:
-					config.DbPort = defaultDbPortPostgreSql
-				default:
-					config.DbPort = defaultDbPortMariaDb
-				}
+			if v, err := cfg.Section("").Key("ffmpeg_timeout").Uint(); err == nil {
+				config.FFMpegTimeout = v
 			}
 
-			if v := cfg.Section("").Key("db_user").String(); len(v) > 0 {
-				config.DbUsername = v
+			if v, err := cfg.Section("").Key("ingest_workers").Uint(); err == nil {
+				config.IngestWorkers = v
 			}
 
 			if v := cfg.Section("").Key("listen").String(); len(v) > 0 {
@@ -193,7 +179,7 @@ func NewConfig() *Config {
 	}
 
 	if *command != "" {
-		NewCommand(config.BaseDir).Do(*command)
+		NewCommand(config).Do(*command)
 	}
 
 	if *serviceAction != "" {
@@ -203,6 +189,30 @@ func NewConfig() *Config {
 	return config
 }
 
+// NewDatabaseConfig reads the database settings of an ini file using the same
+// keys as the server config file, such as the target of a database copy.
+func NewDatabaseConfig(baseDir string, filename string) (*Config, error) {
+	config := &Config{
+		BaseDir: baseDir,
+		DbFile:  "rdio-scanner.db",
+		DbHost:  "localhost",
+		DbType:  DbTypeSqlite,
+	}
+
+	cfg, err := ini.Load(config.GetPath(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	config.readDatabaseSection(cfg.Section(""))
+
+	if !(config.DbType == DbTypeMariadb || config.DbType == DbTypeMysql || config.DbType == DbTypePostgresql || config.DbType == DbTypeSqlite) {
+		return nil, fmt.Errorf("unknown database type %s", config.DbType)
+	}
+
+	return config, nil
+}
+
 func (config *Config) GetConfigFilePath() string {
 	return config.GetPath(config.ConfigFile)
 }
@@ -234,6 +244,43 @@ func (config *Config) GetSslKeyFilePath() string {
 	return config.GetPath(config.SslKeyFile)
 }
 
+func (config *Config) readDatabaseSection(section *ini.Section) {
+	var err error
+
+	if v := section.Key("db_file").String(); len(v) > 0 {
+		config.DbFile = v
+	}
+
+	if v := section.Key("db_host").String(); len(v) > 0 {
+		config.DbHost = v
+	}
+
+	if v := section.Key("db_name").String(); len(v) > 0 {
+		config.DbName = v
+	}
+
+	if v := section.Key("db_pass").String(); len(v) > 0 {
+		config.DbPassword = v
+	}
+
+	if v := section.Key("db_type").String(); len(v) > 0 {
+		config.DbType = v
+	}
+
+	if config.DbPort, err = section.Key("db_port").Uint(); err != nil {
+		switch config.DbType {
+		case DbTypePostgresql:
+			config.DbPort = DbPortPostgresql
+		default:
+			config.DbPort = DbPortMariadb
+		}
+	}
+
+	if v := section.Key("db_user").String(); len(v) > 0 {
+		config.DbUsername = v
+	}
+}
+
 func (config *Config) isBaseDirWritable() bool {
 	if f, err := os.CreateTemp(config.BaseDir, ".tmp*"); err == nil {
 		f.Close()
@@ -277,6 +324,14 @@ func (config *Config) saveConfig() error {
 		ini = append(ini, fmt.Sprintf("db_user = %s", config.DbUsername))
 	}
 
+	if config.FFMpegTimeout != uint(IngestDefaultTimeout/time.Second) {
+		ini = append(ini, fmt.Sprintf("ffmpeg_timeout = %d", config.FFMpegTimeout))
+	}
+
+	if config.IngestWorkers > 0 {
+		ini = append(ini, fmt.Sprintf("ingest_workers = %d", config.IngestWorkers))
+	}
+
 	if config.Listen != "" {
 		ini = append(ini, fmt.Sprintf("listen = %s", config.Listen))
 	}
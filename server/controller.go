@@ -21,6 +21,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -28,59 +29,90 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Controller struct {
-	Accesses    *Accesses
-	Admin       *Admin
-	Api         *Api
-	Apikeys     *Apikeys
-	Calls       *Calls
-	Clients     *Clients
-	Config      *Config
-	Database    *Database
-	Delayer     *Delayer
-	Dirwatches  *Dirwatches
-	Downstreams *Downstreams
-	FFMpeg      *FFMpeg
-	Groups      *Groups
-	Logs        *Logs
-	Options     *Options
-	Scheduler   *Scheduler
-	Systems     *Systems
-	Tags        *Tags
-	Register    chan *Client
-	Unregister  chan *Client
-	Ingest      chan *Call
-	running     bool
+	Accesses       *Accesses
+	Admin          *Admin
+	Api            *Api
+	Apikeys        *Apikeys
+	Backups        *Backups
+	Calls          *Calls
+	Clients        *Clients
+	Config         *Config
+	Conversations  *Conversations
+	Database       Storage
+	Delayer        *Delayer
+	Digest         *Digest
+	Dirwatches     *Dirwatches
+	Discoveries    *Discoveries
+	Downsampler    *Downsampler
+	Downstreams    *Downstreams
+	FFMpeg         *FFMpeg
+	Groups         *Groups
+	Incidents      *Incidents
+	Ingester       *Ingester
+	Logs           *Logs
+	Options        *Options
+	Partitions     *Partitions
+	Quarantine     *Quarantine
+	Retention      *Retention
+	Scheduler      *Scheduler
+	Simulcast      *Simulcast
+	Statistics     *Statistics
+	Systems        *Systems
+	Tags           *Tags
+	UnitActivities *UnitActivities
+	Register       chan *Client
+	Unregister     chan *Client
+	Ingest         chan *Call
+	peaks          chan struct{}
+	running        bool
 }
 
 func NewController(config *Config) *Controller {
 	controller := &Controller{
-		Clients:    NewClients(),
-		Config:     config,
-		Accesses:   NewAccesses(),
-		Apikeys:    NewApikeys(),
-		Dirwatches: NewDirwatches(),
-		FFMpeg:     NewFFMpeg(),
-		Groups:     NewGroups(),
-		Logs:       NewLogs(),
-		Options:    NewOptions(),
-		Systems:    NewSystems(),
-		Tags:       NewTags(),
-		Register:   make(chan *Client, 8192),
-		Unregister: make(chan *Client, 8192),
-		Ingest:     make(chan *Call, 8192),
-	}
+		Clients:     NewClients(),
+		Config:      config,
+		Accesses:    NewAccesses(),
+		Apikeys:     NewApikeys(),
+		Dirwatches:  NewDirwatches(),
+		Discoveries: NewDiscoveries(),
+		FFMpeg:      NewFFMpeg(time.Duration(config.FFMpegTimeout) * time.Second),
+		Groups:      NewGroups(),
+		Logs:        NewLogs(),
+		Options:     NewOptions(),
+		Systems:     NewSystems(),
+		Tags:        NewTags(),
+		Register:    make(chan *Client, 8192),
+		Unregister:  make(chan *Client, 8192),
+		Ingest:      make(chan *Call, 8192),
+		peaks:       make(chan struct{}, PeaksWorkers),
+	}
+
+	database := NewDatabase(config)
 
 	controller.Admin = NewAdmin(controller)
 	controller.Api = NewApi(controller)
+	controller.Backups = NewBackups(controller, database)
 	controller.Calls = NewCalls(controller)
-	controller.Database = NewDatabase(config)
+	controller.Conversations = NewConversations(controller)
+	controller.Database = database
 	controller.Delayer = NewDelayer(controller)
+	controller.Digest = NewDigest(controller)
+	controller.Downsampler = NewDownsampler(controller)
+	controller.Incidents = NewIncidents(controller)
+	controller.Ingester = NewIngester(controller)
 	controller.Downstreams = NewDownstreams(controller)
+	controller.Partitions = NewPartitions(controller)
+	controller.Quarantine = NewQuarantine(controller)
+	controller.Retention = NewRetention(controller)
 	controller.Scheduler = NewScheduler(controller)
+	controller.Simulcast = NewSimulcast(controller)
+	controller.Statistics = NewStatistics(controller)
+	controller.UnitActivities = NewUnitActivities(controller)
 
 	controller.Logs.setDaemon(config.daemon)
 	controller.Logs.setDatabase(controller.Database)
@@ -104,11 +136,18 @@ func (controller *Controller) EmitConfig() {
 }
 
 func (controller *Controller) IngestCall(call *Call) {
-	var populated bool
+	var (
+		failure   error
+		populated bool
+	)
 
-	logCall := func(call *Call, level string, message string) {
-		controller.Logs.LogEvent(level, fmt.Sprintf("newcall: system=%v talkgroup=%v file=%v %v", call.System.SystemRef, call.Talkgroup.TalkgroupRef, call.AudioFilename, message))
-	}
+	defer func() {
+		if failure != nil && call.ingested != nil {
+			call.ingested(failure)
+		}
+	}()
+
+	logCall := controller.logCall
 
 	logError := func(err error) {
 		controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("controller.ingestcall: %v", err.Error()))
@@ -164,11 +203,12 @@ func (controller *Controller) IngestCall(call *Call) {
 	if call.System != nil && call.Talkgroup != nil {
 		if call.System.Blacklists.IsBlacklisted(call.Talkgroup.TalkgroupRef) {
 			logCall(call, LogLevelInfo, "blacklisted")
+			failure = errors.New("blacklisted")
 			return
 		}
 	}
 
-	if controller.Options.AutoPopulate && call.System == nil {
+	if (controller.Options.AutoPopulate || call.populate) && call.System == nil {
 		populated = true
 
 		call.System = NewSystem()
@@ -188,7 +228,7 @@ func (controller *Controller) IngestCall(call *Call) {
 		controller.Systems.List = append(controller.Systems.List, call.System)
 	}
 
-	if controller.Options.AutoPopulate || (call.System != nil && call.System.AutoPopulate) {
+	if controller.Options.AutoPopulate || call.populate || (call.System != nil && call.System.AutoPopulate) {
 		if call.System != nil && call.Talkgroup == nil && call.Meta.TalkgroupRef > 0 {
 			var (
 				groupLabels    []string
@@ -212,11 +252,13 @@ func (controller *Controller) IngestCall(call *Call) {
 
 					if err := controller.Groups.Write(controller.Database); err != nil {
 						logError(err)
+						failure = err
 						return
 					}
 
 					if err := controller.Groups.Read(controller.Database); err != nil {
 						logError(err)
+						failure = err
 						return
 					}
 				}
@@ -233,11 +275,13 @@ func (controller *Controller) IngestCall(call *Call) {
 
 				if err := controller.Tags.Write(controller.Database); err != nil {
 					logError(err)
+					failure = err
 					return
 				}
 
 				if err := controller.Tags.Read(controller.Database); err != nil {
 					logError(err)
+					failure = err
 					return
 				}
 			}
@@ -267,6 +311,8 @@ func (controller *Controller) IngestCall(call *Call) {
 			}
 
 			call.System.Talkgroups.List = append(call.System.Talkgroups.List, call.Talkgroup)
+
+			call.discovered = true
 		}
 
 		units := NewUnits()
@@ -289,11 +335,13 @@ func (controller *Controller) IngestCall(call *Call) {
 	if populated {
 		if err := controller.Systems.Write(controller.Database); err != nil {
 			logError(err)
+			failure = err
 			return
 		}
 
 		if err := controller.Systems.Read(controller.Database); err != nil {
 			logError(err)
+			failure = err
 			return
 		}
 
@@ -302,12 +350,14 @@ func (controller *Controller) IngestCall(call *Call) {
 		}
 
 		if call.System == nil {
+			failure = errors.New("no matching system")
 			return
 
 		} else {
 			call.Talkgroup, _ = call.System.Talkgroups.GetTalkgroupByRef(call.Talkgroup.TalkgroupRef)
 
 			if call.Talkgroup == nil {
+				failure = errors.New("no matching talkgroup")
 				return
 			}
 		}
@@ -316,40 +366,247 @@ func (controller *Controller) IngestCall(call *Call) {
 	}
 
 	if call.System == nil || call.Talkgroup == nil {
-		logCall(call, LogLevelWarn, "no matching system/talkgroup")
+		failure = errors.New("no matching system/talkgroup")
+
+		if call.quarantineId == 0 {
+			if err := controller.Discoveries.Record(call, DiscoveryStatusRejected, controller.Database); err != nil {
+				logError(err)
+			}
+		}
+
+		if !controller.Options.Quarantine || call.quarantineId > 0 {
+			logCall(call, LogLevelWarn, "no matching system/talkgroup")
+
+		} else if err := controller.Quarantine.Add(call, controller.Database); err == nil {
+			logCall(call, LogLevelWarn, "no matching system/talkgroup, quarantined")
+
+		} else {
+			logCall(call, LogLevelWarn, "no matching system/talkgroup")
+			logError(err)
+		}
+
+		return
+	}
+
+	controller.Ingester.Dispatch(call)
+}
+
+func (controller *Controller) ProcessCall(ctx context.Context, call *Call) {
+	var (
+		failure error
+		held    bool
+	)
+
+	defer func() {
+		if call.ingested != nil && !held {
+			call.ingested(failure)
+		}
+	}()
+
+	logCall := controller.logCall
+
+	logError := func(err error) {
+		controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("controller.processcall: %v", err.Error()))
+	}
+
+	if ctx.Err() != nil {
+		failure = ErrIngestStopped
 		return
 	}
 
-	if !controller.Options.DisableDuplicateDetection {
-		if dup, err := controller.Calls.CheckDuplicate(call, controller.Options.DuplicateDetectionTimeFrame, controller.Database); err == nil {
-			if dup {
-				logCall(call, LogLevelWarn, "duplicate call rejected")
+	// calls released by the simulcast resolver were already checked and
+	// converted
+	if !call.resolved {
+		simulcast := !controller.Options.DisableDuplicateDetection && controller.Options.DuplicateResolution == DuplicateResolutionQuality
+
+		if !controller.Options.DisableDuplicateDetection && !simulcast {
+			if dup, err := controller.Calls.CheckDuplicate(call, controller.Options.DuplicateDetectionTimeFrame, controller.Database); err == nil {
+				if dup {
+					logCall(call, LogLevelWarn, "duplicate call rejected")
+					failure = errors.New("duplicate call rejected")
+					return
+				}
+			} else {
+				logError(err)
+				failure = err
 				return
 			}
+		}
+
+		samples, stats, err := controller.FFMpeg.Convert(ctx, call, controller.Systems, controller.Tags, controller.Options.AudioConversion, controller.Options.GetAudioProfile(call))
+		if err != nil {
+			controller.Logs.LogEvent(LogLevelWarn, err.Error())
+		}
+
+		// a cancelled job is not written, the source will submit it again
+		if ctx.Err() != nil {
+			failure = ErrIngestStopped
+			return
+		}
+
+		// the audio not converted is measured on its own
+		if stats == nil {
+			stats, _ = controller.FFMpeg.Analyze(ctx, call.Audio)
+		}
+
+		if stats != nil {
+			call.SetStats(stats)
+		}
+
+		if flags, reject := controller.Options.CheckQualityGates(call); reject {
+			failure = fmt.Errorf("rejected by quality gates: %s", strings.Join(flags, ", "))
+			logCall(call, LogLevelWarn, failure.Error())
+			return
+
+		} else if len(flags) > 0 {
+			call.Flags = flags
+		}
+
+		channels, rate := uint(1), uint(DecodeSampleRate)
+
+		if len(samples) > 0 {
+			// the samples of the conversion tell how long the trimmed audio is
+			if processing := ResolveAudioProcessing(call); processing != nil && processing.TrimSilence {
+				call.Duration = uint(len(samples) * 1000 / DecodeSampleRate)
+			}
+
+		} else if samples, channels, rate, err = controller.FFMpeg.Decode(ctx, call.Audio); err != nil {
+			samples = nil
+		}
+
+		if len(samples) > 0 {
+			call.Peaks = NewPeaks(samples, channels)
+
+			if controller.Options.GetFingerprintDetection().IsEnabled() {
+				call.Fingerprint = NewFingerprint(samples, channels, rate)
+			}
+		}
+
+		if simulcast {
+			controller.Simulcast.Hold(call)
+			held = true
+			return
+		}
+	}
+
+	if detection := controller.Options.GetFingerprintDetection(); detection.IsEnabled() {
+		if linked, err := detection.Match(call, controller.Database); err != nil {
+			logError(err)
+
+		} else if linked > 0 && !call.replace {
+			if detection.Action == FingerprintActionDrop {
+				failure = fmt.Errorf("same audio as call %d rejected", linked)
+				logCall(call, LogLevelWarn, failure.Error())
+				return
+			}
+
+			call.LinkedCallId = linked
+		}
+	}
+
+	if call.replace {
+		if err := controller.Calls.ReplaceCall(call, controller.Database); err == nil {
+			logCall(call, LogLevelInfo, fmt.Sprintf("success, replaced call %d", call.Id))
+			controller.EmitCall(call)
+
 		} else {
 			logError(err)
-			return
+			failure = err
 		}
+
+		return
 	}
 
-	if err := controller.FFMpeg.Convert(call, controller.Systems, controller.Tags, controller.Options.AudioConversion); err != nil {
-		controller.Logs.LogEvent(LogLevelWarn, err.Error())
+	if controller.Options.ConversationGap > 0 {
+		if err := controller.Conversations.Assign(call, controller.Options.ConversationGap, controller.Options.ConversationPatches, controller.Database); err != nil {
+			logError(err)
+		}
 	}
 
 	if id, err := controller.Calls.WriteCall(call, controller.Database); err == nil {
 		call.Id = id
 
-		logCall(call, LogLevelInfo, "success")
+		if len(call.Flags) > 0 {
+			logCall(call, LogLevelWarn, fmt.Sprintf("success, flagged by quality gates: %s", strings.Join(call.Flags, ", ")))
+		} else if call.LinkedCallId > 0 {
+			logCall(call, LogLevelInfo, fmt.Sprintf("success, same audio as call %d", call.LinkedCallId))
+		} else {
+			logCall(call, LogLevelInfo, "success")
+		}
+
+		if call.discovered {
+			if err := controller.Discoveries.Record(call, DiscoveryStatusPopulated, controller.Database); err != nil {
+				logError(err)
+			}
+
+		} else if controller.Discoveries.IsTracked(call) {
+			if err := controller.Discoveries.Record(call, "", controller.Database); err != nil {
+				logError(err)
+			}
+		}
+
+		if err := controller.UnitActivities.Record(call, controller.Database); err != nil {
+			logError(err)
+		}
 
 		controller.EmitCall(call)
 
 	} else {
 		logError(err)
+		failure = err
 	}
 }
 
+// computePeaks fills the missing peaks of an older call off the request path,
+// a few calls at a time. When all the workers are busy, the peaks are left for
+// the next time the call is played.
+func (controller *Controller) computePeaks(call *Call) {
+	select {
+	case controller.peaks <- struct{}{}:
+	default:
+		return
+	}
+
+	// the call itself is being sent to the client
+	go func(id uint64, audio []byte) {
+		defer func() { <-controller.peaks }()
+
+		ctx, cancel := context.WithTimeout(controller.Ingester.ctx, PeaksTimeout)
+		defer cancel()
+
+		samples, channels, _, err := controller.FFMpeg.Decode(ctx, audio)
+		if err != nil {
+			return
+		}
+
+		if err := controller.Calls.WritePeaks(&Call{Id: id, Peaks: NewPeaks(samples, channels)}, controller.Database); err != nil {
+			controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("controller.computepeaks: %v", err))
+		}
+	}(call.Id, call.Audio)
+}
+
+func (controller *Controller) logCall(call *Call, level string, message string) {
+	systemRef, talkgroupRef := call.Meta.SystemRef, call.Meta.TalkgroupRef
+
+	if call.System != nil {
+		systemRef = call.System.SystemRef
+	}
+
+	if call.Talkgroup != nil {
+		talkgroupRef = call.Talkgroup.TalkgroupRef
+	}
+
+	controller.Logs.LogEvent(level, fmt.Sprintf("newcall: system=%v talkgroup=%v file=%v %v", systemRef, talkgroupRef, call.AudioFilename, message))
+}
+
 func (controller *Controller) LogClientsCount() {
-	controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("listeners count is %v", controller.Clients.Count()))
+	count := controller.Clients.Count()
+
+	controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("listeners count is %v", count))
+
+	if err := controller.Statistics.RecordListeners(count, controller.Database); err != nil {
+		controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
 }
 
 func (controller *Controller) ProcessMessage(client *Client, message *Message) error {
@@ -367,6 +624,11 @@ func (controller *Controller) ProcessMessage(client *Client, message *Message) e
 	} else if message.Command == MessageCommandConfig {
 		client.SendConfig(controller.Groups, controller.Options, controller.Systems, controller.Tags)
 
+	} else if message.Command == MessageCommandConversation {
+		if err := controller.ProcessMessageCommandConversation(client, message); err != nil {
+			return err
+		}
+
 	} else if message.Command == MessageCommandListCall {
 		if err := controller.ProcessMessageCommandListCall(client, message); err != nil {
 			return err
@@ -407,6 +669,10 @@ func (controller *Controller) ProcessMessageCommandCall(client *Client, message
 		return err
 	}
 
+	if len(call.Peaks) == 0 && len(call.Audio) > 0 {
+		controller.computePeaks(call)
+	}
+
 	if !controller.Accesses.IsRestricted() || client.Access.HasAccess(call) {
 		client.Send <- &Message{Command: MessageCommandCall, Payload: call, Flag: message.Flag}
 	}
@@ -414,6 +680,34 @@ func (controller *Controller) ProcessMessageCommandCall(client *Client, message
 	return nil
 }
 
+func (controller *Controller) ProcessMessageCommandConversation(client *Client, message *Message) error {
+	var id uint64
+
+	switch v := message.Payload.(type) {
+	case float64:
+		id = uint64(v)
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			id = uint64(i)
+		} else {
+			return err
+		}
+	}
+
+	conversation, err := controller.Conversations.GetConversation(id)
+	if err != nil {
+		return err
+	}
+
+	if controller.Accesses.IsRestricted() {
+		conversation.Filter(client.Access)
+	}
+
+	client.Send <- &Message{Command: MessageCommandConversation, Payload: conversation, Flag: message.Flag}
+
+	return nil
+}
+
 func (controller *Controller) ProcessMessageCommandListCall(client *Client, message *Message) error {
 	switch v := message.Payload.(type) {
 	case map[string]any:
@@ -529,6 +823,9 @@ func (controller *Controller) Start() error {
 	if err = controller.Dirwatches.Read(controller.Database); err != nil {
 		return err
 	}
+	if err = controller.Discoveries.Read(controller.Database); err != nil {
+		return err
+	}
 	if err = controller.Downstreams.Read(controller.Database); err != nil {
 		return err
 	}
@@ -544,6 +841,12 @@ func (controller *Controller) Start() error {
 	if err = controller.Tags.Read(controller.Database); err != nil {
 		return err
 	}
+	if err = controller.Partitions.Maintain(controller.Database); err != nil {
+		return err
+	}
+	if err = controller.Statistics.RecordListeners(0, controller.Database); err != nil {
+		return err
+	}
 
 	if err = controller.Admin.Start(); err != nil {
 		return err
@@ -562,12 +865,9 @@ func (controller *Controller) Start() error {
 		controller.Terminate()
 	}()
 
-	go func() {
-		for {
-			call := <-controller.Ingest
-			controller.IngestCall(call)
-		}
-	}()
+	if err = controller.Ingester.Start(); err != nil {
+		return err
+	}
 
 	go func() {
 		var timer *time.Timer
@@ -607,7 +907,11 @@ func (controller *Controller) Start() error {
 func (controller *Controller) Terminate() {
 	controller.Dirwatches.Stop()
 
-	if err := controller.Database.Sql.Close(); err != nil {
+	controller.Simulcast.Stop()
+
+	controller.Ingester.Stop()
+
+	if err := controller.Database.Close(); err != nil {
 		log.Println(err)
 	}
 
@@ -0,0 +1,223 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Conversation groups the consecutive calls of a talkgroup, ie. the back and
+// forth of an exchange, separated by less than the conversation gap.
+type Conversation struct {
+	Id             uint64
+	CallCount      uint
+	Calls          []*Call
+	FirstTimestamp time.Time
+	LastTimestamp  time.Time
+	System         *System
+	Talkgroup      *Talkgroup
+}
+
+// Filter removes the calls the listener has no access to.
+func (conversation *Conversation) Filter(access *Access) *Conversation {
+	calls := []*Call{}
+
+	for _, call := range conversation.Calls {
+		if access != nil && access.HasAccess(call) {
+			calls = append(calls, call)
+		}
+	}
+
+	conversation.Calls = calls
+
+	return conversation
+}
+
+func (conversation *Conversation) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"id":        conversation.Id,
+		"callCount": conversation.CallCount,
+		"calls":     conversation.Calls,
+		"dateTime":  conversation.FirstTimestamp.Format(time.RFC3339),
+		"endTime":   conversation.LastTimestamp.Format(time.RFC3339),
+	}
+
+	if conversation.System != nil {
+		m["system"] = conversation.System.SystemRef
+	}
+
+	if conversation.Talkgroup != nil {
+		m["talkgroup"] = conversation.Talkgroup.TalkgroupRef
+	}
+
+	return json.Marshal(m)
+}
+
+type Conversations struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewConversations(controller *Controller) *Conversations {
+	return &Conversations{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+// Assign sets the conversation of a call about to be written, continuing the
+// last conversation of its talkgroup, or of its patched talkgroups when
+// following patches, if it ended less than the gap before the call.
+func (conversations *Conversations) Assign(call *Call, gap uint, followPatches bool, db Storage) error {
+	var (
+		conversationId uint64
+		err            error
+		query          string
+	)
+
+	conversations.mutex.Lock()
+	defer conversations.mutex.Unlock()
+
+	formatError := errorFormatter("conversations", "assign")
+
+	talkgroupIds := []uint64{call.Talkgroup.Id}
+
+	if followPatches {
+		for _, ref := range call.Patches {
+			if talkgroup, ok := call.System.Talkgroups.GetTalkgroupByRef(ref); ok {
+				talkgroupIds = append(talkgroupIds, talkgroup.Id)
+			}
+		}
+	}
+
+	start := call.Timestamp.UnixMilli()
+	end := start + int64(call.Duration)
+
+	in, args := sqlIn(talkgroupIds)
+
+	query = fmt.Sprintf(`SELECT "conversationId" FROM "conversations" WHERE "systemId" = ? AND "talkgroupId" IN %s AND "lastTimestamp" >= ? AND "firstTimestamp" <= ? ORDER BY "lastTimestamp" DESC LIMIT 1`, in)
+	if err = db.QueryRow(query, append(append([]any{call.System.Id}, args...), start-int64(gap), end+int64(gap))...).Scan(&conversationId); err != nil && err != sql.ErrNoRows {
+		return formatError(err, query)
+	}
+
+	if conversationId > 0 {
+		query = `UPDATE "conversations" SET "callCount" = "callCount" + 1, "firstTimestamp" = CASE WHEN "firstTimestamp" > ? THEN ? ELSE "firstTimestamp" END, "lastTimestamp" = CASE WHEN "lastTimestamp" < ? THEN ? ELSE "lastTimestamp" END WHERE "conversationId" = ?`
+		if _, err = db.Exec(query, start, start, end, end, conversationId); err != nil {
+			return formatError(err, query)
+		}
+
+	} else {
+		query = `INSERT INTO "conversations" ("callCount", "firstTimestamp", "lastTimestamp", "systemId", "talkgroupId") VALUES (1, ?, ?, ?, ?)`
+		if conversationId, err = db.Insert(query, "conversationId", start, end, call.System.Id, call.Talkgroup.Id); err != nil {
+			return formatError(err, query)
+		}
+	}
+
+	call.ConversationId = conversationId
+
+	return nil
+}
+
+// GetConversation returns the conversation with its calls in chronological
+// order.
+func (conversations *Conversations) GetConversation(id uint64) (*Conversation, error) {
+	var (
+		callIds        = []uint64{}
+		err            error
+		firstTimestamp int64
+		lastTimestamp  int64
+		query          string
+		rows           *sql.Rows
+		systemId       uint64
+		talkgroupId    uint64
+	)
+
+	formatError := errorFormatter("conversations", "getconversation")
+
+	conversation := &Conversation{Id: id, Calls: []*Call{}}
+
+	query = `SELECT "callCount", "firstTimestamp", "lastTimestamp", "systemId", "talkgroupId" FROM "conversations" WHERE "conversationId" = ?`
+	if err = conversations.controller.Database.QueryRow(query, id).Scan(&conversation.CallCount, &firstTimestamp, &lastTimestamp, &systemId, &talkgroupId); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	conversation.FirstTimestamp = time.UnixMilli(firstTimestamp)
+	conversation.LastTimestamp = time.UnixMilli(lastTimestamp)
+
+	if system, ok := conversations.controller.Systems.GetSystemById(systemId); ok {
+		conversation.System = system
+
+		if talkgroup, ok := system.Talkgroups.GetTalkgroupById(talkgroupId); ok {
+			conversation.Talkgroup = talkgroup
+		}
+	}
+
+	query = `SELECT "callId" FROM "calls" WHERE "systemId" = ? AND ("timestamp" BETWEEN ? AND ?) AND "conversationId" = ? ORDER BY "timestamp"`
+	if rows, err = conversations.controller.Database.Query(query, systemId, firstTimestamp, lastTimestamp, id); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var callId uint64
+
+		if err = rows.Scan(&callId); err != nil {
+			break
+		}
+
+		callIds = append(callIds, callId)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	for _, callId := range callIds {
+		if call, err := conversations.controller.Calls.GetCall(callId); err == nil {
+			conversation.Calls = append(conversation.Calls, call)
+		} else {
+			return nil, err
+		}
+	}
+
+	return conversation, nil
+}
+
+// Prune removes the conversations left without calls once they can no longer
+// be continued.
+func (conversations *Conversations) Prune(db Storage) error {
+	conversations.mutex.Lock()
+	defer conversations.mutex.Unlock()
+
+	timestamp := time.Now().Add(-24 * time.Hour).UnixMilli()
+	query := `DELETE FROM "conversations" WHERE "lastTimestamp" < ? AND "conversationId" NOT IN (SELECT "conversationId" FROM "calls")`
+
+	if _, err := db.Exec(query, timestamp); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronShortcuts = map[string]string{
+	"@annually": "0 0 1 1 *",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+	"@midnight": "0 0 * * *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// CronSchedule is a standard five fields cron expression (minute, hour, day of
+// month, month and day of week) supporting lists, ranges, steps and the usual
+// @ shortcuts.
+type CronSchedule struct {
+	anyDay   bool
+	anyWeek  bool
+	days     uint64
+	hours    uint64
+	minutes  uint64
+	months   uint64
+	weekdays uint64
+}
+
+func ParseCronSchedule(s string) (*CronSchedule, error) {
+	var err error
+
+	if v, ok := cronShortcuts[strings.ToLower(strings.TrimSpace(s))]; ok {
+		s = v
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q, 5 fields expected", s)
+	}
+
+	schedule := &CronSchedule{}
+
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+
+	if schedule.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	// a field is restricted when its set leaves out some days, however it
+	// is written (1-31 is not restricted, */5 is)
+	schedule.anyDay = schedule.days == cronFieldBits(1, 31)
+	schedule.anyWeek = schedule.weekdays&cronFieldBits(0, 6) == cronFieldBits(0, 6)
+
+	return schedule, nil
+}
+
+// Next returns the first time matching the schedule after t, or the zero time
+// when none is found within five years.
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case schedule.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+
+		case !schedule.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+
+		case schedule.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+
+		case schedule.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matchDay follows the cron rule where a day matching either the day of month
+// or the day of week is selected when both fields are restricted.
+func (schedule *CronSchedule) matchDay(t time.Time) bool {
+	day := schedule.days&(1<<uint(t.Day())) != 0
+	weekday := schedule.weekdays&(1<<uint(t.Weekday())) != 0
+
+	if schedule.anyDay || schedule.anyWeek {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+func cronFieldBits(min uint, max uint) uint64 {
+	var bits uint64
+
+	for i := min; i <= max; i++ {
+		bits |= 1 << i
+	}
+
+	return bits
+}
+
+func parseCronField(s string, min uint, max uint) (uint64, error) {
+	var bits uint64
+
+	invalid := fmt.Errorf("invalid cron field %q", s)
+
+	for _, part := range strings.Split(s, ",") {
+		var (
+			from = min
+			step = uint(1)
+			to   = max
+		)
+
+		expr, stepExpr, hasStep := strings.Cut(part, "/")
+
+		if hasStep {
+			if n, err := strconv.ParseUint(stepExpr, 10, 8); err == nil && n > 0 {
+				step = uint(n)
+			} else {
+				return 0, invalid
+			}
+		}
+
+		if expr != "*" {
+			a, b, isRange := strings.Cut(expr, "-")
+
+			n, err := strconv.ParseUint(a, 10, 8)
+			if err != nil {
+				return 0, invalid
+			}
+			from = uint(n)
+
+			if isRange {
+				if n, err = strconv.ParseUint(b, 10, 8); err != nil {
+					return 0, invalid
+				}
+				to = uint(n)
+			} else if !hasStep {
+				to = from
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return 0, invalid
+		}
+
+		for i := from; i <= to; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	for _, tc := range []struct {
+		name    string
+		expr    string
+		from    string
+		want    string
+		wantErr bool
+	}{
+		{name: "step", expr: "*/15 * * * *", from: "2026-10-19 10:07", want: "2026-10-19 10:15"},
+		{name: "next minute", expr: "* * * * *", from: "2026-10-19 10:07", want: "2026-10-19 10:08"},
+		{name: "shortcut", expr: "@daily", from: "2026-10-19 10:07", want: "2026-10-20 00:00"},
+		{name: "week days", expr: "0 9 * * 1-5", from: "2026-10-23 10:00", want: "2026-10-26 09:00"},
+		{name: "sunday as 7", expr: "0 0 * * 7", from: "2026-10-19 10:00", want: "2026-10-25 00:00"},
+		{name: "day of month step", expr: "0 0 */5 * *", from: "2026-10-02 00:00", want: "2026-10-06 00:00"},
+		{name: "day of month or week", expr: "0 0 13 * 5", from: "2026-10-02 00:00", want: "2026-10-09 00:00"},
+		{name: "day of month before week", expr: "0 0 13 * 5", from: "2026-10-10 00:00", want: "2026-10-13 00:00"},
+		{name: "list", expr: "0 6,18 * * *", from: "2026-10-19 07:00", want: "2026-10-19 18:00"},
+		{name: "month", expr: "30 2 1 1 *", from: "2026-10-19 10:00", want: "2027-01-01 02:30"},
+		{name: "never", expr: "0 0 31 2 *", from: "2026-10-19 10:00"},
+		{name: "missing field", expr: "* * * *", wantErr: true},
+		{name: "out of range", expr: "60 * * * *", wantErr: true},
+		{name: "reversed range", expr: "5-1 * * * *", wantErr: true},
+		{name: "zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "not a number", expr: "a * * * *", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tc.expr)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error is %v, want an error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var want time.Time
+			if len(tc.want) > 0 {
+				want = at(tc.want)
+			}
+
+			if next := schedule.Next(at(tc.from)); !next.Equal(want) {
+				t.Errorf("next is %v, want %v", next, want)
+			}
+		})
+	}
+}
@@ -25,7 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"os"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -34,14 +34,36 @@ import (
 )
 
 type Database struct {
-	Config *Config
-	Sql    *sql.DB
+	Config     *Config
+	Sql        *sql.DB
+	dialect    *Dialect
+	statements *storageStatements
 }
 
 func NewDatabase(config *Config) *Database {
+	database := openDatabase(config)
+
+	if err := database.migrate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.seed(); err != nil {
+		log.Fatal(err)
+	}
+
+	return database
+}
+
+// openDatabase connects to the database without migrating it, for commands
+// inspecting its schema.
+func openDatabase(config *Config) *Database {
 	var err error
 
-	database := &Database{Config: config}
+	database := &Database{
+		Config:     config,
+		dialect:    NewDialect(config.DbType),
+		statements: newStorageStatements(),
+	}
 
 	switch config.DbType {
 	case DbTypeSqlite:
@@ -73,97 +95,52 @@ func NewDatabase(config *Config) *Database {
 	database.Sql.SetMaxIdleConns(25)
 	database.Sql.SetMaxOpenConns(25)
 
-	if err = database.migrate(); err != nil {
-		log.Fatal(err)
-	}
-
-	if err = database.seed(); err != nil {
-		log.Fatal(err)
-	}
-
 	return database
 }
 
-func (db *Database) migrate() error {
-	var schema []string
+// Size returns the bytes taken by the database, including the write-ahead log
+// of SQLite.
+func (db *Database) Size() (uint64, error) {
+	var (
+		query string
+		size  uint64
+	)
 
-	formatError := errorFormatter("database", "migrate")
+	formatError := errorFormatter("database", "size")
 
 	switch db.Config.DbType {
-	case DbTypeMariadb, DbTypeMysql:
-		schema = MysqlSchema
-	case DbTypePostgresql:
-		schema = PostgresqlSchema
 	case DbTypeSqlite:
-		schema = SqliteSchema
-	default:
-		return errors.New("no database schema")
-	}
-
-	if tx, err := db.Sql.Begin(); err == nil {
-
-		for _, query := range schema {
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
+		for _, suffix := range []string{"", "-wal"} {
+			if info, err := os.Stat(db.Config.GetDbFilePath() + suffix); err == nil {
+				size += uint64(info.Size())
+			} else if suffix == "" {
+				return 0, formatError(err, "")
 			}
 		}
+		return size, nil
 
-		if err = tx.Commit(); err != nil {
-			tx.Rollback()
-			return formatError(err, "")
-		}
-	}
-
-	if err := migrateGroups(db); err != nil {
-		return formatError(err, "")
-	}
-
-	if err := migrateTags(db); err != nil {
-		return formatError(err, "")
-	}
-
-	if err := migrateSystems(db); err != nil {
-		return formatError(err, "")
-	}
-
-	if err := migrateTalkgroups(db); err != nil {
-		return formatError(err, "")
-	}
-
-	if err := migrateUnits(db); err != nil {
-		return formatError(err, "")
-	}
-
-	if err := migrateOptions(db); err != nil {
-		return formatError(err, "")
-	}
-
-	if err := migrateMeta(db); err != nil {
-		return formatError(err, "")
-	}
+	case DbTypeMariadb, DbTypeMysql:
+		query = `SELECT COALESCE(SUM("data_length" + "index_length"), 0) FROM "information_schema"."tables" WHERE "table_schema" = DATABASE()`
 
-	if err := migrateLogs(db); err != nil {
-		return formatError(err, "")
+	case DbTypePostgresql:
+		query = `SELECT pg_database_size(current_database())`
 	}
 
-	if err := migrateDownstreams(db); err != nil {
-		return formatError(err, "")
+	if err := db.Sql.QueryRow(query).Scan(&size); err != nil {
+		return 0, formatError(err, query)
 	}
 
-	if err := migrateDirwatches(db); err != nil {
-		return formatError(err, "")
-	}
+	return size, nil
+}
 
-	if err := migrateCalls(db); err != nil {
-		return formatError(err, "")
-	}
+func (db *Database) migrate() error {
+	formatError := errorFormatter("database", "migrate")
 
-	if err := migrateApikeys(db); err != nil {
-		return formatError(err, "")
+	if schemaMigrations(db.Config.DbType) == nil {
+		return formatError(errors.New("no database schema"), "")
 	}
 
-	if err := migrateAccesses(db); err != nil {
+	if err := NewSchemaMigrator(db).Migrate(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -184,7 +161,3 @@ func (db *Database) seed() error {
 
 	return nil
 }
-
-func escapeQuotes(s string) string {
-	return strings.ReplaceAll(s, "'", "''")
-}
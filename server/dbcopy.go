@@ -0,0 +1,289 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const DatabaseCopyBatchSize = 500
+
+type DatabaseCopyCount struct {
+	Source uint
+	Table  string
+	Target uint
+}
+
+// DatabaseCopy copies a whole instance from a database to another one of any
+// type, preserving the ids and therefore the relations between the tables.
+type DatabaseCopy struct {
+	Progress func(table string, copied uint, total uint)
+	Source   *Database
+	Target   *Database
+}
+
+func NewDatabaseCopy(source *Database, target *Database) *DatabaseCopy {
+	return &DatabaseCopy{
+		Progress: func(string, uint, uint) {},
+		Source:   source,
+		Target:   target,
+	}
+}
+
+// Run copies the tables in creation order, which follows the foreign keys,
+// then returns the row counts of both databases for verification.
+func (dbCopy *DatabaseCopy) Run() ([]*DatabaseCopyCount, error) {
+	var count uint
+
+	formatError := errorFormatter("databasecopy", "run")
+
+	query := `SELECT COUNT(*) FROM "calls"`
+	if err := dbCopy.Target.QueryRow(query).Scan(&count); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	if count > 0 {
+		return nil, formatError(errors.New("the target database already holds calls"), "")
+	}
+
+	tables := schemaTables(dbCopy.Target.Config.DbType)
+
+	if err := dbCopy.clear(tables); err != nil {
+		return nil, formatError(err, "")
+	}
+
+	for _, table := range schemaTables(dbCopy.Source.Config.DbType) {
+		if !slices.Contains(tables, table) {
+			continue
+		}
+
+		if err := dbCopy.copyTable(table); err != nil {
+			return nil, formatError(err, "")
+		}
+	}
+
+	counts, err := dbCopy.verify(tables)
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	return counts, nil
+}
+
+func (dbCopy *DatabaseCopy) clear(tables []string) error {
+	tx, err := dbCopy.Target.begin(false)
+	if err != nil {
+		return err
+	}
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		query := fmt.Sprintf(`DELETE FROM "%s"`, tables[i])
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// copyTable streams the rows of a table in batches of primary keys, so that
+// the source database is never locked for long.
+func (dbCopy *DatabaseCopy) copyTable(table string) error {
+	var (
+		copied uint
+		err    error
+		lastId int64
+		query  string
+		rows   *sql.Rows
+		total  uint
+	)
+
+	query = fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)
+	if err = dbCopy.Source.QueryRow(query).Scan(&total); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	query = fmt.Sprintf(`SELECT * FROM "%s" WHERE 1 = 0`, table)
+	if rows, err = dbCopy.Target.Query(query); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	targetColumns, _ := rows.Columns()
+	targetTypes, _ := rows.ColumnTypes()
+	_, targetBools, _ := columnKinds(targetTypes)
+
+	rows.Close()
+
+	if len(targetColumns) == 0 {
+		return fmt.Errorf("no columns for table %s", table)
+	}
+
+	pk := targetColumns[0]
+
+	for {
+		query = fmt.Sprintf(`SELECT * FROM "%s" WHERE "%s" > ? ORDER BY "%s" LIMIT ?`, table, pk, pk)
+		if rows, err = dbCopy.Source.Query(query, lastId, DatabaseCopyBatchSize); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+
+		reader := newTableRows(rows)
+
+		// maps the source columns to the target ones, sqlite column names
+		// not always having the same case
+		mapping := make([]int, len(reader.Columns))
+		columns := []string{}
+		placeholders := []string{}
+
+		for i, column := range reader.Columns {
+			mapping[i] = -1
+
+			for j, targetColumn := range targetColumns {
+				if strings.EqualFold(column, targetColumn) {
+					mapping[i] = j
+					columns = append(columns, fmt.Sprintf(`"%s"`, targetColumn))
+					placeholders = append(placeholders, "?")
+
+					break
+				}
+			}
+		}
+
+		tx, err := dbCopy.Target.begin(false)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		columns, placeholders, callId, err := partitionTimestamp(tx, table, columns, placeholders)
+		if err != nil {
+			tx.Rollback()
+			rows.Close()
+			return err
+		}
+
+		insert := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+		n := 0
+
+		for {
+			var ok bool
+
+			if ok, err = reader.Next(); err != nil || !ok {
+				break
+			}
+
+			values := []any{}
+
+			for i, v := range reader.Values {
+				j := mapping[i]
+				if j < 0 {
+					continue
+				}
+
+				if targetBools[j] {
+					switch b := v.(type) {
+					case int64:
+						v = b != 0
+					}
+				}
+
+				values = append(values, v)
+			}
+
+			if callId >= 0 {
+				values = append(values, values[callId])
+			}
+
+			if id, ok := reader.Values[0].(int64); ok {
+				lastId = id
+			}
+
+			if _, err = tx.Exec(insert, values...); err != nil {
+				err = fmt.Errorf("%s in %s", err, insert)
+				break
+			}
+
+			n++
+		}
+
+		rows.Close()
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		copied += uint(n)
+
+		dbCopy.Progress(table, copied, total)
+
+		if n < DatabaseCopyBatchSize {
+			break
+		}
+	}
+
+	if dbCopy.Target.Config.DbType == DbTypePostgresql {
+		tx, err := dbCopy.Target.begin(false)
+		if err != nil {
+			return err
+		}
+
+		if err = resetSequence(tx, table, pk); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	return nil
+}
+
+func (dbCopy *DatabaseCopy) verify(tables []string) ([]*DatabaseCopyCount, error) {
+	counts := []*DatabaseCopyCount{}
+
+	for _, table := range tables {
+		count := &DatabaseCopyCount{Table: table}
+
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)
+
+		if err := dbCopy.Source.QueryRow(query).Scan(&count.Source); err != nil {
+			return nil, fmt.Errorf("%s in %s", err, query)
+		}
+
+		if err := dbCopy.Target.QueryRow(query).Scan(&count.Target); err != nil {
+			return nil, fmt.Errorf("%s in %s", err, query)
+		}
+
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
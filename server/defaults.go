@@ -45,10 +45,11 @@ type DefaultApikey struct {
 }
 
 type DefaultDirwatch struct {
-	delay       uint
-	deleteAfter bool
-	disabled    bool
-	kind        string
+	delay        uint
+	deleteAfter  bool
+	disabled     bool
+	kind         string
+	pollInterval uint
 }
 
 type DefaultDownstream struct {
@@ -56,16 +57,28 @@ type DefaultDownstream struct {
 }
 
 type DefaultOptions struct {
+	archiveDays                 uint
+	archiveTablespace           string
 	autoPopulate                bool
 	audioConversion             uint
+	audioProfile                string
+	backupAudio                 bool
+	backupKeep                  uint
+	conversationGap             uint
+	conversationPatches         bool
 	dimmerDelay                 uint
 	disableDuplicateDetection   bool
 	duplicateDetectionTimeFrame uint
+	duplicateHoldTime           uint
+	duplicateResolution         string
 	keypadBeeps                 string
 	maxClients                  uint
 	playbackGoesLive            bool
 	pruneDays                   uint
+	quarantine                  bool
+	quarantineDays              uint
 	showListenersCount          bool
+	showStatistics              bool
 	sortTalkgroups              bool
 	time12hFormat               bool
 }
@@ -82,10 +95,11 @@ var defaults Defaults = Defaults{
 		systems: "*",
 	},
 	dirwatch: DefaultDirwatch{
-		delay:       2000,
-		deleteAfter: true,
-		disabled:    false,
-		kind:        "default",
+		delay:        2000,
+		deleteAfter:  true,
+		disabled:     false,
+		kind:         "default",
+		pollInterval: 5000,
 	},
 	downstream: DefaultDownstream{
 		systems: "*",
@@ -100,16 +114,28 @@ var defaults Defaults = Defaults{
 	},
 	keypadBeeps: "uniden",
 	options: DefaultOptions{
+		archiveDays:                 90,
+		archiveTablespace:           "",
 		audioConversion:             AUDIO_CONVERSION_ENABLED,
+		audioProfile:                AudioProfileDefault,
 		autoPopulate:                true,
+		backupAudio:                 true,
+		backupKeep:                  7,
+		conversationGap:             10000,
+		conversationPatches:         false,
 		dimmerDelay:                 5000,
 		disableDuplicateDetection:   false,
 		duplicateDetectionTimeFrame: 500,
+		duplicateHoldTime:           1500,
+		duplicateResolution:         DuplicateResolutionFirst,
 		keypadBeeps:                 "uniden",
 		maxClients:                  200,
 		playbackGoesLive:            false,
 		pruneDays:                   7,
+		quarantine:                  false,
+		quarantineDays:              7,
 		showListenersCount:          false,
+		showStatistics:              false,
 		sortTalkgroups:              false,
 		time12hFormat:               false,
 	},
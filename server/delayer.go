@@ -42,7 +42,7 @@ func NewDelayer(controller *Controller) *Delayer {
 }
 
 func (delayer *Delayer) CanDelay(call *Call) bool {
-	return delayer.getTimestamp(call).After(time.Now())
+	return delayer.getDelay(call) > 0 && delayer.getTimestamp(call).After(time.Now())
 }
 
 func (delayer *Delayer) Delay(call *Call) {
@@ -59,16 +59,22 @@ func (delayer *Delayer) Delay(call *Call) {
 		remaining := time.Until(timestamp)
 
 		if err := delayer.push(call, timestamp); err == nil {
-			delayer.timers[call.Id] = *time.AfterFunc(remaining, func() {
+			timer := time.AfterFunc(remaining, func() {
 				if err := delayer.pop(call); err != nil {
 					logError(err)
 				}
 
+				delayer.mutex.Lock()
 				delete(delayer.timers, call.Id)
+				delayer.mutex.Unlock()
 
 				delayer.controller.EmitCall(call)
 			})
 
+			delayer.mutex.Lock()
+			delayer.timers[call.Id] = *timer
+			delayer.mutex.Unlock()
+
 		} else {
 			logError(err)
 		}
@@ -92,7 +98,7 @@ func (delayer *Delayer) Start() error {
 	formatError := errorFormatter("delayer", "restore")
 
 	query = `SELECT "callId", "timestamp" from "delayed"`
-	if rows, err = delayer.controller.Database.Sql.Query(query); err != nil {
+	if rows, err = delayer.controller.Database.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -117,7 +123,7 @@ func (delayer *Delayer) Start() error {
 
 	if len(callIds) > 0 {
 		query = `DELETE FROM "delayed"`
-		if _, err = delayer.controller.Database.Sql.Exec(query); err != nil {
+		if _, err = delayer.controller.Database.Exec(query); err != nil {
 			return formatError(err, query)
 		}
 	}
@@ -163,8 +169,8 @@ func (delayer *Delayer) pop(call *Call) error {
 
 	formatError := errorFormatter("delayer", "pop")
 
-	query := fmt.Sprintf(`DELETE FROM "delayed" WHERE "callId" = %d`, call.Id)
-	if _, err := delayer.controller.Database.Sql.Exec(query); err != nil {
+	query := `DELETE FROM "delayed" WHERE "callId" = ?`
+	if _, err := delayer.controller.Database.Exec(query, call.Id); err != nil {
 		return formatError(err, query)
 	}
 
@@ -177,8 +183,8 @@ func (delayer *Delayer) push(call *Call, timestamp time.Time) error {
 
 	formatError := errorFormatter("delayer", "push")
 
-	query := fmt.Sprintf(`INSERT INTO "delayed" ("callId", "timestamp") VALUES (%d, %d)`, call.Id, timestamp.UnixMilli())
-	if _, err := delayer.controller.Database.Sql.Exec(query); err != nil {
+	query := `INSERT INTO "delayed" ("callId", "timestamp") VALUES (?, ?)`
+	if _, err := delayer.controller.Database.Exec(query, call.Id, timestamp.UnixMilli()); err != nil {
 		return formatError(err, query)
 	}
 
@@ -0,0 +1,367 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+
+	DigestAccessNotice    = 7 * 24 * time.Hour
+	DigestDownstreamLimit = 5
+	DigestErrorsLimit     = 10
+	DigestTalkgroupsLimit = 10
+)
+
+// Digest emails a summary of the activity of the server to the address of
+// the options.
+type Digest struct {
+	controller *Controller
+}
+
+func NewDigest(controller *Controller) *Digest {
+	return &Digest{controller: controller}
+}
+
+// Send composes the digest of the period ending now and emails it.
+func (digest *Digest) Send(period string) error {
+	formatError := errorFormatter("digest", "send")
+
+	options := digest.controller.Options
+
+	options.mutex.Lock()
+	recipients := smtpRecipients(options.Email)
+	relay := *options.Smtp
+	options.mutex.Unlock()
+
+	if len(recipients) == 0 {
+		return formatError(errors.New("no email address in the options"), "")
+	}
+
+	if !relay.IsConfigured() {
+		return formatError(errors.New("no smtp relay in the options"), "")
+	}
+
+	subject, body, err := digest.Compose(period, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err = relay.Send(recipients, subject, body); err != nil {
+		return err
+	}
+
+	digest.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("digest: %s digest sent to %s", period, strings.Join(recipients, ", ")))
+
+	return nil
+}
+
+// Compose returns the subject and the text of the digest of the period ending
+// at the given time.
+func (digest *Digest) Compose(period string, end time.Time) (string, string, error) {
+	var (
+		b        strings.Builder
+		db       = digest.controller.Database
+		duration = 24 * time.Hour
+		err      error
+		errors   uint
+		systems  *StatisticsResults
+	)
+
+	if period == DigestWeekly {
+		duration = 7 * duration
+	}
+
+	start := end.Add(-duration)
+
+	fmt.Fprintf(&b, "Rdio Scanner %s digest\n", period)
+	fmt.Fprintf(&b, "%s to %s\n", start.Format(time.RFC1123), end.Format(time.RFC1123))
+
+	statisticsOptions := NewStatisticsOptions()
+	statisticsOptions.By = StatisticsBySystem
+	statisticsOptions.DateEnd = end
+	statisticsOptions.DateStart = start
+	statisticsOptions.Limit = 500
+
+	if systems, err = digest.controller.Statistics.Compute(statisticsOptions, nil, false, db); err != nil {
+		return "", "", err
+	}
+
+	digest.section(&b, "Activity per system")
+	for _, series := range systems.Series {
+		fmt.Fprintf(&b, "  %s (%d): %d calls, %s airtime\n", series.Label, series.System, series.Calls, digestAirtime(series.Airtime))
+	}
+	fmt.Fprintf(&b, "  Total: %d calls, %s airtime\n", systems.Calls, digestAirtime(systems.Airtime))
+
+	if err = digest.talkgroups(&b, statisticsOptions); err != nil {
+		return "", "", err
+	}
+
+	if errors, err = digest.errors(&b, start); err != nil {
+		return "", "", err
+	}
+
+	if err = digest.downstreams(&b, start); err != nil {
+		return "", "", err
+	}
+
+	digest.accesses(&b, end)
+
+	digest.disk(&b)
+
+	subject := fmt.Sprintf("Rdio Scanner %s digest: %d calls, %d errors", period, systems.Calls, errors)
+
+	return subject, b.String(), nil
+}
+
+func (digest *Digest) accesses(b *strings.Builder, end time.Time) {
+	digest.section(b, "Access codes expiring within 7 days")
+
+	count := 0
+
+	accesses := digest.controller.Accesses
+
+	accesses.mutex.Lock()
+	for _, access := range accesses.List {
+		if access.Expiration == 0 {
+			continue
+		}
+
+		expiration := time.Unix(int64(access.Expiration), 0)
+
+		if expiration.After(end.Add(DigestAccessNotice)) {
+			continue
+		}
+
+		if expiration.Before(end) {
+			fmt.Fprintf(b, "  %s: expired %s\n", access.Ident, expiration.Format(time.RFC1123))
+		} else {
+			fmt.Fprintf(b, "  %s: expires %s\n", access.Ident, expiration.Format(time.RFC1123))
+		}
+
+		count++
+	}
+	accesses.mutex.Unlock()
+
+	if count == 0 {
+		b.WriteString("  None\n")
+	}
+}
+
+func (digest *Digest) disk(b *strings.Builder) {
+	var (
+		config = digest.controller.Config
+		size   int64
+	)
+
+	digest.section(b, "Disk usage")
+
+	if dbSize, err := digest.controller.Database.Size(); err == nil {
+		fmt.Fprintf(b, "  Database: %s\n", digestBytes(dbSize))
+	} else {
+		fmt.Fprintf(b, "  Database: %s\n", err.Error())
+	}
+
+	if backups, err := digest.controller.Backups.List(); err == nil {
+		for _, backup := range backups {
+			size += backup.Size
+		}
+		fmt.Fprintf(b, "  Backups: %s in %d files\n", digestBytes(uint64(size)), len(backups))
+	}
+
+	if free, total, err := diskSpace(config.BaseDir); err == nil && total > 0 {
+		fmt.Fprintf(b, "  Free space: %s of %s (%d%%) in %s\n", digestBytes(free), digestBytes(total), free*100/total, config.BaseDir)
+	} else if err != nil {
+		fmt.Fprintf(b, "  Free space: %s\n", err.Error())
+	}
+}
+
+func (digest *Digest) downstreams(b *strings.Builder, start time.Time) error {
+	var (
+		count uint
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("digest", "downstreams")
+
+	db := digest.controller.Database
+
+	digest.section(b, "Downstream failures")
+
+	query = `SELECT COUNT(*) FROM "logs" WHERE "level" = ? AND "timestamp" >= ? AND "message" LIKE 'downstream:%'`
+	if err = db.QueryRow(query, LogLevelError, start.UnixMilli()).Scan(&count); err != nil {
+		return formatError(err, query)
+	}
+
+	if count == 0 {
+		b.WriteString("  None\n")
+		return nil
+	}
+
+	fmt.Fprintf(b, "  %d failures, the latest being:\n", count)
+
+	query = `SELECT "message", "timestamp" FROM "logs" WHERE "level" = ? AND "timestamp" >= ? AND "message" LIKE 'downstream:%' ORDER BY "timestamp" DESC LIMIT ?`
+	if rows, err = db.Query(query, LogLevelError, start.UnixMilli(), DigestDownstreamLimit); err != nil {
+		return formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			message   string
+			timestamp int64
+		)
+
+		if err = rows.Scan(&message, &timestamp); err != nil {
+			break
+		}
+
+		fmt.Fprintf(b, "  %s %s\n", time.UnixMilli(timestamp).Format(time.DateTime), message)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
+
+// errors writes the errors logged during the period, downstream failures
+// aside, the most frequent first, and returns their count.
+func (digest *Digest) errors(b *strings.Builder, start time.Time) (uint, error) {
+	var (
+		count    uint
+		err      error
+		query    string
+		rows     *sql.Rows
+		warnings uint
+	)
+
+	formatError := errorFormatter("digest", "errors")
+
+	db := digest.controller.Database
+
+	digest.section(b, "Errors")
+
+	query = `SELECT COUNT(*) FROM "logs" WHERE "level" = ? AND "timestamp" >= ? AND "message" NOT LIKE 'downstream:%'`
+	if err = db.QueryRow(query, LogLevelError, start.UnixMilli()).Scan(&count); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	query = `SELECT COUNT(*) FROM "logs" WHERE "level" = ? AND "timestamp" >= ?`
+	if err = db.QueryRow(query, LogLevelWarn, start.UnixMilli()).Scan(&warnings); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	fmt.Fprintf(b, "  %d errors and %d warnings logged\n", count, warnings)
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	query = `SELECT "message", COUNT(*) FROM "logs" WHERE "level" = ? AND "timestamp" >= ? AND "message" NOT LIKE 'downstream:%' GROUP BY "message" ORDER BY COUNT(*) DESC, "message" LIMIT ?`
+	if rows, err = db.Query(query, LogLevelError, start.UnixMilli(), DigestErrorsLimit); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			message string
+			times   uint
+		)
+
+		if err = rows.Scan(&message, &times); err != nil {
+			break
+		}
+
+		fmt.Fprintf(b, "  %dx %s\n", times, message)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return 0, formatError(err, "")
+	}
+
+	return count, nil
+}
+
+func (digest *Digest) section(b *strings.Builder, title string) {
+	fmt.Fprintf(b, "\n%s\n%s\n", strings.ToUpper(title), strings.Repeat("-", len(title)))
+}
+
+func (digest *Digest) talkgroups(b *strings.Builder, systemsOptions *StatisticsOptions) error {
+	statisticsOptions := *systemsOptions
+	statisticsOptions.By = StatisticsByTalkgroup
+	statisticsOptions.Limit = DigestTalkgroupsLimit
+
+	results, err := digest.controller.Statistics.Compute(&statisticsOptions, nil, false, digest.controller.Database)
+	if err != nil {
+		return err
+	}
+
+	digest.section(b, "Top talkgroups")
+
+	if len(results.Series) == 0 {
+		b.WriteString("  None\n")
+	}
+
+	for i, series := range results.Series {
+		system := ""
+		if s, ok := digest.controller.Systems.GetSystemByRef(series.System); ok {
+			system = s.Label
+		}
+
+		fmt.Fprintf(b, "  %d. %s / %s (%d): %d calls, %s airtime\n", i+1, system, series.Label, series.Talkgroup, series.Calls, digestAirtime(series.Airtime))
+	}
+
+	return nil
+}
+
+func digestAirtime(ms uint64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
+
+func digestBytes(n uint64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -48,35 +48,69 @@ const (
 	DirwatchTypeTrunkRecorder = "trunk-recorder"
 )
 
+const (
+	DirwatchFileFailed   = "failed"
+	DirwatchFileIgnored  = "ignored"
+	DirwatchFileIngested = "ingested"
+	DirwatchFileQueued   = "queued"
+	DirwatchFileSkipped  = "skipped"
+)
+
+var (
+	errDirwatchIgnored  = errors.New("file ignored")
+	errDirwatchNotReady = errors.New("audio file not ready")
+)
+
 type Dirwatch struct {
-	Id          uint64
-	Delay       uint
-	DeleteAfter bool
-	Directory   string
-	Disabled    bool
-	Extension   string
-	Frequency   uint
-	Kind        string
-	Mask        string
-	Order       uint
-	SiteId      uint64
-	SystemId    uint64
-	TalkgroupId uint64
-	controller  *Controller
-	dirs        map[string]bool
-	mutex       sync.Mutex
-	timers      map[string]*time.Timer
-	watcher     *fsnotify.Watcher
+	Id               uint64
+	ArchiveDirectory string
+	Delay            uint
+	DeleteAfter      bool
+	Directory        string
+	Disabled         bool
+	Extension        string
+	FailedDirectory  string
+	Frequency        uint
+	Kind             string
+	Mask             string
+	Order            uint
+	PollInterval     uint
+	Polling          bool
+	SiteId           uint64
+	SystemId         uint64
+	TalkgroupId      uint64
+	cancel           chan any
+	controller       *Controller
+	dirs             map[string]bool
+	ledger           map[string]*DirwatchFile
+	ledgerMutex      sync.Mutex
+	mutex            sync.Mutex
+	pending          map[string]*DirwatchFile
+	poller           *time.Ticker
+	timers           map[string]*time.Timer
+	watcher          *fsnotify.Watcher
+}
+
+type DirwatchFile struct {
+	Id      uint64
+	ModTime int64
+	Reason  string
+	Size    int64
+	Status  string
 }
 
 func NewDirwatch() *Dirwatch {
 	return &Dirwatch{
-		Delay:       defaults.dirwatch.delay,
-		DeleteAfter: defaults.dirwatch.deleteAfter,
-		Kind:        defaults.dirwatch.kind,
-		dirs:        map[string]bool{},
-		mutex:       sync.Mutex{},
-		timers:      map[string]*time.Timer{},
+		Delay:        defaults.dirwatch.delay,
+		DeleteAfter:  defaults.dirwatch.deleteAfter,
+		Kind:         defaults.dirwatch.kind,
+		PollInterval: defaults.dirwatch.pollInterval,
+		dirs:         map[string]bool{},
+		ledger:       map[string]*DirwatchFile{},
+		ledgerMutex:  sync.Mutex{},
+		mutex:        sync.Mutex{},
+		pending:      map[string]*DirwatchFile{},
+		timers:       map[string]*time.Timer{},
 	}
 }
 
@@ -86,6 +120,11 @@ func (dirwatch *Dirwatch) FromMap(m map[string]any) *Dirwatch {
 		dirwatch.Id = uint64(v)
 	}
 
+	switch v := m["archiveDirectory"].(type) {
+	case string:
+		dirwatch.ArchiveDirectory = v
+	}
+
 	switch v := m["delay"].(type) {
 	case float64:
 		dirwatch.Delay = uint(v)
@@ -111,6 +150,11 @@ func (dirwatch *Dirwatch) FromMap(m map[string]any) *Dirwatch {
 		dirwatch.Extension = v
 	}
 
+	switch v := m["failedDirectory"].(type) {
+	case string:
+		dirwatch.FailedDirectory = v
+	}
+
 	switch v := m["frequency"].(type) {
 	case float64:
 		dirwatch.Frequency = uint(v)
@@ -131,6 +175,16 @@ func (dirwatch *Dirwatch) FromMap(m map[string]any) *Dirwatch {
 		dirwatch.Order = uint(v)
 	}
 
+	switch v := m["pollInterval"].(type) {
+	case float64:
+		dirwatch.PollInterval = uint(v)
+	}
+
+	switch v := m["polling"].(type) {
+	case bool:
+		dirwatch.Polling = v
+	}
+
 	switch v := m["siteId"].(type) {
 	case float64:
 		dirwatch.SiteId = uint64(v)
@@ -149,7 +203,7 @@ func (dirwatch *Dirwatch) FromMap(m map[string]any) *Dirwatch {
 	return dirwatch
 }
 
-func (dirwatch *Dirwatch) Ingest(p string) {
+func (dirwatch *Dirwatch) Ingest(p string) error {
 	var err error
 
 	switch dirwatch.Kind {
@@ -163,9 +217,23 @@ func (dirwatch *Dirwatch) Ingest(p string) {
 		err = dirwatch.ingestDefault(p)
 	}
 
-	if err != nil {
-		dirwatch.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("dirwatch.ingest: %s, %s", err.Error(), p))
+	switch {
+	case err == nil, errors.Is(err, errDirwatchNotReady):
+		return err
+
+	case errors.Is(err, errDirwatchIgnored):
+		if fi, err := os.Stat(p); err == nil {
+			dirwatch.setLedgerFile(p, fi, DirwatchFileIgnored, "")
+		}
+
+		return err
 	}
+
+	dirwatch.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("dirwatch.ingest: %s, %s", err.Error(), p))
+
+	dirwatch.settle(dirwatch.getFiles(p), err, false)
+
+	return err
 }
 
 func (dirwatch *Dirwatch) ingestDefault(p string) error {
@@ -180,49 +248,44 @@ func (dirwatch *Dirwatch) ingestDefault(p string) error {
 		ext = ".wav"
 	}
 
-	if strings.EqualFold(path.Ext(p), ext) {
-		call := NewCall()
-
-		call.AudioFilename = filepath.Base(p)
-		call.AudioMime = mime.TypeByExtension(path.Ext(p))
-		call.Timestamp = time.Now().UTC()
+	if !strings.EqualFold(path.Ext(p), ext) {
+		return errDirwatchIgnored
+	}
 
-		if dirwatch.Frequency > 0 {
-			call.Frequencies = append(call.Frequencies, CallFrequency{
-				Frequency: dirwatch.Frequency,
-				Offset:    0,
-			})
-		}
+	call := NewCall()
 
-		if call.Audio, err = os.ReadFile(p); err != nil {
-			return err
-		}
+	call.AudioFilename = filepath.Base(p)
+	call.AudioMime = mime.TypeByExtension(path.Ext(p))
+	call.Timestamp = time.Now().UTC()
 
-		dirwatch.parseMask(call)
+	if dirwatch.Frequency > 0 {
+		call.Frequencies = append(call.Frequencies, CallFrequency{
+			Frequency: dirwatch.Frequency,
+			Offset:    0,
+		})
+	}
 
-		if dirwatch.SystemId > 0 {
-			call.Meta.SystemId = dirwatch.SystemId
-		}
+	if call.Audio, err = os.ReadFile(p); err != nil {
+		return err
+	}
 
-		if dirwatch.TalkgroupId > 0 {
-			call.Meta.TalkgroupId = dirwatch.TalkgroupId
-		}
+	dirwatch.parseMask(call)
 
-		if ok, err := call.IsValid(); ok {
-			dirwatch.controller.Ingest <- call
+	if dirwatch.SystemId > 0 {
+		call.Meta.SystemId = dirwatch.SystemId
+	}
 
-			if dirwatch.DeleteAfter {
-				if err = os.Remove(p); err != nil {
-					return err
-				}
-			}
+	if dirwatch.TalkgroupId > 0 {
+		call.Meta.TalkgroupId = dirwatch.TalkgroupId
+	}
 
-		} else {
-			return err
-		}
+	if ok, err := call.IsValid(); !ok {
+		return err
 	}
 
-	return err
+	dirwatch.push(call, p)
+
+	return nil
 }
 
 func (dirwatch *Dirwatch) ingestDSDPlus(p string) error {
@@ -238,7 +301,7 @@ func (dirwatch *Dirwatch) ingestDSDPlus(p string) error {
 	}
 
 	if !strings.EqualFold(path.Ext(p), ext) {
-		return nil
+		return errDirwatchIgnored
 	}
 
 	call := NewCall()
@@ -273,19 +336,12 @@ func (dirwatch *Dirwatch) ingestDSDPlus(p string) error {
 		return err
 	}
 
-	if ok, err := call.IsValid(); ok {
-		dirwatch.controller.Ingest <- call
-
-		if dirwatch.DeleteAfter {
-			if err = os.Remove(p); err != nil {
-				return err
-			}
-		}
-
-	} else {
+	if ok, err := call.IsValid(); !ok {
 		return err
 	}
 
+	dirwatch.push(call, p)
+
 	return nil
 }
 
@@ -293,7 +349,7 @@ func (dirwatch *Dirwatch) ingestSdrTrunk(p string) error {
 	var err error
 
 	if !strings.EqualFold(path.Ext(p), ".mp3") {
-		return nil
+		return errDirwatchIgnored
 	}
 
 	call := NewCall()
@@ -316,19 +372,12 @@ func (dirwatch *Dirwatch) ingestSdrTrunk(p string) error {
 		return err
 	}
 
-	if ok, err := call.IsValid(); ok {
-		dirwatch.controller.Ingest <- call
-
-		if dirwatch.DeleteAfter {
-			if err = os.Remove(p); err != nil {
-				return err
-			}
-		}
-
-	} else {
+	if ok, err := call.IsValid(); !ok {
 		return err
 	}
 
+	dirwatch.push(call, p)
+
 	return nil
 }
 
@@ -336,22 +385,13 @@ func (dirwatch *Dirwatch) ingestTrunkRecorder(p string) error {
 	var (
 		b   []byte
 		err error
-		ext string
 	)
 
 	if !strings.EqualFold(path.Ext(p), ".json") {
-		return nil
+		return errDirwatchIgnored
 	}
 
-	if len(dirwatch.Extension) > 0 {
-		ext = fmt.Sprintf(".%s", dirwatch.Extension)
-	} else {
-		ext = ".wav"
-	}
-
-	base := strings.TrimSuffix(p, ".json")
-
-	audioName := base + ext
+	audioName := dirwatch.getTrunkRecorderAudioName(p)
 
 	call := NewCall()
 
@@ -366,7 +406,7 @@ func (dirwatch *Dirwatch) ingestTrunkRecorder(p string) error {
 	}
 
 	if call.Audio, err = os.ReadFile(audioName); err != nil {
-		return nil
+		return errDirwatchNotReady
 	}
 
 	if b, err = os.ReadFile(p); err != nil {
@@ -377,21 +417,11 @@ func (dirwatch *Dirwatch) ingestTrunkRecorder(p string) error {
 		return err
 	}
 
-	if ok, err := call.IsValid(); ok {
-		dirwatch.controller.Ingest <- call
-
-	} else {
+	if ok, err := call.IsValid(); !ok {
 		return err
 	}
 
-	if dirwatch.DeleteAfter {
-		if err = os.Remove(p); err != nil {
-			return err
-		}
-		if err = os.Remove(audioName); err != nil {
-			return err
-		}
-	}
+	dirwatch.push(call, p, audioName)
 
 	return nil
 }
@@ -405,10 +435,18 @@ func (dirwatch *Dirwatch) MarshalJSON() ([]byte, error) {
 		"disabled":    dirwatch.Disabled,
 	}
 
+	if len(dirwatch.ArchiveDirectory) > 0 {
+		m["archiveDirectory"] = dirwatch.ArchiveDirectory
+	}
+
 	if len(dirwatch.Extension) > 0 {
 		m["extension"] = dirwatch.Extension
 	}
 
+	if len(dirwatch.FailedDirectory) > 0 {
+		m["failedDirectory"] = dirwatch.FailedDirectory
+	}
+
 	if dirwatch.Frequency > 0 {
 		m["frequency"] = dirwatch.Frequency
 	}
@@ -425,6 +463,14 @@ func (dirwatch *Dirwatch) MarshalJSON() ([]byte, error) {
 		m["order"] = dirwatch.Order
 	}
 
+	if dirwatch.PollInterval > 0 {
+		m["pollInterval"] = dirwatch.PollInterval
+	}
+
+	if dirwatch.Polling {
+		m["polling"] = dirwatch.Polling
+	}
+
 	if dirwatch.SiteId > 0 {
 		m["siteId"] = dirwatch.SiteId
 	}
@@ -692,13 +738,21 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 		return nil
 	}
 
-	if dirwatch.watcher != nil {
+	if dirwatch.watcher != nil || dirwatch.poller != nil {
 		return errors.New("dirwatch.start: already started")
 	}
 
 	dirwatch.controller = controller
 	dirwatch.dirs = map[string]bool{}
 
+	if err = dirwatch.readLedger(); err != nil {
+		return err
+	}
+
+	if dirwatch.Polling {
+		return dirwatch.startPolling()
+	}
+
 	if dirwatch.watcher, err = fsnotify.NewWatcher(); err != nil {
 		return err
 	}
@@ -751,6 +805,10 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 					return
 				}
 
+				if dirwatch.isExcluded(event.Name) {
+					continue
+				}
+
 				switch event.Op {
 				case fsnotify.Create:
 					if dirwatch.isDir(event.Name) {
@@ -796,6 +854,12 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 	}()
 
 	go func() {
+		var (
+			files = map[string]fs.FileInfo{}
+			seed  = !dirwatch.DeleteAfter && len(dirwatch.ArchiveDirectory) == 0 && len(dirwatch.ledger) == 0
+			seen  = map[string]bool{}
+		)
+
 		defer func() {
 			switch v := recover().(type) {
 			case error:
@@ -805,20 +869,60 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 
 		time.Sleep(delay)
 
-		if err := fs.WalkDir(os.DirFS(dirwatch.Directory), ".", func(p string, _ fs.DirEntry, err error) error {
+		if err := fs.WalkDir(os.DirFS(dirwatch.Directory), ".", func(p string, d fs.DirEntry, err error) error {
 			fp := filepath.Join(dirwatch.Directory, p)
 
+			if err != nil {
+				return err
+			}
+
+			if dirwatch.isExcluded(fp) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
 			if dirwatch.isDir(fp) {
 				dirwatch.dirs[fp] = true
 				dirwatch.watcher.Add(fp)
+				return nil
+			}
+
+			fi, err := d.Info()
+			if err != nil || !fi.Mode().IsRegular() {
+				return nil
+			}
+
+			seen[fp] = true
 
-			} else if dirwatch.DeleteAfter {
+			switch {
+			case dirwatch.DeleteAfter:
 				dirwatch.Ingest(fp)
+
+			case seed:
+				files[fp] = fi
+
+			default:
+				if f := dirwatch.getLedgerFile(fp); f == nil || f.ModTime != fi.ModTime().UnixMilli() || f.Size != fi.Size() {
+					dirwatch.Ingest(fp)
+				}
 			}
 
-			return err
+			return nil
 		}); err != nil {
 			controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.walkdir: %s", err.Error()))
+			return
+		}
+
+		if seed {
+			if err := dirwatch.seedLedger(files); err != nil {
+				controller.Logs.LogEvent(LogLevelError, err.Error())
+			}
+		}
+
+		if err := dirwatch.pruneLedger(seen); err != nil {
+			controller.Logs.LogEvent(LogLevelError, err.Error())
 		}
 	}()
 
@@ -831,6 +935,525 @@ func (dirwatch *Dirwatch) Stop() {
 		dirwatch.watcher = nil
 		w.Close()
 	}
+
+	if dirwatch.poller != nil {
+		dirwatch.poller.Stop()
+		dirwatch.poller = nil
+		close(dirwatch.cancel)
+	}
+}
+
+func (dirwatch *Dirwatch) forgetLedgerFile(p string) error {
+	dirwatch.ledgerMutex.Lock()
+	defer dirwatch.ledgerMutex.Unlock()
+
+	if _, ok := dirwatch.ledger[p]; !ok {
+		return nil
+	}
+
+	delete(dirwatch.ledger, p)
+
+	if dirwatch.Id == 0 {
+		return nil
+	}
+
+	formatError := errorFormatter("dirwatch", "forgetledgerfile")
+
+	query := `DELETE FROM "dirwatchFiles" WHERE "dirwatchId" = ? AND "path" = ?`
+	if _, err := dirwatch.controller.Database.Exec(query, dirwatch.Id, p); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) getFiles(p string) []string {
+	files := []string{p}
+
+	if dirwatch.Kind == DirwatchTypeTrunkRecorder && strings.EqualFold(path.Ext(p), ".json") {
+		files = append(files, dirwatch.getTrunkRecorderAudioName(p))
+	}
+
+	return files
+}
+
+func (dirwatch *Dirwatch) getLedgerFile(p string) *DirwatchFile {
+	dirwatch.ledgerMutex.Lock()
+	defer dirwatch.ledgerMutex.Unlock()
+
+	return dirwatch.ledger[p]
+}
+
+func (dirwatch *Dirwatch) getTrunkRecorderAudioName(p string) string {
+	base := strings.TrimSuffix(p, path.Ext(p))
+
+	if len(dirwatch.Extension) > 0 {
+		return fmt.Sprintf("%s.%s", base, dirwatch.Extension)
+	}
+
+	return fmt.Sprintf("%s.wav", base)
+}
+
+func (dirwatch *Dirwatch) isExcluded(p string) bool {
+	for _, d := range []string{dirwatch.ArchiveDirectory, dirwatch.FailedDirectory} {
+		if d = dirwatch.resolveDirectory(d); len(d) == 0 {
+			continue
+		}
+
+		if rel, err := filepath.Rel(d, p); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (dirwatch *Dirwatch) moveFile(p string, d string) (string, error) {
+	rel, err := filepath.Rel(dirwatch.Directory, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(p)
+	}
+
+	dest := filepath.Join(d, rel)
+
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dest); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dest = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+
+	if err = os.Rename(p, dest); err == nil {
+		return dest, nil
+	}
+
+	// rename fails across filesystems, fall back to copy and remove
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.WriteFile(dest, b, 0644); err != nil {
+		return "", err
+	}
+
+	return dest, os.Remove(p)
+}
+
+// poll walks the directory once, ingesting the files whose size and
+// modification time are unchanged since the previous poll, the clock of a
+// network share not being comparable to ours. When seeding, the files found
+// are recorded as skipped instead. It returns whether the walk completed.
+func (dirwatch *Dirwatch) poll(cancel chan any, seed bool) bool {
+	var (
+		cancelled bool
+		files     = map[string]fs.FileInfo{}
+		seen      = map[string]bool{}
+	)
+
+	logError := func(err error) {
+		dirwatch.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.poll: %s", err.Error()))
+	}
+
+	err := filepath.WalkDir(dirwatch.Directory, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-cancel:
+			cancelled = true
+			return fs.SkipAll
+		default:
+		}
+
+		if dirwatch.isExcluded(p) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		seen[p] = true
+
+		if seed {
+			files[p] = fi
+			return nil
+		}
+
+		file := &DirwatchFile{ModTime: fi.ModTime().UnixMilli(), Size: fi.Size()}
+
+		if f := dirwatch.getLedgerFile(p); f != nil && f.ModTime == file.ModTime && f.Size == file.Size {
+			return nil
+		}
+
+		if f, ok := dirwatch.pending[p]; !ok || f.ModTime != file.ModTime || f.Size != file.Size {
+			dirwatch.pending[p] = file
+			return nil
+		}
+
+		delete(dirwatch.pending, p)
+
+		dirwatch.Ingest(p)
+
+		return nil
+	})
+
+	if err != nil {
+		logError(err)
+		return false
+	}
+
+	if cancelled {
+		return false
+	}
+
+	if seed {
+		if err := dirwatch.seedLedger(files); err != nil {
+			logError(err)
+		}
+	}
+
+	for p := range dirwatch.pending {
+		if !seen[p] {
+			delete(dirwatch.pending, p)
+		}
+	}
+
+	if err := dirwatch.pruneLedger(seen); err != nil {
+		logError(err)
+	}
+
+	return true
+}
+
+func (dirwatch *Dirwatch) pruneLedger(seen map[string]bool) error {
+	ids := []uint64{}
+
+	dirwatch.ledgerMutex.Lock()
+	for p, f := range dirwatch.ledger {
+		if !seen[p] && f.Status != DirwatchFileQueued {
+			if f.Id > 0 {
+				ids = append(ids, f.Id)
+			}
+			delete(dirwatch.ledger, p)
+		}
+	}
+	dirwatch.ledgerMutex.Unlock()
+
+	if len(ids) == 0 || dirwatch.Id == 0 {
+		return nil
+	}
+
+	formatError := errorFormatter("dirwatch", "pruneledger")
+
+	in, args := sqlIn(ids)
+	query := fmt.Sprintf(`DELETE FROM "dirwatchFiles" WHERE "dirwatchFileId" IN %s`, in)
+	if _, err := dirwatch.controller.Database.Exec(query, args...); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) push(call *Call, files ...string) {
+	for _, p := range files {
+		if fi, err := os.Stat(p); err == nil {
+			dirwatch.setLedgerFile(p, fi, DirwatchFileQueued, "")
+		}
+	}
+
+	call.ingested = func(err error) {
+		dirwatch.settle(files, err, dirwatch.DeleteAfter)
+	}
+
+	call.Source = fmt.Sprintf("dirwatch:%s", dirwatch.Directory)
+
+	dirwatch.controller.Ingest <- call
+}
+
+func (dirwatch *Dirwatch) readLedger() error {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	dirwatch.ledgerMutex.Lock()
+	defer dirwatch.ledgerMutex.Unlock()
+
+	dirwatch.ledger = map[string]*DirwatchFile{}
+
+	if dirwatch.Id == 0 {
+		return nil
+	}
+
+	formatError := errorFormatter("dirwatch", "readledger")
+
+	query = `SELECT "dirwatchFileId", "modTime", "path", "reason", "size", "status" FROM "dirwatchFiles" WHERE "dirwatchId" = ?`
+	if rows, err = dirwatch.controller.Database.Query(query, dirwatch.Id); err != nil {
+		return formatError(err, query)
+	}
+
+	for rows.Next() {
+		var p string
+
+		file := &DirwatchFile{}
+
+		if err = rows.Scan(&file.Id, &file.ModTime, &p, &file.Reason, &file.Size, &file.Status); err != nil {
+			break
+		}
+
+		dirwatch.ledger[p] = file
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) resolveDirectory(d string) string {
+	if len(d) == 0 || filepath.IsAbs(d) {
+		return d
+	}
+
+	return filepath.Join(dirwatch.Directory, d)
+}
+
+func (dirwatch *Dirwatch) seedLedger(files map[string]fs.FileInfo) error {
+	var (
+		err   error
+		query string
+		tx    *StorageTx
+	)
+
+	dirwatch.ledgerMutex.Lock()
+	defer dirwatch.ledgerMutex.Unlock()
+
+	for p, fi := range files {
+		dirwatch.ledger[p] = &DirwatchFile{ModTime: fi.ModTime().UnixMilli(), Size: fi.Size(), Status: DirwatchFileSkipped}
+	}
+
+	if dirwatch.Id == 0 || len(files) == 0 {
+		return nil
+	}
+
+	formatError := errorFormatter("dirwatch", "seedledger")
+
+	if tx, err = dirwatch.controller.Database.Begin(); err != nil {
+		return formatError(err, "")
+	}
+
+	for p, f := range dirwatch.ledger {
+		if _, ok := files[p]; !ok {
+			continue
+		}
+
+		query = `INSERT INTO "dirwatchFiles" ("dirwatchId", "modTime", "path", "reason", "size", "status", "timestamp") VALUES (?, ?, ?, '', ?, ?, ?)`
+		if _, err = tx.Exec(query, dirwatch.Id, f.ModTime, p, f.Size, f.Status, time.Now().UnixMilli()); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) setLedgerFile(p string, fi fs.FileInfo, status string, reason string) error {
+	dirwatch.ledgerMutex.Lock()
+	defer dirwatch.ledgerMutex.Unlock()
+
+	file := &DirwatchFile{ModTime: fi.ModTime().UnixMilli(), Reason: reason, Size: fi.Size(), Status: status}
+
+	if f, ok := dirwatch.ledger[p]; ok {
+		file.Id = f.Id
+	}
+
+	dirwatch.ledger[p] = file
+
+	switch status {
+	case DirwatchFileIgnored, DirwatchFileQueued:
+		return nil
+	}
+
+	return dirwatch.writeLedger(p, file)
+}
+
+func (dirwatch *Dirwatch) settle(files []string, err error, remove bool) {
+	var (
+		d      string
+		reason string
+		status string
+	)
+
+	logError := func(err error) {
+		dirwatch.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.settle: %s", err.Error()))
+	}
+
+	// the server is stopping, the files stay in place for the next run
+	if errors.Is(err, ErrIngestStopped) {
+		for _, p := range files {
+			if err := dirwatch.forgetLedgerFile(p); err != nil {
+				logError(err)
+			}
+		}
+		return
+	}
+
+	if err == nil {
+		d = dirwatch.resolveDirectory(dirwatch.ArchiveDirectory)
+		status = DirwatchFileIngested
+	} else {
+		d = dirwatch.resolveDirectory(dirwatch.FailedDirectory)
+		reason = err.Error()
+		status = DirwatchFileFailed
+	}
+
+	for i, p := range files {
+		fi, statErr := os.Stat(p)
+		if statErr != nil {
+			if err := dirwatch.forgetLedgerFile(p); err != nil {
+				logError(err)
+			}
+			continue
+		}
+
+		switch {
+		case len(d) > 0:
+			dest, moveErr := dirwatch.moveFile(p, d)
+			if moveErr != nil {
+				logError(moveErr)
+				if err := dirwatch.setLedgerFile(p, fi, status, reason); err != nil {
+					logError(err)
+				}
+				continue
+			}
+
+			if len(reason) > 0 && i == 0 {
+				if err := os.WriteFile(fmt.Sprintf("%s.reason.txt", dest), []byte(reason+"\n"), 0644); err != nil {
+					logError(err)
+				}
+			}
+
+			if err := dirwatch.forgetLedgerFile(p); err != nil {
+				logError(err)
+			}
+
+		case remove:
+			if err := os.Remove(p); err != nil {
+				logError(err)
+			}
+
+			if err := dirwatch.forgetLedgerFile(p); err != nil {
+				logError(err)
+			}
+
+		default:
+			if err := dirwatch.setLedgerFile(p, fi, status, reason); err != nil {
+				logError(err)
+			}
+		}
+	}
+}
+
+func (dirwatch *Dirwatch) startPolling() error {
+	var interval time.Duration
+
+	if dirwatch.PollInterval > 0 {
+		interval = time.Duration(math.Max(float64(dirwatch.PollInterval), 1000)) * time.Millisecond
+	} else {
+		interval = time.Duration(defaults.dirwatch.pollInterval) * time.Millisecond
+	}
+
+	dirwatch.cancel = make(chan any)
+	dirwatch.pending = map[string]*DirwatchFile{}
+	dirwatch.poller = time.NewTicker(interval)
+
+	// like the first walk of the watcher, the first poll records the files
+	// already there as skipped when nothing would tell them apart later
+	seed := !dirwatch.DeleteAfter && len(dirwatch.ArchiveDirectory) == 0 && len(dirwatch.ledger) == 0
+
+	go func(poller *time.Ticker, cancel chan any) {
+		defer func() {
+			switch v := recover().(type) {
+			case error:
+				dirwatch.controller.Logs.LogEvent(LogLevelError, v.Error())
+			}
+		}()
+
+		for {
+			select {
+			case <-cancel:
+				return
+			case <-poller.C:
+				if dirwatch.poll(cancel, seed) {
+					seed = false
+				}
+			}
+		}
+	}(dirwatch.poller, dirwatch.cancel)
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) writeLedger(p string, file *DirwatchFile) error {
+	var (
+		err   error
+		query string
+	)
+
+	if dirwatch.Id == 0 {
+		return nil
+	}
+
+	db := dirwatch.controller.Database
+
+	formatError := errorFormatter("dirwatch", "writeledger")
+
+	if file.Id > 0 {
+		query = `UPDATE "dirwatchFiles" SET "modTime" = ?, "reason" = ?, "size" = ?, "status" = ?, "timestamp" = ? WHERE "dirwatchFileId" = ?`
+		if _, err = db.Exec(query, file.ModTime, file.Reason, file.Size, file.Status, time.Now().UnixMilli(), file.Id); err != nil {
+			return formatError(err, query)
+		}
+
+		return nil
+	}
+
+	// a seeded entry has no id yet, make sure it is not recorded twice
+	query = `DELETE FROM "dirwatchFiles" WHERE "dirwatchId" = ? AND "path" = ?`
+	if _, err = db.Exec(query, dirwatch.Id, p); err != nil {
+		return formatError(err, query)
+	}
+
+	query = `INSERT INTO "dirwatchFiles" ("dirwatchId", "modTime", "path", "reason", "size", "status", "timestamp") VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if file.Id, err = db.Insert(query, "dirwatchFileId", dirwatch.Id, file.ModTime, p, file.Reason, file.Size, file.Status, time.Now().UnixMilli()); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
 }
 
 func (dirwatch *Dirwatch) isDir(d string) bool {
@@ -849,6 +1472,9 @@ func (dirwatch *Dirwatch) walkDir(d string) error {
 	return fs.WalkDir(dfs, ".", func(p string, _ fs.DirEntry, err error) error {
 		fp := filepath.Join(d, p)
 		if dirwatch.isDir(fp) {
+			if dirwatch.isExcluded(fp) {
+				return fs.SkipDir
+			}
 			if !dirwatch.dirs[fp] {
 				dirwatch.dirs[fp] = true
 				dirwatch.watcher.Add(fp)
@@ -889,7 +1515,7 @@ func (dirwatches *Dirwatches) FromMap(f []any) *Dirwatches {
 	return dirwatches
 }
 
-func (dirwatches *Dirwatches) Read(db *Database) error {
+func (dirwatches *Dirwatches) Read(db Storage) error {
 	var (
 		err   error
 		query string
@@ -905,15 +1531,15 @@ func (dirwatches *Dirwatches) Read(db *Database) error {
 
 	formatError := dirwatches.errorFormatter("read")
 
-	query = `SELECT "dirwatchId", "delay", "deleteAfter", "directory", "disabled", "extension", "frequency", "mask", "order", "siteId", "systemId", "talkgroupId", "type" FROM "dirwatches"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	query = `SELECT "dirwatchId", "archiveDirectory", "delay", "deleteAfter", "directory", "disabled", "extension", "failedDirectory", "frequency", "mask", "order", "pollInterval", "polling", "siteId", "systemId", "talkgroupId", "type" FROM "dirwatches"`
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
 	for rows.Next() {
 		dirwatch := NewDirwatch()
 
-		if err = rows.Scan(&dirwatch.Id, &dirwatch.Delay, &dirwatch.DeleteAfter, &dirwatch.Directory, &dirwatch.Disabled, &dirwatch.Extension, &dirwatch.Frequency, &dirwatch.Mask, &dirwatch.Order, &dirwatch.SiteId, &dirwatch.SystemId, &dirwatch.TalkgroupId, &dirwatch.Kind); err != nil {
+		if err = rows.Scan(&dirwatch.Id, &dirwatch.ArchiveDirectory, &dirwatch.Delay, &dirwatch.DeleteAfter, &dirwatch.Directory, &dirwatch.Disabled, &dirwatch.Extension, &dirwatch.FailedDirectory, &dirwatch.Frequency, &dirwatch.Mask, &dirwatch.Order, &dirwatch.PollInterval, &dirwatch.Polling, &dirwatch.SiteId, &dirwatch.SystemId, &dirwatch.TalkgroupId, &dirwatch.Kind); err != nil {
 			break
 		}
 
@@ -948,13 +1574,13 @@ func (dirwatches *Dirwatches) Stop() {
 	dirwatches.List = []*Dirwatch{}
 }
 
-func (dirwatches *Dirwatches) Write(db *Database) error {
+func (dirwatches *Dirwatches) Write(db Storage) error {
 	var (
 		dirwatchIds = []uint64{}
 		err         error
 		query       string
 		rows        *sql.Rows
-		tx          *sql.Tx
+		tx          *StorageTx
 	)
 
 	dirwatches.mutex.Lock()
@@ -962,7 +1588,7 @@ func (dirwatches *Dirwatches) Write(db *Database) error {
 
 	formatError := dirwatches.errorFormatter("write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -997,33 +1623,31 @@ func (dirwatches *Dirwatches) Write(db *Database) error {
 	}
 
 	if len(dirwatchIds) > 0 {
-		if b, err := json.Marshal(dirwatchIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "dirwatches" WHERE "dirwatchId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
+		in, args := sqlIn(dirwatchIds)
+		query = fmt.Sprintf(`DELETE FROM "dirwatches" WHERE "dirwatchId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
 		}
 	}
 
 	for _, dirwatch := range dirwatches.List {
 		var count uint
 
-		query = fmt.Sprintf(`SELECT COUNT(*) FROM "dirwatches" WHERE "dirwatchId" = %d`, dirwatch.Id)
-		if err = tx.QueryRow(query).Scan(&count); err != nil {
+		query = `SELECT COUNT(*) FROM "dirwatches" WHERE "dirwatchId" = ?`
+		if err = tx.QueryRow(query, dirwatch.Id).Scan(&count); err != nil {
 			break
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "dirwatches" ("delay", "deleteAfter", "directory", "disabled", "extension", "frequency", "mask", "order", "siteId", "systemId", "talkgroupId", "type") VALUES (%d, %t, '%s', %t, '%s', %d, '%s', %d, %d, %d, %d, '%s')`, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.SiteId, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "dirwatches" ("archiveDirectory", "delay", "deleteAfter", "directory", "disabled", "extension", "failedDirectory", "frequency", "mask", "order", "pollInterval", "polling", "siteId", "systemId", "talkgroupId", "type") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			if _, err = tx.Exec(query, dirwatch.ArchiveDirectory, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.FailedDirectory, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.PollInterval, dirwatch.Polling, dirwatch.SiteId, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "dirwatches" SET "delay" = %d, "deleteAfter" = %t, "directory" = '%s', "disabled" = %t, "extension" = '%s', "frequency" = %d, "mask" = '%s', "order" = %d, "siteId" = %d, "systemId" = %d, "talkgroupId" = %d, "type" = '%s' WHERE "dirwatchId" = %d`, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.SiteId, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind, dirwatch.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "dirwatches" SET "archiveDirectory" = ?, "delay" = ?, "deleteAfter" = ?, "directory" = ?, "disabled" = ?, "extension" = ?, "failedDirectory" = ?, "frequency" = ?, "mask" = ?, "order" = ?, "pollInterval" = ?, "polling" = ?, "siteId" = ?, "systemId" = ?, "talkgroupId" = ?, "type" = ? WHERE "dirwatchId" = ?`
+			if _, err = tx.Exec(query, dirwatch.ArchiveDirectory, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.FailedDirectory, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.PollInterval, dirwatch.Polling, dirwatch.SiteId, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind, dirwatch.Id); err != nil {
 				break
 			}
 		}
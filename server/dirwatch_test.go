@@ -0,0 +1,182 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirwatchKeepsFilesOnShutdown(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		deleteAfter bool
+		failed      string
+	}{
+		{name: "failed directory", failed: "failed"},
+		{name: "delete after", deleteAfter: true},
+		{name: "ledger only"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewController(&Config{BaseDir: t.TempDir(), DbFile: "rdio-scanner.db", DbType: DbTypeSqlite})
+			defer controller.Database.Close()
+
+			controller.Options.DuplicateHoldTime = 60000
+
+			dir := t.TempDir()
+
+			dirwatch := NewDirwatch()
+			dirwatch.controller = controller
+			dirwatch.DeleteAfter = tc.deleteAfter
+			dirwatch.Directory = dir
+			dirwatch.FailedDirectory = tc.failed
+
+			id, err := controller.Database.Insert(`INSERT INTO "dirwatches" ("directory") VALUES (?)`, "dirwatchId", dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dirwatch.Id = id
+
+			files := []string{}
+			for i := range 3 {
+				p := filepath.Join(dir, fmt.Sprintf("call%d.wav", i))
+				if err := os.WriteFile(p, []byte("audio"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, p)
+
+				call := NewCall()
+				call.System = &System{Id: 1}
+				call.Talkgroup = &Talkgroup{Id: 1}
+
+				dirwatch.push(call, p)
+			}
+
+			// one call held by the simulcast resolver, one being processed
+			// and one dispatched once the ingester is stopped
+			held, processed, dispatched := <-controller.Ingest, <-controller.Ingest, <-controller.Ingest
+
+			controller.Simulcast.Hold(held)
+			controller.Ingester.Stop()
+			controller.Simulcast.Stop()
+			controller.ProcessCall(controller.Ingester.ctx, processed)
+			controller.Ingester.Dispatch(dispatched)
+
+			for _, p := range files {
+				if _, err := os.Stat(p); err != nil {
+					t.Errorf("%s was not kept: %v", p, err)
+				}
+
+				if f := dirwatch.getLedgerFile(p); f != nil {
+					t.Errorf("%s is still in the ledger as %s", p, f.Status)
+				}
+			}
+
+			if len(tc.failed) > 0 {
+				if entries, err := os.ReadDir(dirwatch.resolveDirectory(tc.failed)); err == nil && len(entries) > 0 {
+					t.Errorf("%d files moved to the failed directory", len(entries))
+				}
+			}
+
+			var count int
+			if err := controller.Database.QueryRow(`SELECT COUNT(*) FROM "dirwatchFiles" WHERE "dirwatchId" = ?`, id).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count > 0 {
+				t.Errorf("%d files recorded in the ledger", count)
+			}
+		})
+	}
+}
+
+func TestDirwatchPoll(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		ahead time.Duration
+		grow  bool
+		polls int
+		seed  bool
+		want  string
+	}{
+		{name: "seeded", polls: 1, seed: true, want: DirwatchFileSkipped},
+		{name: "first poll", polls: 1},
+		{name: "unchanged", polls: 2, want: DirwatchFileIgnored},
+		{name: "share clock ahead", ahead: time.Hour, polls: 2, want: DirwatchFileIgnored},
+		{name: "still written", grow: true, polls: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewController(&Config{BaseDir: t.TempDir(), DbFile: "rdio-scanner.db", DbType: DbTypeSqlite})
+			defer controller.Database.Close()
+
+			dir := t.TempDir()
+
+			dirwatch := NewDirwatch()
+			dirwatch.controller = controller
+			dirwatch.Directory = dir
+			dirwatch.pending = map[string]*DirwatchFile{}
+
+			id, err := controller.Database.Insert(`INSERT INTO "dirwatches" ("directory") VALUES (?)`, "dirwatchId", dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dirwatch.Id = id
+
+			p := filepath.Join(dir, "call.txt")
+			if err := os.WriteFile(p, []byte("audio"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.ahead > 0 {
+				mtime := time.Now().Add(tc.ahead)
+				if err := os.Chtimes(p, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cancel := make(chan any)
+			seed := tc.seed
+
+			for i := range tc.polls {
+				if tc.grow && i > 0 {
+					if err := os.WriteFile(p, []byte("more audio"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if !dirwatch.poll(cancel, seed) {
+					t.Fatal("poll did not complete")
+				}
+				seed = false
+			}
+
+			var status string
+			if f := dirwatch.getLedgerFile(p); f != nil {
+				status = f.Status
+			}
+			if status != tc.want {
+				t.Errorf("ledger status is %q, want %q", status, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DiscoveryStatusPopulated = "populated"
+	DiscoveryStatusRejected  = "rejected"
+
+	discoveryMaxRefs = 100
+)
+
+type Discovery struct {
+	Id             uint64    `json:"id"`
+	Count          uint      `json:"count"`
+	FirstSeen      time.Time `json:"firstSeen"`
+	Frequencies    []uint    `json:"frequencies"`
+	LastSeen       time.Time `json:"lastSeen"`
+	Status         string    `json:"status"`
+	SystemLabel    string    `json:"systemLabel"`
+	SystemRef      uint      `json:"system"`
+	TalkgroupLabel string    `json:"talkgroupLabel"`
+	TalkgroupRef   uint      `json:"talkgroup"`
+	Units          []uint    `json:"units"`
+}
+
+type Discoveries struct {
+	keys  map[string]bool
+	mutex sync.Mutex
+}
+
+func NewDiscoveries() *Discoveries {
+	return &Discoveries{
+		keys:  map[string]bool{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (discoveries *Discoveries) Clear(db Storage, systemRef uint, talkgroupRef uint) error {
+	discoveries.mutex.Lock()
+	defer discoveries.mutex.Unlock()
+
+	formatError := errorFormatter("discoveries", "clear")
+
+	query := `DELETE FROM "discoveries"`
+	args := []any{}
+
+	if systemRef > 0 {
+		query += ` WHERE "systemRef" = ?`
+		args = append(args, systemRef)
+
+		if talkgroupRef > 0 {
+			query += ` AND "talkgroupRef" = ?`
+			args = append(args, talkgroupRef)
+		}
+	}
+
+	if _, err := db.Exec(query, args...); err != nil {
+		return formatError(err, query)
+	}
+
+	for key := range discoveries.keys {
+		delete(discoveries.keys, key)
+	}
+
+	return discoveries.readKeys(db)
+}
+
+func (discoveries *Discoveries) IsTracked(call *Call) bool {
+	if call.System == nil || call.Talkgroup == nil {
+		return false
+	}
+
+	discoveries.mutex.Lock()
+	defer discoveries.mutex.Unlock()
+
+	return discoveries.keys[discoveries.key(call.System.SystemRef, call.Talkgroup.TalkgroupRef)]
+}
+
+func (discoveries *Discoveries) List(db Storage, systemRef uint) ([]Discovery, error) {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	discoveries.mutex.Lock()
+	defer discoveries.mutex.Unlock()
+
+	formatError := errorFormatter("discoveries", "list")
+
+	list := []Discovery{}
+
+	query = `SELECT "discoveryId", "count", "firstSeen", "frequencies", "lastSeen", "status", "systemLabel", "systemRef", "talkgroupLabel", "talkgroupRef", "units" FROM "discoveries"`
+	args := []any{}
+
+	if systemRef > 0 {
+		query += ` WHERE "systemRef" = ?`
+		args = append(args, systemRef)
+	}
+
+	query += ` ORDER BY "systemRef", "talkgroupRef"`
+
+	if rows, err = db.Query(query, args...); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			discovery   Discovery
+			firstSeen   int64
+			frequencies string
+			lastSeen    int64
+			units       string
+		)
+
+		if err = rows.Scan(&discovery.Id, &discovery.Count, &firstSeen, &frequencies, &lastSeen, &discovery.Status, &discovery.SystemLabel, &discovery.SystemRef, &discovery.TalkgroupLabel, &discovery.TalkgroupRef, &units); err != nil {
+			break
+		}
+
+		discovery.FirstSeen = time.UnixMilli(firstSeen)
+		discovery.LastSeen = time.UnixMilli(lastSeen)
+
+		discovery.Frequencies = []uint{}
+		json.Unmarshal([]byte(frequencies), &discovery.Frequencies)
+
+		discovery.Units = []uint{}
+		json.Unmarshal([]byte(units), &discovery.Units)
+
+		list = append(list, discovery)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	return list, nil
+}
+
+func (discoveries *Discoveries) Read(db Storage) error {
+	discoveries.mutex.Lock()
+	defer discoveries.mutex.Unlock()
+
+	discoveries.keys = map[string]bool{}
+
+	return discoveries.readKeys(db)
+}
+
+func (discoveries *Discoveries) Record(call *Call, status string, db Storage) error {
+	var (
+		count       uint
+		err         error
+		frequencies string
+		id          uint64
+		query       string
+		units       string
+
+		systemLabel    = call.Meta.SystemLabel
+		systemRef      = call.Meta.SystemRef
+		talkgroupLabel = call.Meta.TalkgroupLabel
+		talkgroupRef   = call.Meta.TalkgroupRef
+		timestamp      = call.Timestamp.UnixMilli()
+	)
+
+	if call.System != nil {
+		systemLabel = call.System.Label
+		systemRef = call.System.SystemRef
+	}
+
+	if call.Talkgroup != nil {
+		talkgroupLabel = call.Talkgroup.Label
+		talkgroupRef = call.Talkgroup.TalkgroupRef
+	}
+
+	if systemRef == 0 && talkgroupRef == 0 {
+		return nil
+	}
+
+	discoveries.mutex.Lock()
+	defer discoveries.mutex.Unlock()
+
+	formatError := errorFormatter("discoveries", "record")
+
+	merge := func(s string, refs []uint) string {
+		a := []uint{}
+		json.Unmarshal([]byte(s), &a)
+
+		m := map[uint]bool{}
+		for _, ref := range a {
+			m[ref] = true
+		}
+
+		for _, ref := range refs {
+			if ref > 0 && !m[ref] && len(a) < discoveryMaxRefs {
+				m[ref] = true
+				a = append(a, ref)
+			}
+		}
+
+		sort.Slice(a, func(i int, j int) bool {
+			return a[i] < a[j]
+		})
+
+		b, _ := json.Marshal(a)
+
+		return string(b)
+	}
+
+	callFrequencies := []uint{}
+	for _, f := range call.Frequencies {
+		callFrequencies = append(callFrequencies, f.Frequency)
+	}
+
+	callUnits := []uint{}
+	for _, u := range call.Units {
+		callUnits = append(callUnits, u.UnitRef)
+	}
+
+	query = `SELECT "discoveryId", "count", "frequencies", "units" FROM "discoveries" WHERE "systemRef" = ? AND "talkgroupRef" = ?`
+	if err = db.QueryRow(query, systemRef, talkgroupRef).Scan(&id, &count, &frequencies, &units); err != nil && err != sql.ErrNoRows {
+		return formatError(err, query)
+	}
+
+	frequencies = merge(frequencies, callFrequencies)
+	units = merge(units, callUnits)
+
+	if id > 0 {
+		// an empty status keeps the one recorded when the talkgroup was first seen
+		query = `UPDATE "discoveries" SET "count" = ?, "firstSeen" = CASE WHEN "firstSeen" > ? THEN ? ELSE "firstSeen" END, "frequencies" = ?, "lastSeen" = CASE WHEN "lastSeen" < ? THEN ? ELSE "lastSeen" END, "status" = COALESCE(NULLIF(?, ''), "status"), "systemLabel" = ?, "talkgroupLabel" = ?, "units" = ? WHERE "discoveryId" = ?`
+		_, err = db.Exec(query, count+1, timestamp, timestamp, frequencies, timestamp, timestamp, status, systemLabel, talkgroupLabel, units, id)
+
+	} else {
+		query = `INSERT INTO "discoveries" ("count", "firstSeen", "frequencies", "lastSeen", "status", "systemLabel", "systemRef", "talkgroupLabel", "talkgroupRef", "units") VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err = db.Exec(query, timestamp, frequencies, timestamp, status, systemLabel, systemRef, talkgroupLabel, talkgroupRef, units)
+	}
+
+	if err != nil {
+		return formatError(err, query)
+	}
+
+	discoveries.keys[discoveries.key(systemRef, talkgroupRef)] = true
+
+	return nil
+}
+
+func (discoveries *Discoveries) key(systemRef uint, talkgroupRef uint) string {
+	return fmt.Sprintf("%d:%d", systemRef, talkgroupRef)
+}
+
+func (discoveries *Discoveries) readKeys(db Storage) error {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("discoveries", "read")
+
+	query = `SELECT "systemRef", "talkgroupRef" FROM "discoveries"`
+	if rows, err = db.Query(query); err != nil {
+		return formatError(err, query)
+	}
+
+	for rows.Next() {
+		var systemRef, talkgroupRef uint
+
+		if err = rows.Scan(&systemRef, &talkgroupRef); err != nil {
+			break
+		}
+
+		discoveries.keys[discoveries.key(systemRef, talkgroupRef)] = true
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
@@ -0,0 +1,396 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DownsampleBatchSize = 50
+
+// DownsampleTier re-encodes the calls older than the given number of days with
+// a lower bitrate audio profile.
+type DownsampleTier struct {
+	Days    uint   `json:"days"`
+	Profile string `json:"profile"`
+}
+
+func NewDownsampleTier() *DownsampleTier {
+	return &DownsampleTier{}
+}
+
+func (tier *DownsampleTier) FromMap(m map[string]any) *DownsampleTier {
+	switch v := m["days"].(type) {
+	case float64:
+		tier.Days = uint(v)
+	}
+
+	switch v := m["profile"].(type) {
+	case string:
+		tier.Profile = v
+	}
+
+	return tier
+}
+
+type DownsampleTiers struct {
+	List []*DownsampleTier
+}
+
+func NewDownsampleTiers() *DownsampleTiers {
+	return &DownsampleTiers{List: []*DownsampleTier{}}
+}
+
+// FromMap keeps the valid tiers sorted from the youngest to the oldest.
+func (tiers *DownsampleTiers) FromMap(f []any) *DownsampleTiers {
+	tiers.List = []*DownsampleTier{}
+
+	for _, v := range f {
+		switch m := v.(type) {
+		case map[string]any:
+			tier := NewDownsampleTier().FromMap(m)
+
+			if tier.Days > 0 && len(tier.Profile) > 0 {
+				tiers.List = append(tiers.List, tier)
+			}
+		}
+	}
+
+	sort.SliceStable(tiers.List, func(i int, j int) bool {
+		return tiers.List[i].Days < tiers.List[j].Days
+	})
+
+	return tiers
+}
+
+func (tiers *DownsampleTiers) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tiers.List)
+}
+
+type DownsampleReport struct {
+	Available bool                    `json:"available"`
+	Reclaimed int64                   `json:"reclaimed"`
+	Running   bool                    `json:"running"`
+	Tiers     []*DownsampleTierReport `json:"tiers"`
+}
+
+type DownsampleTierReport struct {
+	Days       uint   `json:"days"`
+	Done       uint   `json:"done"`
+	Failed     uint   `json:"failed"`
+	Pending    uint   `json:"pending"`
+	Profile    string `json:"profile"`
+	Reclaimed  int64  `json:"reclaimed"`
+	SizeAfter  int64  `json:"sizeAfter"`
+	SizeBefore int64  `json:"sizeBefore"`
+}
+
+// Downsampler re-encodes the aging calls in the background. Every processed
+// call is recorded in the downsampledCalls table, successful or not, so that
+// an interrupted job resumes where it stopped.
+type Downsampler struct {
+	controller *Controller
+	mutex      sync.Mutex
+	running    bool
+}
+
+type downsampleStep struct {
+	args    []any
+	profile *AudioProfile
+	tier    *DownsampleTier
+	where   string
+}
+
+func NewDownsampler(controller *Controller) *Downsampler {
+	return &Downsampler{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (downsampler *Downsampler) IsRunning() bool {
+	downsampler.mutex.Lock()
+	defer downsampler.mutex.Unlock()
+
+	return downsampler.running
+}
+
+// Report returns, for each tier, the calls pending and processed and the
+// space reclaimed.
+func (downsampler *Downsampler) Report(db Storage) (*DownsampleReport, error) {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("downsampler", "report")
+
+	report := &DownsampleReport{
+		Available: downsampler.controller.FFMpeg.available,
+		Running:   downsampler.IsRunning(),
+		Tiers:     []*DownsampleTierReport{},
+	}
+
+	for _, step := range downsampler.plan() {
+		tierReport := &DownsampleTierReport{
+			Days:    step.tier.Days,
+			Profile: step.tier.Profile,
+		}
+
+		if len(step.where) > 0 {
+			query = fmt.Sprintf(`SELECT COUNT(*) FROM "calls" WHERE %s`, step.where)
+			if err = db.QueryRow(query, step.args...).Scan(&tierReport.Pending); err != nil {
+				return nil, formatError(err, query)
+			}
+		}
+
+		report.Tiers = append(report.Tiers, tierReport)
+	}
+
+	query = `SELECT "profile", "error" = '', COUNT(*), SUM("sizeBefore"), SUM("sizeAfter") FROM "downsampledCalls" GROUP BY "profile", "error" = ''`
+	if rows, err = db.Query(query); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			count      uint
+			done       bool
+			profile    string
+			sizeAfter  int64
+			sizeBefore int64
+		)
+
+		if err = rows.Scan(&profile, &done, &count, &sizeBefore, &sizeAfter); err != nil {
+			break
+		}
+
+		report.Reclaimed += sizeBefore - sizeAfter
+
+		for _, tierReport := range report.Tiers {
+			if tierReport.Profile != profile {
+				continue
+			}
+
+			if done {
+				tierReport.Done += count
+				tierReport.Reclaimed += sizeBefore - sizeAfter
+				tierReport.SizeAfter += sizeAfter
+				tierReport.SizeBefore += sizeBefore
+			} else {
+				tierReport.Failed += count
+			}
+
+			break
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	return report, nil
+}
+
+// Run processes the tiers until no call is left to re-encode, doing nothing
+// when already running.
+func (downsampler *Downsampler) Run() error {
+	downsampler.mutex.Lock()
+
+	if downsampler.running || !downsampler.controller.FFMpeg.available {
+		downsampler.mutex.Unlock()
+		return nil
+	}
+
+	downsampler.running = true
+	downsampler.mutex.Unlock()
+
+	defer func() {
+		downsampler.mutex.Lock()
+		downsampler.running = false
+		downsampler.mutex.Unlock()
+	}()
+
+	return downsampler.run(downsampler.plan(), downsampler.controller.Database)
+}
+
+// Start launches the job in the background.
+func (downsampler *Downsampler) Start() {
+	go func() {
+		if err := downsampler.Run(); err != nil {
+			downsampler.controller.Logs.LogEvent(LogLevelError, err.Error())
+		}
+	}()
+}
+
+// downsample re-encodes the audio of the call, the original audio being kept
+// when the result is not smaller.
+func (downsampler *Downsampler) downsample(callId uint64, profile *AudioProfile, db Storage) (int64, int64, error) {
+	var (
+		audio         []byte
+		audioFilename string
+	)
+
+	query := `SELECT "audio", "audioFilename" FROM "calls" WHERE "callId" = ?`
+	if err := db.QueryRow(query, callId).Scan(&audio, &audioFilename); err != nil {
+		return 0, 0, fmt.Errorf("%s in %s", err, query)
+	}
+
+	size := int64(len(audio))
+
+	b, err := downsampler.controller.FFMpeg.Transcode(context.Background(), audio, profile)
+	if err != nil {
+		return size, size, err
+	}
+
+	if int64(len(b)) >= size {
+		return size, size, nil
+	}
+
+	call := &Call{
+		Id:            callId,
+		Audio:         b,
+		AudioFilename: fmt.Sprintf("%s.%s", strings.TrimSuffix(audioFilename, path.Ext(audioFilename)), profile.Extension()),
+		AudioMime:     profile.Mime(),
+		AudioProfile:  profile.Name,
+	}
+
+	if err = downsampler.controller.Calls.ReplaceAudio(call, db); err != nil {
+		return size, size, err
+	}
+
+	return size, int64(len(b)), nil
+}
+
+func (downsampler *Downsampler) run(steps []*downsampleStep, db Storage) error {
+	formatError := errorFormatter("downsampler", "run")
+
+	for _, step := range steps {
+		var (
+			count     uint
+			failed    uint
+			reclaimed int64
+		)
+
+		if len(step.where) == 0 {
+			continue
+		}
+
+		for {
+			callIds := []uint64{}
+
+			query := fmt.Sprintf(`SELECT "callId" FROM "calls" WHERE %s ORDER BY "callId" LIMIT ?`, step.where)
+			rows, err := db.Query(query, append(append([]any{}, step.args...), DownsampleBatchSize)...)
+			if err != nil {
+				return formatError(err, query)
+			}
+
+			for rows.Next() {
+				var callId uint64
+				if err = rows.Scan(&callId); err != nil {
+					break
+				}
+				callIds = append(callIds, callId)
+			}
+
+			rows.Close()
+
+			if err != nil {
+				return formatError(err, "")
+			}
+
+			for _, callId := range callIds {
+				var s string
+
+				sizeBefore, sizeAfter, err := downsampler.downsample(callId, step.profile, db)
+				if err != nil {
+					s = err.Error()
+					failed++
+				} else {
+					count++
+					reclaimed += sizeBefore - sizeAfter
+				}
+
+				query = `INSERT INTO "downsampledCalls" ("callId", "error", "profile", "sizeAfter", "sizeBefore", "timestamp") VALUES (?, ?, ?, ?, ?, ?)`
+				if _, err = db.Exec(query, callId, s, step.profile.Name, sizeAfter, sizeBefore, time.Now().UnixMilli()); err != nil {
+					return formatError(err, query)
+				}
+			}
+
+			if len(callIds) < DownsampleBatchSize {
+				break
+			}
+		}
+
+		if count > 0 || failed > 0 {
+			downsampler.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("downsampling: %d calls older than %d days re-encoded to %s, %d bytes reclaimed, %d failed", count, step.tier.Days, step.profile.Name, reclaimed, failed))
+		}
+	}
+
+	return nil
+}
+
+// plan returns the tiers to process, from the oldest to the youngest, each
+// one covering the calls up to the age of the next older tier.
+func (downsampler *Downsampler) plan() []*downsampleStep {
+	options := downsampler.controller.Options
+
+	options.mutex.Lock()
+	tiers := append([]*DownsampleTier{}, options.DownsampleTiers.List...)
+	options.mutex.Unlock()
+
+	steps := []*downsampleStep{}
+
+	for i := len(tiers) - 1; i >= 0; i-- {
+		tier := tiers[i]
+		step := &downsampleStep{tier: tier}
+
+		if profile, ok := options.AudioProfiles.GetProfile(tier.Profile); ok {
+			step.profile = profile
+
+			step.args = []any{retentionTimestamp(tier.Days)}
+			step.where = `"timestamp" < ?`
+
+			if i < len(tiers)-1 {
+				step.args = append(step.args, retentionTimestamp(tiers[i+1].Days))
+				step.where += ` AND "timestamp" >= ?`
+			}
+
+			step.args = append(step.args, profile.Name, profile.Name)
+			step.where += fmt.Sprintf(` AND "audioProfile" <> ? AND "callId" NOT IN (SELECT "callId" FROM "downsampledCalls" WHERE "profile" = ?) AND %s`, callsPruneExemption)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps
+}
@@ -364,7 +364,7 @@ func (downstreams *Downstreams) FromMap(f []any) *Downstreams {
 	return downstreams
 }
 
-func (downstreams *Downstreams) Read(db *Database) error {
+func (downstreams *Downstreams) Read(db Storage) error {
 	var (
 		err   error
 		query string
@@ -379,7 +379,7 @@ func (downstreams *Downstreams) Read(db *Database) error {
 	formatError := downstreams.errorFormatter("read")
 
 	query = `SELECT "downstreamId", "apikey", "disabled", "order", "systems", "url" FROM "downstreams"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -429,13 +429,13 @@ func (downstreams *Downstreams) Send(controller *Controller, call *Call) {
 	}
 }
 
-func (downstreams *Downstreams) Write(db *Database) error {
+func (downstreams *Downstreams) Write(db Storage) error {
 	var (
 		downstreamIds = []uint64{}
 		err           error
 		query         string
 		rows          *sql.Rows
-		tx            *sql.Tx
+		tx            *StorageTx
 	)
 
 	downstreams.mutex.Lock()
@@ -443,7 +443,7 @@ func (downstreams *Downstreams) Write(db *Database) error {
 
 	formatError := downstreams.errorFormatter("write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -478,13 +478,11 @@ func (downstreams *Downstreams) Write(db *Database) error {
 	}
 
 	if len(downstreamIds) > 0 {
-		if b, err := json.Marshal(downstreamIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "downstreams" WHERE "downstreamId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
+		in, args := sqlIn(downstreamIds)
+		query = fmt.Sprintf(`DELETE FROM "downstreams" WHERE "downstreamId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
 		}
 	}
 
@@ -501,21 +499,21 @@ func (downstreams *Downstreams) Write(db *Database) error {
 		}
 
 		if downstream.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "downstreams" WHERE "downstreamId" = %d`, downstream.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "downstreams" WHERE "downstreamId" = ?`
+			if err = tx.QueryRow(query, downstream.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "downstreams" ("apikey", "disabled", "order", "systems", "url") VALUES ('%s', %t, %d, '%s', '%s')`, escapeQuotes(downstream.Apikey), downstream.Disabled, downstream.Order, systems, escapeQuotes(downstream.Url))
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "downstreams" ("apikey", "disabled", "order", "systems", "url") VALUES (?, ?, ?, ?, ?)`
+			if _, err = tx.Exec(query, downstream.Apikey, downstream.Disabled, downstream.Order, systems, downstream.Url); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "downstreams" SET "apikey" = '%s', "disabled" = %t, "order" = %d, "systems" = '%s', "url" = '%s' WHERE "downstreamId" = %d`, escapeQuotes(downstream.Apikey), downstream.Disabled, downstream.Order, systems, escapeQuotes(downstream.Url), downstream.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "downstreams" SET "apikey" = ?, "disabled" = ?, "order" = ?, "systems" = ?, "url" = ? WHERE "downstreamId" = ?`
+			if _, err = tx.Exec(query, downstream.Apikey, downstream.Disabled, downstream.Order, systems, downstream.Url, downstream.Id); err != nil {
 				break
 			}
 		}
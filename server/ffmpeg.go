@@ -22,23 +22,31 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+const DecodeSampleRate = 8000
+
 type FFMpeg struct {
 	available bool
+	timeout   time.Duration
 	version43 bool
-	warned    bool
+	warned    sync.Once
 }
 
-func NewFFMpeg() *FFMpeg {
-	ffmpeg := &FFMpeg{}
+func NewFFMpeg(timeout time.Duration) *FFMpeg {
+	ffmpeg := &FFMpeg{timeout: timeout}
 
 	stdout := bytes.NewBuffer([]byte(nil))
 
@@ -66,23 +74,86 @@ func NewFFMpeg() *FFMpeg {
 	return ffmpeg
 }
 
-func (ffmpeg *FFMpeg) Convert(call *Call, systems *Systems, tags *Tags, mode uint) error {
+// Analyze measures the audio with the astats filter, falling back to a plain
+// wave parser when ffmpeg is not available. Convert measures the audio it
+// converts the same way, leaving Analyze to the calls it does not convert.
+func (ffmpeg *FFMpeg) Analyze(ctx context.Context, audio []byte) (*AudioStats, error) {
+	if !ffmpeg.available {
+		return ParseWav(audio)
+	}
+
+	if ffmpeg.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, ffmpeg.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", "-", "-af", "astats", "-f", "null", "-")
+	cmd.Stdin = bytes.NewReader(audio)
+
+	stderr := bytes.NewBuffer([]byte(nil))
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if stats, err := ParseWav(audio); err == nil {
+			return stats, nil
+		}
+		return nil, fmt.Errorf("ffmpeg.analyze: %v", err)
+	}
+
+	return ParseAstats(stderr.String())
+}
+
+// Decode returns the interleaved samples of the audio, downmixed to mono at
+// 8kHz by ffmpeg or as is from a plain wave parser when ffmpeg is not
+// available.
+func (ffmpeg *FFMpeg) Decode(ctx context.Context, audio []byte) ([]float64, uint, uint, error) {
+	if !ffmpeg.available {
+		return DecodeWav(audio)
+	}
+
+	if ffmpeg.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, ffmpeg.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", "-", "-ac", "1", "-ar", fmt.Sprintf("%d", DecodeSampleRate), "-f", "s16le", "-")
+	cmd.Stdin = bytes.NewReader(audio)
+
+	stdout := bytes.NewBuffer([]byte(nil))
+	cmd.Stdout = stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, 0, 0, fmt.Errorf("ffmpeg.decode: %v", err)
+	}
+
+	return decodePcm(stdout.Bytes()), 1, DecodeSampleRate, nil
+}
+
+// Convert encodes the audio of the call with the profile. The same ffmpeg run
+// measures the received audio, as Analyze would, and decodes the converted
+// audio, as Decode would, so that neither has to run again.
+func (ffmpeg *FFMpeg) Convert(ctx context.Context, call *Call, systems *Systems, tags *Tags, mode uint, profile *AudioProfile) ([]float64, *AudioStats, error) {
 	var (
-		args = []string{"-i", "-"}
-		err  error
+		args    = []string{"-i", "-"}
+		err     error
+		filters = []string{"astats"}
+		samples []float64
+		stats   *AudioStats
 	)
 
 	if mode == AUDIO_CONVERSION_DISABLED {
-		return nil
+		return nil, nil, nil
 	}
 
 	if !ffmpeg.available {
-		if !ffmpeg.warned {
-			ffmpeg.warned = true
-
-			return errors.New("ffmpeg is not available, no audio conversion will be performed")
-		}
-		return nil
+		ffmpeg.warned.Do(func() {
+			err = errors.New("ffmpeg is not available, no audio conversion will be performed")
+		})
+		return nil, nil, err
 	}
 
 	if tag, ok := tags.GetTagById(call.Talkgroup.TagId); ok {
@@ -95,18 +166,53 @@ func (ffmpeg *FFMpeg) Convert(call *Call, systems *Systems, tags *Tags, mode uin
 		)
 	}
 
+	processing := ResolveAudioProcessing(call)
+
 	if ffmpeg.version43 {
+		if processing != nil {
+			filters = append(filters, processing.Filters()...)
+		}
+
+		// the padding giving loudnorm enough audio would add back the
+		// silence just trimmed
+		pad := []string{"apad=whole_dur=3s"}
+		if processing != nil && processing.TrimSilence {
+			pad = nil
+		}
+
 		switch mode {
 		case AUDIO_CONVERSION_ENABLED_NORM:
-			args = append(args, "-af", "apad=whole_dur=3s,loudnorm")
+			filters = append(append(filters, pad...), "loudnorm")
 		case AUDIO_CONVERSION_ENABLED_LOUD_NORM:
-			args = append(args, "-af", "apad=whole_dur=3s,loudnorm=I=-16:TP=-1.5:LRA=11")
+			filters = append(append(filters, pad...), "loudnorm=I=-16:TP=-1.5:LRA=11")
 		}
 	}
 
-	args = append(args, "-c:a", "aac", "-b:a", "32k", "-movflags", "frag_keyframe+empty_moov", "-f", "ipod", "-")
+	// the decoded output goes to a file, extra pipes not being available on
+	// every platform
+	pcm, err := os.CreateTemp("", "rdio-scanner-*.pcm")
+	if err == nil {
+		pcm.Close()
+		defer os.Remove(pcm.Name())
 
-	cmd := exec.Command("ffmpeg", args...)
+		chain := append(profile.Chain(filters), "asplit=2[encoded][decoded]")
+
+		args = append(args, "-filter_complex", "[0:a]"+strings.Join(chain, ","), "-map", "[encoded]")
+		args = append(args, profile.EncoderArgs()...)
+		args = append(args, "-map", "[decoded]", "-ac", "1", "-ar", fmt.Sprintf("%d", DecodeSampleRate), "-f", "s16le", "-y", pcm.Name())
+
+	} else {
+		args = append(args, profile.Args(filters)...)
+	}
+
+	if ffmpeg.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, ffmpeg.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	cmd.Stdin = bytes.NewReader(call.Audio)
 
 	stdout := bytes.NewBuffer([]byte(nil))
@@ -116,13 +222,69 @@ func (ffmpeg *FFMpeg) Convert(call *Call, systems *Systems, tags *Tags, mode uin
 	cmd.Stderr = stderr
 
 	if err = cmd.Run(); err == nil {
+		if s, err := ParseAstats(stderr.String()); err == nil {
+			stats = s
+		}
+
 		call.Audio = stdout.Bytes()
-		call.AudioFilename = fmt.Sprintf("%v.m4a", strings.TrimSuffix(call.AudioFilename, path.Ext((call.AudioFilename))))
-		call.AudioMime = "audio/mp4"
+		call.AudioFilename = fmt.Sprintf("%v.%v", strings.TrimSuffix(call.AudioFilename, path.Ext((call.AudioFilename))), profile.Extension())
+		call.AudioMime = profile.Mime()
+		call.AudioProfile = profile.Name
 
-	} else {
+		if processing != nil && ffmpeg.version43 {
+			call.Trim(processing.TrimmedOffset(stderr.String()))
+		}
+
+		if pcm != nil {
+			if b, err := os.ReadFile(pcm.Name()); err == nil {
+				samples = decodePcm(b)
+			}
+		}
+
+	} else if ctx.Err() == context.DeadlineExceeded {
+		return nil, nil, fmt.Errorf("ffmpeg timed out after %v converting %v, original audio kept", ffmpeg.timeout, call.AudioFilename)
+
+	} else if ctx.Err() == nil {
 		fmt.Println(stderr.String())
 	}
 
-	return nil
+	return samples, stats, nil
+}
+
+// Transcode re-encodes already converted audio with the profile, without
+// applying the processing and normalization filters a second time.
+func (ffmpeg *FFMpeg) Transcode(ctx context.Context, audio []byte, profile *AudioProfile) ([]byte, error) {
+	if !ffmpeg.available {
+		return nil, errors.New("ffmpeg.transcode: ffmpeg is not available")
+	}
+
+	if ffmpeg.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, ffmpeg.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", append([]string{"-i", "-"}, profile.Args(nil)...)...)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	stdout := bytes.NewBuffer([]byte(nil))
+	cmd.Stdout = stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg.transcode: %v", err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// decodePcm returns the samples of signed 16 bits little endian audio.
+func decodePcm(pcm []byte) []float64 {
+	samples := make([]float64, len(pcm)/2)
+
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+	}
+
+	return samples
 }
@@ -0,0 +1,236 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"encoding/base64"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	FingerprintActionDrop = "drop"
+	FingerprintActionLink = "link"
+	FingerprintActionOff  = "off"
+
+	FingerprintFrame     = 20 * time.Millisecond
+	FingerprintMinFrames = 25
+	FingerprintMaxShift  = 10
+)
+
+// Fingerprint is the energy envelope of a call, one value per 20ms frame
+// where bit 0 tells the energy is rising and bit 1 that the frame is louder
+// than the call median, ie. voice activity. It is not affected by the gain,
+// codec or bitrate of each talkgroup so that the same transmission patched
+// to several talkgroups or systems gives the same fingerprint.
+type Fingerprint []uint8
+
+func NewFingerprint(samples []float64, channels uint, rate uint) Fingerprint {
+	if channels == 0 || rate == 0 {
+		return nil
+	}
+
+	size := int(rate*channels) * int(FingerprintFrame/time.Millisecond) / 1000
+	if size == 0 || len(samples) < size*2 {
+		return nil
+	}
+
+	energies := make([]float64, len(samples)/size)
+
+	for i := range energies {
+		for _, v := range samples[i*size : (i+1)*size] {
+			energies[i] += v * v
+		}
+	}
+
+	sorted := append([]float64{}, energies...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	fingerprint := make(Fingerprint, len(energies))
+
+	for i, e := range energies {
+		if i > 0 && e > energies[i-1] {
+			fingerprint[i] |= 1
+		}
+
+		if e > median && e > 1e-6 {
+			fingerprint[i] |= 2
+		}
+	}
+
+	return fingerprint
+}
+
+// ParseFingerprint decodes the value stored in the database.
+func ParseFingerprint(s string) Fingerprint {
+	if len(s) == 0 {
+		return nil
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return Fingerprint(b)
+	}
+
+	return nil
+}
+
+// Similarity returns the ratio of matching frames, from 0 to 1, for the best
+// alignment of the two fingerprints within a 200ms shift. Frames silent in
+// both are ignored so that silence does not make unrelated calls match.
+func (fingerprint Fingerprint) Similarity(other Fingerprint) float64 {
+	var best float64
+
+	for shift := -FingerprintMaxShift; shift <= FingerprintMaxShift; shift++ {
+		var compared, matched int
+
+		for i := max(0, -shift); i < len(fingerprint) && i+shift < len(other); i++ {
+			a, b := fingerprint[i], other[i+shift]
+
+			if a&2 == 0 && b&2 == 0 {
+				continue
+			}
+
+			compared++
+
+			if a == b {
+				matched++
+			}
+		}
+
+		if compared >= FingerprintMinFrames {
+			best = math.Max(best, float64(matched)/float64(compared))
+		}
+	}
+
+	return best
+}
+
+// String returns the value stored in the database.
+func (fingerprint Fingerprint) String() string {
+	return base64.StdEncoding.EncodeToString(fingerprint)
+}
+
+// FingerprintDetection finds the same audio received on other talkgroups or
+// systems, which duplicate detection does not catch.
+type FingerprintDetection struct {
+	Action     string `json:"action"`
+	Similarity uint   `json:"similarity"`
+	TimeFrame  uint   `json:"timeFrame"`
+}
+
+func NewFingerprintDetection() *FingerprintDetection {
+	return &FingerprintDetection{
+		Action:     FingerprintActionOff,
+		Similarity: 90,
+		TimeFrame:  3000,
+	}
+}
+
+func (detection *FingerprintDetection) FromMap(m map[string]any) *FingerprintDetection {
+	switch v := m["action"].(type) {
+	case string:
+		switch v {
+		case FingerprintActionDrop, FingerprintActionLink:
+			detection.Action = v
+		default:
+			detection.Action = FingerprintActionOff
+		}
+	}
+
+	switch v := m["similarity"].(type) {
+	case float64:
+		if v > 0 && v <= 100 {
+			detection.Similarity = uint(v)
+		}
+	}
+
+	switch v := m["timeFrame"].(type) {
+	case float64:
+		detection.TimeFrame = uint(v)
+	}
+
+	return detection
+}
+
+func (detection *FingerprintDetection) IsEnabled() bool {
+	return detection.Action == FingerprintActionDrop || detection.Action == FingerprintActionLink
+}
+
+// Match returns the call already stored within the time frame whose audio
+// matches the call, following its link to the first call of the
+// transmission.
+func (detection *FingerprintDetection) Match(call *Call, db Storage) (uint64, error) {
+	var (
+		callId uint64
+		best   float64
+	)
+
+	formatError := errorFormatter("fingerprint", "match")
+
+	if len(call.Fingerprint) < FingerprintMinFrames {
+		return 0, nil
+	}
+
+	d := time.Duration(detection.TimeFrame) * time.Millisecond
+	from := call.Timestamp.Add(-d)
+	to := call.Timestamp.Add(d)
+
+	query := `SELECT "callId", "fingerprint", "linkedCallId" FROM "calls" WHERE ("timestamp" BETWEEN ? AND ?) AND "fingerprint" <> ''`
+
+	rows, err := db.Query(query, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return 0, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			id     uint64
+			linked uint64
+			s      string
+		)
+
+		if err = rows.Scan(&id, &s, &linked); err != nil {
+			break
+		}
+
+		similarity := call.Fingerprint.Similarity(ParseFingerprint(s))
+
+		if similarity*100 >= float64(detection.Similarity) && similarity > best {
+			best = similarity
+
+			if linked > 0 {
+				callId = linked
+			} else {
+				callId = id
+			}
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return 0, formatError(err, "")
+	}
+
+	return callId, nil
+}
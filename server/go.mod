@@ -12,6 +12,7 @@ require (
 	github.com/jackc/pgx/v5 v5.9.2
 	github.com/kardianos/service v1.2.4
 	golang.org/x/crypto v0.51.0
+	golang.org/x/sys v0.44.0
 	gopkg.in/ini.v1 v1.67.2
 	modernc.org/sqlite v1.50.1
 )
@@ -29,7 +30,6 @@ require (
 	golang.org/x/mod v0.36.0 // indirect
 	golang.org/x/net v0.54.0 // indirect
 	golang.org/x/sync v0.20.0 // indirect
-	golang.org/x/sys v0.44.0 // indirect
 	golang.org/x/text v0.37.0 // indirect
 	golang.org/x/tools v0.45.0 // indirect
 	lukechampine.com/uint128 v1.3.0 // indirect
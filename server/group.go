@@ -26,7 +26,6 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strings"
 	"sync"
 )
 
@@ -258,7 +257,7 @@ func (groups *Groups) GetGroupsMap(systemsMap *SystemsMap) GroupsMap {
 	return groupsMap
 }
 
-func (groups *Groups) Read(db *Database) error {
+func (groups *Groups) Read(db Storage) error {
 	var (
 		err   error
 		query string
@@ -273,7 +272,7 @@ func (groups *Groups) Read(db *Database) error {
 	formatError := groups.errorFormatter("read")
 
 	query = `SELECT "groupId", "alert", "label", "led", "order" FROM "groups"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -300,13 +299,13 @@ func (groups *Groups) Read(db *Database) error {
 	return nil
 }
 
-func (groups *Groups) Write(db *Database) error {
+func (groups *Groups) Write(db Storage) error {
 	var (
 		err      error
 		groupIds = []uint64{}
 		query    string
 		rows     *sql.Rows
-		tx       *sql.Tx
+		tx       *StorageTx
 	)
 
 	groups.mutex.Lock()
@@ -314,7 +313,7 @@ func (groups *Groups) Write(db *Database) error {
 
 	formatError := groups.errorFormatter("write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -349,13 +348,11 @@ func (groups *Groups) Write(db *Database) error {
 	}
 
 	if len(groupIds) > 0 {
-		if b, err := json.Marshal(groupIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "groups" WHERE "groupId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
+		in, args := sqlIn(groupIds)
+		query = fmt.Sprintf(`DELETE FROM "groups" WHERE "groupId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
 		}
 	}
 
@@ -363,21 +360,21 @@ func (groups *Groups) Write(db *Database) error {
 		var count uint
 
 		if group.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "groups" WHERE "groupId" = %d`, group.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "groups" WHERE "groupId" = ?`
+			if err = tx.QueryRow(query, group.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "groups" ("alert", "label", "led", "order") VALUES ('%s', '%s', '%s', %d)`, group.Alert, escapeQuotes(group.Label), group.Led, group.Order)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "groups" ("alert", "label", "led", "order") VALUES (?, ?, ?, ?)`
+			if _, err = tx.Exec(query, group.Alert, group.Label, group.Led, group.Order); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "groups" SET "alert" = '%s', "label" = '%s', "led" = '%s', "order" = %d where "groupId" = %d`, group.Alert, escapeQuotes(group.Label), group.Led, group.Order, group.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "groups" SET "alert" = ?, "label" = ?, "led" = ?, "order" = ? WHERE "groupId" = ?`
+			if _, err = tx.Exec(query, group.Alert, group.Label, group.Led, group.Order, group.Id); err != nil {
 				break
 			}
 		}
@@ -0,0 +1,664 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IncidentActionAttach = "attach"
+	IncidentActionClose  = "close"
+	IncidentActionCreate = "create"
+	IncidentActionDelete = "delete"
+	IncidentActionDetach = "detach"
+	IncidentActionNote   = "note"
+	IncidentActionReopen = "reopen"
+	IncidentActionUpdate = "update"
+
+	// IncidentAttachLimit caps the number of calls attached at once from a
+	// search or a time range.
+	IncidentAttachLimit = 5000
+)
+
+// Incident is a named collection of calls with notes. The calls of an open
+// incident are kept when the database is pruned.
+type Incident struct {
+	Id          uint64
+	Accesses    []uint64
+	CallIds     []uint64
+	Closed      bool
+	Description string
+	Label       string
+	Notes       []IncidentNote
+	Timestamp   time.Time
+}
+
+type IncidentNote struct {
+	Id        uint64    `json:"id"`
+	Note      string    `json:"note"`
+	Timestamp time.Time `json:"dateTime"`
+}
+
+func NewIncident() *Incident {
+	return &Incident{
+		Accesses: []uint64{},
+		CallIds:  []uint64{},
+		Notes:    []IncidentNote{},
+	}
+}
+
+// IsSharedWith tells if the incident is shared with the access.
+func (incident *Incident) IsSharedWith(access *Access) bool {
+	return access != nil && slices.Contains(incident.Accesses, access.Id)
+}
+
+func (incident *Incident) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"id":          incident.Id,
+		"accesses":    incident.Accesses,
+		"callCount":   len(incident.CallIds),
+		"calls":       incident.CallIds,
+		"closed":      incident.Closed,
+		"dateTime":    incident.Timestamp.Format(time.RFC3339),
+		"description": incident.Description,
+		"label":       incident.Label,
+		"notes":       incident.Notes,
+	})
+}
+
+type IncidentRequest struct {
+	Accesses    []uint64
+	Action      string
+	CallIds     []uint64
+	DateStart   time.Time
+	DateStop    time.Time
+	Description string
+	Id          uint64
+	Label       string
+	Note        string
+	Search      map[string]any
+	Talkgroups  [][2]uint
+}
+
+func NewIncidentRequest() *IncidentRequest {
+	return &IncidentRequest{
+		CallIds:    []uint64{},
+		Talkgroups: [][2]uint{},
+	}
+}
+
+func (request *IncidentRequest) FromMap(m map[string]any) *IncidentRequest {
+	switch v := m["accesses"].(type) {
+	case []any:
+		request.Accesses = []uint64{}
+		for _, id := range v {
+			switch id := id.(type) {
+			case float64:
+				request.Accesses = append(request.Accesses, uint64(id))
+			}
+		}
+	}
+
+	switch v := m["action"].(type) {
+	case string:
+		request.Action = v
+	}
+
+	switch v := m["calls"].(type) {
+	case []any:
+		for _, id := range v {
+			switch id := id.(type) {
+			case float64:
+				request.CallIds = append(request.CallIds, uint64(id))
+			}
+		}
+	}
+
+	switch v := m["dateStart"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			request.DateStart = t
+		}
+	}
+
+	switch v := m["dateStop"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			request.DateStop = t
+		}
+	}
+
+	switch v := m["description"].(type) {
+	case string:
+		request.Description = v
+	}
+
+	switch v := m["id"].(type) {
+	case float64:
+		request.Id = uint64(v)
+	}
+
+	switch v := m["label"].(type) {
+	case string:
+		request.Label = strings.TrimSpace(v)
+	}
+
+	switch v := m["note"].(type) {
+	case string:
+		request.Note = strings.TrimSpace(v)
+	}
+
+	switch v := m["search"].(type) {
+	case map[string]any:
+		request.Search = v
+	}
+
+	switch v := m["talkgroups"].(type) {
+	case []any:
+		for _, f := range v {
+			switch v := f.(type) {
+			case map[string]any:
+				systemRef, ok1 := v["system"].(float64)
+				talkgroupRef, ok2 := v["talkgroup"].(float64)
+				if ok1 && ok2 {
+					request.Talkgroups = append(request.Talkgroups, [2]uint{uint(systemRef), uint(talkgroupRef)})
+				}
+			}
+		}
+	}
+
+	return request
+}
+
+type Incidents struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewIncidents(controller *Controller) *Incidents {
+	return &Incidents{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+// Export writes a zip archive of the incident with the audio files of its
+// calls, followed by incident.json holding the incident, its notes and the
+// metadata of its calls.
+func (incidents *Incidents) Export(id uint64, w io.Writer, db Storage) error {
+	formatError := errorFormatter("incidents", "export")
+
+	incident, err := incidents.GetIncident(id, db)
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	archive := zip.NewWriter(w)
+
+	calls := []map[string]any{}
+
+	for _, callId := range incident.CallIds {
+		call, err := incidents.controller.Calls.GetCall(callId)
+		if err != nil {
+			continue
+		}
+
+		filename := fmt.Sprintf("calls/%d-%s", call.Id, path.Base(call.AudioFilename))
+
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: filename, Method: zip.Store, Modified: call.Timestamp})
+		if err != nil {
+			return formatError(err, "")
+		}
+
+		if _, err = f.Write(call.Audio); err != nil {
+			return formatError(err, "")
+		}
+
+		m := map[string]any{
+			"id":        call.Id,
+			"audioName": filename,
+			"dateTime":  call.Timestamp.Format(time.RFC3339),
+			"duration":  call.Duration,
+		}
+
+		if call.System != nil {
+			m["system"] = call.System.SystemRef
+			m["systemLabel"] = call.System.Label
+		}
+
+		if call.Talkgroup != nil {
+			m["talkgroup"] = call.Talkgroup.TalkgroupRef
+			m["talkgroupLabel"] = call.Talkgroup.Label
+			m["talkgroupName"] = call.Talkgroup.Name
+		}
+
+		if len(call.Units) > 0 {
+			units := []uint{}
+			for _, unit := range call.Units {
+				units = append(units, unit.UnitRef)
+			}
+			m["units"] = units
+		}
+
+		calls = append(calls, m)
+	}
+
+	b, err := json.MarshalIndent(map[string]any{
+		"calls":    calls,
+		"incident": incident,
+	}, "", "  ")
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	f, err := archive.Create("incident.json")
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	if _, err = f.Write(b); err != nil {
+		return formatError(err, "")
+	}
+
+	if err = archive.Close(); err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
+
+func (incidents *Incidents) GetIncident(id uint64, db Storage) (*Incident, error) {
+	var (
+		accesses  string
+		err       error
+		query     string
+		rows      *sql.Rows
+		timestamp int64
+	)
+
+	formatError := errorFormatter("incidents", "getincident")
+
+	incident := NewIncident()
+
+	query = `SELECT "incidentId", "accesses", "closed", "description", "label", "timestamp" FROM "incidents" WHERE "incidentId" = ?`
+	if err = db.QueryRow(query, id).Scan(&incident.Id, &accesses, &incident.Closed, &incident.Description, &incident.Label, &timestamp); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	incident.Accesses = parseIncidentAccesses(accesses)
+	incident.Timestamp = time.UnixMilli(timestamp)
+
+	query = `SELECT ic."callId" FROM "incidentCalls" AS ic LEFT JOIN "calls" AS c ON c."callId" = ic."callId" WHERE ic."incidentId" = ? ORDER BY c."timestamp" ASC`
+	if rows, err = db.Query(query, id); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var callId uint64
+		if err = rows.Scan(&callId); err != nil {
+			break
+		}
+		incident.CallIds = append(incident.CallIds, callId)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	query = `SELECT "incidentNoteId", "note", "timestamp" FROM "incidentNotes" WHERE "incidentId" = ? ORDER BY "timestamp" ASC`
+	if rows, err = db.Query(query, id); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		note := IncidentNote{}
+		if err = rows.Scan(&note.Id, &note.Note, &timestamp); err != nil {
+			break
+		}
+		note.Timestamp = time.UnixMilli(timestamp)
+		incident.Notes = append(incident.Notes, note)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	return incident, nil
+}
+
+func (incidents *Incidents) List(db Storage) ([]*Incident, error) {
+	var (
+		err   error
+		ids   = []uint64{}
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("incidents", "list")
+
+	query = `SELECT "incidentId" FROM "incidents" ORDER BY "timestamp" DESC`
+	if rows, err = db.Query(query); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	list := []*Incident{}
+
+	for _, id := range ids {
+		incident, err := incidents.GetIncident(id, db)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, incident)
+	}
+
+	return list, nil
+}
+
+func (incidents *Incidents) Process(request *IncidentRequest, db Storage) (*Incident, error) {
+	var (
+		err   error
+		query string
+	)
+
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	formatError := errorFormatter("incidents", "process")
+
+	switch request.Action {
+	case IncidentActionCreate:
+		if len(request.Label) == 0 {
+			return nil, errors.New("incidents.process: label is required")
+		}
+
+		query = `INSERT INTO "incidents" ("accesses", "closed", "description", "label", "timestamp") VALUES (?, ?, ?, ?, ?)`
+		if request.Id, err = db.Insert(query, "incidentId", joinIds(request.Accesses), false, request.Description, request.Label, time.Now().UnixMilli()); err != nil {
+			return nil, formatError(err, query)
+		}
+
+		if len(request.CallIds) > 0 {
+			if err = incidents.attach(request.Id, request.CallIds, db); err != nil {
+				return nil, err
+			}
+		}
+
+	case IncidentActionUpdate:
+		if len(request.Label) == 0 {
+			return nil, errors.New("incidents.process: label is required")
+		}
+
+		set := `"description" = ?, "label" = ?`
+		args := []any{request.Description, request.Label}
+
+		if request.Accesses != nil {
+			set += `, "accesses" = ?`
+			args = append(args, joinIds(request.Accesses))
+		}
+
+		query = fmt.Sprintf(`UPDATE "incidents" SET %s WHERE "incidentId" = ?`, set)
+
+		if _, err = db.Exec(query, append(args, request.Id)...); err != nil {
+			return nil, formatError(err, query)
+		}
+
+	case IncidentActionClose, IncidentActionReopen:
+		query = `UPDATE "incidents" SET "closed" = ? WHERE "incidentId" = ?`
+		if _, err = db.Exec(query, request.Action == IncidentActionClose, request.Id); err != nil {
+			return nil, formatError(err, query)
+		}
+
+	case IncidentActionDelete:
+		query = `DELETE FROM "incidents" WHERE "incidentId" = ?`
+		if _, err = db.Exec(query, request.Id); err != nil {
+			return nil, formatError(err, query)
+		}
+
+		return nil, nil
+
+	case IncidentActionAttach:
+		callIds := request.CallIds
+
+		if request.Search != nil {
+			ids, err := incidents.search(request.Search)
+			if err != nil {
+				return nil, err
+			}
+			callIds = append(callIds, ids...)
+		}
+
+		if !request.DateStart.IsZero() && !request.DateStop.IsZero() {
+			ids, err := incidents.timeRange(request, db)
+			if err != nil {
+				return nil, err
+			}
+			callIds = append(callIds, ids...)
+		}
+
+		if err = incidents.attach(request.Id, callIds, db); err != nil {
+			return nil, err
+		}
+
+	case IncidentActionDetach:
+		if len(request.CallIds) > 0 {
+			in, args := sqlIn(request.CallIds)
+
+			query = fmt.Sprintf(`DELETE FROM "incidentCalls" WHERE "incidentId" = ? AND "callId" IN %s`, in)
+			if _, err = db.Exec(query, append([]any{request.Id}, args...)...); err != nil {
+				return nil, formatError(err, query)
+			}
+		}
+
+	case IncidentActionNote:
+		if len(request.Note) == 0 {
+			return nil, errors.New("incidents.process: note is required")
+		}
+
+		query = `INSERT INTO "incidentNotes" ("incidentId", "note", "timestamp") VALUES (?, ?, ?)`
+		if _, err = db.Exec(query, request.Id, request.Note, time.Now().UnixMilli()); err != nil {
+			return nil, formatError(err, query)
+		}
+
+	default:
+		return nil, fmt.Errorf("incidents.process: unknown action %s", request.Action)
+	}
+
+	return incidents.GetIncident(request.Id, db)
+}
+
+func (incidents *Incidents) attach(incidentId uint64, callIds []uint64, db Storage) error {
+	var (
+		err   error
+		query string
+		tx    *StorageTx
+	)
+
+	formatError := errorFormatter("incidents", "attach")
+
+	if len(callIds) > IncidentAttachLimit {
+		return fmt.Errorf("incidents.attach: too many calls, %d calls at most can be attached at once", IncidentAttachLimit)
+	}
+
+	if tx, err = db.Begin(); err != nil {
+		return formatError(err, "")
+	}
+
+	for _, callId := range callIds {
+		var count uint
+
+		query = `SELECT COUNT(*) FROM "incidentCalls" WHERE "incidentId" = ? AND "callId" = ?`
+		if err = tx.QueryRow(query, incidentId, callId).Scan(&count); err != nil {
+			break
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		query = `INSERT INTO "incidentCalls" ("callId", "incidentId") VALUES (?, ?)`
+		if _, err = tx.Exec(query, callId, incidentId); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return formatError(err, query)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
+
+// search returns the ids of the calls matching the search options, walking
+// all the pages of results.
+func (incidents *Incidents) search(m map[string]any) ([]uint64, error) {
+	callIds := []uint64{}
+
+	client := &Client{Controller: incidents.controller}
+	client.Scope(incidents.controller.Groups, incidents.controller.Options, incidents.controller.Systems, incidents.controller.Tags)
+
+	searchOptions := NewCallSearchOptions().fromMap(m)
+	searchOptions.Limit = uint(500)
+
+	for {
+		searchResults, err := incidents.controller.Calls.Search(searchOptions, client)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, searchResult := range searchResults.Results {
+			callIds = append(callIds, searchResult.Id)
+		}
+
+		if len(callIds) > IncidentAttachLimit {
+			return callIds, nil
+		}
+
+		cursor, ok := parseCallsSearchCursor(searchResults.Cursor)
+		if !ok {
+			break
+		}
+
+		searchOptions.Cursor = cursor
+	}
+
+	return callIds, nil
+}
+
+// timeRange returns the ids of the calls of the talkgroups, or of all
+// talkgroups, between the dates of the request.
+func (incidents *Incidents) timeRange(request *IncidentRequest, db Storage) ([]uint64, error) {
+	var (
+		args    = []any{request.DateStart.UnixMilli(), request.DateStop.UnixMilli()}
+		callIds = []uint64{}
+		err     error
+		query   string
+		rows    *sql.Rows
+		where   = `c."timestamp" BETWEEN ? AND ?`
+	)
+
+	formatError := errorFormatter("incidents", "timerange")
+
+	if len(request.Talkgroups) > 0 {
+		a := []string{}
+		for _, talkgroup := range request.Talkgroups {
+			a = append(a, `(s."systemRef" = ? AND t."talkgroupRef" = ?)`)
+			args = append(args, talkgroup[0], talkgroup[1])
+		}
+		where += fmt.Sprintf(" AND (%s)", strings.Join(a, " OR "))
+	}
+
+	query = fmt.Sprintf(`SELECT c."callId" FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" WHERE %s ORDER BY c."timestamp" ASC LIMIT ?`, where)
+	if rows, err = db.Query(query, append(args, IncidentAttachLimit+1)...); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var callId uint64
+		if err = rows.Scan(&callId); err != nil {
+			break
+		}
+		callIds = append(callIds, callId)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	return callIds, nil
+}
+
+func parseIncidentAccesses(s string) []uint64 {
+	accesses := []uint64{}
+
+	for _, f := range strings.Split(s, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(f), 10, 64); err == nil {
+			accesses = append(accesses, id)
+		}
+	}
+
+	return accesses
+}
+
+func joinIds(ids []uint64) string {
+	a := []string{}
+
+	for _, id := range ids {
+		a = append(a, strconv.FormatUint(id, 10))
+	}
+
+	return strings.Join(a, ",")
+}
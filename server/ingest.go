@@ -0,0 +1,226 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	IngestQueueSize      = 1024
+	IngestStopTimeout    = 10 * time.Second
+	IngestWarnDepth      = 256
+	IngestWarnInterval   = time.Minute
+	IngestDefaultTimeout = 30 * time.Second
+)
+
+// ErrIngestStopped is reported to the sources of the calls left unwritten
+// when the server stops, they keep them for the next run.
+var ErrIngestStopped = errors.New("server stopping")
+
+// Ingester resolves incoming calls serially, then hands them to a pool of
+// workers. Calls are sharded by system and talkgroup, each shard being
+// drained by a single worker, so calls of a same talkgroup are checked for
+// duplicates, written and emitted in the order they were received.
+type Ingester struct {
+	cancel     context.CancelFunc
+	controller *Controller
+	ctx        context.Context
+	processing atomic.Int64
+	processed  atomic.Uint64
+	queues     []chan *Call
+	started    time.Time
+	warned     time.Time
+	warnMutex  sync.Mutex
+	waitGroup  sync.WaitGroup
+}
+
+type IngesterStats struct {
+	Pending    int    `json:"pending"`
+	Processed  uint64 `json:"processed"`
+	Processing int64  `json:"processing"`
+	Queued     int    `json:"queued"`
+	Shards     []int  `json:"shards"`
+	Uptime     uint64 `json:"uptime"`
+	Workers    int    `json:"workers"`
+}
+
+func NewIngester(controller *Controller) *Ingester {
+	workers := int(controller.Config.IngestWorkers)
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ingester := &Ingester{
+		controller: controller,
+		queues:     make([]chan *Call, workers),
+	}
+
+	for i := range ingester.queues {
+		ingester.queues[i] = make(chan *Call, IngestQueueSize)
+	}
+
+	ingester.ctx, ingester.cancel = context.WithCancel(context.Background())
+
+	return ingester
+}
+
+func (ingester *Ingester) Dispatch(call *Call) {
+	queue := ingester.queues[ingester.shard(call)]
+
+	if ingester.ctx.Err() != nil {
+		if call.ingested != nil {
+			call.ingested(ErrIngestStopped)
+		}
+		return
+	}
+
+	if len(queue) >= IngestWarnDepth {
+		ingester.warn()
+	}
+
+	select {
+	case queue <- call:
+	case <-ingester.ctx.Done():
+		if call.ingested != nil {
+			call.ingested(ErrIngestStopped)
+		}
+	}
+}
+
+func (ingester *Ingester) Start() error {
+	ingester.started = time.Now()
+
+	for _, queue := range ingester.queues {
+		ingester.waitGroup.Add(1)
+
+		go func(queue chan *Call) {
+			defer ingester.waitGroup.Done()
+
+			for {
+				select {
+				case call := <-queue:
+					ingester.processing.Add(1)
+					ingester.controller.ProcessCall(ingester.ctx, call)
+					ingester.processing.Add(-1)
+					ingester.processed.Add(1)
+
+				case <-ingester.ctx.Done():
+					return
+				}
+			}
+		}(queue)
+	}
+
+	go func() {
+		for {
+			select {
+			case call := <-ingester.controller.Ingest:
+				if len(ingester.controller.Ingest) >= IngestWarnDepth {
+					ingester.warn()
+				}
+
+				ingester.controller.IngestCall(call)
+
+			case <-ingester.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (ingester *Ingester) Stats() IngesterStats {
+	stats := IngesterStats{
+		Pending:    len(ingester.controller.Ingest),
+		Processed:  ingester.processed.Load(),
+		Processing: ingester.processing.Load(),
+		Shards:     make([]int, len(ingester.queues)),
+		Workers:    len(ingester.queues),
+	}
+
+	for i, queue := range ingester.queues {
+		stats.Shards[i] = len(queue)
+		stats.Queued += len(queue)
+	}
+
+	if !ingester.started.IsZero() {
+		stats.Uptime = uint64(time.Since(ingester.started).Seconds())
+	}
+
+	return stats
+}
+
+// Stop cancels in flight jobs, running ffmpeg processes are killed, and
+// waits for the workers to return. Calls still queued or cancelled are not
+// written, their sources keep them for the next run.
+func (ingester *Ingester) Stop() {
+	ingester.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		ingester.waitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(IngestStopTimeout):
+		ingester.controller.Logs.LogEvent(LogLevelWarn, "ingester.stop: workers did not stop in time")
+	}
+}
+
+func (ingester *Ingester) shard(call *Call) int {
+	var key uint64
+
+	if call.System != nil {
+		key = call.System.Id
+	}
+
+	if call.Talkgroup != nil {
+		key = key*31 + call.Talkgroup.Id
+	}
+
+	return int(key % uint64(len(ingester.queues)))
+}
+
+func (ingester *Ingester) warn() {
+	ingester.warnMutex.Lock()
+	defer ingester.warnMutex.Unlock()
+
+	if time.Since(ingester.warned) < IngestWarnInterval {
+		return
+	}
+
+	ingester.warned = time.Now()
+
+	stats := ingester.Stats()
+
+	ingester.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("ingester: %d calls waiting, %d pending resolution, %d workers busy", stats.Queued+stats.Pending, stats.Pending, stats.Processing))
+}
@@ -47,7 +47,7 @@ func NewLog() *Log {
 }
 
 type Logs struct {
-	database *Database
+	database Storage
 	mutex    sync.Mutex
 	daemon   *Daemon
 }
@@ -83,8 +83,8 @@ func (logs *Logs) LogEvent(level string, message string) error {
 			Message:  message,
 		}
 
-		query := fmt.Sprintf(`INSERT INTO "logs" ("level", "message", "timestamp") VALUES ('%s', '%s', %d)`, l.Level, l.Message, l.DateTime.UnixMilli())
-		if _, err := logs.database.Sql.Exec(query); err != nil {
+		query := `INSERT INTO "logs" ("level", "message", "timestamp") VALUES (?, ?, ?)`
+		if _, err := logs.database.Exec(query, l.Level, l.Message, l.DateTime.UnixMilli()); err != nil {
 			return fmt.Errorf("logs.logevent: %s in %s", err, query)
 		}
 	}
@@ -92,27 +92,28 @@ func (logs *Logs) LogEvent(level string, message string) error {
 	return nil
 }
 
-func (logs *Logs) Prune(db *Database, pruneDays uint) error {
+func (logs *Logs) Prune(db Storage, pruneDays uint) error {
 	logs.mutex.Lock()
 	defer logs.mutex.Unlock()
 
 	timestamp := time.Now().Add(-24 * time.Hour * time.Duration(pruneDays)).UnixMilli()
-	query := fmt.Sprintf(`DELETE FROM "logs" WHERE "timestamp" < %d`, timestamp)
+	query := `DELETE FROM "logs" WHERE "timestamp" < ?`
 
-	if _, err := db.Sql.Exec(query); err != nil {
+	if _, err := db.Exec(query, timestamp); err != nil {
 		return fmt.Errorf("%s in %s", err, query)
 	}
 
 	return nil
 }
 
-func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsSearchResults, error) {
+func (logs *Logs) Search(searchOptions *LogsSearchOptions, db Storage) (*LogsSearchResults, error) {
 	const (
 		ascOrder  = "ASC"
 		descOrder = "DESC"
 	)
 
 	var (
+		args []any
 		err  error
 		rows *sql.Rows
 
@@ -140,7 +141,8 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 
 	switch v := searchOptions.Level.(type) {
 	case string:
-		where += fmt.Sprintf(` AND "level" = '%s'`, v)
+		where += ` AND "level" = ?`
+		args = append(args, v)
 	}
 
 	switch v := searchOptions.Sort.(type) {
@@ -170,7 +172,8 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 			stop = start.Add(time.Hour*24 - time.Millisecond - time.Duration(v.Hour())).Add(time.Minute * time.Duration(-v.Minute()))
 		}
 
-		where += fmt.Sprintf(` AND ("timestamp" BETWEEN %d AND %d)`, start.UnixMilli(), stop.UnixMilli())
+		where += ` AND ("timestamp" BETWEEN ? AND ?)`
+		args = append(args, start.UnixMilli(), stop.UnixMilli())
 	}
 
 	switch v := searchOptions.Limit.(type) {
@@ -186,7 +189,7 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	}
 
 	query = fmt.Sprintf(`SELECT "timestamp" FROM "logs" WHERE %s ORDER BY "timestamp" ASC`, where)
-	if err = db.Sql.QueryRow(query).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
+	if err = db.QueryRow(query, args...).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
@@ -195,7 +198,7 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	}
 
 	query = fmt.Sprintf(`SELECT "timestamp" FROM "logs" WHERE %s ORDER BY "timestamp" DESC`, where)
-	if err = db.Sql.QueryRow(query).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
+	if err = db.QueryRow(query, args...).Scan(&timestamp); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
@@ -204,12 +207,12 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	}
 
 	query = fmt.Sprintf(`SELECT COUNT(*) FROM "logs" WHERE %s`, where)
-	if err = db.Sql.QueryRow(query).Scan(&logResults.Count); err != nil && err != sql.ErrNoRows {
+	if err = db.QueryRow(query, args...).Scan(&logResults.Count); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
-	query = fmt.Sprintf(`SELECT "logId", "level", "message", "timestamp" FROM "logs" WHERE %s ORDER BY "timestamp" %s limit %d offset %d`, where, order, limit, offset)
-	if rows, err = db.Sql.Query(query); err != nil && err != sql.ErrNoRows {
+	query = fmt.Sprintf(`SELECT "logId", "level", "message", "timestamp" FROM "logs" WHERE %s ORDER BY "timestamp" %s LIMIT ? OFFSET ?`, where, order)
+	if rows, err = db.Query(query, append(args, limit, offset)...); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(err, query)
 	}
 
@@ -256,7 +259,7 @@ func (logs *Logs) setDaemon(d *Daemon) {
 	logs.daemon = d
 }
 
-func (logs *Logs) setDatabase(d *Database) {
+func (logs *Logs) setDatabase(d Storage) {
 	logs.database = d
 }
 
@@ -114,8 +114,22 @@ func main() {
 
 	http.HandleFunc("/api/admin/alerts", controller.Admin.AlertsHandler)
 
+	http.HandleFunc("/api/admin/audio-profiles", controller.Admin.AudioProfilesHandler)
+
+	http.HandleFunc("/api/admin/backups", controller.Admin.BackupsHandler)
+
 	http.HandleFunc("/api/admin/config", controller.Admin.ConfigHandler)
 
+	http.HandleFunc("/api/admin/discoveries", controller.Admin.DiscoveriesHandler)
+
+	http.HandleFunc("/api/admin/downsampling", controller.Admin.DownsamplingHandler)
+
+	http.HandleFunc("/api/admin/incidents", controller.Admin.IncidentsHandler)
+
+	http.HandleFunc("/api/admin/ingest", controller.Admin.IngestHandler)
+
+	http.HandleFunc("/api/admin/jobs", controller.Admin.JobsHandler)
+
 	http.HandleFunc("/api/admin/login", controller.Admin.LoginHandler)
 
 	http.HandleFunc("/api/admin/logout", controller.Admin.LogoutHandler)
@@ -124,14 +138,30 @@ func main() {
 
 	http.HandleFunc("/api/admin/password", controller.Admin.PasswordHandler)
 
+	http.HandleFunc("/api/admin/quarantine", controller.Admin.QuarantineHandler)
+
+	http.HandleFunc("/api/admin/retention", controller.Admin.RetentionHandler)
+
+	http.HandleFunc("/api/admin/statistics", controller.Admin.StatisticsHandler)
+
 	http.HandleFunc("/api/admin/user-add", controller.Admin.UserAddHandler)
 
 	http.HandleFunc("/api/admin/user-remove", controller.Admin.UserRemoveHandler)
 
 	http.HandleFunc("/api/call-upload", controller.Api.CallUploadHandler)
 
+	http.HandleFunc("/api/calls", controller.Api.CallsHandler)
+
+	http.HandleFunc("/api/conversation", controller.Api.ConversationHandler)
+
+	http.HandleFunc("/api/incident", controller.Api.IncidentHandler)
+
 	http.HandleFunc("/api/trunk-recorder-call-upload", controller.Api.TrunkRecorderCallUploadHandler)
 
+	http.HandleFunc("/api/statistics", controller.Api.StatisticsHandler)
+
+	http.HandleFunc("/api/units", controller.Api.UnitsHandler)
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		url := r.URL.Path[1:]
 
@@ -27,6 +27,7 @@ import (
 const (
 	MessageCommandCall           = "CAL"
 	MessageCommandConfig         = "CFG"
+	MessageCommandConversation   = "CNV"
 	MessageCommandExpired        = "XPR"
 	MessageCommandIOS            = "IOS"
 	MessageCommandListCall       = "LCL"
@@ -29,12 +29,11 @@ import (
 	"strings"
 )
 
-func migrateAccesses(db *Database) error {
+func migrateAccesses(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		accessId   sql.NullInt64
 		code       sql.NullString
@@ -47,19 +46,16 @@ func migrateAccesses(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateAccesses")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerAccesses"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerAccesses"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating accesses...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "code", "expiration", "ident", "limit", "order", "systems" FROM "rdioScannerAccesses"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -77,7 +73,7 @@ func migrateAccesses(db *Database) error {
 		}
 
 		if code.Valid && len(code.String) > 0 {
-			access.Code = escapeQuotes(code.String)
+			access.Code = code.String
 		} else {
 			continue
 		}
@@ -87,7 +83,7 @@ func migrateAccesses(db *Database) error {
 		}
 
 		if ident.Valid {
-			access.Ident = escapeQuotes(ident.String)
+			access.Ident = ident.String
 		}
 
 		if limit.Valid {
@@ -102,8 +98,8 @@ func migrateAccesses(db *Database) error {
 			access.Systems = systems.String
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "accesses" ("accessId", "code", "expiration", "ident", "limit", "order", "systems") VALUES (%d, '%s', %d, '%s', %d, %d, '%s')`, access.Id, access.Code, access.Expiration, access.Ident, access.Limit, access.Order, access.Systems)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "accesses" ("accessId", "code", "expiration", "ident", "limit", "order", "systems") VALUES (?, ?, ?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, access.Id, access.Code, access.Expiration, access.Ident, access.Limit, access.Order, access.Systems); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
@@ -111,24 +107,18 @@ func migrateAccesses(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerAccesses"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateApikeys(db *Database) error {
+func migrateApikeys(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		apikeyId sql.NullInt64
 		disabled sql.NullBool
@@ -140,19 +130,16 @@ func migrateApikeys(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateApikeys")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerApiKeys"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerApiKeys"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating apikeys...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "disabled", "ident", "key", "order", "systems" FROM "rdioScannerApiKeys"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -174,11 +161,11 @@ func migrateApikeys(db *Database) error {
 		}
 
 		if ident.Valid {
-			apikey.Ident = escapeQuotes(ident.String)
+			apikey.Ident = ident.String
 		}
 
 		if key.Valid {
-			apikey.Key = escapeQuotes(key.String)
+			apikey.Key = key.String
 		}
 
 		if order.Valid {
@@ -189,8 +176,8 @@ func migrateApikeys(db *Database) error {
 			apikey.Systems = systems.String
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "apikeys" ("apikeyId", "disabled", "ident", "key", "order", "systems") VALUES (%d, %t, '%s', '%s', %d, '%s')`, apikey.Id, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, apikey.Systems)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "apikeys" ("apikeyId", "disabled", "ident", "key", "order", "systems") VALUES (?, ?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, apikey.Id, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, apikey.Systems); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
@@ -198,24 +185,18 @@ func migrateApikeys(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerApiKeys"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateCalls(db *Database) error {
+func migrateCalls(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		systems    = map[int32]int32{}
 		talkgroups = map[int32]map[int32]int32{}
@@ -240,14 +221,16 @@ func migrateCalls(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateCalls")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerCalls"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerCalls"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating calls...")
 
 	query = `SELECT s."systemId", s."systemRef", t."talkgroupId", t."talkgroupRef" FROM "systems" AS s LEFT JOIN "talkgroups" AS t`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = tx.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -268,13 +251,8 @@ func migrateCalls(db *Database) error {
 
 	rows.Close()
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "id", "audio", "audioName", "audioType", "dateTime", "frequencies", "frequency", "patches", "source", "sources", "system", "talkgroup" FROM "rdioScannerCalls"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -298,7 +276,7 @@ func migrateCalls(db *Database) error {
 		}
 
 		if audioFilename.Valid {
-			call.AudioFilename = escapeQuotes(audioFilename.String)
+			call.AudioFilename = audioFilename.String
 		}
 
 		if audioMime.Valid {
@@ -319,14 +297,8 @@ func migrateCalls(db *Database) error {
 			continue
 		}
 
-		if db.Config.DbType == DbTypePostgresql {
-			query = fmt.Sprintf(`INSERT INTO "calls" ("callId", "audio", "audioFilename", "audioMime", "siteRef", "systemId", "talkgroupId", "timestamp") VALUES (%d, $1, '%s', '%s', 0, %d, %d, %d)`, call.Id, call.AudioFilename, call.AudioMime, systems[systemRef.Int32], talkgroups[systemRef.Int32][talkgroupRef.Int32], timestamp)
-
-		} else {
-			query = fmt.Sprintf(`INSERT INTO "calls" ("callId", "audio", "audioFilename", "audioMime", "siteRef", "systemId", "talkgroupId", "timestamp") VALUES (%d, ?, '%s', '%s', 0, %d, %d, %d)`, call.Id, call.AudioFilename, call.AudioMime, systems[systemRef.Int32], talkgroups[systemRef.Int32][talkgroupRef.Int32], timestamp)
-		}
-
-		if _, err = tx.Exec(query, call.Audio); err == nil {
+		query = `INSERT INTO "calls" ("callId", "audio", "audioFilename", "audioMime", "siteRef", "systemId", "talkgroupId", "timestamp") VALUES (?, ?, ?, ?, 0, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, call.Id, call.Audio, call.AudioFilename, call.AudioMime, systems[systemRef.Int32], talkgroups[systemRef.Int32][talkgroupRef.Int32], timestamp); err == nil {
 			if frequencies.Valid && len(frequencies.String) > 0 {
 				var f any
 				if err = json.Unmarshal([]byte(frequencies.String), &f); err == nil {
@@ -362,8 +334,8 @@ func migrateCalls(db *Database) error {
 									spikeCount = uint(v)
 								}
 
-								query = fmt.Sprintf(`INSERT INTO "callFrequencies" ("callId", "errors", "frequency", "offset", "spikes") VALUES (%d, %d, %d, %f, %d)`, call.Id, errorCount, freq, pos, spikeCount)
-								if _, err = tx.Exec(query); err != nil {
+								query = `INSERT INTO "callFrequencies" ("callId", "errors", "frequency", "offset", "spikes") VALUES (?, ?, ?, ?, ?)`
+								if _, err = migrateExec(tx, query, call.Id, errorCount, freq, pos, spikeCount); err != nil {
 									log.Println(formatError(err, query))
 								}
 							}
@@ -372,8 +344,8 @@ func migrateCalls(db *Database) error {
 				}
 
 			} else if frequency.Valid && frequency.Int32 > 0 {
-				query = fmt.Sprintf(`INSERT INTO "callFrequencies" ("callId", "errors", "frequency", "offset", "spikes") VALUES (%d, 0, %d, 0, 0)`, call.Id, frequency.Int32)
-				if _, err = tx.Exec(query); err != nil {
+				query = `INSERT INTO "callFrequencies" ("callId", "errors", "frequency", "offset", "spikes") VALUES (?, 0, ?, 0, 0)`
+				if _, err = migrateExec(tx, query, call.Id, frequency.Int32); err != nil {
 					log.Println(formatError(err, query))
 				}
 			}
@@ -387,8 +359,8 @@ func migrateCalls(db *Database) error {
 							switch i := v.(type) {
 							case float64:
 								if i := talkgroups[systemRef.Int32][int32(i)]; i > 0 {
-									query = fmt.Sprintf(`INSERT INTO "callPatches" ("callId", "talkgroupId") VALUES (%d, %d)`, call.Id, i)
-									if _, err = tx.Exec(query); err != nil {
+									query = `INSERT INTO "callPatches" ("callId", "talkgroupId") VALUES (?, ?)`
+									if _, err = migrateExec(tx, query, call.Id, i); err != nil {
 										log.Println(formatError(err, query))
 									}
 								}
@@ -409,8 +381,8 @@ func migrateCalls(db *Database) error {
 								switch src := (m["src"]).(type) {
 								case float64:
 									if src > 0 {
-										query = fmt.Sprintf(`INSERT INTO "callUnits" ("callId", "offset", "unitRef") VALUES (%d, %f, %f)`, call.Id, m["pos"], src)
-										if _, err = tx.Exec(query); err != nil {
+										query = `INSERT INTO "callUnits" ("callId", "offset", "unitRef") VALUES (?, ?, ?)`
+										if _, err = migrateExec(tx, query, call.Id, m["pos"], uint(src)); err != nil {
 											log.Println(formatError(err, query))
 										}
 									}
@@ -422,12 +394,12 @@ func migrateCalls(db *Database) error {
 
 			} else if source.Valid && source.Int32 > 0 {
 				var c int
-				query = fmt.Sprintf(`SELECT COUNT(*) FROM "units" WHERE "systemId" = %d AND "unitRef" = %d`, systems[systemRef.Int32], source.Int32)
-				if err = tx.QueryRow(query).Scan(&c); err == nil && c == 0 {
-					query = fmt.Sprintf(`INSERT INTO "units" ("label", "systemId", "unitRef") VALUES(%d, %d, %d)`, source.Int32, systems[systemRef.Int32], source.Int32)
-					if _, err = tx.Exec(query); err != nil {
-						query = fmt.Sprintf(`INSERT INTO "callUnits" ("callId", "offset", "unitRef") VALUES (%d, %d, %d)`, call.Id, 0, source.Int32)
-						if _, err = tx.Exec(query); err != nil {
+				query = `SELECT COUNT(*) FROM "units" WHERE "systemId" = ? AND "unitRef" = ?`
+				if err = tx.QueryRow(query, systems[systemRef.Int32], source.Int32).Scan(&c); err == nil && c == 0 {
+					query = `INSERT INTO "units" ("label", "systemId", "unitRef") VALUES(?, ?, ?)`
+					if _, err = migrateExec(tx, query, fmt.Sprint(source.Int32), systems[systemRef.Int32], source.Int32); err != nil {
+						query = `INSERT INTO "callUnits" ("callId", "offset", "unitRef") VALUES (?, ?, ?)`
+						if _, err = migrateExec(tx, query, call.Id, 0, source.Int32); err != nil {
 							log.Println(formatError(err, query))
 						}
 
@@ -448,24 +420,85 @@ func migrateCalls(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerCalls"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
+	return nil
+}
+
+// migrateVersion6 imports the tables of a version 6 database, each import
+// dropping its source table.
+func migrateVersion6(tx *StorageTx) error {
+	for _, migrate := range []func(tx *StorageTx) error{
+		migrateGroups,
+		migrateTags,
+		migrateSystems,
+		migrateTalkgroups,
+		migrateUnits,
+		migrateOptions,
+		migrateMeta,
+		migrateLogs,
+		migrateDownstreams,
+		migrateDirwatches,
+		migrateCalls,
+		migrateApikeys,
+		migrateAccesses,
+	} {
+		if err := migrate(tx); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func migrateDirwatches(db *Database) error {
+func migrateColumns(tx *StorageTx, columns [][]string) error {
+	formatError := errorFormatter("migration", "migrateColumns")
+
+	for _, column := range columns {
+		if len(column) != 3 {
+			continue
+		}
+
+		var (
+			count uint
+			query string
+		)
+
+		switch tx.db.Config.DbType {
+		case DbTypeMariadb, DbTypeMysql:
+			query = `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+		case DbTypePostgresql:
+			query = `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`
+		default:
+			query = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+		}
+
+		if err := tx.QueryRow(query, column[0], column[1]).Scan(&count); err != nil {
+			return formatError(err, query)
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		log.Printf("adding column %s to table %s...\n", column[1], column[0])
+
+		query = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, column[0], column[1], column[2])
+		if _, err := tx.Exec(query); err != nil {
+			return formatError(err, query)
+		}
+	}
+
+	return nil
+}
+
+func migrateDirwatches(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		systems    = map[int32]int32{}
 		talkgroups = map[int32]map[int32]int32{}
@@ -491,14 +524,16 @@ func migrateDirwatches(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateDirwatches")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerDirwatches"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerDirwatches"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating dirwatches...")
 
 	query = `SELECT s."systemId", s."systemRef", t."talkgroupId", t."talkgroupRef" FROM "systems" AS s LEFT JOIN "talkgroups" AS t`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = tx.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -519,13 +554,8 @@ func migrateDirwatches(db *Database) error {
 
 	rows.Close()
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "delay", "deleteAfter", "directory", "disabled", "extension", "frequency", "mask", "order", "systemId", "talkgroupId", "type" FROM "rdioScannerDirwatches"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -551,7 +581,7 @@ func migrateDirwatches(db *Database) error {
 		}
 
 		if directory.Valid && len(directory.String) > 0 {
-			dirwatch.Directory = escapeQuotes(directory.String)
+			dirwatch.Directory = directory.String
 		} else {
 			continue
 		}
@@ -561,7 +591,7 @@ func migrateDirwatches(db *Database) error {
 		}
 
 		if extension.Valid {
-			dirwatch.Extension = escapeQuotes(extension.String)
+			dirwatch.Extension = extension.String
 		}
 
 		if frequency.Valid {
@@ -569,7 +599,7 @@ func migrateDirwatches(db *Database) error {
 		}
 
 		if mask.Valid && len(mask.String) > 0 {
-			dirwatch.Mask = escapeQuotes(mask.String)
+			dirwatch.Mask = mask.String
 		}
 
 		if kind.Valid && len(kind.String) > 0 {
@@ -592,33 +622,27 @@ func migrateDirwatches(db *Database) error {
 			refTalkgroup = nil
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "dirwatches" ("dirwatchId", "delay", "deleteAfter", "directory", "disabled", "extension", "frequency", "mask", "order", "systemId", "talkgroupId", "type") VALUES (%d, %d, %t, '%s', %t, '%s', %d, '%s', %d, %d, %d, '%s')`, dirwatch.Id, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, refSystem, refTalkgroup, dirwatch.Kind)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "dirwatches" ("dirwatchId", "delay", "deleteAfter", "directory", "disabled", "extension", "frequency", "mask", "order", "systemId", "talkgroupId", "type") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, dirwatch.Id, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, refSystem, refTalkgroup, dirwatch.Kind); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
 
 	rows.Close()
 
-	query = `DROP TABLE "RdioScannerDirWatches"`
-	if _, err = tx.Exec(`DROP TABLE "RdioScannerDirWatches"`); err != nil {
+	query = `DROP TABLE "rdioScannerDirwatches"`
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateDownstreams(db *Database) error {
+func migrateDownstreams(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		apikey       sql.NullString
 		disabled     sql.NullBool
@@ -630,19 +654,16 @@ func migrateDownstreams(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateDownstreams")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerDownstreams"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerDownstreams"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating downstreams...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "apiKey", "disabled", "order", "systems", "url" FROM "rdioScannerDownstreams"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -660,7 +681,7 @@ func migrateDownstreams(db *Database) error {
 		}
 
 		if apikey.Valid && len(apikey.String) > 0 {
-			downstream.Apikey = escapeQuotes(apikey.String)
+			downstream.Apikey = apikey.String
 		} else {
 			continue
 		}
@@ -680,13 +701,13 @@ func migrateDownstreams(db *Database) error {
 		}
 
 		if url.Valid && len(url.String) > 0 {
-			downstream.Url = escapeQuotes(url.String)
+			downstream.Url = url.String
 		} else {
 			continue
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "downstreams" ("downstreamId", "apikey", "disabled", "order", "systems", "url") VALUES (%d, '%s', %t, %d, '%s', '%s')`, downstream.Id, downstream.Apikey, downstream.Disabled, downstream.Order, downstream.Systems, downstream.Url)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "downstreams" ("downstreamId", "apikey", "disabled", "order", "systems", "url") VALUES (?, ?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, downstream.Id, downstream.Apikey, downstream.Disabled, downstream.Order, downstream.Systems, downstream.Url); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
@@ -694,24 +715,41 @@ func migrateDownstreams(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerDownstreams"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
+	return nil
+}
+
+// migrateExec runs a statement of the legacy import within a savepoint, so
+// that its failure, which is logged and skipped, leaves the transaction usable
+// on PostgreSQL.
+func migrateExec(tx *StorageTx, query string, args ...any) (sql.Result, error) {
+	if _, err := tx.Exec(`SAVEPOINT migrate`); err != nil {
+		return nil, err
 	}
 
-	return nil
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT migrate`); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`RELEASE SAVEPOINT migrate`); err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
-func migrateGroups(db *Database) error {
+func migrateGroups(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		groups  = []*Group{}
 		groupId sql.NullInt32
@@ -720,19 +758,16 @@ func migrateGroups(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateGroups")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerGroups"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerGroups"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating groups...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "label" FROM "rdioScannerGroups"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -750,7 +785,7 @@ func migrateGroups(db *Database) error {
 		}
 
 		if label.Valid {
-			group.Label = escapeQuotes(label.String)
+			group.Label = label.String
 		}
 
 		groups = append(groups, group)
@@ -765,31 +800,25 @@ func migrateGroups(db *Database) error {
 	for i, group := range groups {
 		group.Order = uint(i + 1)
 
-		query = fmt.Sprintf(`INSERT INTO "groups" ("groupId", "label", "order") VALUES (%d, '%s', %d)`, group.Id, group.Label, group.Order)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "groups" ("groupId", "label", "order") VALUES (?, ?, ?)`
+		if _, err = migrateExec(tx, query, group.Id, group.Label, group.Order); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
 
 	query = `DROP TABLE "rdioScannerGroups"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateLogs(db *Database) error {
+func migrateLogs(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		timestamp int64
 
@@ -801,19 +830,16 @@ func migrateLogs(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateLogs")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerLogs"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerLogs"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating logs...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "dateTime", "level", "message" FROM "rdioScannerLogs"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -843,13 +869,13 @@ func migrateLogs(db *Database) error {
 		}
 
 		if message.Valid && len(message.String) > 0 {
-			l.Message = escapeQuotes(message.String)
+			l.Message = message.String
 		} else {
 			continue
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "logs" ("logId", "level", "message", "timestamp") VALUES (%d, '%s', '%s', %d)`, l.Id, l.Level, l.Message, timestamp)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "logs" ("logId", "level", "message", "timestamp") VALUES (?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, l.Id, l.Level, l.Message, timestamp); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
@@ -857,41 +883,37 @@ func migrateLogs(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerLogs"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateMeta(db *Database) error {
+func migrateMeta(tx *StorageTx) error {
 	formatError := errorFormatter("migration", "migrateMeta")
 
-	if _, err := db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerMeta"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerMeta"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating meta...")
 
 	query := `DROP TABLE "rdioScannerMeta"`
-	if _, err := db.Sql.Exec(query); err != nil {
+	if _, err := tx.Exec(query); err != nil {
 		return formatError(err, query)
 	}
 
 	return nil
 }
 
-func migrateOptions(db *Database) error {
+func migrateOptions(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		key   sql.NullString
 		value sql.NullString
@@ -899,19 +921,16 @@ func migrateOptions(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateOptions")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerConfigs"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerConfigs"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating options...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "key", "val" FROM "rdioScannerConfigs"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -931,8 +950,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["audioConversion"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "audioConversion", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "audioConversion", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -940,8 +959,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["autoPopulate"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "autoPopulate", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "autoPopulate", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -949,8 +968,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["branding"].(type) {
 				case string:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "branding", escapeQuotes(string(b)))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "branding", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -958,8 +977,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["dimmerDelay"].(type) {
 				case float64:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "dimmerDelay", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "dimmerDelay", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -967,8 +986,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["disableDuplicateDetection"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "disableDuplicateDetection", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "disableDuplicateDetection", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -976,8 +995,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["duplicateDetectionTimeFrame"].(type) {
 				case float64:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "duplicateDetectionTimeFrame", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "duplicateDetectionTimeFrame", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -985,8 +1004,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["email"].(type) {
 				case string:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "email", escapeQuotes(string(b)))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "email", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -994,8 +1013,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["keypadBeeps"].(type) {
 				case string:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "keypadBeeps", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "keypadBeeps", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1003,8 +1022,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["maxClients"].(type) {
 				case float64:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "maxClients", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "maxClients", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1012,8 +1031,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["playbackGoesLive"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "playbackGoesLive", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "playbackGoesLive", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1021,8 +1040,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["pruneDays"].(type) {
 				case float64:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "pruneDays", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "pruneDays", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1030,8 +1049,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["showListenersCount"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "showListenersCount", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "showListenersCount", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1039,8 +1058,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["sortTalkgroups"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "sortTalkgroups", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "sortTalkgroups", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1048,8 +1067,8 @@ func migrateOptions(db *Database) error {
 				switch v := m["time12hFormat"].(type) {
 				case bool:
 					if b, err := json.Marshal(v); err == nil {
-						query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, "time12hFormat", string(b))
-						if _, err = tx.Exec(query); err != nil {
+						query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+						if _, err = migrateExec(tx, query, "time12hFormat", string(b)); err != nil {
 							log.Println(formatError(err, query))
 						}
 					}
@@ -1057,8 +1076,8 @@ func migrateOptions(db *Database) error {
 			}
 
 		} else {
-			query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, escapeQuotes(key.String), escapeQuotes(value.String))
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+			if _, err = migrateExec(tx, query, key.String, value.String); err != nil {
 				log.Println(formatError(err, query))
 			}
 		}
@@ -1067,24 +1086,18 @@ func migrateOptions(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerConfigs"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateSystems(db *Database) error {
+func migrateSystems(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		autoPopulate sql.NullBool
 		blacklists   sql.NullString
@@ -1097,19 +1110,16 @@ func migrateSystems(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateSystems")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerSystems"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerSystems"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating systems...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "autoPopulate", "blacklists", "id", "label", "led", "order" FROM "rdioScannerSystems"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -1135,7 +1145,7 @@ func migrateSystems(db *Database) error {
 		}
 
 		if label.Valid {
-			system.Label = escapeQuotes(label.String)
+			system.Label = label.String
 		}
 
 		if led.Valid {
@@ -1150,8 +1160,8 @@ func migrateSystems(db *Database) error {
 			system.SystemRef = uint(systemRef.Int32)
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "systems" ("systemId", "autoPopulate", "blacklists", "label", "led", "order", "systemRef") VALUES (%d, %t, '%s', '%s', '%s', %d, %d)`, system.Id, system.AutoPopulate, system.Blacklists, system.Label, system.Led, system.Order, system.SystemRef)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "systems" ("systemId", "autoPopulate", "blacklists", "label", "led", "order", "systemRef") VALUES (?, ?, ?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, system.Id, system.AutoPopulate, system.Blacklists, system.Label, system.Led, system.Order, system.SystemRef); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
@@ -1159,24 +1169,42 @@ func migrateSystems(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerSystems"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
+	return nil
+}
+
+// migrateTableExists tells whether a table is in the database without
+// querying it, a failed query aborting the transaction on PostgreSQL.
+func migrateTableExists(tx *StorageTx, table string) (bool, error) {
+	var (
+		count uint
+		query string
+	)
+
+	switch tx.db.Config.DbType {
+	case DbTypeMariadb, DbTypeMysql:
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+	case DbTypePostgresql:
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`
+	default:
+		query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ? COLLATE NOCASE`
 	}
 
-	return nil
+	if err := tx.QueryRow(query, table).Scan(&count); err != nil {
+		return false, fmt.Errorf("%v in %s", err, query)
+	}
+
+	return count > 0, nil
 }
 
-func migrateTags(db *Database) error {
+func migrateTags(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		label sql.NullString
 		tags  = []*Tag{}
@@ -1185,19 +1213,16 @@ func migrateTags(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateTags")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerTags"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerTags"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating tags...")
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "label" FROM "rdioScannerTags"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -1215,7 +1240,7 @@ func migrateTags(db *Database) error {
 		}
 
 		if label.Valid {
-			tag.Label = escapeQuotes(label.String)
+			tag.Label = label.String
 		}
 
 		tags = append(tags, tag)
@@ -1230,31 +1255,25 @@ func migrateTags(db *Database) error {
 	for i, tag := range tags {
 		tag.Order = uint(i + 1)
 
-		query = fmt.Sprintf(`INSERT INTO "tags" ("tagId", "label", "order") VALUES (%d, '%s', %d)`, tag.Id, tag.Label, tag.Order)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "tags" ("tagId", "label", "order") VALUES (?, ?, ?)`
+		if _, err = migrateExec(tx, query, tag.Id, tag.Label, tag.Order); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
 
 	query = `DROP TABLE "rdioScannerTags"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateTalkgroups(db *Database) error {
+func migrateTalkgroups(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		systems = map[int64]int64{}
 
@@ -1272,14 +1291,16 @@ func migrateTalkgroups(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateTalkgroups")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerTalkgroups"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerTalkgroups"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating talkgroups...")
 
 	query = `SELECT "systemId", "systemRef" FROM "systems"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = tx.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -1295,13 +1316,8 @@ func migrateTalkgroups(db *Database) error {
 
 	rows.Close()
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "frequency", "groupId", "id", "label", "led", "name", "order", "systemId", "tagId" FROM "rdioScannerTalkgroups"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -1327,7 +1343,7 @@ func migrateTalkgroups(db *Database) error {
 		}
 
 		if label.Valid {
-			talkgroup.Label = escapeQuotes(label.String)
+			talkgroup.Label = label.String
 		}
 
 		if led.Valid {
@@ -1335,7 +1351,7 @@ func migrateTalkgroups(db *Database) error {
 		}
 
 		if name.Valid {
-			talkgroup.Name = escapeQuotes(name.String)
+			talkgroup.Name = name.String
 		}
 
 		if order.Valid {
@@ -1354,10 +1370,10 @@ func migrateTalkgroups(db *Database) error {
 			talkgroup.TagId = uint64(tagId.Int64)
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "talkgroups" ("talkgroupId", "frequency", "label", "led", "name", "order", "systemId", "tagId", "talkgroupRef") VALUES (%d, %d, '%s', '%s', '%s', %d, %d, %d, %d)`, talkgroup.Id, talkgroup.Frequency, talkgroup.Label, talkgroup.Led, talkgroup.Name, talkgroup.Order, systems[systemId.Int64], talkgroup.TagId, talkgroup.TalkgroupRef)
-		if _, err = tx.Exec(query); err == nil {
-			query = fmt.Sprintf(`INSERT INTO "talkgroupGroups" ("groupId", "talkgroupId") VALUES (%d, %d)`, talkgroup.GroupIds[0], talkgroup.Id)
-			if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "talkgroups" ("talkgroupId", "frequency", "label", "led", "name", "order", "systemId", "tagId", "talkgroupRef") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, talkgroup.Id, talkgroup.Frequency, talkgroup.Label, talkgroup.Led, talkgroup.Name, talkgroup.Order, systems[systemId.Int64], talkgroup.TagId, talkgroup.TalkgroupRef); err == nil {
+			query = `INSERT INTO "talkgroupGroups" ("groupId", "talkgroupId") VALUES (?, ?)`
+			if _, err = migrateExec(tx, query, talkgroup.GroupIds[0], talkgroup.Id); err != nil {
 				log.Println(formatError(err, query))
 			}
 
@@ -1369,24 +1385,18 @@ func migrateTalkgroups(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerTalkgroups"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
 
-func migrateUnits(db *Database) error {
+func migrateUnits(tx *StorageTx) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
 
 		systems = map[int32]int32{}
 
@@ -1399,14 +1409,16 @@ func migrateUnits(db *Database) error {
 
 	formatError := errorFormatter("migration", "migrateUnits")
 
-	if _, err = db.Sql.Exec(`SELECT COUNT(*) FROM "rdioScannerUnits"`); err != nil {
+	if ok, err := migrateTableExists(tx, "rdioScannerUnits"); err != nil {
+		return formatError(err, "")
+	} else if !ok {
 		return nil
 	}
 
 	log.Println("migrating units...")
 
 	query = `SELECT "systemId", "systemRef" FROM "systems"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = tx.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -1422,13 +1434,8 @@ func migrateUnits(db *Database) error {
 
 	rows.Close()
 
-	if tx, err = db.Sql.Begin(); err != nil {
-		return formatError(err, "")
-	}
-
 	query = `SELECT "_id", "id", "label", "order", "systemId" FROM "rdioScannerUnits"`
 	if rows, err = tx.Query(query); err != nil {
-		tx.Rollback()
 		return formatError(err, query)
 	}
 
@@ -1448,7 +1455,7 @@ func migrateUnits(db *Database) error {
 		}
 
 		if label.Valid {
-			unit.Label = escapeQuotes(label.String)
+			unit.Label = label.String
 		}
 
 		if order.Valid {
@@ -1459,8 +1466,8 @@ func migrateUnits(db *Database) error {
 			unit.UnitRef = uint(unitRef.Int32)
 		}
 
-		query = fmt.Sprintf(`INSERT INTO "units" ("unitId", "label", "order", "systemId", "unitRef") VALUES (%d, '%s', %d, %d, %d)`, unitId.Int64, unit.Label, unit.Order, systems[systemId.Int32], unit.Id)
-		if _, err = tx.Exec(query); err != nil {
+		query = `INSERT INTO "units" ("unitId", "label", "order", "systemId", "unitRef") VALUES (?, ?, ?, ?, ?)`
+		if _, err = migrateExec(tx, query, unitId.Int64, unit.Label, unit.Order, systems[systemId.Int32], unit.Id); err != nil {
 			log.Println(formatError(err, query))
 		}
 	}
@@ -1468,14 +1475,9 @@ func migrateUnits(db *Database) error {
 	rows.Close()
 
 	query = `DROP TABLE "rdioScannerUnits"`
-	if _, err = tx.Exec(query); err != nil {
+	if _, err = migrateExec(tx, query); err != nil {
 		log.Println(formatError(err, query))
 	}
 
-	if err = tx.Commit(); err != nil {
-		tx.Rollback()
-		return formatError(err, "")
-	}
-
 	return nil
 }
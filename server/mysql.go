@@ -68,6 +68,8 @@ var MysqlSchema = []string{
 	`CREATE TABLE IF NOT EXISTS "systems" (
     "systemId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "alert" text NOT NULL DEFAULT '',
+    "audioProcessing" text NOT NULL DEFAULT '',
+    "audioProfile" text NOT NULL DEFAULT '',
     "autoPopulate" boolean NOT NULL DEFAULT false,
     "blacklists" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
@@ -82,6 +84,7 @@ var MysqlSchema = []string{
     "siteId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "label" text NOT NULL,
     "order" integer NOT NULL DEFAULT 0,
+    "priority" integer NOT NULL DEFAULT 0,
     "siteRef" integer NOT NULL,
     "systemId" bigint NOT NULL DEFAULT 0,
     FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE
@@ -90,6 +93,8 @@ var MysqlSchema = []string{
 	`CREATE TABLE IF NOT EXISTS "talkgroups" (
     "talkgroupId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "alert" text NOT NULL DEFAULT '',
+    "audioProcessing" text NOT NULL DEFAULT '',
+    "audioProfile" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
     "frequency" integer NOT NULL DEFAULT 0,
     "label" text NOT NULL,
@@ -117,16 +122,29 @@ var MysqlSchema = []string{
     "audio" blob NOT NULL,
     "audioFilename" text NOT NULL,
     "audioMime" text NOT NULL,
+    "audioProfile" text NOT NULL DEFAULT '',
+    "conversationId" bigint NOT NULL DEFAULT 0,
+    "duration" integer NOT NULL DEFAULT 0,
+    "fingerprint" text NOT NULL DEFAULT '',
+    "flags" text NOT NULL DEFAULT '',
+    "linkedCallId" bigint NOT NULL DEFAULT 0,
+    "peakLevel" real NOT NULL DEFAULT 0,
+    "peaks" text NOT NULL DEFAULT '',
+    "rmsLevel" real NOT NULL DEFAULT 0,
+    "sampleRate" integer NOT NULL DEFAULT 0,
     "siteRef" integer NOT NULL DEFAULT 0,
     "systemId" bigint NOT NULL,
     "talkgroupId" bigint NOT NULL,
     "timestamp" bigint NOT NULL,
+    "trimmed" real NOT NULL DEFAULT 0,
     FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
     FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
 	`CREATE INDEX IF NOT EXISTS "calls_idx" ON "calls" ("systemId","siteRef","talkgroupId","timestamp");`,
 
+	`CREATE INDEX IF NOT EXISTS "calls_timestamp_idx" ON "calls" ("timestamp","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callFrequencies" (
     "callFrequencyId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "callId" bigint NOT NULL,
@@ -138,6 +156,8 @@ var MysqlSchema = []string{
     FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callFrequencies_idx" ON "callFrequencies" ("frequency","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callPatches" (
     "callPatchId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "callId" bigint NOT NULL,
@@ -146,6 +166,8 @@ var MysqlSchema = []string{
     FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callPatches_idx" ON "callPatches" ("talkgroupId","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callUnits" (
     "callUnitId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "callId" bigint NOT NULL,
@@ -154,6 +176,21 @@ var MysqlSchema = []string{
     FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callUnits_idx" ON "callUnits" ("unitRef","callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "conversations" (
+    "conversationId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "callCount" integer NOT NULL DEFAULT 0,
+    "firstTimestamp" bigint NOT NULL,
+    "lastTimestamp" bigint NOT NULL,
+    "systemId" bigint NOT NULL,
+    "talkgroupId" bigint NOT NULL,
+    FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "conversations_idx" ON "conversations" ("systemId","talkgroupId","lastTimestamp");`,
+
 	`CREATE TABLE IF NOT EXISTS "delayed" (
     "delayedId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "callID" bigint NOT NULL,
@@ -163,20 +200,111 @@ var MysqlSchema = []string{
 
 	`CREATE TABLE IF NOT EXISTS "dirwatches" (
     "dirwatchId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "archiveDirectory" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
     "deleteAfter" boolean NOT NULL DEFAULT false,
     "directory" text NOT NULL,
     "disabled" boolean NOT NULL DEFAULT false,
     "extension" text NOT NULL DEFAULT '',
+    "failedDirectory" text NOT NULL DEFAULT '',
     "frequency" integer NOT NULL DEFAULT 0,
     "mask" text NOT NULL DEFAULT '',
     "order" integer NOT NULL DEFAULT 0,
+    "pollInterval" integer NOT NULL DEFAULT 0,
+    "polling" boolean NOT NULL DEFAULT false,
     "siteId" bigint NOT NULL DEFAULT 0,
     "systemId" bigint NOT NULL DEFAULT 0,
     "talkgroupId" bigint NOT NULL DEFAULT 0,
     "type" text NOT NULL DEFAULT ''
   );`,
 
+	`CREATE TABLE IF NOT EXISTS "dirwatchFiles" (
+    "dirwatchFileId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "dirwatchId" bigint NOT NULL,
+    "modTime" bigint NOT NULL DEFAULT 0,
+    "path" text NOT NULL,
+    "reason" text NOT NULL DEFAULT '',
+    "size" bigint NOT NULL DEFAULT 0,
+    "status" text NOT NULL DEFAULT '',
+    "timestamp" bigint NOT NULL,
+    FOREIGN KEY ("dirwatchId") REFERENCES "dirwatches" ("dirwatchId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "dirwatchFiles_idx" ON "dirwatchFiles" ("dirwatchId");`,
+
+	`CREATE TABLE IF NOT EXISTS "discoveries" (
+    "discoveryId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "count" integer NOT NULL DEFAULT 0,
+    "firstSeen" bigint NOT NULL,
+    "frequencies" text NOT NULL DEFAULT '',
+    "lastSeen" bigint NOT NULL,
+    "status" text NOT NULL DEFAULT '',
+    "systemLabel" text NOT NULL DEFAULT '',
+    "systemRef" integer NOT NULL DEFAULT 0,
+    "talkgroupLabel" text NOT NULL DEFAULT '',
+    "talkgroupRef" integer NOT NULL DEFAULT 0,
+    "units" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "discoveries_idx" ON "discoveries" ("systemRef","talkgroupRef");`,
+
+	`CREATE TABLE IF NOT EXISTS "downsampledCalls" (
+    "downsampledCallId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "callId" bigint NOT NULL,
+    "error" text NOT NULL DEFAULT '',
+    "profile" text NOT NULL,
+    "sizeAfter" bigint NOT NULL DEFAULT 0,
+    "sizeBefore" bigint NOT NULL DEFAULT 0,
+    "timestamp" bigint NOT NULL,
+    FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "downsampledCalls_idx" ON "downsampledCalls" ("callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "incidents" (
+    "incidentId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "accesses" text NOT NULL DEFAULT '',
+    "closed" boolean NOT NULL DEFAULT false,
+    "description" text NOT NULL DEFAULT '',
+    "label" text NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "incidentCalls" (
+    "incidentCallId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "callId" bigint NOT NULL,
+    "incidentId" bigint NOT NULL,
+    FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("incidentId") REFERENCES "incidents" ("incidentId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "incidentCalls_idx" ON "incidentCalls" ("incidentId","callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "incidentNotes" (
+    "incidentNoteId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "incidentId" bigint NOT NULL,
+    "note" text NOT NULL,
+    "timestamp" bigint NOT NULL,
+    FOREIGN KEY ("incidentId") REFERENCES "incidents" ("incidentId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "jobs" (
+    "jobId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "enabled" boolean NOT NULL DEFAULT true,
+    "name" text NOT NULL,
+    "schedule" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "jobRuns" (
+    "jobRunId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "duration" bigint NOT NULL DEFAULT 0,
+    "error" text NOT NULL DEFAULT '',
+    "manual" boolean NOT NULL DEFAULT false,
+    "name" text NOT NULL,
+    "status" text NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`,
+
 	`CREATE TABLE IF NOT EXISTS "logs" (
     "logId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "level" text NOT NULL,
@@ -190,6 +318,40 @@ var MysqlSchema = []string{
     "value" text NOT NULL
   );`,
 
+	`CREATE TABLE IF NOT EXISTS "quarantinedCalls" (
+    "quarantinedCallId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "audio" blob NOT NULL,
+    "audioFilename" text NOT NULL,
+    "audioMime" text NOT NULL,
+    "frequencies" text NOT NULL DEFAULT '',
+    "meta" text NOT NULL DEFAULT '',
+    "patches" text NOT NULL DEFAULT '',
+    "siteRef" integer NOT NULL DEFAULT 0,
+    "systemLabel" text NOT NULL DEFAULT '',
+    "systemRef" integer NOT NULL DEFAULT 0,
+    "talkgroupLabel" text NOT NULL DEFAULT '',
+    "talkgroupRef" integer NOT NULL DEFAULT 0,
+    "timestamp" bigint NOT NULL,
+    "units" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "quarantinedCalls_idx" ON "quarantinedCalls" ("systemRef","talkgroupRef","timestamp");`,
+
+	`CREATE TABLE IF NOT EXISTS "unitActivities" (
+    "unitActivityId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "callCount" integer NOT NULL DEFAULT 0,
+    "firstTimestamp" bigint NOT NULL,
+    "lastTimestamp" bigint NOT NULL,
+    "siteRef" integer NOT NULL DEFAULT 0,
+    "systemId" bigint NOT NULL,
+    "talkgroupId" bigint NOT NULL,
+    "unitRef" integer NOT NULL,
+    FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "unitActivities_idx" ON "unitActivities" ("systemId","unitRef","talkgroupId","siteRef");`,
+
 	`CREATE TABLE IF NOT EXISTS "units" (
     "unitId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
     "label" text NOT NULL,
@@ -201,3 +363,72 @@ var MysqlSchema = []string{
     FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 }
+
+var MysqlMigrations = []*SchemaMigration{
+	{
+		Columns: MysqlColumns,
+		Name:    "initial schema",
+		Up:      MysqlSchema,
+		Version: 1,
+	},
+	{
+		Func:    migrateVersion6,
+		Name:    "version 6 import",
+		Version: 2,
+	},
+	{
+		Columns: [][]string{
+			{"calls", "source", "text NOT NULL DEFAULT ''"},
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS "listenerCounts";`,
+			`ALTER TABLE "calls" DROP COLUMN "source";`,
+		},
+		Name: "call statistics",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "listenerCounts" (
+    "listenerCountId" bigint NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    "count" integer NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`,
+
+			`CREATE INDEX IF NOT EXISTS "listenerCounts_idx" ON "listenerCounts" ("timestamp");`,
+		},
+		Version: 3,
+	},
+	{
+		Columns: [][]string{
+			{"calls", "simulcast", "text NOT NULL DEFAULT ''"},
+		},
+		Down: []string{
+			`ALTER TABLE "calls" DROP COLUMN "simulcast";`,
+		},
+		Name:    "simulcast decisions",
+		Version: 4,
+	},
+}
+
+var MysqlColumns = [][]string{
+	{"calls", "audioProfile", "text NOT NULL DEFAULT ''"},
+	{"calls", "conversationId", "bigint NOT NULL DEFAULT 0"},
+	{"calls", "duration", "integer NOT NULL DEFAULT 0"},
+	{"calls", "fingerprint", "text NOT NULL DEFAULT ''"},
+	{"calls", "flags", "text NOT NULL DEFAULT ''"},
+	{"calls", "linkedCallId", "bigint NOT NULL DEFAULT 0"},
+	{"calls", "peakLevel", "real NOT NULL DEFAULT 0"},
+	{"calls", "peaks", "text NOT NULL DEFAULT ''"},
+	{"calls", "rmsLevel", "real NOT NULL DEFAULT 0"},
+	{"calls", "sampleRate", "integer NOT NULL DEFAULT 0"},
+	{"calls", "trimmed", "real NOT NULL DEFAULT 0"},
+	{"dirwatches", "archiveDirectory", "text NOT NULL DEFAULT ''"},
+	{"dirwatches", "failedDirectory", "text NOT NULL DEFAULT ''"},
+	{"dirwatches", "pollInterval", "integer NOT NULL DEFAULT 0"},
+	{"dirwatches", "polling", "boolean NOT NULL DEFAULT false"},
+	{"dirwatchFiles", "reason", "text NOT NULL DEFAULT ''"},
+	{"dirwatchFiles", "status", "text NOT NULL DEFAULT ''"},
+	{"sites", "priority", "integer NOT NULL DEFAULT 0"},
+	{"systems", "audioProcessing", "text NOT NULL DEFAULT ''"},
+	{"systems", "audioProfile", "text NOT NULL DEFAULT ''"},
+	{"talkgroups", "audioProcessing", "text NOT NULL DEFAULT ''"},
+	{"talkgroups", "audioProfile", "text NOT NULL DEFAULT ''"},
+}
@@ -23,7 +23,6 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"math/rand"
 	"sync"
@@ -32,20 +31,38 @@ import (
 )
 
 type Options struct {
-	AudioConversion             uint   `json:"audioConversion"`
-	AutoPopulate                bool   `json:"autoPopulate"`
-	Branding                    string `json:"branding"`
-	DimmerDelay                 uint   `json:"dimmerDelay"`
-	DisableDuplicateDetection   bool   `json:"disableDuplicateDetection"`
-	DuplicateDetectionTimeFrame uint   `json:"duplicateDetectionTimeFrame"`
-	Email                       string `json:"email"`
-	KeypadBeeps                 string `json:"keypadBeeps"`
-	MaxClients                  uint   `json:"maxClients"`
-	PlaybackGoesLive            bool   `json:"playbackGoesLive"`
-	PruneDays                   uint   `json:"pruneDays"`
-	ShowListenersCount          bool   `json:"showListenersCount"`
-	SortTalkgroups              bool   `json:"sortTalkgroups"`
-	Time12hFormat               bool   `json:"time12hFormat"`
+	ArchiveDays                 uint                  `json:"archiveDays"`
+	ArchiveTablespace           string                `json:"archiveTablespace"`
+	AudioConversion             uint                  `json:"audioConversion"`
+	AudioProfile                string                `json:"audioProfile"`
+	AudioProfiles               *AudioProfiles        `json:"audioProfiles"`
+	AutoPopulate                bool                  `json:"autoPopulate"`
+	BackupAudio                 bool                  `json:"backupAudio"`
+	BackupKeep                  uint                  `json:"backupKeep"`
+	Branding                    string                `json:"branding"`
+	ConversationGap             uint                  `json:"conversationGap"`
+	ConversationPatches         bool                  `json:"conversationPatches"`
+	DimmerDelay                 uint                  `json:"dimmerDelay"`
+	DisableDuplicateDetection   bool                  `json:"disableDuplicateDetection"`
+	DownsampleTiers             *DownsampleTiers      `json:"downsampleTiers"`
+	DuplicateDetectionTimeFrame uint                  `json:"duplicateDetectionTimeFrame"`
+	DuplicateHoldTime           uint                  `json:"duplicateHoldTime"`
+	DuplicateResolution         string                `json:"duplicateResolution"`
+	Email                       string                `json:"email"`
+	FingerprintDetection        *FingerprintDetection `json:"fingerprintDetection"`
+	KeypadBeeps                 string                `json:"keypadBeeps"`
+	MaxClients                  uint                  `json:"maxClients"`
+	PlaybackGoesLive            bool                  `json:"playbackGoesLive"`
+	PruneDays                   uint                  `json:"pruneDays"`
+	QualityGates                *QualityGates         `json:"qualityGates"`
+	Quarantine                  bool                  `json:"quarantine"`
+	QuarantineDays              uint                  `json:"quarantineDays"`
+	RetentionRules              *RetentionRules       `json:"retentionRules"`
+	ShowListenersCount          bool                  `json:"showListenersCount"`
+	ShowStatistics              bool                  `json:"showStatistics"`
+	Smtp                        *Smtp                 `json:"smtp"`
+	SortTalkgroups              bool                  `json:"sortTalkgroups"`
+	Time12hFormat               bool                  `json:"time12hFormat"`
 	adminPassword               string
 	adminPasswordNeedChange     bool
 	mutex                       sync.Mutex
@@ -61,7 +78,13 @@ const (
 
 func NewOptions() *Options {
 	return &Options{
-		mutex: sync.Mutex{},
+		AudioProfiles:        NewAudioProfiles(),
+		DownsampleTiers:      NewDownsampleTiers(),
+		FingerprintDetection: NewFingerprintDetection(),
+		QualityGates:         NewQualityGates(),
+		RetentionRules:       NewRetentionRules(),
+		Smtp:                 NewSmtp(),
+		mutex:                sync.Mutex{},
 	}
 }
 
@@ -69,11 +92,37 @@ func (options *Options) FromMap(m map[string]any) *Options {
 	options.mutex.Lock()
 	defer options.mutex.Unlock()
 
+	switch v := m["archiveDays"].(type) {
+	case float64:
+		options.ArchiveDays = uint(v)
+	default:
+		options.ArchiveDays = defaults.options.archiveDays
+	}
+
+	switch v := m["archiveTablespace"].(type) {
+	case string:
+		options.ArchiveTablespace = v
+	default:
+		options.ArchiveTablespace = defaults.options.archiveTablespace
+	}
+
 	switch v := m["audioConversion"].(type) {
 	case float64:
 		options.AudioConversion = uint(v)
 	default:
-		options.MaxClients = defaults.options.audioConversion
+		options.AudioConversion = defaults.options.audioConversion
+	}
+
+	switch v := m["audioProfile"].(type) {
+	case string:
+		options.AudioProfile = v
+	default:
+		options.AudioProfile = defaults.options.audioProfile
+	}
+
+	switch v := m["audioProfiles"].(type) {
+	case []any:
+		options.AudioProfiles.FromMap(v)
 	}
 
 	switch v := m["autoPopulate"].(type) {
@@ -83,11 +132,39 @@ func (options *Options) FromMap(m map[string]any) *Options {
 		options.AutoPopulate = defaults.options.autoPopulate
 	}
 
+	switch v := m["backupAudio"].(type) {
+	case bool:
+		options.BackupAudio = v
+	default:
+		options.BackupAudio = defaults.options.backupAudio
+	}
+
+	switch v := m["backupKeep"].(type) {
+	case float64:
+		options.BackupKeep = uint(v)
+	default:
+		options.BackupKeep = defaults.options.backupKeep
+	}
+
 	switch v := m["branding"].(type) {
 	case string:
 		options.Branding = v
 	}
 
+	switch v := m["conversationGap"].(type) {
+	case float64:
+		options.ConversationGap = uint(v)
+	default:
+		options.ConversationGap = defaults.options.conversationGap
+	}
+
+	switch v := m["conversationPatches"].(type) {
+	case bool:
+		options.ConversationPatches = v
+	default:
+		options.ConversationPatches = defaults.options.conversationPatches
+	}
+
 	switch v := m["dimmerDelay"].(type) {
 	case float64:
 		options.DimmerDelay = uint(v)
@@ -111,11 +188,37 @@ func (options *Options) FromMap(m map[string]any) *Options {
 		options.DisableDuplicateDetection = defaults.options.disableDuplicateDetection
 	}
 
+	switch v := m["downsampleTiers"].(type) {
+	case []any:
+		options.DownsampleTiers.FromMap(v)
+	}
+
 	switch v := m["duplicateDetectionTimeFrame"].(type) {
 	case float64:
 		options.DuplicateDetectionTimeFrame = uint(v)
 	default:
 		options.DuplicateDetectionTimeFrame = defaults.options.duplicateDetectionTimeFrame
+		options.DuplicateHoldTime = defaults.options.duplicateHoldTime
+		options.DuplicateResolution = defaults.options.duplicateResolution
+	}
+
+	switch v := m["duplicateHoldTime"].(type) {
+	case float64:
+		options.DuplicateHoldTime = uint(v)
+	default:
+		options.DuplicateHoldTime = defaults.options.duplicateHoldTime
+	}
+
+	switch v := m["duplicateResolution"].(type) {
+	case string:
+		switch v {
+		case DuplicateResolutionFirst, DuplicateResolutionQuality:
+			options.DuplicateResolution = v
+		default:
+			options.DuplicateResolution = defaults.options.duplicateResolution
+		}
+	default:
+		options.DuplicateResolution = defaults.options.duplicateResolution
 	}
 
 	switch v := m["email"].(type) {
@@ -123,6 +226,11 @@ func (options *Options) FromMap(m map[string]any) *Options {
 		options.Email = v
 	}
 
+	switch v := m["fingerprintDetection"].(type) {
+	case map[string]any:
+		options.FingerprintDetection = NewFingerprintDetection().FromMap(v)
+	}
+
 	switch v := m["keypadBeeps"].(type) {
 	case string:
 		options.KeypadBeeps = v
@@ -149,6 +257,30 @@ func (options *Options) FromMap(m map[string]any) *Options {
 		options.PruneDays = defaults.options.pruneDays
 	}
 
+	switch v := m["qualityGates"].(type) {
+	case map[string]any:
+		options.QualityGates = NewQualityGates().FromMap(v)
+	}
+
+	switch v := m["quarantine"].(type) {
+	case bool:
+		options.Quarantine = v
+	default:
+		options.Quarantine = defaults.options.quarantine
+	}
+
+	switch v := m["quarantineDays"].(type) {
+	case float64:
+		options.QuarantineDays = uint(v)
+	default:
+		options.QuarantineDays = defaults.options.quarantineDays
+	}
+
+	switch v := m["retentionRules"].(type) {
+	case []any:
+		options.RetentionRules.FromMap(v)
+	}
+
 	switch v := m["showListenersCount"].(type) {
 	case bool:
 		options.ShowListenersCount = v
@@ -156,6 +288,18 @@ func (options *Options) FromMap(m map[string]any) *Options {
 		options.ShowListenersCount = defaults.options.showListenersCount
 	}
 
+	switch v := m["showStatistics"].(type) {
+	case bool:
+		options.ShowStatistics = v
+	default:
+		options.ShowStatistics = defaults.options.showStatistics
+	}
+
+	switch v := m["smtp"].(type) {
+	case map[string]any:
+		options.Smtp = NewSmtp().FromMap(v)
+	}
+
 	switch v := m["sortTalkgroups"].(type) {
 	case bool:
 		options.SortTalkgroups = v
@@ -173,7 +317,42 @@ func (options *Options) FromMap(m map[string]any) *Options {
 	return options
 }
 
-func (options *Options) Read(db *Database) error {
+// GetAudioProfile returns the encoding profile to apply to a call.
+func (options *Options) GetAudioProfile(call *Call) *AudioProfile {
+	options.mutex.Lock()
+	defer options.mutex.Unlock()
+
+	return options.AudioProfiles.Resolve(call, options.AudioProfile)
+}
+
+// GetFingerprintDetection returns the current fingerprint detection settings.
+func (options *Options) GetFingerprintDetection() *FingerprintDetection {
+	options.mutex.Lock()
+	defer options.mutex.Unlock()
+
+	return options.FingerprintDetection
+}
+
+// ListAudioProfiles returns the built-in profiles followed by the custom ones.
+func (options *Options) ListAudioProfiles() []*AudioProfile {
+	options.mutex.Lock()
+	defer options.mutex.Unlock()
+
+	return append(append([]*AudioProfile{}, AudioProfilesBuiltin...), options.AudioProfiles.List...)
+}
+
+// CheckQualityGates returns the gates the call fails and whether it must be
+// rejected rather than flagged.
+func (options *Options) CheckQualityGates(call *Call) ([]string, bool) {
+	options.mutex.Lock()
+	defer options.mutex.Unlock()
+
+	flags := options.QualityGates.Check(call)
+
+	return flags, len(flags) > 0 && options.QualityGates.Action == QualityGateActionReject
+}
+
+func (options *Options) Read(db Storage) error {
 	var (
 		defaultPassword []byte
 		err             error
@@ -192,8 +371,15 @@ func (options *Options) Read(db *Database) error {
 
 	options.adminPassword = string(defaultPassword)
 	options.adminPasswordNeedChange = defaults.adminPasswordNeedChange
+	options.ArchiveDays = defaults.options.archiveDays
+	options.ArchiveTablespace = defaults.options.archiveTablespace
 	options.AudioConversion = defaults.options.audioConversion
+	options.AudioProfile = defaults.options.audioProfile
 	options.AutoPopulate = defaults.options.autoPopulate
+	options.BackupAudio = defaults.options.backupAudio
+	options.BackupKeep = defaults.options.backupKeep
+	options.ConversationGap = defaults.options.conversationGap
+	options.ConversationPatches = defaults.options.conversationPatches
 	options.DimmerDelay = defaults.options.dimmerDelay
 	options.DisableDuplicateDetection = defaults.options.disableDuplicateDetection
 	options.DuplicateDetectionTimeFrame = defaults.options.duplicateDetectionTimeFrame
@@ -201,7 +387,10 @@ func (options *Options) Read(db *Database) error {
 	options.MaxClients = defaults.options.maxClients
 	options.PlaybackGoesLive = defaults.options.playbackGoesLive
 	options.PruneDays = defaults.options.pruneDays
+	options.Quarantine = defaults.options.quarantine
+	options.QuarantineDays = defaults.options.quarantineDays
 	options.ShowListenersCount = defaults.options.showListenersCount
+	options.ShowStatistics = defaults.options.showStatistics
 	options.SortTalkgroups = defaults.options.sortTalkgroups
 
 	formatError := errorFormatter("options", "read")
@@ -217,7 +406,7 @@ func (options *Options) Read(db *Database) error {
 	}
 
 	query = `SELECT "key", "value" FROM "options"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -245,6 +434,20 @@ func (options *Options) Read(db *Database) error {
 					options.adminPasswordNeedChange = v
 				}
 			}
+		case "archiveDays":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case float64:
+					options.ArchiveDays = uint(v)
+				}
+			}
+		case "archiveTablespace":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case string:
+					options.ArchiveTablespace = v
+				}
+			}
 		case "audioConversion":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -252,6 +455,20 @@ func (options *Options) Read(db *Database) error {
 					options.AudioConversion = uint(v)
 				}
 			}
+		case "audioProfile":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case string:
+					options.AudioProfile = v
+				}
+			}
+		case "audioProfiles":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case []any:
+					options.AudioProfiles.FromMap(v)
+				}
+			}
 		case "autoPopulate":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -259,6 +476,20 @@ func (options *Options) Read(db *Database) error {
 					options.AutoPopulate = v
 				}
 			}
+		case "backupAudio":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case bool:
+					options.BackupAudio = v
+				}
+			}
+		case "backupKeep":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case float64:
+					options.BackupKeep = uint(v)
+				}
+			}
 		case "branding":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -266,6 +497,20 @@ func (options *Options) Read(db *Database) error {
 					options.Branding = v
 				}
 			}
+		case "conversationGap":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case float64:
+					options.ConversationGap = uint(v)
+				}
+			}
+		case "conversationPatches":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case bool:
+					options.ConversationPatches = v
+				}
+			}
 		case "dimmerDelay":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -280,13 +525,36 @@ func (options *Options) Read(db *Database) error {
 					options.DisableDuplicateDetection = v
 				}
 			}
-		case "duplicateDetectionTimeframe":
+		case "downsampleTiers":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case []any:
+					options.DownsampleTiers.FromMap(v)
+				}
+			}
+		case "duplicateDetectionTimeFrame":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
 				case float64:
 					options.DuplicateDetectionTimeFrame = uint(v)
 				}
 			}
+		case "duplicateHoldTime":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case float64:
+					options.DuplicateHoldTime = uint(v)
+				}
+			}
+		case "duplicateResolution":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case string:
+					if v == DuplicateResolutionQuality {
+						options.DuplicateResolution = v
+					}
+				}
+			}
 		case "email":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -294,6 +562,13 @@ func (options *Options) Read(db *Database) error {
 					options.Email = v
 				}
 			}
+		case "fingerprintDetection":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case map[string]any:
+					options.FingerprintDetection = NewFingerprintDetection().FromMap(v)
+				}
+			}
 		case "keypadBeeps":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -322,6 +597,34 @@ func (options *Options) Read(db *Database) error {
 					options.PruneDays = uint(v)
 				}
 			}
+		case "qualityGates":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case map[string]any:
+					options.QualityGates = NewQualityGates().FromMap(v)
+				}
+			}
+		case "quarantine":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case bool:
+					options.Quarantine = v
+				}
+			}
+		case "quarantineDays":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case float64:
+					options.QuarantineDays = uint(v)
+				}
+			}
+		case "retentionRules":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case []any:
+					options.RetentionRules.FromMap(v)
+				}
+			}
 		case "secret":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				const n = 256
@@ -343,6 +646,20 @@ func (options *Options) Read(db *Database) error {
 					options.ShowListenersCount = v
 				}
 			}
+		case "showStatistics":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case bool:
+					options.ShowStatistics = v
+				}
+			}
+		case "smtp":
+			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
+				switch v := f.(type) {
+				case map[string]any:
+					options.Smtp = NewSmtp().FromMap(v)
+				}
+			}
 		case "sortTalkgroups":
 			if err = json.Unmarshal([]byte(value.String), &f); err == nil {
 				switch v := f.(type) {
@@ -363,11 +680,11 @@ func (options *Options) Read(db *Database) error {
 	return nil
 }
 
-func (options *Options) Write(db *Database) error {
+func (options *Options) Write(db Storage) error {
 	var (
 		err error
 		res sql.Result
-		tx  *sql.Tx
+		tx  *StorageTx
 	)
 	options.mutex.Lock()
 	defer options.mutex.Unlock()
@@ -376,16 +693,15 @@ func (options *Options) Write(db *Database) error {
 
 	set := func(key string, val any) {
 		if val, err = json.Marshal(val); err == nil {
-			switch v := val.(type) {
-			case string:
-				val = escapeQuotes(v)
+			if v, ok := val.([]byte); ok {
+				val = string(v)
 			}
 
-			query := fmt.Sprintf(`UPDATE "options" SET "value" = '%s' WHERE "key" = '%s'`, val, key)
-			if res, err = tx.Exec(query); err == nil {
+			query := `UPDATE "options" SET "value" = ? WHERE "key" = ?`
+			if res, err = tx.Exec(query, val, key); err == nil {
 				if i, err := res.RowsAffected(); err == nil && i == 0 {
-					query = fmt.Sprintf(`INSERT INTO "options" ("key", "value") VALUES ('%s', '%s')`, key, val)
-					if _, err = tx.Exec(query); err != nil {
+					query = `INSERT INTO "options" ("key", "value") VALUES (?, ?)`
+					if _, err = tx.Exec(query, key, val); err != nil {
 						log.Println(formatError(err, query))
 					}
 				}
@@ -393,24 +709,43 @@ func (options *Options) Write(db *Database) error {
 		}
 	}
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
 	set("adminPassword", options.adminPassword)
 	set("adminPasswordNeedChange", options.adminPasswordNeedChange)
+	set("archiveDays", options.ArchiveDays)
+	set("archiveTablespace", options.ArchiveTablespace)
+	set("audioConversion", options.AudioConversion)
+	set("audioProfile", options.AudioProfile)
+	set("audioProfiles", options.AudioProfiles.List)
 	set("autoPopulate", options.AutoPopulate)
+	set("backupAudio", options.BackupAudio)
+	set("backupKeep", options.BackupKeep)
 	set("branding", options.Branding)
+	set("conversationGap", options.ConversationGap)
+	set("conversationPatches", options.ConversationPatches)
 	set("dimmerDelay", options.DimmerDelay)
 	set("disableDuplicateDetection", options.DisableDuplicateDetection)
+	set("downsampleTiers", options.DownsampleTiers.List)
 	set("duplicateDetectionTimeFrame", options.DuplicateDetectionTimeFrame)
+	set("duplicateHoldTime", options.DuplicateHoldTime)
+	set("duplicateResolution", options.DuplicateResolution)
 	set("email", options.Email)
+	set("fingerprintDetection", options.FingerprintDetection)
 	set("keypadBeeps", options.KeypadBeeps)
 	set("maxClients", options.MaxClients)
 	set("playbackGoesLive", options.PlaybackGoesLive)
 	set("pruneDays", options.PruneDays)
+	set("qualityGates", options.QualityGates)
+	set("quarantine", options.Quarantine)
+	set("quarantineDays", options.QuarantineDays)
+	set("retentionRules", options.RetentionRules.List)
 	set("secret", options.secret)
 	set("showListenersCount", options.ShowListenersCount)
+	set("showStatistics", options.ShowStatistics)
+	set("smtp", options.Smtp)
 	set("sortTalkgroups", options.SortTalkgroups)
 	set("time12hFormat", options.Time12hFormat)
 
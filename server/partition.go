@@ -0,0 +1,1028 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PartitionDefault       = "default"
+	PartitionMigrationName = "calls partitions"
+	PartitionMonthsAhead   = 3
+	PartitionMoveBatchSize = 500
+	PartitionMoveVacuum    = 20
+)
+
+// PartitionCallTables are the tables holding the frequencies, patches and
+// units of the calls. They carry the timestamp of their call and are
+// partitioned on it like the calls, so that the rows of a month are dropped
+// along with its calls.
+var PartitionCallTables = []string{"callFrequencies", "callPatches", "callUnits"}
+
+// PartitionReferenceTables are the other tables referencing the calls, which
+// lose their foreign keys once the calls are partitioned. Holding a few rows
+// per call at most, their rows are deleted by call ids along with the calls.
+var PartitionReferenceTables = []string{"delayed", "downsampledCalls", "incidentCalls"}
+
+// partitionCallKeys are the foreign keys on the calls restored when the calls
+// are no longer partitioned.
+var partitionCallKeys = map[string]string{
+	"callFrequencies":  "callFrequencies_callId",
+	"callPatches":      "callPatches_callId",
+	"callUnits":        "callUnits_callId",
+	"delayed":          "delayed_callId",
+	"downsampledCalls": "downsampledCalls_callId_fkey",
+	"incidentCalls":    "incidentCalls_callId_fkey",
+}
+
+var partitionBoundRegexp = regexp.MustCompile(`FROM \('?([^')]+)'?\) TO \('?([^')]+)'?\)`)
+
+type Partition struct {
+	Default    bool
+	From       int64
+	Name       string
+	Tablespace string
+	To         int64
+}
+
+// table returns the partition of the same month of another partitioned table.
+func (partition *Partition) table(table string) string {
+	return table + strings.TrimPrefix(partition.Name, "calls")
+}
+
+// Partitions maintains the monthly partitions of the calls and their details
+// on PostgreSQL, once the optional calls partitions migration is applied with
+// the db-partition command.
+type Partitions struct {
+	controller  *Controller
+	moveMutex   sync.Mutex
+	mutex       sync.Mutex
+	partitioned bool
+}
+
+func NewPartitions(controller *Controller) *Partitions {
+	return &Partitions{
+		controller: controller,
+		moveMutex:  sync.Mutex{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+// IsPartitioned tells whether the calls partitions migration was found
+// applied by the last maintenance.
+func (partitions *Partitions) IsPartitioned() bool {
+	partitions.mutex.Lock()
+	defer partitions.mutex.Unlock()
+
+	return partitions.partitioned
+}
+
+// List returns the partitions of the calls ordered by their range.
+func (partitions *Partitions) List(db Storage) ([]*Partition, error) {
+	partitions.mutex.Lock()
+	defer partitions.mutex.Unlock()
+
+	return partitions.list(db)
+}
+
+// Maintain reads whether the calls are partitioned, then creates the
+// partitions of the coming months ahead of the calls and moves the aged ones
+// to the archive tablespace.
+func (partitions *Partitions) Maintain(db Storage) error {
+	var count uint
+
+	partitions.mutex.Lock()
+	defer partitions.mutex.Unlock()
+
+	if db.Dialect().Family != DbTypePostgresql {
+		return nil
+	}
+
+	query := `SELECT COUNT(*) FROM "schemaMigrations" WHERE "name" = ?`
+	if err := db.QueryRow(query, PartitionMigrationName).Scan(&count); err != nil {
+		return errorFormatter("partitions", "maintain")(err, query)
+	}
+
+	partitions.partitioned = count > 0
+
+	if !partitions.partitioned {
+		return nil
+	}
+
+	if err := partitions.create(db); err != nil {
+		return err
+	}
+
+	return partitions.archive(db)
+}
+
+// Move moves the calls and their details left in the tables replaced by the
+// calls partitions migration, or by its rollback, into the current tables.
+// The latest calls are moved first, in batches committed one by one so that
+// an interrupted move is resumed where it stopped, and the space of the moved
+// calls is released from the end of the previous table along the way.
+func (partitions *Partitions) Move(db Storage) error {
+	var (
+		err     error
+		moved   uint
+		n       uint
+		pending bool
+		query   string
+		tx      *StorageTx
+	)
+
+	if db.Dialect().Family != DbTypePostgresql {
+		return nil
+	}
+
+	if !partitions.moveMutex.TryLock() {
+		return nil
+	}
+	defer partitions.moveMutex.Unlock()
+
+	formatError := errorFormatter("partitions", "move")
+
+	if pending, err = partitionPending(db.QueryRow); err != nil || !pending {
+		return formatError(err, "")
+	}
+
+	for batch := 1; ; batch++ {
+		if tx, err = db.Begin(); err != nil {
+			return formatError(err, "")
+		}
+
+		if n, err = partitionMoveBatch(tx); err == nil && n == 0 {
+			err = partitionMoveFinish(tx)
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return formatError(err, "")
+		}
+
+		if err = tx.Commit(); err != nil {
+			tx.Rollback()
+			return formatError(err, "")
+		}
+
+		if n == 0 {
+			partitions.log(fmt.Sprintf("partitions: %d calls moved, move finished", moved))
+			return nil
+		}
+
+		moved += n
+
+		if batch%PartitionMoveVacuum == 0 {
+			query = fmt.Sprintf(`VACUUM "%s"`, partitionPrevious("calls"))
+			if _, err = db.Exec(query); err != nil {
+				return formatError(err, query)
+			}
+
+			partitions.log(fmt.Sprintf("partitions: %d calls moved", moved))
+		}
+	}
+}
+
+// Prune drops the partitions of which every call matches one of the
+// conditions, along with the partitions of their details and the rows of
+// their calls in the other tables, and returns the number of calls removed.
+func (partitions *Partitions) Prune(db Storage, wheres []string, args []any, cutoff int64) (uint, error) {
+	var (
+		count uint
+		err   error
+		list  []*Partition
+	)
+
+	partitions.mutex.Lock()
+	defer partitions.mutex.Unlock()
+
+	if !partitions.partitioned || len(wheres) == 0 {
+		return 0, nil
+	}
+
+	if list, err = partitions.list(db); err != nil {
+		return 0, err
+	}
+
+	for _, partition := range list {
+		if partition.Default || partition.To > cutoff {
+			continue
+		}
+
+		total, err := partitions.drop(db, partition, wheres, args)
+		if err != nil {
+			return count, err
+		}
+
+		count += total
+	}
+
+	return count, nil
+}
+
+// archive moves the partitions of the months older than the archive days,
+// with their indexes, to the archive tablespace.
+func (partitions *Partitions) archive(db Storage) error {
+	var (
+		err   error
+		list  []*Partition
+		query string
+		rows  *sql.Rows
+		tx    *StorageTx
+	)
+
+	if partitions.controller == nil {
+		return nil
+	}
+
+	days := partitions.controller.Options.ArchiveDays
+	tablespace := partitions.controller.Options.ArchiveTablespace
+
+	if days == 0 || tablespace == "" {
+		return nil
+	}
+
+	formatError := errorFormatter("partitions", "archive")
+
+	if list, err = partitions.list(db); err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -int(days)).UnixMilli()
+
+	for _, partition := range list {
+		if partition.Default || partition.To > cutoff || partition.Tablespace == tablespace {
+			continue
+		}
+
+		if tx, err = db.Begin(); err != nil {
+			return formatError(err, "")
+		}
+
+		for _, table := range partitionTables() {
+			name := partition.table(table)
+			queries := []string{fmt.Sprintf(`ALTER TABLE "%s" SET TABLESPACE %s`, name, partitionIdentifier(tablespace))}
+
+			query = `SELECT "indexname" FROM "pg_indexes" WHERE "schemaname" = current_schema() AND "tablename" = ?`
+			if rows, err = tx.Query(query, name); err != nil {
+				tx.Rollback()
+				return formatError(err, query)
+			}
+
+			for rows.Next() {
+				var index string
+				if err = rows.Scan(&index); err != nil {
+					break
+				}
+				queries = append(queries, fmt.Sprintf(`ALTER INDEX "%s" SET TABLESPACE %s`, index, partitionIdentifier(tablespace)))
+			}
+
+			rows.Close()
+
+			if err != nil {
+				tx.Rollback()
+				return formatError(err, "")
+			}
+
+			for _, query = range queries {
+				if _, err = tx.Exec(query); err != nil {
+					tx.Rollback()
+					return formatError(err, query)
+				}
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			tx.Rollback()
+			return formatError(err, "")
+		}
+
+		partitions.log(fmt.Sprintf("partitions: %s archived to %s", partition.Name, tablespace))
+	}
+
+	return nil
+}
+
+// create adds the monthly partitions following the last one up to the months
+// ahead.
+func (partitions *Partitions) create(db Storage) error {
+	var (
+		err  error
+		from int64
+		list []*Partition
+		name string
+		tx   *StorageTx
+	)
+
+	formatError := errorFormatter("partitions", "create")
+
+	if list, err = partitions.list(db); err != nil {
+		return err
+	}
+
+	for _, partition := range list {
+		if !partition.Default {
+			from = max(from, partition.To)
+		}
+	}
+
+	now := time.Now().UnixMilli()
+
+	if from == 0 {
+		from = partitionMonthStart(now)
+	}
+
+	for until := partitionAhead(now); from < until; from = partitionMonthEnd(from) {
+		if tx, err = db.Begin(); err != nil {
+			return formatError(err, "")
+		}
+
+		if name, err = partitionMonth(tx, from); err != nil {
+			tx.Rollback()
+			return formatError(err, "")
+		}
+
+		if err = tx.Commit(); err != nil {
+			tx.Rollback()
+			return formatError(err, "")
+		}
+
+		partitions.log(fmt.Sprintf("partitions: %s created", name))
+	}
+
+	return nil
+}
+
+// drop removes a partition of the calls along with the partitions of their
+// details and the rows of its calls in the other tables, once every call of
+// the partition matches one of the conditions, and returns the number of calls
+// removed. The incidents are locked until the partition is dropped so that no
+// call of the partition is attached to an open incident in the meantime.
+func (partitions *Partitions) drop(db Storage, partition *Partition, wheres []string, args []any) (uint, error) {
+	var (
+		err   error
+		kept  uint
+		query string
+		total uint
+		tx    *StorageTx
+	)
+
+	formatError := errorFormatter("partitions", "drop")
+
+	if tx, err = db.Begin(); err != nil {
+		return 0, formatError(err, "")
+	}
+
+	query = `LOCK TABLE "incidents", "incidentCalls" IN SHARE MODE`
+	if _, err = tx.Exec(query); err != nil {
+		tx.Rollback()
+		return 0, formatError(err, query)
+	}
+
+	query = fmt.Sprintf(`SELECT COUNT(*) FROM "%s" WHERE NOT (((%s)) AND %s)`, partition.Name, strings.Join(wheres, ") OR ("), callsPruneExemption)
+	if err = tx.QueryRow(query, args...).Scan(&kept); err != nil {
+		tx.Rollback()
+		return 0, formatError(err, query)
+	}
+
+	if kept > 0 {
+		tx.Rollback()
+		return 0, nil
+	}
+
+	query = fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, partition.Name)
+	if err = tx.QueryRow(query).Scan(&total); err != nil {
+		tx.Rollback()
+		return 0, formatError(err, query)
+	}
+
+	for _, table := range PartitionReferenceTables {
+		query = fmt.Sprintf(`DELETE FROM "%s" WHERE "callId" IN (SELECT "callId" FROM "%s")`, table, partition.Name)
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return 0, formatError(err, query)
+		}
+	}
+
+	for _, table := range append(append([]string{}, PartitionCallTables...), "calls") {
+		query = fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, partition.table(table))
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return 0, formatError(err, query)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, formatError(err, "")
+	}
+
+	return total, nil
+}
+
+func (partitions *Partitions) list(db Storage) ([]*Partition, error) {
+	var (
+		err   error
+		list  = []*Partition{}
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("partitions", "list")
+
+	query = `SELECT c."relname", pg_get_expr(c."relpartbound", c."oid"), COALESCE(t."spcname", '') FROM "pg_inherits" AS i LEFT JOIN "pg_class" AS c ON c."oid" = i."inhrelid" LEFT JOIN "pg_tablespace" AS t ON t."oid" = c."reltablespace" WHERE i."inhparent" = '"calls"'::regclass`
+	if rows, err = db.Query(query); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			bound     string
+			partition = &Partition{}
+		)
+
+		if err = rows.Scan(&partition.Name, &bound, &partition.Tablespace); err != nil {
+			break
+		}
+
+		if bound == "DEFAULT" {
+			partition.Default = true
+
+		} else if m := partitionBoundRegexp.FindStringSubmatch(bound); m != nil {
+			partition.From = partitionBound(m[1])
+			partition.To = partitionBound(m[2])
+
+		} else {
+			continue
+		}
+
+		list = append(list, partition)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	sort.Slice(list, func(i int, j int) bool {
+		return list[i].From < list[j].From
+	})
+
+	return list, nil
+}
+
+// log reports to the server logs, or to the console for the commands.
+func (partitions *Partitions) log(message string) {
+	if partitions.controller != nil {
+		partitions.controller.Logs.LogEvent(LogLevelInfo, message)
+	} else {
+		log.Println(message)
+	}
+}
+
+// partitionCalls is the optional migration replacing the calls and their
+// details by tables partitioned by month on the timestamp of the calls, with
+// the partitions of the months from the oldest call to the months ahead. The
+// rows are left in the replaced tables, for Partitions.Move to move them in
+// batches.
+func partitionCalls(tx *StorageTx) error {
+	var (
+		err   error
+		first sql.NullInt64
+		last  sql.NullInt64
+		query string
+	)
+
+	// restored dumps of partitioned calls come with their partitions
+	if ok, err := partitionIsPartitioned(tx.QueryRow); err != nil || ok {
+		return err
+	}
+
+	if ok, err := partitionPending(tx.QueryRow); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("the calls are still being moved from %s", partitionPrevious("calls"))
+	}
+
+	// foreign keys cannot reference the calls without their partition key
+	query = `SELECT "conrelid"::regclass::text, "conname" FROM "pg_constraint" WHERE "contype" = 'f' AND "confrelid" = '"calls"'::regclass`
+	references, err := partitionPairs(tx, query)
+	if err != nil {
+		return err
+	}
+
+	for _, reference := range references {
+		query = fmt.Sprintf(`ALTER TABLE %s DROP CONSTRAINT "%s"`, reference[0], reference[1])
+		if _, err = tx.Exec(query); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	query = `SELECT MIN("timestamp"), MAX("timestamp") FROM "calls"`
+	if err = tx.QueryRow(query).Scan(&first, &last); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	now := time.Now().UnixMilli()
+
+	if !first.Valid || first.Int64 > now {
+		first.Int64 = now
+	}
+
+	if !last.Valid || last.Int64 < now {
+		last.Int64 = now
+	}
+
+	for _, table := range partitionTables() {
+		if err = partitionRebuild(tx, table, true); err != nil {
+			return err
+		}
+
+		query = fmt.Sprintf(`CREATE TABLE "%s_%s" PARTITION OF "%s" DEFAULT`, table, PartitionDefault, table)
+		if _, err = tx.Exec(query); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	for month, until := partitionMonthStart(first.Int64), partitionAhead(last.Int64); month < until; month = partitionMonthEnd(month) {
+		if _, err = partitionMonth(tx, month); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unpartitionCalls rolls back partitionCalls, replacing the partitioned tables
+// by plain ones. The foreign keys on the calls are restored once
+// Partitions.Move has moved the rows back.
+func unpartitionCalls(tx *StorageTx) error {
+	if ok, err := partitionIsPartitioned(tx.QueryRow); err != nil || !ok {
+		return err
+	}
+
+	if ok, err := partitionPending(tx.QueryRow); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("the calls are still being moved from %s", partitionPrevious("calls"))
+	}
+
+	for _, table := range partitionTables() {
+		if err := partitionRebuild(tx, table, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partitionAhead returns the end of the partitions to create ahead of the
+// timestamp.
+func partitionAhead(timestamp int64) int64 {
+	return time.UnixMilli(partitionMonthStart(timestamp)).UTC().AddDate(0, PartitionMonthsAhead+1, 0).UnixMilli()
+}
+
+func partitionBound(s string) int64 {
+	switch s {
+	case "MINVALUE":
+		return math.MinInt64
+	case "MAXVALUE":
+		return math.MaxInt64
+	}
+
+	i, _ := strconv.ParseInt(s, 10, 64)
+
+	return i
+}
+
+func partitionColumns(tx *StorageTx, table string) ([]string, error) {
+	var (
+		columns = []string{}
+		err     error
+		rows    *sql.Rows
+	)
+
+	query := `SELECT "column_name" FROM "information_schema"."columns" WHERE "table_schema" = current_schema() AND "table_name" = ? ORDER BY "ordinal_position"`
+	if rows, err = tx.Query(query, table); err != nil {
+		return nil, fmt.Errorf("%s in %s", err, query)
+	}
+
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			break
+		}
+		columns = append(columns, column)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+func partitionIdentifier(s string) string {
+	return fmt.Sprintf(`"%s"`, strings.ReplaceAll(s, `"`, `""`))
+}
+
+// partitionInsert returns the insert of a row of details of a call, with the
+// timestamp of the call on which the details are partitioned.
+func partitionInsert(partitioned bool, table string, columns []string, timestamp int64, args ...any) (string, []any) {
+	if partitioned {
+		columns = append(append([]string{}, columns...), "timestamp")
+		args = append(args, timestamp)
+	}
+
+	list := make([]string, len(columns))
+	for i, column := range columns {
+		list[i] = fmt.Sprintf(`"%s"`, column)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	return fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, strings.Join(list, ", "), placeholders), args
+}
+
+func partitionIsPartitioned(queryRow func(query string, args ...any) *sql.Row) (bool, error) {
+	var count uint
+
+	query := `SELECT COUNT(*) FROM "pg_partitioned_table" WHERE "partrelid" = to_regclass('"calls"')`
+	if err := queryRow(query).Scan(&count); err != nil {
+		return false, fmt.Errorf("%s in %s", err, query)
+	}
+
+	return count > 0, nil
+}
+
+// partitionMonth adds the partitions of the month starting at the timestamp
+// to the calls and their details, moving into them the rows that landed in
+// the default partitions, and returns the name of the calls partition.
+func partitionMonth(tx *StorageTx, from int64) (string, error) {
+	to := partitionMonthEnd(from)
+	suffix := time.UnixMilli(from).UTC().Format("2006_01")
+
+	for _, table := range partitionTables() {
+		name := fmt.Sprintf("%s_%s", table, suffix)
+		other := fmt.Sprintf("%s_%s", table, PartitionDefault)
+
+		queries := []struct {
+			args  []any
+			query string
+		}{
+			{query: fmt.Sprintf(`CREATE TABLE "%s" (LIKE "%s" INCLUDING DEFAULTS)`, name, table)},
+			{args: []any{from, to}, query: fmt.Sprintf(`INSERT INTO "%s" SELECT * FROM "%s" WHERE "timestamp" >= ? AND "timestamp" < ?`, name, other)},
+			{args: []any{from, to}, query: fmt.Sprintf(`DELETE FROM "%s" WHERE "timestamp" >= ? AND "timestamp" < ?`, other)},
+			{query: fmt.Sprintf(`ALTER TABLE "%s" ATTACH PARTITION "%s" FOR VALUES FROM (%d) TO (%d)`, table, name, from, to)},
+		}
+
+		for _, q := range queries {
+			if _, err := tx.Exec(q.query, q.args...); err != nil {
+				return "", fmt.Errorf("%s in %s", err, q.query)
+			}
+		}
+	}
+
+	return fmt.Sprintf("calls_%s", suffix), nil
+}
+
+func partitionMonthEnd(timestamp int64) int64 {
+	return time.UnixMilli(partitionMonthStart(timestamp)).UTC().AddDate(0, 1, 0).UnixMilli()
+}
+
+func partitionMonthStart(timestamp int64) int64 {
+	t := time.UnixMilli(timestamp).UTC()
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+}
+
+// partitionMoveBatch moves the latest calls left in the previous tables, with
+// their details, into the current tables and returns the number of calls
+// moved. The details get the timestamp of their call when the current tables
+// are partitioned on it.
+func partitionMoveBatch(tx *StorageTx) (uint, error) {
+	var (
+		err   error
+		ids   = []uint64{}
+		query string
+		rows  *sql.Rows
+	)
+
+	query = fmt.Sprintf(`SELECT "callId" FROM "%s" ORDER BY "callId" DESC LIMIT ?`, partitionPrevious("calls"))
+	if rows, err = tx.Query(query, PartitionMoveBatchSize); err != nil {
+		return 0, fmt.Errorf("%s in %s", err, query)
+	}
+
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	in, args := sqlIn(ids)
+
+	for _, table := range partitionTables() {
+		current, err := partitionColumns(tx, table)
+		if err != nil {
+			return 0, err
+		}
+
+		previous, err := partitionColumns(tx, partitionPrevious(table))
+		if err != nil {
+			return 0, err
+		}
+
+		targets, sources := []string{}, []string{}
+
+		for _, column := range current {
+			if slices.Contains(previous, column) {
+				targets = append(targets, fmt.Sprintf(`"%s"`, column))
+				sources = append(sources, fmt.Sprintf(`d."%s"`, column))
+
+			} else if column == "timestamp" {
+				targets = append(targets, `"timestamp"`)
+				sources = append(sources, `c."timestamp"`)
+			}
+		}
+
+		query = fmt.Sprintf(`INSERT INTO "%s" (%s) SELECT %s FROM "%s" AS d INNER JOIN "%s" AS c ON c."callId" = d."callId" WHERE d."callId" IN %s`, table, strings.Join(targets, ", "), strings.Join(sources, ", "), partitionPrevious(table), partitionPrevious("calls"), in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	for _, table := range append(append([]string{}, PartitionCallTables...), "calls") {
+		query = fmt.Sprintf(`DELETE FROM "%s" WHERE "callId" IN %s`, partitionPrevious(table), in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	return uint(len(ids)), nil
+}
+
+// partitionMoveFinish drops the emptied previous tables and, when the calls
+// are no longer partitioned, restores the foreign keys on the calls.
+func partitionMoveFinish(tx *StorageTx) error {
+	for _, table := range append(append([]string{}, PartitionCallTables...), "calls") {
+		query := fmt.Sprintf(`DROP TABLE "%s"`, partitionPrevious(table))
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	if ok, err := partitionIsPartitioned(tx.QueryRow); err != nil || ok {
+		return err
+	}
+
+	for _, table := range append(append([]string{}, PartitionCallTables...), PartitionReferenceTables...) {
+		var count uint
+
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "pg_constraint" WHERE "conname" = ? AND "conrelid" = '"%s"'::regclass`, table)
+		if err := tx.QueryRow(query, partitionCallKeys[table]).Scan(&count); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		queries := []string{
+			fmt.Sprintf(`DELETE FROM "%s" AS d WHERE NOT EXISTS (SELECT 1 FROM "calls" AS c WHERE c."callId" = d."callId")`, table),
+			fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE`, table, partitionCallKeys[table]),
+		}
+
+		for _, query = range queries {
+			if _, err := tx.Exec(query); err != nil {
+				return fmt.Errorf("%s in %s", err, query)
+			}
+		}
+	}
+
+	return nil
+}
+
+func partitionPairs(tx *StorageTx, query string, args ...any) ([][2]string, error) {
+	var (
+		err   error
+		pairs = [][2]string{}
+		rows  *sql.Rows
+	)
+
+	if rows, err = tx.Query(query, args...); err != nil {
+		return nil, fmt.Errorf("%s in %s", err, query)
+	}
+
+	for rows.Next() {
+		var pair [2]string
+		if err = rows.Scan(&pair[0], &pair[1]); err != nil {
+			break
+		}
+		pairs = append(pairs, pair)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// partitionPending tells whether calls are left to move from the tables
+// replaced by the calls partitions migration or its rollback.
+func partitionPending(queryRow func(query string, args ...any) *sql.Row) (bool, error) {
+	var pending bool
+
+	query := fmt.Sprintf(`SELECT to_regclass('"%s"') IS NOT NULL`, partitionPrevious("calls"))
+	if err := queryRow(query).Scan(&pending); err != nil {
+		return false, fmt.Errorf("%s in %s", err, query)
+	}
+
+	return pending, nil
+}
+
+func partitionPrevious(table string) string {
+	return fmt.Sprintf("%s_previous", table)
+}
+
+// partitionRebuild renames a table and its indexes out of the way of a new
+// table with the same columns, primary key, foreign keys, indexes and
+// sequence, partitioned by month on the timestamp of the calls or not. The
+// rows stay in the renamed table, indexed on their call for the move.
+func partitionRebuild(tx *StorageTx, table string, partitioned bool) error {
+	var (
+		err     error
+		indexes [][2]string
+		keys    [][2]string
+		pk      [2]string
+		query   string
+		seq     sql.NullString
+	)
+
+	previous := partitionPrevious(table)
+
+	query = fmt.Sprintf(`SELECT c."conname", a."attname" FROM "pg_constraint" AS c INNER JOIN "pg_attribute" AS a ON a."attrelid" = c."conrelid" AND a."attnum" = c."conkey"[1] WHERE c."contype" = 'p' AND c."conrelid" = '"%s"'::regclass`, table)
+	if err = tx.QueryRow(query).Scan(&pk[0], &pk[1]); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	query = `SELECT "indexname", "indexdef" FROM "pg_indexes" WHERE "schemaname" = current_schema() AND "tablename" = ?`
+	if indexes, err = partitionPairs(tx, query, table); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`SELECT "conname", pg_get_constraintdef("oid") FROM "pg_constraint" WHERE "contype" = 'f' AND "conrelid" = '"%s"'::regclass`, table)
+	if keys, err = partitionPairs(tx, query); err != nil {
+		return err
+	}
+
+	query = `SELECT pg_get_serial_sequence(?, ?)`
+	if err = tx.QueryRow(query, fmt.Sprintf(`"%s"`, table), pk[1]).Scan(&seq); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	// the names of the indexes, primary key included, are freed for the
+	// new table
+	queries := []string{fmt.Sprintf(`ALTER TABLE "%s" RENAME TO "%s"`, table, previous)}
+
+	for _, index := range indexes {
+		queries = append(queries, fmt.Sprintf(`ALTER INDEX "%s" RENAME TO "%s"`, index[0], partitionPrevious(index[0])))
+	}
+
+	switch {
+	case partitioned && table == "calls":
+		queries = append(queries,
+			fmt.Sprintf(`CREATE TABLE "%s" (LIKE "%s" INCLUDING DEFAULTS) PARTITION BY RANGE ("timestamp")`, table, previous),
+			fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" PRIMARY KEY ("%s", "timestamp")`, table, pk[0], pk[1]),
+		)
+
+	case partitioned:
+		queries = append(queries,
+			fmt.Sprintf(`CREATE TABLE "%s" (LIKE "%s" INCLUDING DEFAULTS, "timestamp" bigint NOT NULL) PARTITION BY RANGE ("timestamp")`, table, previous),
+			fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" PRIMARY KEY ("%s", "timestamp")`, table, pk[0], pk[1]),
+		)
+
+	case table == "calls":
+		queries = append(queries,
+			fmt.Sprintf(`CREATE TABLE "%s" (LIKE "%s" INCLUDING DEFAULTS)`, table, previous),
+			fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" PRIMARY KEY ("%s")`, table, pk[0], pk[1]),
+		)
+
+	default:
+		queries = append(queries,
+			fmt.Sprintf(`CREATE TABLE "%s" (LIKE "%s" INCLUDING DEFAULTS)`, table, previous),
+			fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "timestamp"`, table),
+			fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" PRIMARY KEY ("%s")`, table, pk[0], pk[1]),
+		)
+	}
+
+	for _, index := range indexes {
+		if index[0] != pk[0] {
+			queries = append(queries, index[1])
+		}
+	}
+
+	// the details are read by call ids, spread over the partitions
+	if partitioned && table != "calls" {
+		queries = append(queries,
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_callId_idx" ON "%s" ("callId")`, table, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_callId_idx" ON "%s" ("callId")`, previous, previous),
+		)
+	}
+
+	for _, key := range keys {
+		queries = append(queries, fmt.Sprintf(`ALTER TABLE "%s" ADD CONSTRAINT "%s" %s`, table, key[0], key[1]))
+	}
+
+	// the sequence would otherwise be dropped with the previous table
+	if seq.Valid {
+		queries = append(queries, fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY "%s"."%s"`, seq.String, table, pk[1]))
+	}
+
+	for _, query = range queries {
+		if _, err = tx.Exec(query); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+
+	return nil
+}
+
+// partitionTables returns the calls followed by the tables of their details.
+func partitionTables() []string {
+	return append([]string{"calls"}, PartitionCallTables...)
+}
+
+// partitionTimestamp completes the columns and placeholders of an insert of
+// details of the calls from an unpartitioned database with the timestamp of
+// their call, once the details are partitioned on it. It returns the index of
+// the call id among the columns, its value to be bound again, or -1.
+func partitionTimestamp(tx *StorageTx, table string, columns []string, placeholders []string) ([]string, []string, int, error) {
+	callId := -1
+
+	if tx.db.Dialect().Family != DbTypePostgresql || !slices.Contains(PartitionCallTables, table) {
+		return columns, placeholders, callId, nil
+	}
+
+	for i, column := range columns {
+		switch strings.Trim(column, `"`) {
+		case "callId":
+			callId = i
+		case "timestamp":
+			return columns, placeholders, -1, nil
+		}
+	}
+
+	targetColumns, err := partitionColumns(tx, table)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	if callId < 0 || !slices.Contains(targetColumns, "timestamp") {
+		return columns, placeholders, -1, nil
+	}
+
+	return append(columns, `"timestamp"`), append(placeholders, `(SELECT "timestamp" FROM "calls" WHERE "callId" = ?)`), callId, nil
+}
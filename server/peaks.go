@@ -0,0 +1,96 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+const (
+	PeaksCount   = 256
+	PeaksTimeout = 30 * time.Second
+	PeaksWorkers = 2
+)
+
+// Peaks is the waveform of a call, the highest level of each of its slices
+// scaled from 0 to 255, so that clients can draw it without decoding the
+// audio.
+type Peaks []uint8
+
+// NewPeaks slices the interleaved samples into at most PeaksCount buckets.
+func NewPeaks(samples []float64, channels uint) Peaks {
+	if channels == 0 {
+		channels = 1
+	}
+
+	frames := len(samples) / int(channels)
+	if frames == 0 {
+		return nil
+	}
+
+	count := min(frames, PeaksCount)
+	peaks := make(Peaks, count)
+
+	for i := range count {
+		var peak float64
+
+		from, to := i*frames/count, (i+1)*frames/count
+
+		for _, v := range samples[from*int(channels) : to*int(channels)] {
+			peak = math.Max(peak, math.Abs(v))
+		}
+
+		peaks[i] = uint8(math.Round(math.Min(peak, 1) * 255))
+	}
+
+	return peaks
+}
+
+// ParsePeaks decodes the value stored in the database.
+func ParsePeaks(s string) Peaks {
+	if len(s) == 0 {
+		return nil
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return Peaks(b)
+	}
+
+	return nil
+}
+
+func (peaks Peaks) MarshalJSON() ([]byte, error) {
+	values := make([]uint, len(peaks))
+
+	for i, v := range peaks {
+		values[i] = uint(v)
+	}
+
+	return json.Marshal(values)
+}
+
+// String returns the value stored in the database.
+func (peaks Peaks) String() string {
+	return base64.StdEncoding.EncodeToString(peaks)
+}
@@ -68,6 +68,8 @@ var PostgresqlSchema = []string{
 	`CREATE TABLE IF NOT EXISTS "systems" (
     "systemId" bigserial NOT NULL PRIMARY KEY,
     "alert" text NOT NULL DEFAULT '',
+    "audioProcessing" text NOT NULL DEFAULT '',
+    "audioProfile" text NOT NULL DEFAULT '',
     "autoPopulate" boolean NOT NULL DEFAULT false,
     "blacklists" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
@@ -82,6 +84,7 @@ var PostgresqlSchema = []string{
     "siteId" bigserial NOT NULL PRIMARY KEY,
     "label" text NOT NULL,
     "order" integer NOT NULL DEFAULT 0,
+    "priority" integer NOT NULL DEFAULT 0,
     "siteRef" integer NOT NULL,
     "systemId" bigint NOT NULL DEFAULT 0,
     CONSTRAINT "sites_systemId" FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE
@@ -90,6 +93,8 @@ var PostgresqlSchema = []string{
 	`CREATE TABLE IF NOT EXISTS "talkgroups" (
     "talkgroupId" bigserial NOT NULL PRIMARY KEY,
     "alert" text NOT NULL DEFAULT '',
+    "audioProcessing" text NOT NULL DEFAULT '',
+    "audioProfile" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
     "frequency" integer NOT NULL DEFAULT 0,
     "label" text NOT NULL,
@@ -117,10 +122,21 @@ var PostgresqlSchema = []string{
     "audio" bytea NOT NULL,
     "audioFilename" text NOT NULL,
     "audioMime" text NOT NULL,
+    "audioProfile" text NOT NULL DEFAULT '',
+    "conversationId" bigint NOT NULL DEFAULT 0,
+    "duration" integer NOT NULL DEFAULT 0,
+    "fingerprint" text NOT NULL DEFAULT '',
+    "flags" text NOT NULL DEFAULT '',
+    "linkedCallId" bigint NOT NULL DEFAULT 0,
+    "peakLevel" float NOT NULL DEFAULT 0,
+    "peaks" text NOT NULL DEFAULT '',
+    "rmsLevel" float NOT NULL DEFAULT 0,
+    "sampleRate" integer NOT NULL DEFAULT 0,
     "siteRef" integer NOT NULL DEFAULT 0,
     "systemId" bigint NOT NULL,
     "talkgroupId" bigint NOT NULL,
     "timestamp" bigint NOT NULL,
+    "trimmed" float NOT NULL DEFAULT 0,
     CONSTRAINT "calls_systemId" FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
     CONSTRAINT "calls_talkgroupId" FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
@@ -138,6 +154,8 @@ var PostgresqlSchema = []string{
     CONSTRAINT "callFrequencies_callId" FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callFrequencies_idx" ON "callFrequencies" ("frequency","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callPatches" (
     "callPatchId" bigserial NOT NULL PRIMARY KEY,
     "callId" bigint NOT NULL,
@@ -146,6 +164,8 @@ var PostgresqlSchema = []string{
     CONSTRAINT "callPatches_talkgroupId" FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callPatches_idx" ON "callPatches" ("talkgroupId","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callUnits" (
     "callUnitId" bigserial NOT NULL PRIMARY KEY,
     "callId" bigint NOT NULL,
@@ -154,6 +174,21 @@ var PostgresqlSchema = []string{
     CONSTRAINT "callUnits_callId" FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callUnits_idx" ON "callUnits" ("unitRef","callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "conversations" (
+    "conversationId" bigserial NOT NULL PRIMARY KEY,
+    "callCount" integer NOT NULL DEFAULT 0,
+    "firstTimestamp" bigint NOT NULL,
+    "lastTimestamp" bigint NOT NULL,
+    "systemId" bigint NOT NULL,
+    "talkgroupId" bigint NOT NULL,
+    FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "conversations_idx" ON "conversations" ("systemId","talkgroupId","lastTimestamp");`,
+
 	`CREATE TABLE IF NOT EXISTS "delayed" (
     "delayedId" bigserial NOT NULL PRIMARY KEY,
     "callId" bigint NOT NULL,
@@ -163,20 +198,111 @@ var PostgresqlSchema = []string{
 
 	`CREATE TABLE IF NOT EXISTS "dirwatches" (
     "dirwatchId" bigserial NOT NULL PRIMARY KEY,
+    "archiveDirectory" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
     "deleteAfter" boolean NOT NULL DEFAULT false,
     "directory" text NOT NULL,
     "disabled" boolean NOT NULL DEFAULT false,
     "extension" text NOT NULL DEFAULT '',
+    "failedDirectory" text NOT NULL DEFAULT '',
     "frequency" integer NOT NULL DEFAULT 0,
     "mask" text NOT NULL DEFAULT '',
     "order" integer NOT NULL DEFAULT 0,
+    "pollInterval" integer NOT NULL DEFAULT 0,
+    "polling" boolean NOT NULL DEFAULT false,
     "siteId" bigint NOT NULL DEFAULT 0,
     "systemId" bigint NOT NULL DEFAULT 0,
     "talkgroupId" bigint NOT NULL DEFAULT 0,
     "type" text NOT NULL DEFAULT ''
   );`,
 
+	`CREATE TABLE IF NOT EXISTS "dirwatchFiles" (
+    "dirwatchFileId" bigserial NOT NULL PRIMARY KEY,
+    "dirwatchId" bigint NOT NULL,
+    "modTime" bigint NOT NULL DEFAULT 0,
+    "path" text NOT NULL,
+    "reason" text NOT NULL DEFAULT '',
+    "size" bigint NOT NULL DEFAULT 0,
+    "status" text NOT NULL DEFAULT '',
+    "timestamp" bigint NOT NULL,
+    CONSTRAINT "dirwatchFiles_dirwatchId" FOREIGN KEY ("dirwatchId") REFERENCES "dirwatches" ("dirwatchId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "dirwatchFiles_idx" ON "dirwatchFiles" ("dirwatchId");`,
+
+	`CREATE TABLE IF NOT EXISTS "discoveries" (
+    "discoveryId" bigserial NOT NULL PRIMARY KEY,
+    "count" integer NOT NULL DEFAULT 0,
+    "firstSeen" bigint NOT NULL,
+    "frequencies" text NOT NULL DEFAULT '',
+    "lastSeen" bigint NOT NULL,
+    "status" text NOT NULL DEFAULT '',
+    "systemLabel" text NOT NULL DEFAULT '',
+    "systemRef" integer NOT NULL DEFAULT 0,
+    "talkgroupLabel" text NOT NULL DEFAULT '',
+    "talkgroupRef" integer NOT NULL DEFAULT 0,
+    "units" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "discoveries_idx" ON "discoveries" ("systemRef","talkgroupRef");`,
+
+	`CREATE TABLE IF NOT EXISTS "downsampledCalls" (
+    "downsampledCallId" bigserial NOT NULL PRIMARY KEY,
+    "callId" bigint NOT NULL,
+    "error" text NOT NULL DEFAULT '',
+    "profile" text NOT NULL,
+    "sizeAfter" bigint NOT NULL DEFAULT 0,
+    "sizeBefore" bigint NOT NULL DEFAULT 0,
+    "timestamp" bigint NOT NULL,
+    FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "downsampledCalls_idx" ON "downsampledCalls" ("callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "incidents" (
+    "incidentId" bigserial NOT NULL PRIMARY KEY,
+    "accesses" text NOT NULL DEFAULT '',
+    "closed" boolean NOT NULL DEFAULT false,
+    "description" text NOT NULL DEFAULT '',
+    "label" text NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "incidentCalls" (
+    "incidentCallId" bigserial NOT NULL PRIMARY KEY,
+    "callId" bigint NOT NULL,
+    "incidentId" bigint NOT NULL,
+    FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("incidentId") REFERENCES "incidents" ("incidentId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "incidentCalls_idx" ON "incidentCalls" ("incidentId","callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "incidentNotes" (
+    "incidentNoteId" bigserial NOT NULL PRIMARY KEY,
+    "incidentId" bigint NOT NULL,
+    "note" text NOT NULL,
+    "timestamp" bigint NOT NULL,
+    FOREIGN KEY ("incidentId") REFERENCES "incidents" ("incidentId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "jobs" (
+    "jobId" bigserial NOT NULL PRIMARY KEY,
+    "enabled" boolean NOT NULL DEFAULT true,
+    "name" text NOT NULL,
+    "schedule" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "jobRuns" (
+    "jobRunId" bigserial NOT NULL PRIMARY KEY,
+    "duration" bigint NOT NULL DEFAULT 0,
+    "error" text NOT NULL DEFAULT '',
+    "manual" boolean NOT NULL DEFAULT false,
+    "name" text NOT NULL,
+    "status" text NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`,
+
 	`CREATE TABLE IF NOT EXISTS "logs" (
     "logId" bigserial NOT NULL PRIMARY KEY,
     "level" text NOT NULL,
@@ -190,6 +316,40 @@ var PostgresqlSchema = []string{
     "value" text NOT NULL
   );`,
 
+	`CREATE TABLE IF NOT EXISTS "quarantinedCalls" (
+    "quarantinedCallId" bigserial NOT NULL PRIMARY KEY,
+    "audio" bytea NOT NULL,
+    "audioFilename" text NOT NULL,
+    "audioMime" text NOT NULL,
+    "frequencies" text NOT NULL DEFAULT '',
+    "meta" text NOT NULL DEFAULT '',
+    "patches" text NOT NULL DEFAULT '',
+    "siteRef" integer NOT NULL DEFAULT 0,
+    "systemLabel" text NOT NULL DEFAULT '',
+    "systemRef" integer NOT NULL DEFAULT 0,
+    "talkgroupLabel" text NOT NULL DEFAULT '',
+    "talkgroupRef" integer NOT NULL DEFAULT 0,
+    "timestamp" bigint NOT NULL,
+    "units" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "quarantinedCalls_idx" ON "quarantinedCalls" ("systemRef","talkgroupRef","timestamp");`,
+
+	`CREATE TABLE IF NOT EXISTS "unitActivities" (
+    "unitActivityId" bigserial NOT NULL PRIMARY KEY,
+    "callCount" integer NOT NULL DEFAULT 0,
+    "firstTimestamp" bigint NOT NULL,
+    "lastTimestamp" bigint NOT NULL,
+    "siteRef" integer NOT NULL DEFAULT 0,
+    "systemId" bigint NOT NULL,
+    "talkgroupId" bigint NOT NULL,
+    "unitRef" integer NOT NULL,
+    FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "unitActivities_idx" ON "unitActivities" ("systemId","unitRef","talkgroupId","siteRef");`,
+
 	`CREATE TABLE IF NOT EXISTS "units" (
     "unitId" bigserial NOT NULL PRIMARY KEY,
     "label" text NOT NULL,
@@ -201,3 +361,93 @@ var PostgresqlSchema = []string{
     CONSTRAINT "units_systemId" FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 }
+
+var PostgresqlMigrations = []*SchemaMigration{
+	{
+		Columns: PostgresqlColumns,
+		Name:    "initial schema",
+		Up:      PostgresqlSchema,
+		Version: 1,
+	},
+	{
+		Func:    migrateVersion6,
+		Name:    "version 6 import",
+		Version: 2,
+	},
+	{
+		Columns: [][]string{
+			{"calls", "source", "text NOT NULL DEFAULT ''"},
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS "listenerCounts";`,
+			`ALTER TABLE "calls" DROP COLUMN "source";`,
+		},
+		Name: "call statistics",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "listenerCounts" (
+    "listenerCountId" bigserial NOT NULL PRIMARY KEY,
+    "count" integer NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`,
+
+			`CREATE INDEX IF NOT EXISTS "listenerCounts_idx" ON "listenerCounts" ("timestamp");`,
+		},
+		Version: 3,
+	},
+	{
+		Columns: [][]string{
+			{"calls", "simulcast", "text NOT NULL DEFAULT ''"},
+		},
+		Down: []string{
+			`ALTER TABLE "calls" DROP COLUMN "simulcast";`,
+		},
+		Name:    "simulcast decisions",
+		Version: 4,
+	},
+	{
+		// on partitioned calls, the index is also created on every
+		// partition, current and future
+		Down: []string{
+			`DROP INDEX IF EXISTS "calls_timestamp_idx";`,
+		},
+		Name: "calls timestamp index",
+		Up: []string{
+			`CREATE INDEX IF NOT EXISTS "calls_timestamp_idx" ON "calls" ("timestamp","callId");`,
+		},
+		Version: 5,
+	},
+	{
+		// applied with the db-partition command only, the rows being
+		// moved afterwards by Partitions.Move
+		DownFunc: unpartitionCalls,
+		Func:     partitionCalls,
+		Name:     PartitionMigrationName,
+		Optional: true,
+		Version:  6,
+	},
+}
+
+var PostgresqlColumns = [][]string{
+	{"calls", "audioProfile", "text NOT NULL DEFAULT ''"},
+	{"calls", "conversationId", "bigint NOT NULL DEFAULT 0"},
+	{"calls", "duration", "integer NOT NULL DEFAULT 0"},
+	{"calls", "fingerprint", "text NOT NULL DEFAULT ''"},
+	{"calls", "flags", "text NOT NULL DEFAULT ''"},
+	{"calls", "linkedCallId", "bigint NOT NULL DEFAULT 0"},
+	{"calls", "peakLevel", "float NOT NULL DEFAULT 0"},
+	{"calls", "peaks", "text NOT NULL DEFAULT ''"},
+	{"calls", "rmsLevel", "float NOT NULL DEFAULT 0"},
+	{"calls", "sampleRate", "integer NOT NULL DEFAULT 0"},
+	{"calls", "trimmed", "float NOT NULL DEFAULT 0"},
+	{"dirwatches", "archiveDirectory", "text NOT NULL DEFAULT ''"},
+	{"dirwatches", "failedDirectory", "text NOT NULL DEFAULT ''"},
+	{"dirwatches", "pollInterval", "integer NOT NULL DEFAULT 0"},
+	{"dirwatches", "polling", "boolean NOT NULL DEFAULT false"},
+	{"dirwatchFiles", "reason", "text NOT NULL DEFAULT ''"},
+	{"dirwatchFiles", "status", "text NOT NULL DEFAULT ''"},
+	{"sites", "priority", "integer NOT NULL DEFAULT 0"},
+	{"systems", "audioProcessing", "text NOT NULL DEFAULT ''"},
+	{"systems", "audioProfile", "text NOT NULL DEFAULT ''"},
+	{"talkgroups", "audioProcessing", "text NOT NULL DEFAULT ''"},
+	{"talkgroups", "audioProfile", "text NOT NULL DEFAULT ''"},
+}
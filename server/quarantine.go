@@ -0,0 +1,486 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	QuarantineActionCreate  = "create"
+	QuarantineActionDiscard = "discard"
+	QuarantineActionMap     = "map"
+	QuarantineActionRelease = "release"
+)
+
+type Quarantine struct {
+	controller *Controller
+	mutex      sync.Mutex
+	releasing  map[uint64]bool
+}
+
+type QuarantineGroup struct {
+	Count          uint      `json:"count"`
+	DateStart      time.Time `json:"dateStart"`
+	DateStop       time.Time `json:"dateStop"`
+	SystemLabel    string    `json:"systemLabel"`
+	SystemRef      uint      `json:"system"`
+	TalkgroupLabel string    `json:"talkgroupLabel"`
+	TalkgroupRef   uint      `json:"talkgroup"`
+}
+
+type QuarantineRequest struct {
+	Action         string
+	Ids            []uint64
+	SystemId       uint64
+	SystemRef      uint
+	TalkgroupGroup string
+	TalkgroupId    uint64
+	TalkgroupLabel string
+	TalkgroupName  string
+	TalkgroupRef   uint
+	TalkgroupTag   string
+}
+
+func NewQuarantine(controller *Controller) *Quarantine {
+	return &Quarantine{
+		controller: controller,
+		mutex:      sync.Mutex{},
+		releasing:  map[uint64]bool{},
+	}
+}
+
+func NewQuarantineRequest() *QuarantineRequest {
+	return &QuarantineRequest{
+		Ids: []uint64{},
+	}
+}
+
+func (request *QuarantineRequest) FromMap(m map[string]any) *QuarantineRequest {
+	switch v := m["action"].(type) {
+	case string:
+		request.Action = v
+	}
+
+	switch v := m["ids"].(type) {
+	case []any:
+		for _, id := range v {
+			switch id := id.(type) {
+			case float64:
+				request.Ids = append(request.Ids, uint64(id))
+			}
+		}
+	}
+
+	switch v := m["group"].(type) {
+	case string:
+		request.TalkgroupGroup = v
+	}
+
+	switch v := m["label"].(type) {
+	case string:
+		request.TalkgroupLabel = v
+	}
+
+	switch v := m["name"].(type) {
+	case string:
+		request.TalkgroupName = v
+	}
+
+	switch v := m["system"].(type) {
+	case float64:
+		request.SystemRef = uint(v)
+	}
+
+	switch v := m["systemId"].(type) {
+	case float64:
+		request.SystemId = uint64(v)
+	}
+
+	switch v := m["tag"].(type) {
+	case string:
+		request.TalkgroupTag = v
+	}
+
+	switch v := m["talkgroup"].(type) {
+	case float64:
+		request.TalkgroupRef = uint(v)
+	}
+
+	switch v := m["talkgroupId"].(type) {
+	case float64:
+		request.TalkgroupId = uint64(v)
+	}
+
+	return request
+}
+
+func (quarantine *Quarantine) Add(call *Call, db Storage) error {
+	var (
+		err         error
+		frequencies []byte
+		meta        []byte
+		patches     []byte
+		query       string
+		units       []byte
+
+		systemLabel    = call.Meta.SystemLabel
+		systemRef      = call.Meta.SystemRef
+		talkgroupLabel = call.Meta.TalkgroupLabel
+		talkgroupRef   = call.Meta.TalkgroupRef
+	)
+
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+
+	formatError := errorFormatter("quarantine", "add")
+
+	if call.System != nil {
+		systemLabel = call.System.Label
+		systemRef = call.System.SystemRef
+	}
+
+	if call.Talkgroup != nil {
+		talkgroupLabel = call.Talkgroup.Label
+		talkgroupRef = call.Talkgroup.TalkgroupRef
+	}
+
+	if frequencies, err = json.Marshal(call.Frequencies); err != nil {
+		return formatError(err, "")
+	}
+
+	if meta, err = json.Marshal(call.Meta); err != nil {
+		return formatError(err, "")
+	}
+
+	if patches, err = json.Marshal(call.Patches); err != nil {
+		return formatError(err, "")
+	}
+
+	if units, err = json.Marshal(call.Units); err != nil {
+		return formatError(err, "")
+	}
+
+	query = `INSERT INTO "quarantinedCalls" ("audio", "audioFilename", "audioMime", "frequencies", "meta", "patches", "siteRef", "systemLabel", "systemRef", "talkgroupLabel", "talkgroupRef", "timestamp", "units") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err = db.Exec(query, call.Audio, call.AudioFilename, call.AudioMime, string(frequencies), string(meta), string(patches), call.SiteRef, systemLabel, systemRef, talkgroupLabel, talkgroupRef, call.Timestamp.UnixMilli(), string(units)); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
+
+func (quarantine *Quarantine) Discard(request *QuarantineRequest, db Storage) (uint, error) {
+	var (
+		count int64
+		err   error
+		query string
+		res   sql.Result
+	)
+
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+
+	formatError := errorFormatter("quarantine", "discard")
+
+	where, args := quarantine.where(request)
+
+	query = fmt.Sprintf(`DELETE FROM "quarantinedCalls" WHERE %s`, where)
+	if res, err = db.Exec(query, args...); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	if count, err = res.RowsAffected(); err != nil {
+		return 0, formatError(err, "")
+	}
+
+	return uint(count), nil
+}
+
+func (quarantine *Quarantine) List(db Storage) ([]QuarantineGroup, error) {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+
+	formatError := errorFormatter("quarantine", "list")
+
+	groups := []QuarantineGroup{}
+
+	query = `SELECT "systemRef", "talkgroupRef", MAX("systemLabel"), MAX("talkgroupLabel"), COUNT(*), MIN("timestamp"), MAX("timestamp") FROM "quarantinedCalls" GROUP BY "systemRef", "talkgroupRef" ORDER BY "systemRef", "talkgroupRef"`
+	if rows, err = db.Query(query); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			group QuarantineGroup
+			start int64
+			stop  int64
+		)
+
+		if err = rows.Scan(&group.SystemRef, &group.TalkgroupRef, &group.SystemLabel, &group.TalkgroupLabel, &group.Count, &start, &stop); err != nil {
+			break
+		}
+
+		group.DateStart = time.UnixMilli(start)
+		group.DateStop = time.UnixMilli(stop)
+
+		groups = append(groups, group)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	return groups, nil
+}
+
+// Prune removes the calls held longer than the quarantine days of the options,
+// whatever their prune days.
+func (quarantine *Quarantine) Prune(db Storage) error {
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+
+	days := quarantine.controller.Options.QuarantineDays
+	if days == 0 {
+		days = defaults.options.quarantineDays
+	}
+
+	timestamp := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixMilli()
+	query := `DELETE FROM "quarantinedCalls" WHERE "timestamp" < ?`
+
+	if _, err := db.Exec(query, timestamp); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	return nil
+}
+
+func (quarantine *Quarantine) Process(request *QuarantineRequest, db Storage) (uint, error) {
+	switch request.Action {
+	case QuarantineActionDiscard:
+		return quarantine.Discard(request, db)
+
+	case QuarantineActionCreate, QuarantineActionMap, QuarantineActionRelease:
+		return quarantine.Release(request, db)
+	}
+
+	return 0, fmt.Errorf("quarantine.process: unknown action %s", request.Action)
+}
+
+// Release sends the matching quarantined calls back to the ingest. Only their
+// ids are read here, each call being loaded right before it is queued by a
+// background routine so that large groups neither fill the memory nor hold
+// the request while the ingest is busy.
+func (quarantine *Quarantine) Release(request *QuarantineRequest, db Storage) (uint, error) {
+	var (
+		err   error
+		ids   = []uint64{}
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("quarantine", "release")
+
+	if request.Action == QuarantineActionMap && (request.SystemId == 0 || request.TalkgroupId == 0) {
+		return 0, formatError(errors.New("systemId and talkgroupId are required"), "")
+	}
+
+	quarantine.mutex.Lock()
+	defer quarantine.mutex.Unlock()
+
+	where, args := quarantine.where(request)
+
+	query = fmt.Sprintf(`SELECT "quarantinedCallId" FROM "quarantinedCalls" WHERE %s ORDER BY "timestamp"`, where)
+	if rows, err = db.Query(query, args...); err != nil {
+		return 0, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var id uint64
+
+		if err = rows.Scan(&id); err != nil {
+			break
+		}
+
+		// already on its way from a previous release
+		if quarantine.releasing[id] {
+			continue
+		}
+
+		quarantine.releasing[id] = true
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		for _, id := range ids {
+			delete(quarantine.releasing, id)
+		}
+
+		return 0, formatError(err, "")
+	}
+
+	go quarantine.release(request, ids, db)
+
+	return uint(len(ids)), nil
+}
+
+func (quarantine *Quarantine) read(id uint64, db Storage) (*Call, error) {
+	var (
+		frequencies string
+		meta        string
+		patches     string
+		timestamp   int64
+		units       string
+	)
+
+	formatError := errorFormatter("quarantine", "read")
+
+	call := NewCall()
+
+	query := `SELECT "quarantinedCallId", "audio", "audioFilename", "audioMime", "frequencies", "meta", "patches", "siteRef", "timestamp", "units" FROM "quarantinedCalls" WHERE "quarantinedCallId" = ?`
+	if err := db.QueryRow(query, id).Scan(&call.quarantineId, &call.Audio, &call.AudioFilename, &call.AudioMime, &frequencies, &meta, &patches, &call.SiteRef, &timestamp, &units); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	if err := quarantine.unmarshal(call, frequencies, meta, patches, units); err != nil {
+		return nil, formatError(err, "")
+	}
+
+	call.Timestamp = time.UnixMilli(timestamp)
+
+	return call, nil
+}
+
+func (quarantine *Quarantine) release(request *QuarantineRequest, ids []uint64, db Storage) {
+	done := func(id uint64) {
+		quarantine.mutex.Lock()
+		delete(quarantine.releasing, id)
+		quarantine.mutex.Unlock()
+	}
+
+	ctx := quarantine.controller.Ingester.ctx
+
+	for i, id := range ids {
+		call, err := quarantine.read(id, db)
+		if err != nil {
+			done(id)
+
+			if !errors.Is(err, sql.ErrNoRows) {
+				quarantine.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("quarantine.release: %s", err.Error()))
+			}
+
+			continue
+		}
+
+		switch request.Action {
+		case QuarantineActionCreate:
+			call.populate = true
+
+			if len(request.TalkgroupGroup) > 0 {
+				call.Meta.TalkgroupGroups = []string{request.TalkgroupGroup}
+			}
+
+			if len(request.TalkgroupLabel) > 0 {
+				call.Meta.TalkgroupLabel = request.TalkgroupLabel
+			}
+
+			if len(request.TalkgroupName) > 0 {
+				call.Meta.TalkgroupName = request.TalkgroupName
+			}
+
+			if len(request.TalkgroupTag) > 0 {
+				call.Meta.TalkgroupTag = request.TalkgroupTag
+			}
+
+		case QuarantineActionMap:
+			call.Meta.SystemId = request.SystemId
+			call.Meta.TalkgroupId = request.TalkgroupId
+		}
+
+		call.ingested = func(err error) {
+			done(id)
+
+			if err == nil {
+				quarantine.Discard(&QuarantineRequest{Ids: []uint64{id}}, db)
+			}
+		}
+
+		call.Source = "quarantine"
+
+		select {
+		case quarantine.controller.Ingest <- call:
+
+		case <-ctx.Done():
+			// the calls left stay in quarantine for a later release
+			for _, id := range ids[i:] {
+				done(id)
+			}
+			return
+		}
+	}
+}
+
+func (quarantine *Quarantine) unmarshal(call *Call, frequencies string, meta string, patches string, units string) error {
+	for _, field := range []struct {
+		name  string
+		value string
+		v     any
+	}{
+		{"frequencies", frequencies, &call.Frequencies},
+		{"meta", meta, &call.Meta},
+		{"patches", patches, &call.Patches},
+		{"units", units, &call.Units},
+	} {
+		if len(field.value) == 0 {
+			continue
+		}
+
+		if err := json.Unmarshal([]byte(field.value), field.v); err != nil {
+			return fmt.Errorf("quarantined call %d has invalid %s: %s", call.quarantineId, field.name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (quarantine *Quarantine) where(request *QuarantineRequest) (string, []any) {
+	if len(request.Ids) > 0 {
+		in, args := sqlIn(request.Ids)
+		return fmt.Sprintf(`"quarantinedCallId" IN %s`, in), args
+	}
+
+	return `"systemRef" = ? AND "talkgroupRef" = ?`, []any{request.SystemRef, request.TalkgroupRef}
+}
@@ -0,0 +1,379 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	RetentionBatchSize = 1000
+
+	RetentionScopeGroup     = "group"
+	RetentionScopeSystem    = "system"
+	RetentionScopeTag       = "tag"
+	RetentionScopeTalkgroup = "talkgroup"
+)
+
+// RetentionRule keeps the calls of a system, a talkgroup, a group or a tag for
+// a given number of days, or forever when days is zero.
+type RetentionRule struct {
+	Days      uint   `json:"days"`
+	Group     string `json:"group,omitempty"`
+	Scope     string `json:"scope"`
+	System    uint   `json:"system,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Talkgroup uint   `json:"talkgroup,omitempty"`
+}
+
+func NewRetentionRule() *RetentionRule {
+	return &RetentionRule{}
+}
+
+func (rule *RetentionRule) FromMap(m map[string]any) *RetentionRule {
+	switch v := m["days"].(type) {
+	case float64:
+		rule.Days = uint(v)
+	}
+
+	switch v := m["group"].(type) {
+	case string:
+		rule.Group = v
+	}
+
+	switch v := m["scope"].(type) {
+	case string:
+		rule.Scope = v
+	}
+
+	switch v := m["system"].(type) {
+	case float64:
+		rule.System = uint(v)
+	}
+
+	switch v := m["tag"].(type) {
+	case string:
+		rule.Tag = v
+	}
+
+	switch v := m["talkgroup"].(type) {
+	case float64:
+		rule.Talkgroup = uint(v)
+	}
+
+	return rule
+}
+
+func (rule *RetentionRule) IsValid() bool {
+	switch rule.Scope {
+	case RetentionScopeGroup:
+		return len(rule.Group) > 0
+	case RetentionScopeSystem:
+		return rule.System > 0
+	case RetentionScopeTag:
+		return len(rule.Tag) > 0
+	case RetentionScopeTalkgroup:
+		return rule.System > 0 && rule.Talkgroup > 0
+	}
+
+	return false
+}
+
+func (rule *RetentionRule) Label() string {
+	var target string
+
+	switch rule.Scope {
+	case RetentionScopeGroup:
+		target = fmt.Sprintf("group %s", rule.Group)
+	case RetentionScopeSystem:
+		target = fmt.Sprintf("system %d", rule.System)
+	case RetentionScopeTag:
+		target = fmt.Sprintf("tag %s", rule.Tag)
+	case RetentionScopeTalkgroup:
+		target = fmt.Sprintf("talkgroup %d/%d", rule.System, rule.Talkgroup)
+	}
+
+	if rule.Days == 0 {
+		return fmt.Sprintf("%s kept forever", target)
+	}
+
+	return fmt.Sprintf("%s kept %d days", target, rule.Days)
+}
+
+// Matches tells whether the rule applies to the talkgroup, and how specific
+// it is, the most specific rule taking precedence.
+func (rule *RetentionRule) Matches(system *System, talkgroup *Talkgroup, groups *Groups, tags *Tags) (uint, bool) {
+	switch rule.Scope {
+	case RetentionScopeTalkgroup:
+		return 4, rule.System == system.SystemRef && rule.Talkgroup == talkgroup.TalkgroupRef
+
+	case RetentionScopeGroup:
+		if group, ok := groups.GetGroupByLabel(rule.Group); ok {
+			for _, id := range talkgroup.GroupIds {
+				if id == group.Id {
+					return 3, true
+				}
+			}
+		}
+		return 3, false
+
+	case RetentionScopeTag:
+		if tag, ok := tags.GetTagByLabel(rule.Tag); ok {
+			return 2, tag.Id == talkgroup.TagId
+		}
+		return 2, false
+
+	case RetentionScopeSystem:
+		return 1, rule.System == system.SystemRef
+	}
+
+	return 0, false
+}
+
+type RetentionRules struct {
+	List []*RetentionRule
+}
+
+func NewRetentionRules() *RetentionRules {
+	return &RetentionRules{List: []*RetentionRule{}}
+}
+
+func (rules *RetentionRules) FromMap(f []any) *RetentionRules {
+	rules.List = []*RetentionRule{}
+
+	for _, v := range f {
+		switch m := v.(type) {
+		case map[string]any:
+			rule := NewRetentionRule().FromMap(m)
+
+			if rule.IsValid() {
+				rules.List = append(rules.List, rule)
+			}
+		}
+	}
+
+	return rules
+}
+
+func (rules *RetentionRules) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rules.List)
+}
+
+// Resolve returns the rule applying to the talkgroup. Among the matching rules
+// of the same precedence, the one keeping the calls the longest wins.
+func (rules *RetentionRules) Resolve(system *System, talkgroup *Talkgroup, groups *Groups, tags *Tags) (*RetentionRule, bool) {
+	var (
+		best     *RetentionRule
+		priority uint
+	)
+
+	for _, rule := range rules.List {
+		p, ok := rule.Matches(system, talkgroup, groups, tags)
+		if !ok || p < priority {
+			continue
+		}
+
+		if best == nil || p > priority || best.Days != 0 && (rule.Days == 0 || rule.Days > best.Days) {
+			best = rule
+			priority = p
+		}
+	}
+
+	return best, best != nil
+}
+
+type RetentionReport struct {
+	Calls uint   `json:"calls"`
+	Days  uint   `json:"days"`
+	Label string `json:"label"`
+	Rule  *int   `json:"rule"`
+}
+
+type Retention struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+type retentionBucket struct {
+	args         []any
+	days         uint
+	label        string
+	rule         *int
+	talkgroupIds []uint64
+	where        string
+}
+
+func NewRetention(controller *Controller) *Retention {
+	return &Retention{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+// Apply removes the calls past their retention, rule by rule, in batches.
+func (retention *Retention) Apply(db Storage) error {
+	retention.mutex.Lock()
+	defer retention.mutex.Unlock()
+
+	var (
+		args    = []any{}
+		buckets = retention.plan()
+		cutoff  int64
+		wheres  = []string{}
+	)
+
+	for _, bucket := range buckets {
+		if len(bucket.where) > 0 {
+			args = append(args, bucket.args...)
+			cutoff = max(cutoff, retentionTimestamp(bucket.days))
+			wheres = append(wheres, bucket.where)
+		}
+	}
+
+	if count, err := retention.controller.Partitions.Prune(db, wheres, args, cutoff); err != nil {
+		return err
+	} else if count > 0 {
+		retention.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("retention: %d calls removed by dropping partitions", count))
+	}
+
+	for _, bucket := range buckets {
+		if len(bucket.where) == 0 {
+			continue
+		}
+
+		count, err := retention.controller.Calls.Prune(db, bucket.where, bucket.args, RetentionBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			retention.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("retention: %d calls removed by %s", count, bucket.label))
+		}
+	}
+
+	return nil
+}
+
+// Report returns, without removing anything, how many calls each rule would
+// remove.
+func (retention *Retention) Report(db Storage) ([]*RetentionReport, error) {
+	retention.mutex.Lock()
+	defer retention.mutex.Unlock()
+
+	reports := []*RetentionReport{}
+
+	for _, bucket := range retention.plan() {
+		report := &RetentionReport{
+			Days:  bucket.days,
+			Label: bucket.label,
+			Rule:  bucket.rule,
+		}
+
+		if len(bucket.where) > 0 {
+			count, err := retention.controller.Calls.CountPrunable(db, bucket.where, bucket.args)
+			if err != nil {
+				return nil, err
+			}
+			report.Calls = count
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// plan assigns every talkgroup to the rule applying to it, the remaining ones
+// falling under the global prune days.
+func (retention *Retention) plan() []*retentionBucket {
+	var (
+		buckets = []*retentionBucket{}
+		indexes = map[*RetentionRule]int{}
+		ruled   = []uint64{}
+		options = retention.controller.Options
+	)
+
+	options.mutex.Lock()
+	rules := append([]*RetentionRule{}, options.RetentionRules.List...)
+	pruneDays := options.PruneDays
+	options.mutex.Unlock()
+
+	for i, rule := range rules {
+		indexes[rule] = i
+		buckets = append(buckets, &retentionBucket{
+			days:         rule.Days,
+			label:        rule.Label(),
+			rule:         &i,
+			talkgroupIds: []uint64{},
+		})
+	}
+
+	resolver := &RetentionRules{List: rules}
+
+	for _, system := range retention.controller.Systems.List {
+		for _, talkgroup := range system.Talkgroups.List {
+			if rule, ok := resolver.Resolve(system, talkgroup, retention.controller.Groups, retention.controller.Tags); ok {
+				bucket := buckets[indexes[rule]]
+				bucket.talkgroupIds = append(bucket.talkgroupIds, talkgroup.Id)
+				ruled = append(ruled, talkgroup.Id)
+			}
+		}
+	}
+
+	for _, bucket := range buckets {
+		if bucket.days == 0 || len(bucket.talkgroupIds) == 0 {
+			continue
+		}
+
+		in, args := sqlIn(bucket.talkgroupIds)
+		bucket.args = append(args, retentionTimestamp(bucket.days))
+		bucket.where = fmt.Sprintf(`"talkgroupId" IN %s AND "timestamp" < ?`, in)
+	}
+
+	if pruneDays > 0 {
+		args := []any{retentionTimestamp(pruneDays)}
+		where := `"timestamp" < ?`
+
+		if len(ruled) > 0 {
+			sort.Slice(ruled, func(i int, j int) bool { return ruled[i] < ruled[j] })
+			in, inArgs := sqlIn(ruled)
+			args = append(inArgs, args...)
+			where = fmt.Sprintf(`"talkgroupId" NOT IN %s AND %s`, in, where)
+		}
+
+		buckets = append(buckets, &retentionBucket{
+			args:  args,
+			days:  pruneDays,
+			label: fmt.Sprintf("default kept %d days", pruneDays),
+			where: where,
+		})
+	}
+
+	return buckets
+}
+
+func retentionTimestamp(days uint) int64 {
+	return time.Now().Add(-24 * time.Hour * time.Duration(days)).UnixMilli()
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestRetentionRulesResolve(t *testing.T) {
+	system := &System{SystemRef: 1}
+	talkgroup := &Talkgroup{GroupIds: []uint64{1}, TagId: 2, TalkgroupRef: 100}
+
+	groups := NewGroups()
+	groups.List = []*Group{{Id: 1, Label: "Fire"}, {Id: 3, Label: "Police"}}
+
+	tags := NewTags()
+	tags.List = []*Tag{{Id: 2, Label: "EMS"}, {Id: 4, Label: "Law"}}
+
+	for _, tc := range []struct {
+		name  string
+		rules []*RetentionRule
+		want  string
+	}{
+		{name: "no rule"},
+		{
+			name:  "system",
+			rules: []*RetentionRule{{Days: 30, Scope: RetentionScopeSystem, System: 1}},
+			want:  "system 1 kept 30 days",
+		},
+		{
+			name:  "other system",
+			rules: []*RetentionRule{{Days: 30, Scope: RetentionScopeSystem, System: 2}},
+		},
+		{
+			name: "talkgroup over group",
+			rules: []*RetentionRule{
+				{Days: 10, Group: "Fire", Scope: RetentionScopeGroup},
+				{Days: 5, Scope: RetentionScopeTalkgroup, System: 1, Talkgroup: 100},
+			},
+			want: "talkgroup 1/100 kept 5 days",
+		},
+		{
+			name: "group over tag",
+			rules: []*RetentionRule{
+				{Days: 60, Scope: RetentionScopeTag, Tag: "EMS"},
+				{Days: 10, Group: "Fire", Scope: RetentionScopeGroup},
+			},
+			want: "group Fire kept 10 days",
+		},
+		{
+			name: "tag over system",
+			rules: []*RetentionRule{
+				{Scope: RetentionScopeSystem, System: 1},
+				{Days: 20, Scope: RetentionScopeTag, Tag: "EMS"},
+			},
+			want: "tag EMS kept 20 days",
+		},
+		{
+			name: "unmatched group",
+			rules: []*RetentionRule{
+				{Days: 5, Group: "Police", Scope: RetentionScopeGroup},
+				{Days: 7, Scope: RetentionScopeTag, Tag: "Law"},
+				{Days: 30, Scope: RetentionScopeSystem, System: 1},
+			},
+			want: "system 1 kept 30 days",
+		},
+		{
+			name: "longest of a same precedence",
+			rules: []*RetentionRule{
+				{Days: 10, Scope: RetentionScopeSystem, System: 1},
+				{Days: 30, Scope: RetentionScopeSystem, System: 1},
+				{Days: 20, Scope: RetentionScopeSystem, System: 1},
+			},
+			want: "system 1 kept 30 days",
+		},
+		{
+			name: "forever of a same precedence",
+			rules: []*RetentionRule{
+				{Days: 30, Scope: RetentionScopeSystem, System: 1},
+				{Scope: RetentionScopeSystem, System: 1},
+				{Days: 60, Scope: RetentionScopeSystem, System: 1},
+			},
+			want: "system 1 kept forever",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := NewRetentionRules()
+			rules.List = tc.rules
+
+			var label string
+			if rule, ok := rules.Resolve(system, talkgroup, groups, tags); ok {
+				label = rule.Label()
+			}
+
+			if label != tc.want {
+				t.Errorf("resolved %q, want %q", label, tc.want)
+			}
+		})
+	}
+}
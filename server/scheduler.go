@@ -21,66 +21,305 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+const (
+	SchedulerHistoryLimit = 100
+
+	SchedulerJobBackup       = "backup"
+	SchedulerJobDigestDaily  = "digest-daily"
+	SchedulerJobDigestWeekly = "digest-weekly"
+	SchedulerJobDownsample   = "downsample"
+	SchedulerJobPartitions   = "partitions"
+	SchedulerJobPrune        = "prune"
+
+	SchedulerStatusError   = "error"
+	SchedulerStatusSuccess = "success"
+)
+
+type SchedulerJob struct {
+	Description     string
+	Enabled         bool
+	LastDuration    time.Duration
+	LastError       string
+	LastRun         time.Time
+	LastStatus      string
+	Name            string
+	NextRun         time.Time
+	Running         bool
+	Schedule        string
+	cron            *CronSchedule
+	defaultSchedule string
+	run             func() error
+}
+
+func (job *SchedulerJob) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"defaultSchedule": job.defaultSchedule,
+		"description":     job.Description,
+		"enabled":         job.Enabled,
+		"lastDuration":    job.LastDuration.Milliseconds(),
+		"lastError":       job.LastError,
+		"lastStatus":      job.LastStatus,
+		"name":            job.Name,
+		"running":         job.Running,
+		"schedule":        job.Schedule,
+	}
+
+	if !job.LastRun.IsZero() {
+		m["lastRun"] = job.LastRun.Format(time.RFC3339)
+	}
+
+	if job.Enabled && !job.NextRun.IsZero() {
+		m["nextRun"] = job.NextRun.Format(time.RFC3339)
+	}
+
+	return json.Marshal(m)
+}
+
+type SchedulerRun struct {
+	Id        uint64
+	Duration  time.Duration
+	Error     string
+	Manual    bool
+	Name      string
+	Status    string
+	Timestamp time.Time
+}
+
+func (run *SchedulerRun) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"id":       run.Id,
+		"dateTime": run.Timestamp.Format(time.RFC3339),
+		"duration": run.Duration.Milliseconds(),
+		"error":    run.Error,
+		"manual":   run.Manual,
+		"name":     run.Name,
+		"status":   run.Status,
+	})
+}
+
+// Scheduler runs the registered jobs according to their cron expression and
+// keeps their run history.
 type Scheduler struct {
 	Controller *Controller
 	Ticker     *time.Ticker
 	cancel     chan any
+	jobs       []*SchedulerJob
 	mutex      sync.Mutex
 	started    bool
 }
 
 func NewScheduler(controller *Controller) *Scheduler {
-	return &Scheduler{
+	scheduler := &Scheduler{
 		Controller: controller,
 		cancel:     make(chan any),
+		jobs:       []*SchedulerJob{},
 	}
+
+	scheduler.Register(SchedulerJobBackup, "Back up the database to the backups folder", "0 3 * * *", false, func() error {
+		_, err := controller.Backups.Create(controller.Options.BackupAudio, controller.Options.BackupKeep)
+		return err
+	})
+
+	scheduler.Register(SchedulerJobDigestDaily, "Email the daily digest to the address of the options", "0 7 * * *", false, func() error {
+		return controller.Digest.Send(DigestDaily)
+	})
+
+	scheduler.Register(SchedulerJobDigestWeekly, "Email the weekly digest to the address of the options", "0 7 * * 1", false, func() error {
+		return controller.Digest.Send(DigestWeekly)
+	})
+
+	scheduler.Register(SchedulerJobDownsample, "Re-encode aging calls with the downsampling tiers", "30 * * * *", true, func() error {
+		return controller.Downsampler.Run()
+	})
+
+	scheduler.Register(SchedulerJobPartitions, "Create the coming monthly partitions of the calls on PostgreSQL, archive the aged ones and resume an interrupted move of the calls", "15 0 * * *", true, func() error {
+		if err := controller.Partitions.Maintain(controller.Database); err != nil {
+			return err
+		}
+
+		return controller.Partitions.Move(controller.Database)
+	})
+
+	scheduler.Register(SchedulerJobPrune, "Remove the calls past their retention and stale records", "0 * * * *", true, scheduler.pruneDatabase)
+
+	return scheduler
 }
 
-func (scheduler *Scheduler) pruneDatabase() error {
-	if scheduler.Controller.Options.PruneDays == 0 {
-		return nil
+// History returns the most recent runs of a job, or of all jobs when name is
+// empty.
+func (scheduler *Scheduler) History(name string, db Storage) ([]*SchedulerRun, error) {
+	var (
+		args  = []any{}
+		err   error
+		query string
+		rows  *sql.Rows
+		where string
+	)
+
+	formatError := errorFormatter("scheduler", "history")
+
+	if len(name) > 0 {
+		where = ` WHERE "name" = ?`
+		args = append(args, name)
 	}
 
-	scheduler.Controller.Logs.LogEvent(LogLevelInfo, "database pruning")
+	runs := []*SchedulerRun{}
 
-	if err := scheduler.Controller.Calls.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
-		return err
+	query = fmt.Sprintf(`SELECT "jobRunId", "duration", "error", "manual", "name", "status", "timestamp" FROM "jobRuns"%s ORDER BY "jobRunId" DESC LIMIT ?`, where)
+	if rows, err = db.Query(query, append(args, SchedulerHistoryLimit)...); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			duration  int64
+			run       = &SchedulerRun{}
+			timestamp int64
+		)
+
+		if err = rows.Scan(&run.Id, &duration, &run.Error, &run.Manual, &run.Name, &run.Status, &timestamp); err != nil {
+			break
+		}
+
+		run.Duration = time.Duration(duration) * time.Millisecond
+		run.Timestamp = time.UnixMilli(timestamp)
+
+		runs = append(runs, run)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
 	}
 
-	if err := scheduler.Controller.Logs.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+	return runs, nil
+}
+
+// List returns a snapshot of the registered jobs sorted by name.
+func (scheduler *Scheduler) List() []*SchedulerJob {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	jobs := []*SchedulerJob{}
+
+	for _, job := range scheduler.jobs {
+		c := *job
+		jobs = append(jobs, &c)
+	}
+
+	sort.Slice(jobs, func(i int, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+
+	return jobs
+}
+
+// Register adds a job with its default schedule and state, which can later be
+// changed from the administration.
+func (scheduler *Scheduler) Register(name string, description string, schedule string, enabled bool, run func() error) error {
+	cron, err := ParseCronSchedule(schedule)
+	if err != nil {
 		return err
 	}
 
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if _, ok := scheduler.getJob(name); ok {
+		return fmt.Errorf("job %s already registered", name)
+	}
+
+	scheduler.jobs = append(scheduler.jobs, &SchedulerJob{
+		Description:     description,
+		Enabled:         enabled,
+		Name:            name,
+		Schedule:        schedule,
+		cron:            cron,
+		defaultSchedule: schedule,
+		run:             run,
+	})
+
 	return nil
 }
 
-func (scheduler *Scheduler) run() {
+// SetEnabled enables or disables a job.
+func (scheduler *Scheduler) SetEnabled(name string, enabled bool) error {
 	scheduler.mutex.Lock()
 	defer scheduler.mutex.Unlock()
 
-	logError := func(err error) {
-		scheduler.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("scheduler.run: %s", err.Error()))
+	job, ok := scheduler.getJob(name)
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+
+	job.Enabled = enabled
+	job.NextRun = job.cron.Next(time.Now())
+
+	return scheduler.writeJob(job)
+}
+
+// SetSchedule changes the cron expression of a job, an empty one restoring its
+// default schedule.
+func (scheduler *Scheduler) SetSchedule(name string, schedule string) error {
+	if len(schedule) == 0 {
+		scheduler.mutex.Lock()
+		if job, ok := scheduler.getJob(name); ok {
+			schedule = job.defaultSchedule
+		}
+		scheduler.mutex.Unlock()
+	}
+
+	cron, err := ParseCronSchedule(schedule)
+	if err != nil {
+		return err
 	}
 
-	if err := scheduler.pruneDatabase(); err != nil {
-		logError(err)
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.getJob(name)
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
 	}
+
+	job.Schedule = schedule
+	job.cron = cron
+	job.NextRun = cron.Next(time.Now())
+
+	return scheduler.writeJob(job)
 }
 
 func (scheduler *Scheduler) Start() error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
 	if scheduler.started {
 		return errors.New("scheduler already started")
 	} else {
 		scheduler.started = true
 	}
 
-	scheduler.Ticker = time.NewTicker(time.Hour)
+	if err := scheduler.read(scheduler.Controller.Database); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, job := range scheduler.jobs {
+		job.NextRun = job.cron.Next(now)
+	}
+
+	scheduler.Ticker = time.NewTicker(time.Minute)
 
 	go func() {
 		for {
@@ -88,8 +327,8 @@ func (scheduler *Scheduler) Start() error {
 			case <-scheduler.cancel:
 				scheduler.Stop()
 				return
-			case <-scheduler.Ticker.C:
-				scheduler.run()
+			case t := <-scheduler.Ticker.C:
+				scheduler.tick(t)
 			}
 		}
 	}()
@@ -98,6 +337,9 @@ func (scheduler *Scheduler) Start() error {
 }
 
 func (scheduler *Scheduler) Stop() error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
 	if !scheduler.started {
 		return errors.New("scheduler not started")
 	}
@@ -108,3 +350,249 @@ func (scheduler *Scheduler) Stop() error {
 
 	return nil
 }
+
+// Trigger runs a job right away, whether it is enabled or not.
+func (scheduler *Scheduler) Trigger(name string) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	job, ok := scheduler.getJob(name)
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+
+	if job.Running {
+		return fmt.Errorf("job %s already running", name)
+	}
+
+	job.Running = true
+
+	go scheduler.execute(job, true)
+
+	return nil
+}
+
+func (scheduler *Scheduler) execute(job *SchedulerJob, manual bool) {
+	start := time.Now()
+
+	err := job.run()
+
+	run := &SchedulerRun{
+		Duration:  time.Since(start),
+		Manual:    manual,
+		Name:      job.Name,
+		Status:    SchedulerStatusSuccess,
+		Timestamp: start,
+	}
+
+	if err != nil {
+		run.Error = err.Error()
+		run.Status = SchedulerStatusError
+
+		scheduler.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("scheduler.%s: %s", job.Name, err.Error()))
+	}
+
+	scheduler.mutex.Lock()
+	job.LastDuration = run.Duration
+	job.LastError = run.Error
+	job.LastRun = run.Timestamp
+	job.LastStatus = run.Status
+	job.Running = false
+	scheduler.mutex.Unlock()
+
+	if err = scheduler.writeRun(run, scheduler.Controller.Database); err != nil {
+		scheduler.Controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
+}
+
+func (scheduler *Scheduler) getJob(name string) (*SchedulerJob, bool) {
+	for _, job := range scheduler.jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+
+	return nil, false
+}
+
+func (scheduler *Scheduler) pruneDatabase() error {
+	pruneDays := scheduler.Controller.Options.PruneDays
+
+	if err := scheduler.Controller.Quarantine.Prune(scheduler.Controller.Database); err != nil {
+		return err
+	}
+
+	if pruneDays == 0 && len(scheduler.Controller.Options.RetentionRules.List) == 0 {
+		return nil
+	}
+
+	scheduler.Controller.Logs.LogEvent(LogLevelInfo, "database pruning")
+
+	if err := scheduler.Controller.Retention.Apply(scheduler.Controller.Database); err != nil {
+		return err
+	}
+
+	if err := scheduler.Controller.Conversations.Prune(scheduler.Controller.Database); err != nil {
+		return err
+	}
+
+	if pruneDays == 0 {
+		return nil
+	}
+
+	if err := scheduler.Controller.UnitActivities.Prune(scheduler.Controller.Database, pruneDays); err != nil {
+		return err
+	}
+
+	if err := scheduler.Controller.Logs.Prune(scheduler.Controller.Database, pruneDays); err != nil {
+		return err
+	}
+
+	if err := scheduler.Controller.Statistics.Prune(scheduler.Controller.Database, pruneDays); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// read restores the settings and the last run of the jobs.
+func (scheduler *Scheduler) read(db Storage) error {
+	var (
+		err   error
+		query string
+		rows  *sql.Rows
+	)
+
+	formatError := errorFormatter("scheduler", "read")
+
+	query = `SELECT "enabled", "name", "schedule" FROM "jobs"`
+	if rows, err = db.Query(query); err != nil {
+		return formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			enabled  bool
+			name     string
+			schedule string
+		)
+
+		if err = rows.Scan(&enabled, &name, &schedule); err != nil {
+			break
+		}
+
+		if job, ok := scheduler.getJob(name); ok {
+			job.Enabled = enabled
+
+			if cron, err := ParseCronSchedule(schedule); err == nil {
+				job.Schedule = schedule
+				job.cron = cron
+			}
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	query = `SELECT "duration", "error", "name", "status", "timestamp" FROM "jobRuns" WHERE "jobRunId" IN (SELECT MAX("jobRunId") FROM "jobRuns" GROUP BY "name")`
+	if rows, err = db.Query(query); err != nil {
+		return formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			duration  int64
+			message   string
+			name      string
+			status    string
+			timestamp int64
+		)
+
+		if err = rows.Scan(&duration, &message, &name, &status, &timestamp); err != nil {
+			break
+		}
+
+		if job, ok := scheduler.getJob(name); ok {
+			job.LastDuration = time.Duration(duration) * time.Millisecond
+			job.LastError = message
+			job.LastRun = time.UnixMilli(timestamp)
+			job.LastStatus = status
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	return nil
+}
+
+func (scheduler *Scheduler) tick(t time.Time) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	for _, job := range scheduler.jobs {
+		if !job.Enabled || job.NextRun.IsZero() || job.NextRun.After(t) {
+			continue
+		}
+
+		job.NextRun = job.cron.Next(t)
+
+		if job.Running {
+			continue
+		}
+
+		job.Running = true
+
+		go scheduler.execute(job, false)
+	}
+}
+
+func (scheduler *Scheduler) writeJob(job *SchedulerJob) error {
+	db := scheduler.Controller.Database
+
+	formatError := errorFormatter("scheduler", "writejob")
+
+	query := `DELETE FROM "jobs" WHERE "name" = ?`
+	if _, err := db.Exec(query, job.Name); err != nil {
+		return formatError(err, query)
+	}
+
+	query = `INSERT INTO "jobs" ("enabled", "name", "schedule") VALUES (?, ?, ?)`
+	if _, err := db.Exec(query, job.Enabled, job.Name, job.Schedule); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
+
+// writeRun records a run, keeping only the most recent ones of the job.
+func (scheduler *Scheduler) writeRun(run *SchedulerRun, db Storage) error {
+	var id uint64
+
+	formatError := errorFormatter("scheduler", "writerun")
+
+	query := `INSERT INTO "jobRuns" ("duration", "error", "manual", "name", "status", "timestamp") VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := db.Exec(query, run.Duration.Milliseconds(), run.Error, run.Manual, run.Name, run.Status, run.Timestamp.UnixMilli()); err != nil {
+		return formatError(err, query)
+	}
+
+	query = `SELECT "jobRunId" FROM "jobRuns" WHERE "name" = ? ORDER BY "jobRunId" DESC LIMIT 1 OFFSET ?`
+	if err := db.QueryRow(query, run.Name, SchedulerHistoryLimit).Scan(&id); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return formatError(err, query)
+	}
+
+	query = `DELETE FROM "jobRuns" WHERE "name" = ? AND "jobRunId" <= ?`
+	if _, err := db.Exec(query, run.Name, id); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
@@ -0,0 +1,466 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SchemaMigration is a numbered step of the database schema of a dialect.
+// Once released, a migration must never be edited: its checksum is recorded
+// when applied and verified on every start, so schema changes always go into
+// a new migration appended to the dialect lists. Optional migrations are only
+// applied on demand.
+type SchemaMigration struct {
+	Columns  [][]string
+	Down     []string
+	DownFunc func(tx *StorageTx) error
+	Func     func(tx *StorageTx) error
+	Name     string
+	Optional bool
+	Up       []string
+	Version  uint
+}
+
+// Checksum hashes the statements of the migration, Func and DownFunc being
+// identified by the migration name only.
+func (migration *SchemaMigration) Checksum() string {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "%d\n%s\n", migration.Version, migration.Name)
+
+	for _, query := range migration.Up {
+		fmt.Fprintf(hash, "%s\n", query)
+	}
+
+	for _, column := range migration.Columns {
+		fmt.Fprintf(hash, "%s\n", strings.Join(column, " "))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type SchemaMigrationStatus struct {
+	AppliedAt time.Time
+	Checksum  string
+	Name      string
+	Status    string
+	Version   uint
+}
+
+const (
+	SchemaMigrationApplied  = "applied"
+	SchemaMigrationModified = "modified"
+	SchemaMigrationOptional = "optional"
+	SchemaMigrationPending  = "pending"
+	SchemaMigrationUnknown  = "unknown"
+)
+
+type schemaMigrationRecord struct {
+	checksum  string
+	name      string
+	timestamp int64
+}
+
+type SchemaMigrator struct {
+	db         *Database
+	migrations []*SchemaMigration
+}
+
+func NewSchemaMigrator(db *Database) *SchemaMigrator {
+	return &SchemaMigrator{
+		db:         db,
+		migrations: schemaMigrations(db.Config.DbType),
+	}
+}
+
+// Apply applies an optional migration on demand, once verified the
+// migrations already applied. An applied migration is left as is.
+func (migrator *SchemaMigrator) Apply(name string) error {
+	formatError := errorFormatter("schemamigrator", "apply")
+
+	if err := migrator.bootstrap(); err != nil {
+		return formatError(err, "")
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	if err = migrator.verify(applied); err != nil {
+		return formatError(err, "")
+	}
+
+	for _, migration := range migrator.migrations {
+		if migration.Name != name {
+			continue
+		}
+
+		if _, ok := applied[migration.Version]; ok {
+			return nil
+		}
+
+		log.Printf("applying schema migration %d %s...\n", migration.Version, migration.Name)
+
+		if err = migrator.apply(migration); err != nil {
+			return formatError(err, "")
+		}
+
+		return nil
+	}
+
+	return formatError(fmt.Errorf("unknown schema migration %s", name), "")
+}
+
+// Migrate verifies the migrations already applied then applies the pending
+// ones in order, each one being recorded once successful. The optional
+// migrations are left to Apply, so that a rolled back one stays so.
+func (migrator *SchemaMigrator) Migrate() error {
+	formatError := errorFormatter("schemamigrator", "migrate")
+
+	if err := migrator.bootstrap(); err != nil {
+		return formatError(err, "")
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	if err = migrator.verify(applied); err != nil {
+		return formatError(err, "")
+	}
+
+	for _, migration := range migrator.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Optional {
+			continue
+		}
+
+		log.Printf("applying schema migration %d %s...\n", migration.Version, migration.Name)
+
+		if err = migrator.apply(migration); err != nil {
+			return formatError(err, "")
+		}
+	}
+
+	return nil
+}
+
+// Rollback reverts the applied migrations above the version, latest first.
+// Migrations changing the schema without down statements cannot be reverted,
+// nor can the baseline schema, which would drop every table.
+func (migrator *SchemaMigrator) Rollback(version uint) error {
+	formatError := errorFormatter("schemamigrator", "rollback")
+
+	if len(migrator.migrations) > 0 && version < migrator.migrations[0].Version {
+		return formatError(fmt.Errorf("cannot roll back below the baseline schema version %d", migrator.migrations[0].Version), "")
+	}
+
+	if err := migrator.bootstrap(); err != nil {
+		return formatError(err, "")
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return formatError(err, "")
+	}
+
+	if err = migrator.verify(applied); err != nil {
+		return formatError(err, "")
+	}
+
+	for i := len(migrator.migrations) - 1; i >= 0; i-- {
+		migration := migrator.migrations[i]
+
+		if migration.Version <= version {
+			break
+		}
+
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		// a migration only running a func leaves the schema as is, unless
+		// it has a func undoing it
+		if len(migration.Down) == 0 && migration.DownFunc == nil && (len(migration.Up) > 0 || len(migration.Columns) > 0) {
+			return formatError(fmt.Errorf("schema migration %d %s cannot be rolled back", migration.Version, migration.Name), "")
+		}
+
+		log.Printf("rolling back schema migration %d %s...\n", migration.Version, migration.Name)
+
+		tx, err := migrator.db.begin(false)
+		if err != nil {
+			return formatError(err, "")
+		}
+
+		for _, query := range migration.Down {
+			if _, err = tx.Exec(query); err != nil {
+				tx.Rollback()
+				return formatError(err, query)
+			}
+		}
+
+		if migration.DownFunc != nil {
+			if err = migration.DownFunc(tx); err != nil {
+				tx.Rollback()
+				return formatError(err, "")
+			}
+		}
+
+		query := `DELETE FROM "schemaMigrations" WHERE "version" = ?`
+		if _, err = tx.Exec(query, migration.Version); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
+		}
+
+		if err = tx.Commit(); err != nil {
+			return formatError(err, "")
+		}
+	}
+
+	return nil
+}
+
+// Status lists the migrations known to the server and the ones recorded in
+// the database, in version order.
+func (migrator *SchemaMigrator) Status() ([]*SchemaMigrationStatus, error) {
+	formatError := errorFormatter("schemamigrator", "status")
+
+	if err := migrator.bootstrap(); err != nil {
+		return nil, formatError(err, "")
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	statuses := []*SchemaMigrationStatus{}
+
+	for _, migration := range migrator.migrations {
+		status := &SchemaMigrationStatus{
+			Checksum: migration.Checksum(),
+			Name:     migration.Name,
+			Status:   SchemaMigrationPending,
+			Version:  migration.Version,
+		}
+
+		if migration.Optional {
+			status.Status = SchemaMigrationOptional
+		}
+
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = time.UnixMilli(record.timestamp)
+
+			if record.checksum == status.Checksum {
+				status.Status = SchemaMigrationApplied
+			} else {
+				status.Status = SchemaMigrationModified
+			}
+
+			delete(applied, migration.Version)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for version, record := range applied {
+		statuses = append(statuses, &SchemaMigrationStatus{
+			AppliedAt: time.UnixMilli(record.timestamp),
+			Checksum:  record.checksum,
+			Name:      record.name,
+			Status:    SchemaMigrationUnknown,
+			Version:   version,
+		})
+	}
+
+	slices.SortFunc(statuses, func(a *SchemaMigrationStatus, b *SchemaMigrationStatus) int {
+		return int(a.Version) - int(b.Version)
+	})
+
+	return statuses, nil
+}
+
+// Version returns the latest migration applied to the database.
+func (migrator *SchemaMigrator) Version() (uint, error) {
+	var version uint
+
+	query := `SELECT COALESCE(MAX("version"), 0) FROM "schemaMigrations"`
+	if err := migrator.db.QueryRow(query).Scan(&version); err != nil {
+		return 0, fmt.Errorf("schemamigrator.version: %v in %s", err, query)
+	}
+
+	return version, nil
+}
+
+// apply runs the statements, the column additions and the func of a migration
+// in the transaction recording it, so that a failed migration leaves nothing
+// behind. MySQL and MariaDB commit the schema statements as they run them.
+func (migrator *SchemaMigrator) apply(migration *SchemaMigration) error {
+	tx, err := migrator.db.begin(false)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range migration.Up {
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%v in %s", err, query)
+		}
+	}
+
+	if err = migrateColumns(tx, migration.Columns); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if migration.Func != nil {
+		if err = migration.Func(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	query := `INSERT INTO "schemaMigrations" ("version", "checksum", "name", "timestamp") VALUES (?, ?, ?, ?)`
+	if _, err = tx.Exec(query, migration.Version, migration.Checksum(), migration.Name, time.Now().UnixMilli()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%v in %s", err, query)
+	}
+
+	return tx.Commit()
+}
+
+func (migrator *SchemaMigrator) applied() (map[uint]*schemaMigrationRecord, error) {
+	applied := map[uint]*schemaMigrationRecord{}
+
+	query := `SELECT "version", "checksum", "name", "timestamp" FROM "schemaMigrations"`
+	rows, err := migrator.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("%v in %s", err, query)
+	}
+
+	for rows.Next() {
+		var version uint
+
+		record := &schemaMigrationRecord{}
+
+		if err = rows.Scan(&version, &record.checksum, &record.name, &record.timestamp); err != nil {
+			break
+		}
+
+		applied[version] = record
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// bootstrap creates the table recording the migrations, with column types
+// common to all dialects.
+func (migrator *SchemaMigrator) bootstrap() error {
+	query := `CREATE TABLE IF NOT EXISTS "schemaMigrations" (
+    "version" bigint NOT NULL PRIMARY KEY,
+    "checksum" text NOT NULL,
+    "name" text NOT NULL,
+    "timestamp" bigint NOT NULL
+  );`
+
+	if _, err := migrator.db.Sql.Exec(query); err != nil {
+		return fmt.Errorf("%v in %s", err, query)
+	}
+
+	return nil
+}
+
+// verify refuses a database migrated by a newer server or whose migrations
+// differ from the ones of this server.
+func (migrator *SchemaMigrator) verify(applied map[uint]*schemaMigrationRecord) error {
+	versions := map[uint]*SchemaMigration{}
+
+	for _, migration := range migrator.migrations {
+		versions[migration.Version] = migration
+	}
+
+	for version, record := range applied {
+		migration, ok := versions[version]
+		if !ok {
+			return fmt.Errorf("schema migration %d %s is unknown to this server, the database was migrated by a newer version", version, record.name)
+		}
+
+		if record.checksum != migration.Checksum() {
+			return fmt.Errorf("schema migration %d %s was modified after being applied", version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+func schemaMigrations(dbType string) []*SchemaMigration {
+	switch dbFamily(dbType) {
+	case DbTypeMysql:
+		return MysqlMigrations
+	case DbTypePostgresql:
+		return PostgresqlMigrations
+	case DbTypeSqlite:
+		return SqliteMigrations
+	}
+
+	return nil
+}
+
+func schemaTableNames(schema []string) []string {
+	tables := []string{}
+
+	re := regexp.MustCompile(`(?i)^\s*create table if not exists "(\w+)"`)
+
+	for _, query := range schema {
+		if m := re.FindStringSubmatch(query); m != nil {
+			tables = append(tables, m[1])
+		}
+	}
+
+	return tables
+}
+
+// schemaTables returns the tables created by the migrations of the dialect in
+// creation order, which follows the foreign keys.
+func schemaTables(dbType string) []string {
+	tables := []string{}
+
+	for _, migration := range schemaMigrations(dbType) {
+		tables = append(tables, schemaTableNames(migration.Up)...)
+	}
+
+	return tables
+}
@@ -0,0 +1,164 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSchemaMigrator(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		migrations   []*SchemaMigration
+		apply        string
+		rollback     bool
+		version      uint
+		wantErr      bool
+		wantRecorded bool
+		wantRows     int
+		wantTable    bool
+	}{
+		{
+			name:         "applied",
+			migrations:   []*SchemaMigration{{Down: []string{`DROP TABLE "tests"`}, Name: "tests", Up: []string{`CREATE TABLE "tests" ("testId" integer)`}}},
+			wantRecorded: true,
+			wantTable:    true,
+		},
+		{
+			name:       "rolled back",
+			migrations: []*SchemaMigration{{Down: []string{`DROP TABLE "tests"`}, Name: "tests", Up: []string{`CREATE TABLE "tests" ("testId" integer)`}}},
+			rollback:   true,
+		},
+		{
+			name:       "failed",
+			migrations: []*SchemaMigration{{Name: "tests", Up: []string{`CREATE TABLE "tests" ("testId" integer)`, `INSERT INTO "missing" VALUES (1)`}}},
+			wantErr:    true,
+		},
+		{
+			name:         "not rolled back without down statements",
+			migrations:   []*SchemaMigration{{Name: "tests", Up: []string{`CREATE TABLE "tests" ("testId" integer)`}}},
+			rollback:     true,
+			wantErr:      true,
+			wantRecorded: true,
+			wantTable:    true,
+		},
+		{
+			name:     "not rolled back below the baseline",
+			rollback: true,
+			version:  0,
+			wantErr:  true,
+		},
+		{
+			name:       "optional left pending",
+			migrations: []*SchemaMigration{{Name: "tests", Optional: true, Up: []string{`CREATE TABLE "tests" ("testId" integer)`}}},
+		},
+		{
+			name:         "optional applied on demand",
+			migrations:   []*SchemaMigration{{Name: "tests", Optional: true, Up: []string{`CREATE TABLE "tests" ("testId" integer)`}}},
+			apply:        "tests",
+			wantRecorded: true,
+			wantTable:    true,
+		},
+		{
+			name: "legacy statement skipped",
+			migrations: []*SchemaMigration{{Func: func(tx *StorageTx) error {
+				if _, err := tx.Exec(`CREATE TABLE "tests" ("testId" integer PRIMARY KEY)`); err != nil {
+					return err
+				}
+				for _, id := range []int{1, 1, 2} {
+					migrateExec(tx, `INSERT INTO "tests" ("testId") VALUES (?)`, id)
+				}
+				return nil
+			}, Name: "tests"}},
+			wantRecorded: true,
+			wantRows:     2,
+			wantTable:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewController(&Config{BaseDir: t.TempDir(), DbFile: "rdio-scanner.db", DbType: DbTypeSqlite})
+			defer controller.Database.Close()
+
+			migrator := NewSchemaMigrator(controller.Database.(*Database))
+
+			baseline, err := migrator.Version()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version := tc.version
+			if len(tc.migrations) > 0 {
+				version = baseline
+			}
+
+			for i, migration := range tc.migrations {
+				migration.Version = baseline + uint(i) + 1
+				migrator.migrations = append(migrator.migrations, migration)
+			}
+
+			err = migrator.Migrate()
+
+			if err == nil && len(tc.apply) > 0 {
+				err = migrator.Apply(tc.apply)
+			}
+
+			if err == nil && tc.rollback {
+				err = migrator.Rollback(version)
+			}
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("error is %v, want an error %v", err, tc.wantErr)
+			}
+
+			var count int
+			if err := controller.Database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'tests'`).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if (count > 0) != tc.wantTable {
+				t.Errorf("tests table is there %v, want %v", count > 0, tc.wantTable)
+			}
+
+			if err := controller.Database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'calls'`).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count == 0 {
+				t.Error("calls table was dropped")
+			}
+
+			if tc.wantTable {
+				if err := controller.Database.QueryRow(`SELECT COUNT(*) FROM "tests"`).Scan(&count); err != nil {
+					t.Fatal(err)
+				}
+				if count != tc.wantRows {
+					t.Errorf("%d rows in the tests table, want %d", count, tc.wantRows)
+				}
+			}
+
+			applied, err := migrator.applied()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := applied[baseline+1]; ok != tc.wantRecorded {
+				t.Errorf("migration recorded %v, want %v", ok, tc.wantRecorded)
+			}
+		})
+	}
+}
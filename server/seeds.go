@@ -20,9 +20,7 @@
 
 package main
 
-import "fmt"
-
-func seedGroups(db *Database) error {
+func seedGroups(db Storage) error {
 	var (
 		count uint
 		query string
@@ -31,15 +29,15 @@ func seedGroups(db *Database) error {
 	formatError := errorFormatter("seeds", "seedgroups")
 
 	query = `SELECT COUNT(*) FROM "groups"`
-	if err := db.Sql.QueryRow(query).Scan(&count); err != nil {
+	if err := db.QueryRow(query).Scan(&count); err != nil {
 		return formatError(err, query)
 	}
 
 	if count == 0 {
-		if tx, err := db.Sql.Begin(); err == nil {
+		if tx, err := db.Begin(); err == nil {
 			for _, group := range defaults.groups {
-				query := fmt.Sprintf(`INSERT INTO "groups" ("label") VALUES ('%s')`, group)
-				if _, err := tx.Exec(query); err != nil {
+				query := `INSERT INTO "groups" ("label") VALUES (?)`
+				if _, err := tx.Exec(query, group); err != nil {
 					tx.Rollback()
 					return formatError(err, query)
 				}
@@ -57,7 +55,7 @@ func seedGroups(db *Database) error {
 	return nil
 }
 
-func seedTags(db *Database) error {
+func seedTags(db Storage) error {
 	var (
 		count uint
 		query string
@@ -66,15 +64,15 @@ func seedTags(db *Database) error {
 	formatError := errorFormatter("seeds", "seedtags")
 
 	query = `SELECT COUNT(*) FROM "tags"`
-	if err := db.Sql.QueryRow(query).Scan(&count); err != nil {
+	if err := db.QueryRow(query).Scan(&count); err != nil {
 		return formatError(err, query)
 	}
 
 	if count == 0 {
-		if tx, err := db.Sql.Begin(); err == nil {
+		if tx, err := db.Begin(); err == nil {
 			for _, tag := range defaults.tags {
-				query := fmt.Sprintf(`INSERT INTO "tags" ("label") VALUES ('%s')`, tag)
-				if _, err := tx.Exec(query); err != nil {
+				query := `INSERT INTO "tags" ("label") VALUES (?)`
+				if _, err := tx.Exec(query, tag); err != nil {
 					tx.Rollback()
 					return formatError(err, query)
 				}
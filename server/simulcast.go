@@ -0,0 +1,365 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DuplicateResolutionFirst   = "first"
+	DuplicateResolutionQuality = "quality"
+)
+
+// Simulcast holds the copies of a same transmission received from several
+// sites or recorders for a short while, so that only the best one is kept.
+type Simulcast struct {
+	controller *Controller
+	groups     map[string][]*simulcastGroup
+	mutex      sync.Mutex
+}
+
+type simulcastGroup struct {
+	calls []*Call
+	timer *time.Timer
+}
+
+// SimulcastDecision records on the kept call which copies of the
+// transmission were discarded in its favor.
+type SimulcastDecision struct {
+	Discarded []*SimulcastScore `json:"discarded"`
+	Kept      *SimulcastScore   `json:"kept"`
+}
+
+// SimulcastScore is what copies of a transmission are compared on.
+type SimulcastScore struct {
+	CallId   uint64 `json:"-"`
+	Duration uint   `json:"duration"`
+	Errors   uint   `json:"errors"`
+	Flagged  bool   `json:"flagged"`
+	Measured bool   `json:"measured"`
+	Priority uint   `json:"priority"`
+	SiteRef  uint   `json:"site"`
+	Source   string `json:"source,omitempty"`
+}
+
+func NewSimulcast(controller *Controller) *Simulcast {
+	return &Simulcast{
+		controller: controller,
+		groups:     map[string][]*simulcastGroup{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+// ParseSimulcastDecision decodes the value stored in the database.
+func ParseSimulcastDecision(s string) *SimulcastDecision {
+	if len(s) == 0 {
+		return nil
+	}
+
+	decision := &SimulcastDecision{}
+
+	if err := json.Unmarshal([]byte(s), decision); err != nil || decision.Kept == nil {
+		return nil
+	}
+
+	return decision
+}
+
+func (decision *SimulcastDecision) String() string {
+	if decision == nil {
+		return ""
+	}
+
+	if b, err := json.Marshal(decision); err == nil {
+		return string(b)
+	}
+
+	return ""
+}
+
+// NewSimulcastScore scores a call being ingested.
+func NewSimulcastScore(call *Call) *SimulcastScore {
+	score := &SimulcastScore{
+		CallId:   call.Id,
+		Duration: call.Duration,
+		Flagged:  len(call.Flags) > 0,
+		Measured: len(call.Frequencies) > 0,
+		SiteRef:  call.SiteRef,
+		Source:   call.Source,
+	}
+
+	for _, f := range call.Frequencies {
+		score.Errors += f.Errors + f.Spikes
+	}
+
+	score.Priority = sitePriority(call.System, call.SiteRef)
+
+	return score
+}
+
+// IsBetter tells if the copy is better than the other one: unflagged first,
+// then fewer decoding errors and spikes when both report them, then the site
+// priority and finally the longest audio. Equal copies keep the other one.
+func (score *SimulcastScore) IsBetter(other *SimulcastScore) bool {
+	if score.Flagged != other.Flagged {
+		return !score.Flagged
+	}
+
+	if score.Measured && other.Measured && score.Errors != other.Errors {
+		return score.Errors < other.Errors
+	}
+
+	if score.Priority != other.Priority {
+		return score.Priority > other.Priority
+	}
+
+	return score.Duration > other.Duration
+}
+
+func (score *SimulcastScore) String() string {
+	s := fmt.Sprintf("site=%d priority=%d duration=%dms", score.SiteRef, score.Priority, score.Duration)
+
+	if score.Measured {
+		s += fmt.Sprintf(" errors=%d", score.Errors)
+	}
+
+	if score.Flagged {
+		s += " flagged"
+	}
+
+	if len(score.Source) > 0 {
+		s += fmt.Sprintf(" source=%s", score.Source)
+	}
+
+	return s
+}
+
+// Hold parks the call until the hold time elapses, grouped with the other
+// copies of the same transmission.
+func (simulcast *Simulcast) Hold(call *Call) {
+	simulcast.mutex.Lock()
+	defer simulcast.mutex.Unlock()
+
+	key := fmt.Sprintf("%d:%d", call.System.Id, call.Talkgroup.Id)
+	frame := time.Duration(simulcast.controller.Options.DuplicateDetectionTimeFrame) * time.Millisecond
+
+	for _, group := range simulcast.groups[key] {
+		d := call.Timestamp.Sub(group.calls[0].Timestamp)
+		if d >= -frame && d <= frame {
+			group.calls = append(group.calls, call)
+			return
+		}
+	}
+
+	group := &simulcastGroup{calls: []*Call{call}}
+
+	group.timer = time.AfterFunc(time.Duration(simulcast.controller.Options.DuplicateHoldTime)*time.Millisecond, func() {
+		simulcast.resolve(key, group)
+	})
+
+	simulcast.groups[key] = append(simulcast.groups[key], group)
+}
+
+// Stop releases the calls on hold, their sources will submit them again.
+func (simulcast *Simulcast) Stop() {
+	simulcast.mutex.Lock()
+	defer simulcast.mutex.Unlock()
+
+	for _, groups := range simulcast.groups {
+		for _, group := range groups {
+			if group.timer.Stop() {
+				for _, call := range group.calls {
+					if call.ingested != nil {
+						call.ingested(ErrIngestStopped)
+					}
+				}
+			}
+		}
+	}
+
+	simulcast.groups = map[string][]*simulcastGroup{}
+}
+
+func (simulcast *Simulcast) resolve(key string, group *simulcastGroup) {
+	simulcast.mutex.Lock()
+
+	groups := simulcast.groups[key]
+	for i, g := range groups {
+		if g == group {
+			groups = append(groups[:i], groups[i+1:]...)
+			break
+		}
+	}
+
+	if len(groups) > 0 {
+		simulcast.groups[key] = groups
+	} else {
+		delete(simulcast.groups, key)
+	}
+
+	simulcast.mutex.Unlock()
+
+	best := group.calls[0]
+	bestScore := NewSimulcastScore(best)
+
+	for _, call := range group.calls[1:] {
+		if score := NewSimulcastScore(call); score.IsBetter(bestScore) {
+			best, bestScore = call, score
+		}
+	}
+
+	decision := &SimulcastDecision{Discarded: []*SimulcastScore{}, Kept: bestScore}
+
+	for _, call := range group.calls {
+		if call != best {
+			decision.Discarded = append(decision.Discarded, NewSimulcastScore(call))
+			simulcast.discard(call, bestScore)
+		}
+	}
+
+	stored, storedDecision, err := simulcast.stored(best)
+	if err != nil {
+		simulcast.controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
+
+	if storedDecision != nil {
+		decision.Discarded = append(storedDecision.Discarded, decision.Discarded...)
+	}
+
+	if stored != nil {
+		if !bestScore.IsBetter(stored) {
+			decision.Discarded = append(decision.Discarded, bestScore)
+			decision.Kept = stored
+
+			simulcast.discard(best, stored)
+
+			if err := simulcast.record(stored.CallId, decision); err != nil {
+				simulcast.controller.Logs.LogEvent(LogLevelError, err.Error())
+			}
+			return
+		}
+
+		decision.Discarded = append(decision.Discarded, stored)
+
+		best.Id = stored.CallId
+		best.replace = true
+
+		simulcast.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("simulcast: system=%d talkgroup=%d call %d replaced by a better copy, kept %s over %s", best.System.SystemRef, best.Talkgroup.TalkgroupRef, stored.CallId, bestScore, stored))
+	}
+
+	if len(decision.Discarded) > 0 {
+		best.Simulcast = decision
+	}
+
+	best.resolved = true
+
+	simulcast.controller.Ingester.Dispatch(best)
+}
+
+func (simulcast *Simulcast) discard(call *Call, kept *SimulcastScore) {
+	err := fmt.Errorf("simulcast duplicate rejected, kept %s over %s", kept, NewSimulcastScore(call))
+
+	simulcast.controller.logCall(call, LogLevelInfo, err.Error())
+
+	if call.ingested != nil {
+		call.ingested(err)
+	}
+}
+
+// record updates the decision of a call already written.
+func (simulcast *Simulcast) record(callId uint64, decision *SimulcastDecision) error {
+	formatError := errorFormatter("simulcast", "record")
+
+	query := `UPDATE "calls" SET "simulcast" = ? WHERE "callId" = ?`
+	if _, err := simulcast.controller.Database.Exec(query, decision.String(), callId); err != nil {
+		return formatError(err, query)
+	}
+
+	return nil
+}
+
+// stored returns the best copy already written along with its decision, when
+// the transmission was received after a first copy was resolved.
+func (simulcast *Simulcast) stored(call *Call) (*SimulcastScore, *SimulcastDecision, error) {
+	var (
+		best     *SimulcastScore
+		decision *SimulcastDecision
+	)
+
+	formatError := errorFormatter("simulcast", "stored")
+
+	d := time.Duration(simulcast.controller.Options.DuplicateDetectionTimeFrame) * time.Millisecond
+	from := call.Timestamp.Add(-d)
+	to := call.Timestamp.Add(d)
+
+	query := `SELECT c."callId", c."duration", c."flags", c."simulcast", c."siteRef", c."source", COUNT(cf."callFrequencyId"), COALESCE(SUM(cf."errors" + cf."spikes"), 0) FROM "calls" AS c LEFT JOIN "callFrequencies" AS cf ON cf."callId" = c."callId" WHERE (c."timestamp" BETWEEN ? AND ?) AND c."systemId" = ? AND c."talkgroupId" = ? GROUP BY c."callId", c."duration", c."flags", c."simulcast", c."siteRef", c."source"`
+
+	rows, err := simulcast.controller.Database.Query(query, from.UnixMilli(), to.UnixMilli(), call.System.Id, call.Talkgroup.Id)
+	if err != nil {
+		return nil, nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			count  uint
+			flags  string
+			record string
+			score  = &SimulcastScore{}
+		)
+
+		if err = rows.Scan(&score.CallId, &score.Duration, &flags, &record, &score.SiteRef, &score.Source, &count, &score.Errors); err != nil {
+			break
+		}
+
+		score.Flagged = len(flags) > 0
+		score.Measured = count > 0
+		score.Priority = sitePriority(call.System, score.SiteRef)
+
+		if best == nil || score.IsBetter(best) {
+			best = score
+			decision = ParseSimulcastDecision(record)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, nil, formatError(err, "")
+	}
+
+	return best, decision, nil
+}
+
+func sitePriority(system *System, siteRef uint) uint {
+	if system == nil || system.Sites == nil || siteRef == 0 {
+		return 0
+	}
+
+	if site, ok := system.Sites.GetSiteByRef(siteRef); ok {
+		return site.Priority
+	}
+
+	return 0
+}
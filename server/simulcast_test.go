@@ -0,0 +1,63 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSimulcastScoreIsBetter(t *testing.T) {
+	system := &System{Sites: NewSites()}
+	system.Sites.List = []*Site{{Priority: 1, SiteRef: 1}, {Priority: 5, SiteRef: 2}}
+
+	newCall := func(siteRef uint, duration uint, flagged bool, frequencies ...CallFrequency) *Call {
+		call := &Call{Duration: duration, Frequencies: frequencies, SiteRef: siteRef, System: system}
+		if flagged {
+			call.Flags = []string{QualityFlagQuiet}
+		}
+		return call
+	}
+
+	for _, tc := range []struct {
+		name  string
+		call  *Call
+		other *Call
+		want  bool
+	}{
+		{name: "unflagged over flagged", call: newCall(1, 1000, false), other: newCall(2, 5000, true, CallFrequency{}), want: true},
+		{name: "flagged under unflagged", call: newCall(2, 5000, true, CallFrequency{}), other: newCall(1, 1000, false)},
+		{name: "fewer errors", call: newCall(1, 1000, false, CallFrequency{Errors: 1}), other: newCall(2, 5000, false, CallFrequency{Errors: 2}), want: true},
+		{name: "spikes counted as errors", call: newCall(2, 5000, false, CallFrequency{Spikes: 2}), other: newCall(1, 1000, false, CallFrequency{Errors: 1})},
+		{name: "errors of one copy only", call: newCall(2, 1000, false), other: newCall(1, 5000, false, CallFrequency{}), want: true},
+		{name: "site priority", call: newCall(2, 1000, false, CallFrequency{Errors: 1}), other: newCall(1, 5000, false, CallFrequency{Errors: 1}), want: true},
+		{name: "unknown site", call: newCall(3, 5000, false), other: newCall(1, 1000, false)},
+		{name: "longest audio", call: newCall(1, 5000, false), other: newCall(1, 1000, false), want: true},
+		{name: "equal copies", call: newCall(1, 1000, false), other: newCall(1, 1000, false)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			score, other := NewSimulcastScore(tc.call), NewSimulcastScore(tc.other)
+
+			if better := score.IsBetter(other); better != tc.want {
+				t.Errorf("%s better than %s %v, want %v", score, other, better, tc.want)
+			}
+		})
+	}
+}
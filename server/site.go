@@ -25,7 +25,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strings"
 	"sync"
 )
 
@@ -33,6 +32,7 @@ type Site struct {
 	Id       uint64
 	Label    string
 	Order    uint
+	Priority uint
 	SiteRef  uint
 	SystemId uint64
 }
@@ -57,6 +57,11 @@ func (site *Site) FromMap(m map[string]any) *Site {
 		site.Order = uint(v)
 	}
 
+	switch v := m["priority"].(type) {
+	case float64:
+		site.Priority = uint(v)
+	}
+
 	switch v := m["siteRef"].(type) {
 	case float64:
 		site.SiteRef = uint(v)
@@ -82,6 +87,10 @@ func (site *Site) MarshalJSON() ([]byte, error) {
 		m["order"] = site.Order
 	}
 
+	if site.Priority > 0 {
+		m["priority"] = site.Priority
+	}
+
 	return json.Marshal(m)
 }
 
@@ -153,7 +162,7 @@ func (sites *Sites) GetSiteByRef(ref uint) (site *Site, ok bool) {
 	return nil, false
 }
 
-func (sites *Sites) ReadTx(tx *sql.Tx, systemId uint64) error {
+func (sites *Sites) ReadTx(tx *StorageTx, systemId uint64) error {
 	var (
 		err   error
 		query string
@@ -167,15 +176,15 @@ func (sites *Sites) ReadTx(tx *sql.Tx, systemId uint64) error {
 
 	formatError := errorFormatter("sites", "read")
 
-	query = fmt.Sprintf(`SELECT "siteId", "label", "order", "siteRef" FROM "sites" WHERE "systemId" = %d`, systemId)
-	if rows, err = tx.Query(query); err != nil {
+	query = `SELECT "siteId", "label", "order", "priority", "siteRef" FROM "sites" WHERE "systemId" = ?`
+	if rows, err = tx.Query(query, systemId); err != nil {
 		return formatError(err, query)
 	}
 
 	for rows.Next() {
 		site := NewSite()
 
-		if err = rows.Scan(&site.Id, &site.Label, &site.Order, &site.SiteRef); err != nil {
+		if err = rows.Scan(&site.Id, &site.Label, &site.Order, &site.Priority, &site.SiteRef); err != nil {
 			break
 		}
 
@@ -195,7 +204,7 @@ func (sites *Sites) ReadTx(tx *sql.Tx, systemId uint64) error {
 	return nil
 }
 
-func (sites *Sites) WriteTx(tx *sql.Tx, systemId uint64) error {
+func (sites *Sites) WriteTx(tx *StorageTx, systemId uint64) error {
 	var (
 		err     error
 		query   string
@@ -208,8 +217,8 @@ func (sites *Sites) WriteTx(tx *sql.Tx, systemId uint64) error {
 
 	formatError := errorFormatter("sites", "writetx")
 
-	query = fmt.Sprintf(`SELECT "siteId" FROM "sites" WHERE "systemId" = %d`, systemId)
-	if rows, err = tx.Query(query); err != nil {
+	query = `SELECT "siteId" FROM "sites" WHERE "systemId" = ?`
+	if rows, err = tx.Query(query, systemId); err != nil {
 		return formatError(err, query)
 	}
 
@@ -237,12 +246,10 @@ func (sites *Sites) WriteTx(tx *sql.Tx, systemId uint64) error {
 	}
 
 	if len(siteIds) > 0 {
-		if b, err := json.Marshal(siteIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "sites" WHERE "siteId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				return formatError(err, query)
-			}
+		in, args := sqlIn(siteIds)
+		query = fmt.Sprintf(`DELETE FROM "sites" WHERE "siteId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return formatError(err, query)
 		}
 	}
 
@@ -250,21 +257,21 @@ func (sites *Sites) WriteTx(tx *sql.Tx, systemId uint64) error {
 		var count uint
 
 		if site.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "sites" WHERE "siteId" = %d`, site.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "sites" WHERE "siteId" = ?`
+			if err = tx.QueryRow(query, site.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "sites" ("label", "order", "siteRef", "systemId") VALUES ('%s', %d, %d, %d)`, escapeQuotes(site.Label), site.Order, site.SiteRef, systemId)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "sites" ("label", "order", "priority", "siteRef", "systemId") VALUES (?, ?, ?, ?, ?)`
+			if _, err = tx.Exec(query, site.Label, site.Order, site.Priority, site.SiteRef, systemId); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "sites" SET "label" = '%s', "order" = %d, "siteRef" = %d where "siteId" = %d`, escapeQuotes(site.Label), site.Order, site.SiteRef, site.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "sites" SET "label" = ?, "order" = ?, "priority" = ?, "siteRef" = ? where "siteId" = ?`
+			if _, err = tx.Exec(query, site.Label, site.Order, site.Priority, site.SiteRef, site.Id); err != nil {
 				break
 			}
 		}
@@ -0,0 +1,229 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SmtpSecurityNone     = "none"
+	SmtpSecurityStartTls = "starttls"
+	SmtpSecurityTls      = "tls"
+
+	SmtpTimeout = 30 * time.Second
+)
+
+// Smtp is the relay through which the server sends its emails.
+type Smtp struct {
+	From     string `json:"from"`
+	Host     string `json:"host"`
+	Password string `json:"password"`
+	Port     uint   `json:"port"`
+	Security string `json:"security"`
+	Username string `json:"username"`
+}
+
+func NewSmtp() *Smtp {
+	return &Smtp{
+		Port:     25,
+		Security: SmtpSecurityNone,
+	}
+}
+
+func (relay *Smtp) FromMap(m map[string]any) *Smtp {
+	switch v := m["from"].(type) {
+	case string:
+		relay.From = strings.TrimSpace(v)
+	}
+
+	switch v := m["host"].(type) {
+	case string:
+		relay.Host = strings.TrimSpace(v)
+	}
+
+	switch v := m["password"].(type) {
+	case string:
+		relay.Password = v
+	}
+
+	switch v := m["port"].(type) {
+	case float64:
+		if v > 0 && v < 65536 {
+			relay.Port = uint(v)
+		}
+	}
+
+	switch v := m["security"].(type) {
+	case string:
+		switch v {
+		case SmtpSecurityStartTls, SmtpSecurityTls:
+			relay.Security = v
+		default:
+			relay.Security = SmtpSecurityNone
+		}
+	}
+
+	switch v := m["username"].(type) {
+	case string:
+		relay.Username = strings.TrimSpace(v)
+	}
+
+	return relay
+}
+
+func (relay *Smtp) IsConfigured() bool {
+	return len(relay.Host) > 0
+}
+
+// Send delivers a plain text email to the recipients, the sender defaulting
+// to the first of them.
+func (relay *Smtp) Send(to []string, subject string, body string) error {
+	var (
+		client *smtp.Client
+		conn   net.Conn
+		err    error
+		from   = relay.From
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("smtp.send: %s", err.Error())
+	}
+
+	if !relay.IsConfigured() {
+		return formatError(errors.New("no smtp relay configured"))
+	}
+
+	if len(to) == 0 {
+		return formatError(errors.New("no recipient"))
+	}
+
+	if len(from) == 0 {
+		from = to[0]
+	}
+
+	addr := net.JoinHostPort(relay.Host, strconv.FormatUint(uint64(relay.Port), 10))
+	dialer := &net.Dialer{Timeout: SmtpTimeout}
+
+	if relay.Security == SmtpSecurityTls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: relay.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return formatError(err)
+	}
+
+	conn.SetDeadline(time.Now().Add(SmtpTimeout))
+
+	if client, err = smtp.NewClient(conn, relay.Host); err != nil {
+		conn.Close()
+		return formatError(err)
+	}
+	defer client.Close()
+
+	if relay.Security == SmtpSecurityStartTls {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return formatError(errors.New("starttls not supported by the relay"))
+		}
+		if err = client.StartTLS(&tls.Config{ServerName: relay.Host}); err != nil {
+			return formatError(err)
+		}
+	}
+
+	if len(relay.Username) > 0 {
+		if err = client.Auth(smtp.PlainAuth("", relay.Username, relay.Password, relay.Host)); err != nil {
+			return formatError(err)
+		}
+	}
+
+	if err = client.Mail(from); err != nil {
+		return formatError(err)
+	}
+
+	for _, recipient := range to {
+		if err = client.Rcpt(recipient); err != nil {
+			return formatError(err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return formatError(err)
+	}
+
+	if _, err = w.Write(smtpMessage(from, to, subject, body)); err != nil {
+		w.Close()
+		return formatError(err)
+	}
+
+	if err = w.Close(); err != nil {
+		return formatError(err)
+	}
+
+	if err = client.Quit(); err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func smtpMessage(from string, to []string, subject string, body string) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+
+	return b.Bytes()
+}
+
+// smtpRecipients splits a list of email addresses separated by commas or
+// semicolons.
+func smtpRecipients(s string) []string {
+	recipients := []string{}
+
+	for _, recipient := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if recipient = strings.TrimSpace(recipient); len(recipient) > 0 {
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	return recipients
+}
@@ -68,6 +68,8 @@ var SqliteSchema = []string{
 	`CREATE TABLE IF NOT EXISTS "systems" (
     "systemId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "alert" text NOT NULL DEFAULT '',
+    "audioProcessing" text NOT NULL DEFAULT '',
+    "audioProfile" text NOT NULL DEFAULT '',
     "autoPopulate" integer(1) NOT NULL DEFAULT 0,
     "blacklists" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
@@ -82,6 +84,7 @@ var SqliteSchema = []string{
     "siteId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "label" text NOT NULL,
     "order" integer NOT NULL DEFAULT 0,
+    "priority" integer NOT NULL DEFAULT 0,
     "siteRef" integer NOT NULL,
     "systemId" integer NOT NULL DEFAULT 0,
     FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE
@@ -90,6 +93,8 @@ var SqliteSchema = []string{
 	`CREATE TABLE IF NOT EXISTS "talkgroups" (
     "talkgroupId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "alert" text NOT NULL DEFAULT '',
+    "audioProcessing" text NOT NULL DEFAULT '',
+    "audioProfile" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
     "frequency" integer NOT NULL DEFAULT 0,
     "label" text NOT NULL,
@@ -117,16 +122,29 @@ var SqliteSchema = []string{
     "audio" blob NOT NULL,
     "audioFilename" text NOT NULL,
     "audioMime" text NOT NULL,
+    "audioProfile" text NOT NULL DEFAULT '',
+    "conversationId" integer NOT NULL DEFAULT 0,
+    "duration" integer NOT NULL DEFAULT 0,
+    "fingerprint" text NOT NULL DEFAULT '',
+    "flags" text NOT NULL DEFAULT '',
+    "linkedCallId" integer NOT NULL DEFAULT 0,
+    "peakLevel" real NOT NULL DEFAULT 0,
+    "peaks" text NOT NULL DEFAULT '',
+    "rmsLevel" real NOT NULL DEFAULT 0,
+    "sampleRate" integer NOT NULL DEFAULT 0,
     "siteRef" integer NOT NULL DEFAULT 0,
     "systemId" integer NOT NULL,
     "talkgroupId" integer NOT NULL,
     "timestamp" integer NOT NULL,
+    "trimmed" real NOT NULL DEFAULT 0,
     FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
     FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
 	`CREATE INDEX IF NOT EXISTS "calls_idx" ON "calls" ("systemId","siteRef","talkgroupId","timestamp");`,
 
+	`CREATE INDEX IF NOT EXISTS "calls_timestamp_idx" ON "calls" ("timestamp","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callFrequencies" (
     "callFrequencyId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "callId" integer NOT NULL,
@@ -138,6 +156,8 @@ var SqliteSchema = []string{
     FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callFrequencies_idx" ON "callFrequencies" ("frequency","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callPatches" (
     "callPatchId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "callId" integer NOT NULL,
@@ -146,6 +166,8 @@ var SqliteSchema = []string{
     FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callPatches_idx" ON "callPatches" ("talkgroupId","callId");`,
+
 	`CREATE TABLE IF NOT EXISTS "callUnits" (
     "callUnitId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "callId" integer NOT NULL,
@@ -154,6 +176,21 @@ var SqliteSchema = []string{
     FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 
+	`CREATE INDEX IF NOT EXISTS "callUnits_idx" ON "callUnits" ("unitRef","callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "conversations" (
+    "conversationId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "callCount" integer NOT NULL DEFAULT 0,
+    "firstTimestamp" integer NOT NULL,
+    "lastTimestamp" integer NOT NULL,
+    "systemId" integer NOT NULL,
+    "talkgroupId" integer NOT NULL,
+    FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "conversations_idx" ON "conversations" ("systemId","talkgroupId","lastTimestamp");`,
+
 	`CREATE TABLE IF NOT EXISTS "delayed" (
     "delayedId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "callId" integer NOT NULL,
@@ -163,20 +200,111 @@ var SqliteSchema = []string{
 
 	`CREATE TABLE IF NOT EXISTS "dirwatches" (
     "dirwatchId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "archiveDirectory" text NOT NULL DEFAULT '',
     "delay" integer NOT NULL DEFAULT 0,
     "deleteAfter" integer(1) NOT NULL DEFAULT 0,
     "directory" text NOT NULL,
     "disabled" integer(1) NOT NULL DEFAULT 0,
     "extension" text NOT NULL DEFAULT '',
+    "failedDirectory" text NOT NULL DEFAULT '',
     "frequency" integer NOT NULL DEFAULT 0,
     "mask" text NOT NULL DEFAULT '',
     "order" integer NOT NULL DEFAULT 0,
+    "pollInterval" integer NOT NULL DEFAULT 0,
+    "polling" integer(1) NOT NULL DEFAULT 0,
     "siteId" integer NOT NULL DEFAULT 0,
     "systemId" integer NOT NULL DEFAULT 0,
     "talkgroupId" integer NOT NULL DEFAULT 0,
     "type" text NOT NULL DEFAULT ''
   );`,
 
+	`CREATE TABLE IF NOT EXISTS "dirwatchFiles" (
+    "dirwatchFileId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "dirwatchId" integer NOT NULL,
+    "modTime" integer NOT NULL DEFAULT 0,
+    "path" text NOT NULL,
+    "reason" text NOT NULL DEFAULT '',
+    "size" integer NOT NULL DEFAULT 0,
+    "status" text NOT NULL DEFAULT '',
+    "timestamp" integer NOT NULL,
+    FOREIGN KEY ("dirwatchId") REFERENCES "dirwatches" ("dirwatchId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "dirwatchFiles_idx" ON "dirwatchFiles" ("dirwatchId");`,
+
+	`CREATE TABLE IF NOT EXISTS "discoveries" (
+    "discoveryId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "count" integer NOT NULL DEFAULT 0,
+    "firstSeen" integer NOT NULL,
+    "frequencies" text NOT NULL DEFAULT '',
+    "lastSeen" integer NOT NULL,
+    "status" text NOT NULL DEFAULT '',
+    "systemLabel" text NOT NULL DEFAULT '',
+    "systemRef" integer NOT NULL DEFAULT 0,
+    "talkgroupLabel" text NOT NULL DEFAULT '',
+    "talkgroupRef" integer NOT NULL DEFAULT 0,
+    "units" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "discoveries_idx" ON "discoveries" ("systemRef","talkgroupRef");`,
+
+	`CREATE TABLE IF NOT EXISTS "downsampledCalls" (
+    "downsampledCallId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "callId" integer NOT NULL,
+    "error" text NOT NULL DEFAULT '',
+    "profile" text NOT NULL,
+    "sizeAfter" integer NOT NULL DEFAULT 0,
+    "sizeBefore" integer NOT NULL DEFAULT 0,
+    "timestamp" integer NOT NULL,
+    FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "downsampledCalls_idx" ON "downsampledCalls" ("callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "incidents" (
+    "incidentId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "accesses" text NOT NULL DEFAULT '',
+    "closed" integer(1) NOT NULL DEFAULT 0,
+    "description" text NOT NULL DEFAULT '',
+    "label" text NOT NULL,
+    "timestamp" integer NOT NULL
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "incidentCalls" (
+    "incidentCallId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "callId" integer NOT NULL,
+    "incidentId" integer NOT NULL,
+    FOREIGN KEY ("callId") REFERENCES "calls" ("callId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("incidentId") REFERENCES "incidents" ("incidentId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "incidentCalls_idx" ON "incidentCalls" ("incidentId","callId");`,
+
+	`CREATE TABLE IF NOT EXISTS "incidentNotes" (
+    "incidentNoteId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "incidentId" integer NOT NULL,
+    "note" text NOT NULL,
+    "timestamp" integer NOT NULL,
+    FOREIGN KEY ("incidentId") REFERENCES "incidents" ("incidentId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "jobs" (
+    "jobId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "enabled" integer(1) NOT NULL DEFAULT 1,
+    "name" text NOT NULL,
+    "schedule" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE TABLE IF NOT EXISTS "jobRuns" (
+    "jobRunId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "duration" integer NOT NULL DEFAULT 0,
+    "error" text NOT NULL DEFAULT '',
+    "manual" integer(1) NOT NULL DEFAULT 0,
+    "name" text NOT NULL,
+    "status" text NOT NULL,
+    "timestamp" integer NOT NULL
+  );`,
+
 	`create table if not exists "logs" (
     "logid" integer not null PRIMARY KEY AUTOINCREMENT,
     "level" text not null,
@@ -190,6 +318,40 @@ var SqliteSchema = []string{
     "value" text NOT NULL
   );`,
 
+	`CREATE TABLE IF NOT EXISTS "quarantinedCalls" (
+    "quarantinedCallId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "audio" blob NOT NULL,
+    "audioFilename" text NOT NULL,
+    "audioMime" text NOT NULL,
+    "frequencies" text NOT NULL DEFAULT '',
+    "meta" text NOT NULL DEFAULT '',
+    "patches" text NOT NULL DEFAULT '',
+    "siteRef" integer NOT NULL DEFAULT 0,
+    "systemLabel" text NOT NULL DEFAULT '',
+    "systemRef" integer NOT NULL DEFAULT 0,
+    "talkgroupLabel" text NOT NULL DEFAULT '',
+    "talkgroupRef" integer NOT NULL DEFAULT 0,
+    "timestamp" integer NOT NULL,
+    "units" text NOT NULL DEFAULT ''
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "quarantinedCalls_idx" ON "quarantinedCalls" ("systemRef","talkgroupRef","timestamp");`,
+
+	`CREATE TABLE IF NOT EXISTS "unitActivities" (
+    "unitActivityId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "callCount" integer NOT NULL DEFAULT 0,
+    "firstTimestamp" integer NOT NULL,
+    "lastTimestamp" integer NOT NULL,
+    "siteRef" integer NOT NULL DEFAULT 0,
+    "systemId" integer NOT NULL,
+    "talkgroupId" integer NOT NULL,
+    "unitRef" integer NOT NULL,
+    FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY ("talkgroupId") REFERENCES "talkgroups" ("talkgroupId") ON DELETE CASCADE ON UPDATE CASCADE
+  );`,
+
+	`CREATE INDEX IF NOT EXISTS "unitActivities_idx" ON "unitActivities" ("systemId","unitRef","talkgroupId","siteRef");`,
+
 	`CREATE TABLE IF NOT EXISTS "units" (
     "unitId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
     "label" text NOT NULL,
@@ -201,3 +363,72 @@ var SqliteSchema = []string{
     FOREIGN KEY ("systemId") REFERENCES "systems" ("systemId") ON DELETE CASCADE ON UPDATE CASCADE
   );`,
 }
+
+var SqliteMigrations = []*SchemaMigration{
+	{
+		Columns: SqliteColumns,
+		Name:    "initial schema",
+		Up:      SqliteSchema,
+		Version: 1,
+	},
+	{
+		Func:    migrateVersion6,
+		Name:    "version 6 import",
+		Version: 2,
+	},
+	{
+		Columns: [][]string{
+			{"calls", "source", "text NOT NULL DEFAULT ''"},
+		},
+		Down: []string{
+			`DROP TABLE IF EXISTS "listenerCounts";`,
+			`ALTER TABLE "calls" DROP COLUMN "source";`,
+		},
+		Name: "call statistics",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS "listenerCounts" (
+    "listenerCountId" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    "count" integer NOT NULL,
+    "timestamp" integer NOT NULL
+  );`,
+
+			`CREATE INDEX IF NOT EXISTS "listenerCounts_idx" ON "listenerCounts" ("timestamp");`,
+		},
+		Version: 3,
+	},
+	{
+		Columns: [][]string{
+			{"calls", "simulcast", "text NOT NULL DEFAULT ''"},
+		},
+		Down: []string{
+			`ALTER TABLE "calls" DROP COLUMN "simulcast";`,
+		},
+		Name:    "simulcast decisions",
+		Version: 4,
+	},
+}
+
+var SqliteColumns = [][]string{
+	{"calls", "audioProfile", "text NOT NULL DEFAULT ''"},
+	{"calls", "conversationId", "integer NOT NULL DEFAULT 0"},
+	{"calls", "duration", "integer NOT NULL DEFAULT 0"},
+	{"calls", "fingerprint", "text NOT NULL DEFAULT ''"},
+	{"calls", "flags", "text NOT NULL DEFAULT ''"},
+	{"calls", "linkedCallId", "integer NOT NULL DEFAULT 0"},
+	{"calls", "peakLevel", "real NOT NULL DEFAULT 0"},
+	{"calls", "peaks", "text NOT NULL DEFAULT ''"},
+	{"calls", "rmsLevel", "real NOT NULL DEFAULT 0"},
+	{"calls", "sampleRate", "integer NOT NULL DEFAULT 0"},
+	{"calls", "trimmed", "real NOT NULL DEFAULT 0"},
+	{"dirwatches", "archiveDirectory", "text NOT NULL DEFAULT ''"},
+	{"dirwatches", "failedDirectory", "text NOT NULL DEFAULT ''"},
+	{"dirwatches", "pollInterval", "integer NOT NULL DEFAULT 0"},
+	{"dirwatches", "polling", "integer(1) NOT NULL DEFAULT 0"},
+	{"dirwatchFiles", "reason", "text NOT NULL DEFAULT ''"},
+	{"dirwatchFiles", "status", "text NOT NULL DEFAULT ''"},
+	{"sites", "priority", "integer NOT NULL DEFAULT 0"},
+	{"systems", "audioProcessing", "text NOT NULL DEFAULT ''"},
+	{"systems", "audioProfile", "text NOT NULL DEFAULT ''"},
+	{"talkgroups", "audioProcessing", "text NOT NULL DEFAULT ''"},
+	{"talkgroups", "audioProfile", "text NOT NULL DEFAULT ''"},
+}
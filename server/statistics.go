@@ -0,0 +1,475 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StatisticsByGroup     = "group"
+	StatisticsBySource    = "source"
+	StatisticsBySystem    = "system"
+	StatisticsByTag       = "tag"
+	StatisticsByTalkgroup = "talkgroup"
+	StatisticsByUnit      = "unit"
+
+	StatisticsIntervalDay  = "day"
+	StatisticsIntervalHour = "hour"
+)
+
+// StatisticsBucket is the activity of a period starting at its date, in UTC.
+type StatisticsBucket struct {
+	Airtime  uint64    `json:"airtime"`
+	Calls    uint      `json:"calls"`
+	DateTime time.Time `json:"dateTime"`
+}
+
+// StatisticsListeners is the highest listeners count of a period.
+type StatisticsListeners struct {
+	Count    uint      `json:"count"`
+	DateTime time.Time `json:"dateTime"`
+}
+
+// StatisticsSeries is the activity of a system, talkgroup, group, tag, unit
+// or ingest source, the airtime being in milliseconds.
+type StatisticsSeries struct {
+	Airtime   uint64             `json:"airtime"`
+	Buckets   []StatisticsBucket `json:"buckets,omitempty"`
+	Calls     uint               `json:"calls"`
+	Group     string             `json:"group,omitempty"`
+	Label     string             `json:"label,omitempty"`
+	Source    string             `json:"source,omitempty"`
+	System    uint               `json:"system,omitempty"`
+	Tag       string             `json:"tag,omitempty"`
+	Talkgroup uint               `json:"talkgroup,omitempty"`
+	Unit      uint               `json:"unit,omitempty"`
+	buckets   map[int64]*StatisticsBucket
+}
+
+type StatisticsOptions struct {
+	By        string    `json:"by"`
+	DateEnd   time.Time `json:"dateEnd"`
+	DateStart time.Time `json:"dateStart"`
+	Interval  string    `json:"interval,omitempty"`
+	Limit     uint      `json:"limit"`
+	System    uint      `json:"system,omitempty"`
+	Talkgroup uint      `json:"talkgroup,omitempty"`
+}
+
+func NewStatisticsOptions() *StatisticsOptions {
+	now := time.Now().UTC()
+
+	return &StatisticsOptions{
+		By:        StatisticsByTalkgroup,
+		DateEnd:   now,
+		DateStart: now.AddDate(0, 0, -7),
+		Limit:     25,
+	}
+}
+
+func (statisticsOptions *StatisticsOptions) FromQuery(get func(key string) string) *StatisticsOptions {
+	switch v := get("by"); v {
+	case StatisticsByGroup, StatisticsBySource, StatisticsBySystem, StatisticsByTag, StatisticsByTalkgroup, StatisticsByUnit:
+		statisticsOptions.By = v
+	}
+
+	if t, err := time.Parse(time.RFC3339, get("dateEnd")); err == nil {
+		statisticsOptions.DateEnd = t.UTC()
+	}
+
+	if t, err := time.Parse(time.RFC3339, get("dateStart")); err == nil {
+		statisticsOptions.DateStart = t.UTC()
+	}
+
+	switch v := get("interval"); v {
+	case StatisticsIntervalDay, StatisticsIntervalHour:
+		statisticsOptions.Interval = v
+	}
+
+	if i, err := strconv.ParseUint(get("limit"), 10, 32); err == nil && i > 0 {
+		statisticsOptions.Limit = min(uint(i), 500)
+	}
+
+	if i, err := strconv.ParseUint(get("system"), 10, 32); err == nil {
+		statisticsOptions.System = uint(i)
+	}
+
+	if i, err := strconv.ParseUint(get("talkgroup"), 10, 32); err == nil {
+		statisticsOptions.Talkgroup = uint(i)
+	}
+
+	return statisticsOptions
+}
+
+func (statisticsOptions *StatisticsOptions) interval() int64 {
+	switch statisticsOptions.Interval {
+	case StatisticsIntervalDay:
+		return 24 * time.Hour.Milliseconds()
+	case StatisticsIntervalHour:
+		return time.Hour.Milliseconds()
+	}
+	return 0
+}
+
+type StatisticsResults struct {
+	Airtime   uint64                `json:"airtime"`
+	Calls     uint                  `json:"calls"`
+	Count     uint                  `json:"count"`
+	Listeners []StatisticsListeners `json:"listeners,omitempty"`
+	Options   *StatisticsOptions    `json:"options"`
+	Series    []*StatisticsSeries   `json:"series"`
+}
+
+// Statistics aggregates the calls, the airtime and the listeners over time.
+type Statistics struct {
+	controller *Controller
+	listeners  int
+	mutex      sync.Mutex
+}
+
+func NewStatistics(controller *Controller) *Statistics {
+	return &Statistics{
+		controller: controller,
+		listeners:  -1,
+		mutex:      sync.Mutex{},
+	}
+}
+
+// Compute returns the activity grouped as requested by the options, limited
+// to the systems and talkgroups of the access if any. The ingest sources and
+// the listeners counts are only given to the administrator.
+func (statistics *Statistics) Compute(statisticsOptions *StatisticsOptions, access *Access, admin bool, db Storage) (*StatisticsResults, error) {
+	var (
+		args     = []any{statisticsOptions.DateStart.UnixMilli(), statisticsOptions.DateEnd.UnixMilli()}
+		bucket   = "0"
+		err      error
+		groupBy  string
+		interval = statisticsOptions.interval()
+		query    string
+		rows     *sql.Rows
+		series   = map[string]*StatisticsSeries{}
+		where    = `c."timestamp" BETWEEN ? AND ?`
+	)
+
+	formatError := errorFormatter("statistics", "compute")
+
+	if statisticsOptions.By == StatisticsBySource && !admin {
+		return nil, formatError(errors.New("statistics by source are restricted to the administrator"), "")
+	}
+
+	if statisticsOptions.System > 0 {
+		where += ` AND s."systemRef" = ?`
+		args = append(args, statisticsOptions.System)
+	}
+
+	if statisticsOptions.Talkgroup > 0 {
+		where += ` AND t."talkgroupRef" = ?`
+		args = append(args, statisticsOptions.Talkgroup)
+	}
+
+	if access != nil {
+		if scope, scopeArgs := access.Scope(); len(scope) > 0 {
+			where += fmt.Sprintf(" AND %s", scope)
+			args = append(args, scopeArgs...)
+		}
+	}
+
+	if interval > 0 {
+		bucket = fmt.Sprintf(`c."timestamp" - (c."timestamp" %% %d)`, interval)
+		groupBy = fmt.Sprintf(", %s", bucket)
+	}
+
+	from := `FROM "calls" AS c LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId"`
+
+	results := &StatisticsResults{
+		Options: statisticsOptions,
+		Series:  []*StatisticsSeries{},
+	}
+
+	query = fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(c."duration"), 0) %s WHERE %s`, from, where)
+	if err = db.QueryRow(query, args...).Scan(&results.Calls, &results.Airtime); err != nil && err != sql.ErrNoRows {
+		return nil, formatError(err, query)
+	}
+
+	switch statisticsOptions.By {
+	case StatisticsBySource:
+		query = fmt.Sprintf(`SELECT 0, 0, c."source", %s, COUNT(*), COALESCE(SUM(c."duration"), 0) %s WHERE %s GROUP BY c."source"%s`, bucket, from, where, groupBy)
+	case StatisticsByUnit:
+		query = fmt.Sprintf(`SELECT c."systemId", cu."unitRef", '', %s, COUNT(*), COALESCE(SUM(c."duration"), 0) FROM (SELECT DISTINCT "callId", "unitRef" FROM "callUnits") AS cu LEFT JOIN "calls" AS c ON c."callId" = cu."callId" LEFT JOIN "systems" AS s ON s."systemId" = c."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = c."talkgroupId" WHERE %s GROUP BY c."systemId", cu."unitRef"%s`, bucket, where, groupBy)
+	default:
+		query = fmt.Sprintf(`SELECT c."systemId", c."talkgroupId", '', %s, COUNT(*), COALESCE(SUM(c."duration"), 0) %s WHERE %s GROUP BY c."systemId", c."talkgroupId"%s`, bucket, from, where, groupBy)
+	}
+
+	if rows, err = db.Query(query, args...); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	for rows.Next() {
+		var (
+			airtime   uint64
+			calls     uint
+			id        uint64
+			source    string
+			systemId  uint64
+			timestamp int64
+		)
+
+		if err = rows.Scan(&systemId, &id, &source, &timestamp, &calls, &airtime); err != nil {
+			break
+		}
+
+		for _, s := range statistics.series(statisticsOptions.By, systemId, id, source) {
+			if _, ok := series[s.key()]; !ok {
+				series[s.key()] = s
+			}
+			series[s.key()].add(timestamp, calls, airtime, interval > 0)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	for _, s := range series {
+		s.Buckets = s.sortedBuckets()
+		results.Series = append(results.Series, s)
+	}
+
+	sort.Slice(results.Series, func(i int, j int) bool {
+		if results.Series[i].Calls != results.Series[j].Calls {
+			return results.Series[i].Calls > results.Series[j].Calls
+		}
+		return results.Series[i].key() < results.Series[j].key()
+	})
+
+	results.Count = uint(len(results.Series))
+
+	if uint(len(results.Series)) > statisticsOptions.Limit {
+		results.Series = results.Series[:statisticsOptions.Limit]
+	}
+
+	if admin {
+		if results.Listeners, err = statistics.listenersCounts(statisticsOptions, db); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (statistics *Statistics) Prune(db Storage, pruneDays uint) error {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	query := `DELETE FROM "listenerCounts" WHERE "timestamp" < ?`
+	if _, err := db.Exec(query, time.Now().Add(-24*time.Hour*time.Duration(pruneDays)).UnixMilli()); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	return nil
+}
+
+// RecordListeners keeps the listeners count when it changed.
+func (statistics *Statistics) RecordListeners(count int, db Storage) error {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	if count == statistics.listeners {
+		return nil
+	}
+
+	query := `INSERT INTO "listenerCounts" ("count", "timestamp") VALUES (?, ?)`
+	if _, err := db.Exec(query, count, time.Now().UnixMilli()); err != nil {
+		return errorFormatter("statistics", "recordlisteners")(err, query)
+	}
+
+	statistics.listeners = count
+
+	return nil
+}
+
+// listenersCounts returns the highest listeners count of each period, hourly
+// unless asked daily, carrying the last known count over the quiet periods.
+func (statistics *Statistics) listenersCounts(statisticsOptions *StatisticsOptions, db Storage) ([]StatisticsListeners, error) {
+	var (
+		count    uint
+		counts   = []StatisticsListeners{}
+		err      error
+		interval = statisticsOptions.interval()
+		query    string
+		rows     *sql.Rows
+		start    = statisticsOptions.DateStart.UnixMilli()
+		stop     = statisticsOptions.DateEnd.UnixMilli()
+	)
+
+	formatError := errorFormatter("statistics", "listeners")
+
+	if interval == 0 {
+		interval = time.Hour.Milliseconds()
+	}
+
+	query = `SELECT "count" FROM "listenerCounts" WHERE "timestamp" < ? ORDER BY "timestamp" DESC LIMIT 1`
+	if err = db.QueryRow(query, start).Scan(&count); err != nil && err != sql.ErrNoRows {
+		return nil, formatError(err, query)
+	}
+
+	query = `SELECT "count", "timestamp" FROM "listenerCounts" WHERE "timestamp" BETWEEN ? AND ? ORDER BY "timestamp" ASC`
+	if rows, err = db.Query(query, start, stop); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	samples := []StatisticsListeners{}
+
+	for rows.Next() {
+		var (
+			sample    StatisticsListeners
+			timestamp int64
+		)
+
+		if err = rows.Scan(&sample.Count, &timestamp); err != nil {
+			break
+		}
+
+		sample.DateTime = time.UnixMilli(timestamp)
+		samples = append(samples, sample)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	for from := start - start%interval; from <= stop; from += interval {
+		peak := count
+
+		for len(samples) > 0 && samples[0].DateTime.UnixMilli() < from+interval {
+			count = samples[0].Count
+			peak = max(peak, count)
+			samples = samples[1:]
+		}
+
+		counts = append(counts, StatisticsListeners{Count: peak, DateTime: time.UnixMilli(from).UTC()})
+	}
+
+	return counts, nil
+}
+
+// series returns the series a row of the calls belongs to, a talkgroup being
+// part of as many series as it has groups.
+func (statistics *Statistics) series(by string, systemId uint64, id uint64, source string) []*StatisticsSeries {
+	var (
+		controller = statistics.controller
+		system     *System
+		talkgroup  *Talkgroup
+	)
+
+	if by == StatisticsBySource {
+		return []*StatisticsSeries{{Source: source}}
+	}
+
+	system, ok := controller.Systems.GetSystemById(systemId)
+	if !ok {
+		return nil
+	}
+
+	if by == StatisticsByUnit {
+		s := &StatisticsSeries{System: system.SystemRef, Unit: uint(id)}
+		if unit, ok := system.Units.GetUnitByRef(uint(id)); ok {
+			s.Label = unit.Label
+		}
+		return []*StatisticsSeries{s}
+	}
+
+	if by == StatisticsBySystem {
+		return []*StatisticsSeries{{Label: system.Label, System: system.SystemRef}}
+	}
+
+	if talkgroup, ok = system.Talkgroups.GetTalkgroupById(id); !ok {
+		return nil
+	}
+
+	switch by {
+	case StatisticsByGroup:
+		list := []*StatisticsSeries{}
+		for _, groupId := range talkgroup.GroupIds {
+			if group, ok := controller.Groups.GetGroupById(groupId); ok {
+				list = append(list, &StatisticsSeries{Group: group.Label})
+			}
+		}
+		return list
+
+	case StatisticsByTag:
+		if tag, ok := controller.Tags.GetTagById(talkgroup.TagId); ok {
+			return []*StatisticsSeries{{Tag: tag.Label}}
+		}
+		return nil
+	}
+
+	return []*StatisticsSeries{{Label: talkgroup.Label, System: system.SystemRef, Talkgroup: talkgroup.TalkgroupRef}}
+}
+
+func (series *StatisticsSeries) add(timestamp int64, calls uint, airtime uint64, bucketed bool) {
+	series.Airtime += airtime
+	series.Calls += calls
+
+	if !bucketed {
+		return
+	}
+
+	if series.buckets == nil {
+		series.buckets = map[int64]*StatisticsBucket{}
+	}
+
+	if bucket, ok := series.buckets[timestamp]; ok {
+		bucket.Airtime += airtime
+		bucket.Calls += calls
+	} else {
+		series.buckets[timestamp] = &StatisticsBucket{Airtime: airtime, Calls: calls, DateTime: time.UnixMilli(timestamp).UTC()}
+	}
+}
+
+func (series *StatisticsSeries) key() string {
+	return fmt.Sprintf("%s/%s/%s/%d/%d/%d", series.Group, series.Source, series.Tag, series.System, series.Talkgroup, series.Unit)
+}
+
+func (series *StatisticsSeries) sortedBuckets() []StatisticsBucket {
+	buckets := []StatisticsBucket{}
+
+	for _, bucket := range series.buckets {
+		buckets = append(buckets, *bucket)
+	}
+
+	sort.Slice(buckets, func(i int, j int) bool {
+		return buckets[i].DateTime.Before(buckets[j].DateTime)
+	})
+
+	return buckets
+}
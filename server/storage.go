@@ -0,0 +1,398 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StorageStatementsLimit bounds the prepared statements kept by a database,
+// the least recently used one being closed to make room for a new one.
+const StorageStatementsLimit = 256
+
+// Storage is the data access the controller depends on. Queries are written
+// with ? placeholders and bound parameters whatever the database type, the
+// dialect rebinding them to the syntax of the database, and are prepared once
+// then reused.
+type Storage interface {
+	Begin() (*StorageTx, error)
+	Close() error
+	Dialect() *Dialect
+	Exec(query string, args ...any) (sql.Result, error)
+	Insert(query string, pk string, args ...any) (uint64, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Size() (uint64, error)
+}
+
+type Dialect struct {
+	Family string
+}
+
+func NewDialect(dbType string) *Dialect {
+	return &Dialect{Family: dbFamily(dbType)}
+}
+
+// Rebind replaces the ? placeholders outside of quoted literals and
+// identifiers with the numbered placeholders of PostgreSQL.
+func (dialect *Dialect) Rebind(query string) string {
+	if dialect.Family != DbTypePostgresql || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var (
+		b     strings.Builder
+		n     int
+		quote rune
+	)
+
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString(fmt.Sprintf("$%d", n))
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Like matches the expression against a pattern bound as an argument, its
+// wildcards being escaped with a backslash, a string escape on MySQL.
+func (dialect *Dialect) Like(expr string) string {
+	if dialect.Family == DbTypeMysql {
+		return fmt.Sprintf(`%s LIKE ? ESCAPE '\\'`, expr)
+	}
+
+	return fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, expr)
+}
+
+// StringAgg concatenates the values of a group with commas.
+func (dialect *Dialect) StringAgg(expr string) string {
+	if dialect.Family == DbTypePostgresql {
+		return fmt.Sprintf("STRING_AGG(CAST(%s AS text), ',')", expr)
+	}
+
+	return fmt.Sprintf("GROUP_CONCAT(%s)", expr)
+}
+
+// StorageTx is a transaction running the prepared statements of its
+// database.
+type StorageTx struct {
+	db         *Database
+	tx         *sql.Tx
+	unprepared bool
+}
+
+func (tx *StorageTx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *StorageTx) Exec(query string, args ...any) (sql.Result, error) {
+	query = tx.db.dialect.Rebind(query)
+
+	if statement := tx.statement(query); statement != nil {
+		defer tx.db.statements.release(statement)
+		return tx.tx.Stmt(statement.stmt).Exec(args...)
+	}
+
+	return tx.tx.Exec(query, args...)
+}
+
+func (tx *StorageTx) Insert(query string, pk string, args ...any) (uint64, error) {
+	if tx.db.dialect.Family == DbTypePostgresql {
+		var id uint64
+
+		err := tx.QueryRow(fmt.Sprintf(`%s RETURNING "%s"`, query, pk), args...).Scan(&id)
+
+		return id, err
+	}
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return uint64(id), err
+}
+
+func (tx *StorageTx) Query(query string, args ...any) (*sql.Rows, error) {
+	query = tx.db.dialect.Rebind(query)
+
+	if statement := tx.statement(query); statement != nil {
+		defer tx.db.statements.release(statement)
+		return tx.tx.Stmt(statement.stmt).Query(args...)
+	}
+
+	return tx.tx.Query(query, args...)
+}
+
+func (tx *StorageTx) QueryRow(query string, args ...any) *sql.Row {
+	query = tx.db.dialect.Rebind(query)
+
+	if statement := tx.statement(query); statement != nil {
+		defer tx.db.statements.release(statement)
+		return tx.tx.Stmt(statement.stmt).QueryRow(args...)
+	}
+
+	return tx.tx.QueryRow(query, args...)
+}
+
+func (tx *StorageTx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+func (tx *StorageTx) statement(query string) *storageStatement {
+	if tx.unprepared {
+		return nil
+	}
+
+	return tx.db.statements.acquire(tx.db.Sql, query)
+}
+
+func (db *Database) Begin() (*StorageTx, error) {
+	return db.begin(true)
+}
+
+// Close closes the prepared statements then the connections.
+func (db *Database) Close() error {
+	db.statements.close()
+
+	return db.Sql.Close()
+}
+
+func (db *Database) Dialect() *Dialect {
+	return db.dialect
+}
+
+func (db *Database) Exec(query string, args ...any) (sql.Result, error) {
+	query = db.dialect.Rebind(query)
+
+	if statement := db.statements.acquire(db.Sql, query); statement != nil {
+		defer db.statements.release(statement)
+		return statement.stmt.Exec(args...)
+	}
+
+	return db.Sql.Exec(query, args...)
+}
+
+// Insert runs an insert statement and returns the primary key of the new
+// row.
+func (db *Database) Insert(query string, pk string, args ...any) (uint64, error) {
+	if db.dialect.Family == DbTypePostgresql {
+		var id uint64
+
+		err := db.QueryRow(fmt.Sprintf(`%s RETURNING "%s"`, query, pk), args...).Scan(&id)
+
+		return id, err
+	}
+
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return uint64(id), err
+}
+
+func (db *Database) Query(query string, args ...any) (*sql.Rows, error) {
+	query = db.dialect.Rebind(query)
+
+	if statement := db.statements.acquire(db.Sql, query); statement != nil {
+		defer db.statements.release(statement)
+		return statement.stmt.Query(args...)
+	}
+
+	return db.Sql.Query(query, args...)
+}
+
+func (db *Database) QueryRow(query string, args ...any) *sql.Row {
+	query = db.dialect.Rebind(query)
+
+	if statement := db.statements.acquire(db.Sql, query); statement != nil {
+		defer db.statements.release(statement)
+		return statement.stmt.QueryRow(args...)
+	}
+
+	return db.Sql.QueryRow(query, args...)
+}
+
+// begin starts a transaction whose statements are prepared and kept, unless
+// they are run once such as the ones of the migrations.
+func (db *Database) begin(prepared bool) (*StorageTx, error) {
+	tx, err := db.Sql.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StorageTx{db: db, tx: tx, unprepared: !prepared}, nil
+}
+
+type storageStatement struct {
+	evicted bool
+	query   string
+	refs    uint
+	stmt    *sql.Stmt
+}
+
+// storageStatements keeps the most recently used prepared statements of a
+// database. An evicted statement is closed as soon as the queries running it
+// are done, the rows they returned keeping it open until closed.
+type storageStatements struct {
+	entries map[string]*list.Element
+	lru     *list.List
+	mutex   sync.Mutex
+}
+
+func newStorageStatements() *storageStatements {
+	return &storageStatements{
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		mutex:   sync.Mutex{},
+	}
+}
+
+// acquire returns the prepared statement of a query, preparing it when not
+// cached, or nil when it cannot be prepared so that the query runs unprepared
+// and reports its error. The statement must be released once run.
+func (statements *storageStatements) acquire(conn *sql.DB, query string) *storageStatement {
+	if statement := statements.get(query); statement != nil {
+		return statement
+	}
+
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return nil
+	}
+
+	statements.mutex.Lock()
+	defer statements.mutex.Unlock()
+
+	// prepared meanwhile by another query
+	if element, ok := statements.entries[query]; ok {
+		stmt.Close()
+
+		statements.lru.MoveToFront(element)
+
+		statement := element.Value.(*storageStatement)
+		statement.refs++
+
+		return statement
+	}
+
+	statement := &storageStatement{query: query, refs: 1, stmt: stmt}
+
+	statements.entries[query] = statements.lru.PushFront(statement)
+
+	for statements.lru.Len() > StorageStatementsLimit {
+		element := statements.lru.Back()
+
+		evicted := element.Value.(*storageStatement)
+		evicted.evicted = true
+
+		statements.lru.Remove(element)
+		delete(statements.entries, evicted.query)
+
+		if evicted.refs == 0 {
+			evicted.stmt.Close()
+		}
+	}
+
+	return statement
+}
+
+func (statements *storageStatements) close() {
+	statements.mutex.Lock()
+	defer statements.mutex.Unlock()
+
+	for _, element := range statements.entries {
+		element.Value.(*storageStatement).stmt.Close()
+	}
+
+	statements.entries = map[string]*list.Element{}
+	statements.lru.Init()
+}
+
+func (statements *storageStatements) get(query string) *storageStatement {
+	statements.mutex.Lock()
+	defer statements.mutex.Unlock()
+
+	element, ok := statements.entries[query]
+	if !ok {
+		return nil
+	}
+
+	statements.lru.MoveToFront(element)
+
+	statement := element.Value.(*storageStatement)
+	statement.refs++
+
+	return statement
+}
+
+func (statements *storageStatements) release(statement *storageStatement) {
+	statements.mutex.Lock()
+	defer statements.mutex.Unlock()
+
+	statement.refs--
+
+	if statement.evicted && statement.refs == 0 {
+		statement.stmt.Close()
+	}
+}
+
+// sqlContains returns the pattern of Dialect.Like matching the values
+// containing a text, its wildcards and backslashes matching themselves.
+func sqlContains(s string) string {
+	return fmt.Sprintf("%%%s%%", strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s))
+}
+
+// sqlIn returns the placeholders of a list of values for an IN clause along
+// with the values as arguments, an empty list matching nothing.
+func sqlIn[T any](values []T) (string, []any) {
+	if len(values) == 0 {
+		return "(NULL)", nil
+	}
+
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	return fmt.Sprintf("(%s)", strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")), args
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDialectLike(t *testing.T) {
+	controller := NewController(&Config{BaseDir: t.TempDir(), DbFile: "rdio-scanner.db", DbType: DbTypeSqlite})
+	defer controller.Database.Close()
+
+	for _, tc := range []struct {
+		name   string
+		search string
+		value  string
+		want   bool
+	}{
+		{name: "contained", search: "ab", value: "xaby", want: true},
+		{name: "underscore", search: "a_b", value: "xa_by", want: true},
+		{name: "underscore wildcard", search: "a_b", value: "axb"},
+		{name: "percent", search: "50%", value: "50%", want: true},
+		{name: "percent wildcard", search: "50%", value: "500"},
+		{name: "backslash", search: `a\b`, value: `xa\by`, want: true},
+		{name: "backslash escape", search: `a\_`, value: `a\x`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var count int
+
+			query := `SELECT COUNT(*) FROM (SELECT ? AS "value") AS v WHERE ` + controller.Database.Dialect().Like(`v."value"`)
+			if err := controller.Database.QueryRow(query, tc.value, sqlContains(tc.search)).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+
+			if (count > 0) != tc.want {
+				t.Errorf("%q matches %q %v, want %v", tc.search, tc.value, count > 0, tc.want)
+			}
+		})
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		dbType string
+		query  string
+		want   string
+	}{
+		{name: "sqlite", dbType: DbTypeSqlite, query: `SELECT * FROM "calls" WHERE "callId" = ?`, want: `SELECT * FROM "calls" WHERE "callId" = ?`},
+		{name: "mysql", dbType: DbTypeMariadb, query: `SELECT * FROM "calls" WHERE "callId" = ?`, want: `SELECT * FROM "calls" WHERE "callId" = ?`},
+		{name: "numbered", dbType: DbTypePostgresql, query: `SELECT * FROM "calls" WHERE "callId" IN (?, ?) AND "timestamp" > ?`, want: `SELECT * FROM "calls" WHERE "callId" IN ($1, $2) AND "timestamp" > $3`},
+		{name: "no placeholder", dbType: DbTypePostgresql, query: `SELECT COUNT(*) FROM "calls"`, want: `SELECT COUNT(*) FROM "calls"`},
+		{name: "quoted literal", dbType: DbTypePostgresql, query: `SELECT '?', ? FROM "calls"`, want: `SELECT '?', $1 FROM "calls"`},
+		{name: "quoted identifier", dbType: DbTypePostgresql, query: `SELECT "a?b" FROM "calls" WHERE "callId" = ?`, want: `SELECT "a?b" FROM "calls" WHERE "callId" = $1`},
+		{name: "doubled quote", dbType: DbTypePostgresql, query: `SELECT 'it''s ?', ?`, want: `SELECT 'it''s ?', $1`},
+		{name: "escape character", dbType: DbTypePostgresql, query: `SELECT ? LIKE ? ESCAPE '\' AND ?`, want: `SELECT $1 LIKE $2 ESCAPE '\' AND $3`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if query := NewDialect(tc.dbType).Rebind(tc.query); query != tc.want {
+				t.Errorf("rebound %s, want %s", query, tc.want)
+			}
+		})
+	}
+}
+
+func TestSqlIn(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		values   []uint
+		want     string
+		wantArgs []any
+	}{
+		{name: "empty", want: "(NULL)"},
+		{name: "one value", values: []uint{1}, want: "(?)", wantArgs: []any{uint(1)}},
+		{name: "values", values: []uint{1, 2, 3}, want: "(?, ?, ?)", wantArgs: []any{uint(1), uint(2), uint(3)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in, args := sqlIn(tc.values)
+
+			if in != tc.want {
+				t.Errorf("placeholders are %s, want %s", in, tc.want)
+			}
+
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Errorf("arguments are %v, want %v", args, tc.wantArgs)
+			}
+		})
+	}
+}
@@ -26,24 +26,25 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
-	"strings"
 	"sync"
 )
 
 type System struct {
-	Id           uint64
-	Alert        string
-	AutoPopulate bool
-	Blacklists   Blacklists
-	Delay        uint
-	Kind         string
-	Label        string
-	Led          string
-	Order        uint
-	Sites        *Sites
-	SystemRef    uint
-	Talkgroups   *Talkgroups
-	Units        *Units
+	Id              uint64
+	Alert           string
+	AudioProcessing *AudioProcessing
+	AudioProfile    string
+	AutoPopulate    bool
+	Blacklists      Blacklists
+	Delay           uint
+	Kind            string
+	Label           string
+	Led             string
+	Order           uint
+	Sites           *Sites
+	SystemRef       uint
+	Talkgroups      *Talkgroups
+	Units           *Units
 }
 
 func NewSystem() *System {
@@ -65,6 +66,16 @@ func (system *System) FromMap(m map[string]any) *System {
 		system.Alert = v
 	}
 
+	switch v := m["audioProcessing"].(type) {
+	case map[string]any:
+		system.AudioProcessing = NewAudioProcessing().FromMap(v).orNil()
+	}
+
+	switch v := m["audioProfile"].(type) {
+	case string:
+		system.AudioProfile = v
+	}
+
 	switch v := m["autoPopulate"].(type) {
 	case bool:
 		system.AutoPopulate = v
@@ -140,6 +151,14 @@ func (system *System) MarshalJSON() ([]byte, error) {
 		m["alert"] = system.Alert
 	}
 
+	if system.AudioProcessing != nil {
+		m["audioProcessing"] = system.AudioProcessing
+	}
+
+	if len(system.AudioProfile) > 0 {
+		m["audioProfile"] = system.AudioProfile
+	}
+
 	if len(system.Blacklists) > 0 {
 		m["blacklists"] = system.Blacklists
 	}
@@ -411,12 +430,12 @@ func (systems *Systems) GetScopedSystems(client *Client, groups *Groups, tags *T
 	return systemsMap
 }
 
-func (systems *Systems) Read(db *Database) error {
+func (systems *Systems) Read(db Storage) error {
 	var (
 		err   error
 		query string
 		rows  *sql.Rows
-		tx    *sql.Tx
+		tx    *StorageTx
 	)
 
 	systems.mutex.Lock()
@@ -426,23 +445,27 @@ func (systems *Systems) Read(db *Database) error {
 
 	formatError := errorFormatter("systems", "read")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
-	query = `SELECT "systemId", "alert", "autoPopulate", "blacklists", "delay", "label", "led", "order", "systemRef", "type" FROM "systems"`
+	query = `SELECT "systemId", "alert", "audioProcessing", "audioProfile", "autoPopulate", "blacklists", "delay", "label", "led", "order", "systemRef", "type" FROM "systems"`
 	if rows, err = tx.Query(query); err != nil {
 		tx.Rollback()
 		return formatError(err, query)
 	}
 
 	for rows.Next() {
+		var audioProcessing string
+
 		system := NewSystem()
 
-		if err = rows.Scan(&system.Id, &system.Alert, &system.AutoPopulate, &system.Blacklists, &system.Delay, &system.Label, &system.Led, &system.Order, &system.SystemRef, &system.Kind); err != nil {
+		if err = rows.Scan(&system.Id, &system.Alert, &audioProcessing, &system.AudioProfile, &system.AutoPopulate, &system.Blacklists, &system.Delay, &system.Label, &system.Led, &system.Order, &system.SystemRef, &system.Kind); err != nil {
 			break
 		}
 
+		system.AudioProcessing = ParseAudioProcessing(audioProcessing)
+
 		systems.List = append(systems.List, system)
 	}
 
@@ -458,7 +481,7 @@ func (systems *Systems) Read(db *Database) error {
 			break
 		}
 
-		if err = system.Talkgroups.ReadTx(tx, system.Id, db.Config.DbType); err != nil {
+		if err = system.Talkgroups.ReadTx(tx, system.Id); err != nil {
 			break
 		}
 
@@ -484,14 +507,14 @@ func (systems *Systems) Read(db *Database) error {
 	return nil
 }
 
-func (systems *Systems) Write(db *Database) error {
+func (systems *Systems) Write(db Storage) error {
 	var (
 		err       error
 		query     string
 		res       sql.Result
 		rows      *sql.Rows
 		systemIds = []uint64{}
-		tx        *sql.Tx
+		tx        *StorageTx
 	)
 
 	systems.mutex.Lock()
@@ -499,7 +522,7 @@ func (systems *Systems) Write(db *Database) error {
 
 	formatError := errorFormatter("systems", "write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -534,30 +557,18 @@ func (systems *Systems) Write(db *Database) error {
 	}
 
 	if len(systemIds) > 0 {
-		if b, err := json.Marshal(systemIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-
-			query = fmt.Sprintf(`DELETE FROM "systems" WHERE "systemId" IN %s`, in)
-			if res, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
-
-			if count, err := res.RowsAffected(); err == nil && count > 0 {
-				query = fmt.Sprintf(`DELETE FROM "sites" WHERE "systemId" IN %s`, in)
-				if _, err = tx.Exec(query); err != nil {
-					tx.Rollback()
-					return formatError(err, query)
-				}
+		in, args := sqlIn(systemIds)
 
-				query = fmt.Sprintf(`DELETE FROM "talkgroups" WHERE "systemId" IN %s`, in)
-				if _, err = tx.Exec(query); err != nil {
-					tx.Rollback()
-					return formatError(err, query)
-				}
+		query = fmt.Sprintf(`DELETE FROM "systems" WHERE "systemId" IN %s`, in)
+		if res, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
+		}
 
-				query = fmt.Sprintf(`DELETE FROM "units" WHERE "systemId" IN %s`, in)
-				if _, err = tx.Exec(query); err != nil {
+		if count, err := res.RowsAffected(); err == nil && count > 0 {
+			for _, table := range []string{"sites", "talkgroups", "units"} {
+				query = fmt.Sprintf(`DELETE FROM "%s" WHERE "systemId" IN %s`, table, in)
+				if _, err = tx.Exec(query, args...); err != nil {
 					tx.Rollback()
 					return formatError(err, query)
 				}
@@ -569,35 +580,21 @@ func (systems *Systems) Write(db *Database) error {
 		var count uint
 
 		if system.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "systems" WHERE "systemId" = %d`, system.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "systems" WHERE "systemId" = ?`
+			if err = tx.QueryRow(query, system.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "systems" ("alert", "autoPopulate", "blacklists", "delay", "label", "led", "order", "systemRef", "type") VALUES ('%s', %t, '%s', %d, '%s', '%s', %d, %d, '%s')`, system.Alert, system.AutoPopulate, system.Blacklists, system.Delay, escapeQuotes(system.Label), system.Led, system.Order, system.SystemRef, system.Kind)
-
-			if db.Config.DbType == DbTypePostgresql {
-				query = query + ` RETURNING "systemId"`
-
-				if err = tx.QueryRow(query).Scan(&system.Id); err != nil {
-					break
-				}
-
-			} else {
-				if res, err = tx.Exec(query); err == nil {
-					if id, err := res.LastInsertId(); err == nil {
-						system.Id = uint64(id)
-					}
-				} else {
-					break
-				}
+			query = `INSERT INTO "systems" ("alert", "audioProcessing", "audioProfile", "autoPopulate", "blacklists", "delay", "label", "led", "order", "systemRef", "type") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			if system.Id, err = tx.Insert(query, "systemId", system.Alert, system.AudioProcessing.String(), system.AudioProfile, system.AutoPopulate, system.Blacklists, system.Delay, system.Label, system.Led, system.Order, system.SystemRef, system.Kind); err != nil {
+				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "systems" SET "alert" = '%s', "autoPopulate" = %t, "blacklists" = '%s', "delay" = %d, "label" = '%s', "led" = '%s', "order" = %d, "systemRef" = %d, "type" = '%s' WHERE "systemId" = %d`, system.Alert, system.AutoPopulate, system.Blacklists, system.Delay, escapeQuotes(system.Label), system.Led, system.Order, system.SystemRef, system.Kind, system.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "systems" SET "alert" = ?, "audioProcessing" = ?, "audioProfile" = ?, "autoPopulate" = ?, "blacklists" = ?, "delay" = ?, "label" = ?, "led" = ?, "order" = ?, "systemRef" = ?, "type" = ? WHERE "systemId" = ?`
+			if _, err = tx.Exec(query, system.Alert, system.AudioProcessing.String(), system.AudioProfile, system.AutoPopulate, system.Blacklists, system.Delay, system.Label, system.Led, system.Order, system.SystemRef, system.Kind, system.Id); err != nil {
 				break
 			}
 		}
@@ -608,7 +605,7 @@ func (systems *Systems) Write(db *Database) error {
 			break
 		}
 
-		if err = system.Talkgroups.WriteTx(tx, system.Id, db.Config.DbType); err != nil {
+		if err = system.Talkgroups.WriteTx(tx, system.Id); err != nil {
 			break
 		}
 
@@ -25,7 +25,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strings"
 	"sync"
 )
 
@@ -243,7 +242,7 @@ func (tags *Tags) GetTagsMap(systemsMap *SystemsMap) TagsMap {
 	return tagsMap
 }
 
-func (tags *Tags) Read(db *Database) error {
+func (tags *Tags) Read(db Storage) error {
 	var (
 		err   error
 		query string
@@ -258,7 +257,7 @@ func (tags *Tags) Read(db *Database) error {
 	formatError := errorFormatter("tags", "read")
 
 	query = `SELECT "tagId", "alert", "label", "led", "order" FROM "tags"`
-	if rows, err = db.Sql.Query(query); err != nil {
+	if rows, err = db.Query(query); err != nil {
 		return formatError(err, query)
 	}
 
@@ -285,13 +284,13 @@ func (tags *Tags) Read(db *Database) error {
 	return nil
 }
 
-func (tags *Tags) Write(db *Database) error {
+func (tags *Tags) Write(db Storage) error {
 	var (
 		err    error
 		query  string
 		rows   *sql.Rows
 		tagIds = []uint64{}
-		tx     *sql.Tx
+		tx     *StorageTx
 	)
 
 	tags.mutex.Lock()
@@ -299,7 +298,7 @@ func (tags *Tags) Write(db *Database) error {
 
 	formatError := errorFormatter("tags", "write")
 
-	if tx, err = db.Sql.Begin(); err != nil {
+	if tx, err = db.Begin(); err != nil {
 		return formatError(err, "")
 	}
 
@@ -334,13 +333,11 @@ func (tags *Tags) Write(db *Database) error {
 	}
 
 	if len(tagIds) > 0 {
-		if b, err := json.Marshal(tagIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "tags" WHERE "tagId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				tx.Rollback()
-				return formatError(err, query)
-			}
+		in, args := sqlIn(tagIds)
+		query = fmt.Sprintf(`DELETE FROM "tags" WHERE "tagId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return formatError(err, query)
 		}
 	}
 
@@ -348,20 +345,20 @@ func (tags *Tags) Write(db *Database) error {
 		var count uint
 
 		if tag.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "tags" WHERE "tagId" = %d`, tag.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "tags" WHERE "tagId" = ?`
+			if err = tx.QueryRow(query, tag.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "tags" ("alert", "label", "led", "order") VALUES ('%s', '%s', '%s', %d)`, tag.Alert, escapeQuotes(tag.Label), tag.Led, tag.Order)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "tags" ("alert", "label", "led", "order") VALUES (?, ?, ?, ?)`
+			if _, err = tx.Exec(query, tag.Alert, tag.Label, tag.Led, tag.Order); err != nil {
 				break
 			}
 		} else {
-			query = fmt.Sprintf(`UPDATE "tags" SET "alert" = '%s', "label" = '%s', "led" = '%s', "order" = %d WHERE "tagId" = %d`, tag.Alert, escapeQuotes(tag.Label), tag.Led, tag.Order, tag.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "tags" SET "alert" = ?, "label" = ?, "led" = ?, "order" = ? WHERE "tagId" = ?`
+			if _, err = tx.Exec(query, tag.Alert, tag.Label, tag.Led, tag.Order, tag.Id); err != nil {
 				break
 			}
 		}
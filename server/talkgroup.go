@@ -31,18 +31,20 @@ import (
 )
 
 type Talkgroup struct {
-	Id           uint64
-	Alert        string
-	Delay        uint
-	Frequency    uint
-	GroupIds     []uint64
-	Kind         string
-	Label        string
-	Led          string
-	Name         string
-	Order        uint
-	TagId        uint64
-	TalkgroupRef uint
+	Id              uint64
+	Alert           string
+	AudioProcessing *AudioProcessing
+	AudioProfile    string
+	Delay           uint
+	Frequency       uint
+	GroupIds        []uint64
+	Kind            string
+	Label           string
+	Led             string
+	Name            string
+	Order           uint
+	TagId           uint64
+	TalkgroupRef    uint
 }
 
 func NewTalkgroup() *Talkgroup {
@@ -62,6 +64,16 @@ func (talkgroup *Talkgroup) FromMap(m map[string]any) *Talkgroup {
 		talkgroup.Alert = v
 	}
 
+	switch v := m["audioProcessing"].(type) {
+	case map[string]any:
+		talkgroup.AudioProcessing = NewAudioProcessing().FromMap(v).orNil()
+	}
+
+	switch v := m["audioProfile"].(type) {
+	case string:
+		talkgroup.AudioProfile = v
+	}
+
 	switch v := m["delay"].(type) {
 	case float64:
 		talkgroup.Delay = uint(v)
@@ -134,6 +146,14 @@ func (talkgroup *Talkgroup) MarshalJSON() ([]byte, error) {
 		m["alert"] = talkgroup.Alert
 	}
 
+	if talkgroup.AudioProcessing != nil {
+		m["audioProcessing"] = talkgroup.AudioProcessing
+	}
+
+	if len(talkgroup.AudioProfile) > 0 {
+		m["audioProfile"] = talkgroup.AudioProfile
+	}
+
 	if talkgroup.Delay > 0 {
 		m["delay"] = talkgroup.Delay
 	}
@@ -231,7 +251,7 @@ func (talkgroups *Talkgroups) GetTalkgroupByRef(ref uint) (talkgroup *Talkgroup,
 	return nil, false
 }
 
-func (talkgroups *Talkgroups) ReadTx(tx *sql.Tx, systemId uint64, dbType string) error {
+func (talkgroups *Talkgroups) ReadTx(tx *StorageTx, systemId uint64) error {
 	var (
 		err   error
 		query string
@@ -247,24 +267,22 @@ func (talkgroups *Talkgroups) ReadTx(tx *sql.Tx, systemId uint64, dbType string)
 
 	formatError := errorFormatter("talkgroups", "read")
 
-	if dbType == DbTypePostgresql {
-		query = fmt.Sprintf(`SELECT t."talkgroupId", t."alert", t."delay", t."frequency", t."label", t."led", t."name", t."order", t."tagId", t."talkgroupRef", t."type", STRING_AGG(CAST(COALESCE(tg."groupId", 0) AS text), ',') FROM "talkgroups" AS t LEFT JOIN "talkgroupGroups" AS tg ON tg."talkgroupId" = t."talkgroupId" WHERE t."systemId" = %d GROUP BY t."talkgroupId"`, systemId)
-
-	} else {
-		query = fmt.Sprintf(`SELECT t."talkgroupId", t."alert", t."delay", t."frequency", t."label", t."led", t."name", t."order", t."tagId", t."talkgroupRef", t."type", GROUP_CONCAT(COALESCE(tg."groupId", 0)) FROM "talkgroups" AS t LEFT JOIN "talkgroupGroups" AS tg ON tg."talkgroupId" = t."talkgroupId" WHERE t."systemId" = %d GROUP BY t."talkgroupId"`, systemId)
-	}
-
-	if rows, err = tx.Query(query); err != nil {
+	query = fmt.Sprintf(`SELECT t."talkgroupId", t."alert", t."audioProcessing", t."audioProfile", t."delay", t."frequency", t."label", t."led", t."name", t."order", t."tagId", t."talkgroupRef", t."type", %s FROM "talkgroups" AS t LEFT JOIN "talkgroupGroups" AS tg ON tg."talkgroupId" = t."talkgroupId" WHERE t."systemId" = ? GROUP BY t."talkgroupId"`, tx.db.dialect.StringAgg(`COALESCE(tg."groupId", 0)`))
+	if rows, err = tx.Query(query, systemId); err != nil {
 		return formatError(err, query)
 	}
 
 	for rows.Next() {
+		var audioProcessing string
+
 		talkgroup := NewTalkgroup()
 
-		if err = rows.Scan(&talkgroup.Id, &talkgroup.Alert, &talkgroup.Delay, &talkgroup.Frequency, &talkgroup.Label, &talkgroup.Led, &talkgroup.Name, &talkgroup.Order, &talkgroup.TagId, &talkgroup.TalkgroupRef, &talkgroup.Kind, &groupIds); err != nil {
+		if err = rows.Scan(&talkgroup.Id, &talkgroup.Alert, &audioProcessing, &talkgroup.AudioProfile, &talkgroup.Delay, &talkgroup.Frequency, &talkgroup.Label, &talkgroup.Led, &talkgroup.Name, &talkgroup.Order, &talkgroup.TagId, &talkgroup.TalkgroupRef, &talkgroup.Kind, &groupIds); err != nil {
 			break
 		}
 
+		talkgroup.AudioProcessing = ParseAudioProcessing(audioProcessing)
+
 		for _, s := range strings.Split(groupIds, ",") {
 			if i, err := strconv.Atoi(s); err == nil && i > 0 {
 				talkgroup.GroupIds = append(talkgroup.GroupIds, uint64(i))
@@ -287,11 +305,10 @@ func (talkgroups *Talkgroups) ReadTx(tx *sql.Tx, systemId uint64, dbType string)
 	return nil
 }
 
-func (talkgroups *Talkgroups) WriteTx(tx *sql.Tx, systemId uint64, dbType string) error {
+func (talkgroups *Talkgroups) WriteTx(tx *StorageTx, systemId uint64) error {
 	var (
 		err   error
 		query string
-		res   sql.Result
 		rows  *sql.Rows
 
 		talkgroupGroupIds = []uint64{}
@@ -303,8 +320,8 @@ func (talkgroups *Talkgroups) WriteTx(tx *sql.Tx, systemId uint64, dbType string
 
 	formatError := errorFormatter("talkgroups", "writetx")
 
-	query = fmt.Sprintf(`SELECT "talkgroupId" FROM "talkgroups" WHERE "systemId" = %d`, systemId)
-	if rows, err = tx.Query(query); err != nil {
+	query = `SELECT "talkgroupId" FROM "talkgroups" WHERE "systemId" = ?`
+	if rows, err = tx.Query(query, systemId); err != nil {
 		return formatError(err, query)
 	}
 
@@ -332,18 +349,16 @@ func (talkgroups *Talkgroups) WriteTx(tx *sql.Tx, systemId uint64, dbType string
 	}
 
 	if len(talkgroupIds) > 0 {
-		if b, err := json.Marshal(talkgroupIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
+		in, args := sqlIn(talkgroupIds)
 
-			query = fmt.Sprintf(`DELETE FROM "talkgroups" WHERE "talkgroupId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				return formatError(err, query)
-			}
+		query = fmt.Sprintf(`DELETE FROM "talkgroups" WHERE "talkgroupId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return formatError(err, query)
+		}
 
-			query = fmt.Sprintf(`DELETE FROM "talkgroupGroups" WHERE "talkgroupId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				return formatError(err, query)
-			}
+		query = fmt.Sprintf(`DELETE FROM "talkgroupGroups" WHERE "talkgroupId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return formatError(err, query)
 		}
 	}
 
@@ -351,41 +366,27 @@ func (talkgroups *Talkgroups) WriteTx(tx *sql.Tx, systemId uint64, dbType string
 		var count uint
 
 		if talkgroup.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "talkgroups" WHERE "talkgroupId" = %d`, talkgroup.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "talkgroups" WHERE "talkgroupId" = ?`
+			if err = tx.QueryRow(query, talkgroup.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "talkgroups" ("alert", "delay", "frequency", "label", "led", "name", "order", "systemId", "tagId", "talkgroupRef", "type") VALUES ('%s', %d, %d, '%s', '%s', '%s', %d, %d, %d, %d, '%s')`, talkgroup.Alert, talkgroup.Delay, talkgroup.Frequency, escapeQuotes(talkgroup.Label), talkgroup.Led, escapeQuotes(talkgroup.Name), talkgroup.Order, systemId, talkgroup.TagId, talkgroup.TalkgroupRef, talkgroup.Kind)
-
-			if dbType == DbTypePostgresql {
-				query = query + ` RETURNING "talkgroupId"`
-
-				if err = tx.QueryRow(query).Scan(&talkgroup.Id); err != nil {
-					break
-				}
-
-			} else {
-				if res, err = tx.Exec(query); err == nil {
-					if id, err := res.LastInsertId(); err == nil {
-						talkgroup.Id = uint64(id)
-					}
-				} else {
-					break
-				}
+			query = `INSERT INTO "talkgroups" ("alert", "audioProcessing", "audioProfile", "delay", "frequency", "label", "led", "name", "order", "systemId", "tagId", "talkgroupRef", "type") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			if talkgroup.Id, err = tx.Insert(query, "talkgroupId", talkgroup.Alert, talkgroup.AudioProcessing.String(), talkgroup.AudioProfile, talkgroup.Delay, talkgroup.Frequency, talkgroup.Label, talkgroup.Led, talkgroup.Name, talkgroup.Order, systemId, talkgroup.TagId, talkgroup.TalkgroupRef, talkgroup.Kind); err != nil {
+				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "talkgroups" SET "alert" = '%s', "delay" = %d, "frequency" = %d, "label" = '%s', "led" = '%s', "name" = '%s', "order" = %d, "tagId" = %d, "talkgroupRef" = %d, "type" = '%s' WHERE "talkgroupId" = %d`, talkgroup.Alert, talkgroup.Delay, talkgroup.Frequency, escapeQuotes(talkgroup.Label), talkgroup.Led, escapeQuotes(talkgroup.Name), talkgroup.Order, talkgroup.TagId, talkgroup.TalkgroupRef, talkgroup.Kind, talkgroup.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "talkgroups" SET "alert" = ?, "audioProcessing" = ?, "audioProfile" = ?, "delay" = ?, "frequency" = ?, "label" = ?, "led" = ?, "name" = ?, "order" = ?, "tagId" = ?, "talkgroupRef" = ?, "type" = ? WHERE "talkgroupId" = ?`
+			if _, err = tx.Exec(query, talkgroup.Alert, talkgroup.AudioProcessing.String(), talkgroup.AudioProfile, talkgroup.Delay, talkgroup.Frequency, talkgroup.Label, talkgroup.Led, talkgroup.Name, talkgroup.Order, talkgroup.TagId, talkgroup.TalkgroupRef, talkgroup.Kind, talkgroup.Id); err != nil {
 				break
 			}
 		}
 
-		query = fmt.Sprintf(`SELECT "groupId", "talkgroupGroupId" FROM "talkgroupGroups" WHERE "talkgroupId" = %d`, talkgroup.Id)
-		if rows, err = tx.Query(query); err != nil {
+		query = `SELECT "groupId", "talkgroupGroupId" FROM "talkgroupGroups" WHERE "talkgroupId" = ?`
+		if rows, err = tx.Query(query, talkgroup.Id); err != nil {
 			break
 		}
 
@@ -416,24 +417,22 @@ func (talkgroups *Talkgroups) WriteTx(tx *sql.Tx, systemId uint64, dbType string
 		}
 
 		if len(talkgroupGroupIds) > 0 {
-			if b, err := json.Marshal(talkgroupGroupIds); err == nil {
-				in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-				query = fmt.Sprintf(`DELETE FROM "talkgroupGroups" WHERE "talkgroupGroupId" IN %s`, in)
-				if _, err = tx.Exec(query); err != nil {
-					return formatError(err, query)
-				}
+			in, args := sqlIn(talkgroupGroupIds)
+			query = fmt.Sprintf(`DELETE FROM "talkgroupGroups" WHERE "talkgroupGroupId" IN %s`, in)
+			if _, err = tx.Exec(query, args...); err != nil {
+				return formatError(err, query)
 			}
 		}
 
 		for _, groupId := range talkgroup.GroupIds {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "talkgroupGroups" WHERE "talkgroupId" = %d AND "groupId" = %d`, talkgroup.Id, groupId)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "talkgroupGroups" WHERE "talkgroupId" = ? AND "groupId" = ?`
+			if err = tx.QueryRow(query, talkgroup.Id, groupId).Scan(&count); err != nil {
 				break
 			}
 
 			if count == 0 {
-				query = fmt.Sprintf(`INSERT INTO "talkgroupGroups" ("groupId", "talkgroupId") VALUES (%d, %d)`, groupId, talkgroup.Id)
-				if _, err = tx.Exec(query); err != nil {
+				query = `INSERT INTO "talkgroupGroups" ("groupId", "talkgroupId") VALUES (?, ?)`
+				if _, err = tx.Exec(query, groupId, talkgroup.Id); err != nil {
 					break
 				}
 			}
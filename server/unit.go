@@ -25,7 +25,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strings"
 	"sync"
 )
 
@@ -154,6 +153,26 @@ func (units *Units) FromMap(f []any) *Units {
 	return units
 }
 
+// GetUnitByRef returns the unit with this ref or whose range includes it.
+func (units *Units) GetUnitByRef(ref uint) (unit *Unit, ok bool) {
+	units.mutex.Lock()
+	defer units.mutex.Unlock()
+
+	for _, unit := range units.List {
+		if unit.UnitRef == ref {
+			return unit, true
+		}
+	}
+
+	for _, unit := range units.List {
+		if unit.UnitFrom > 0 && unit.UnitTo > 0 && ref >= unit.UnitFrom && ref <= unit.UnitTo {
+			return unit, true
+		}
+	}
+
+	return nil, false
+}
+
 func (u *Units) Merge(units *Units) bool {
 	merged := false
 
@@ -171,7 +190,7 @@ func (u *Units) Merge(units *Units) bool {
 	return merged
 }
 
-func (units *Units) ReadTx(tx *sql.Tx, systemId uint64) error {
+func (units *Units) ReadTx(tx *StorageTx, systemId uint64) error {
 	var (
 		err   error
 		query string
@@ -185,8 +204,8 @@ func (units *Units) ReadTx(tx *sql.Tx, systemId uint64) error {
 
 	formatError := errorFormatter("units", "read")
 
-	query = fmt.Sprintf(`SELECT "unitId", "label", "order", "unitRef", "unitFrom", "unitTo" FROM "units" WHERE "systemId" = %d`, systemId)
-	if rows, err = tx.Query(query); err != nil {
+	query = `SELECT "unitId", "label", "order", "unitRef", "unitFrom", "unitTo" FROM "units" WHERE "systemId" = ?`
+	if rows, err = tx.Query(query, systemId); err != nil {
 		return formatError(err, query)
 	}
 
@@ -213,7 +232,7 @@ func (units *Units) ReadTx(tx *sql.Tx, systemId uint64) error {
 	return nil
 }
 
-func (units *Units) WriteTx(tx *sql.Tx, systemId uint64) error {
+func (units *Units) WriteTx(tx *StorageTx, systemId uint64) error {
 	var (
 		err     error
 		query   string
@@ -226,8 +245,8 @@ func (units *Units) WriteTx(tx *sql.Tx, systemId uint64) error {
 
 	formatError := errorFormatter("units", "writetx")
 
-	query = fmt.Sprintf(`SELECT "unitId" FROM "units" WHERE "systemId" = %d`, systemId)
-	if rows, err = tx.Query(query); err != nil {
+	query = `SELECT "unitId" FROM "units" WHERE "systemId" = ?`
+	if rows, err = tx.Query(query, systemId); err != nil {
 		return formatError(err, query)
 	}
 
@@ -255,12 +274,10 @@ func (units *Units) WriteTx(tx *sql.Tx, systemId uint64) error {
 	}
 
 	if len(unitIds) > 0 {
-		if b, err := json.Marshal(unitIds); err == nil {
-			in := strings.ReplaceAll(strings.ReplaceAll(string(b), "[", "("), "]", ")")
-			query = fmt.Sprintf(`DELETE FROM "units" WHERE "unitId" IN %s`, in)
-			if _, err = tx.Exec(query); err != nil {
-				return formatError(err, query)
-			}
+		in, args := sqlIn(unitIds)
+		query = fmt.Sprintf(`DELETE FROM "units" WHERE "unitId" IN %s`, in)
+		if _, err = tx.Exec(query, args...); err != nil {
+			return formatError(err, query)
 		}
 	}
 
@@ -268,21 +285,21 @@ func (units *Units) WriteTx(tx *sql.Tx, systemId uint64) error {
 		var count uint
 
 		if unit.Id > 0 {
-			query = fmt.Sprintf(`SELECT COUNT(*) FROM "units" WHERE "unitId" = %d`, unit.Id)
-			if err = tx.QueryRow(query).Scan(&count); err != nil {
+			query = `SELECT COUNT(*) FROM "units" WHERE "unitId" = ?`
+			if err = tx.QueryRow(query, unit.Id).Scan(&count); err != nil {
 				break
 			}
 		}
 
 		if count == 0 {
-			query = fmt.Sprintf(`INSERT INTO "units" ("label", "order", "systemId", "unitRef", "unitFrom", "unitTo") VALUES ('%s', %d, %d, %d, %d, %d)`, escapeQuotes(unit.Label), unit.Order, systemId, unit.UnitRef, unit.UnitFrom, unit.UnitTo)
-			if _, err = tx.Exec(query); err != nil {
+			query = `INSERT INTO "units" ("label", "order", "systemId", "unitRef", "unitFrom", "unitTo") VALUES (?, ?, ?, ?, ?, ?)`
+			if _, err = tx.Exec(query, unit.Label, unit.Order, systemId, unit.UnitRef, unit.UnitFrom, unit.UnitTo); err != nil {
 				break
 			}
 
 		} else {
-			query = fmt.Sprintf(`UPDATE "units" SET "label" = '%s', "order" = %d, "unitRef" = %d, "unitFrom" = %d, "unitTo" = %d WHERE "unitId" = %d`, escapeQuotes(unit.Label), unit.Order, unit.UnitRef, unit.UnitFrom, unit.UnitTo, unit.Id)
-			if _, err = tx.Exec(query); err != nil {
+			query = `UPDATE "units" SET "label" = ?, "order" = ?, "unitRef" = ?, "unitFrom" = ?, "unitTo" = ? WHERE "unitId" = ?`
+			if _, err = tx.Exec(query, unit.Label, unit.Order, unit.UnitRef, unit.UnitFrom, unit.UnitTo, unit.Id); err != nil {
 				break
 			}
 		}
@@ -0,0 +1,382 @@
+// Copyright (C) 2019-2026 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+//
+// WebSocket API Access Policy:
+// This WebSocket API is reserved exclusively for Saubeo Solutions and its native applications.
+// Unauthorized access is strictly prohibited.
+// See API_ACCESS_POLICY.md for full terms.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnitActivity is the history of a unit of a system, as seen in the calls
+// ingested, with a breakdown by talkgroup and by site.
+type UnitActivity struct {
+	CallCount  uint                `json:"callCount"`
+	FirstSeen  time.Time           `json:"firstSeen"`
+	Label      string              `json:"label,omitempty"`
+	LastSeen   time.Time           `json:"lastSeen"`
+	Sites      []UnitActivityUsage `json:"sites"`
+	System     uint                `json:"system"`
+	Talkgroups []UnitActivityUsage `json:"talkgroups"`
+	Unit       uint                `json:"unit"`
+}
+
+type UnitActivityUsage struct {
+	Id        uint      `json:"id"`
+	CallCount uint      `json:"callCount"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type UnitActivitySearchOptions struct {
+	Limit  uint   `json:"limit"`
+	Offset uint   `json:"offset"`
+	Search string `json:"search,omitempty"`
+	System uint   `json:"system,omitempty"`
+	Unit   uint   `json:"unit,omitempty"`
+}
+
+func NewUnitActivitySearchOptions() *UnitActivitySearchOptions {
+	return &UnitActivitySearchOptions{Limit: 100}
+}
+
+func (searchOptions *UnitActivitySearchOptions) FromQuery(get func(key string) string) *UnitActivitySearchOptions {
+	if i, err := strconv.ParseUint(get("limit"), 10, 32); err == nil && i > 0 {
+		searchOptions.Limit = min(uint(i), 500)
+	}
+
+	if i, err := strconv.ParseUint(get("offset"), 10, 32); err == nil {
+		searchOptions.Offset = uint(i)
+	}
+
+	searchOptions.Search = strings.TrimSpace(get("search"))
+
+	if i, err := strconv.ParseUint(get("system"), 10, 32); err == nil {
+		searchOptions.System = uint(i)
+	}
+
+	if i, err := strconv.ParseUint(get("unit"), 10, 32); err == nil {
+		searchOptions.Unit = uint(i)
+	}
+
+	return searchOptions
+}
+
+type UnitActivitySearchResults struct {
+	Count   uint                       `json:"count"`
+	Options *UnitActivitySearchOptions `json:"options"`
+	Results []*UnitActivity            `json:"results"`
+}
+
+type UnitActivities struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewUnitActivities(controller *Controller) *UnitActivities {
+	return &UnitActivities{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (unitActivities *UnitActivities) Prune(db Storage, pruneDays uint) error {
+	unitActivities.mutex.Lock()
+	defer unitActivities.mutex.Unlock()
+
+	timestamp := time.Now().Add(-24 * time.Hour * time.Duration(pruneDays)).UnixMilli()
+	query := `DELETE FROM "unitActivities" WHERE "lastTimestamp" < ?`
+
+	if _, err := db.Exec(query, timestamp); err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+
+	return nil
+}
+
+// Record adds the call to the history of each of its units.
+func (unitActivities *UnitActivities) Record(call *Call, db Storage) error {
+	var (
+		err   error
+		query string
+		refs  = map[uint]bool{}
+	)
+
+	unitActivities.mutex.Lock()
+	defer unitActivities.mutex.Unlock()
+
+	formatError := errorFormatter("unitactivities", "record")
+
+	timestamp := call.Timestamp.UnixMilli()
+
+	for _, unit := range call.Units {
+		if unit.UnitRef == 0 || refs[unit.UnitRef] {
+			continue
+		}
+
+		refs[unit.UnitRef] = true
+
+		var unitActivityId uint64
+
+		query = `SELECT "unitActivityId" FROM "unitActivities" WHERE "systemId" = ? AND "unitRef" = ? AND "talkgroupId" = ? AND "siteRef" = ?`
+		if err = db.QueryRow(query, call.System.Id, unit.UnitRef, call.Talkgroup.Id, call.SiteRef).Scan(&unitActivityId); err != nil && err != sql.ErrNoRows {
+			return formatError(err, query)
+		}
+
+		var args []any
+
+		if unitActivityId > 0 {
+			query = `UPDATE "unitActivities" SET "callCount" = "callCount" + 1, "firstTimestamp" = CASE WHEN "firstTimestamp" > ? THEN ? ELSE "firstTimestamp" END, "lastTimestamp" = CASE WHEN "lastTimestamp" < ? THEN ? ELSE "lastTimestamp" END WHERE "unitActivityId" = ?`
+			args = []any{timestamp, timestamp, timestamp, timestamp, unitActivityId}
+
+		} else {
+			query = `INSERT INTO "unitActivities" ("callCount", "firstTimestamp", "lastTimestamp", "siteRef", "systemId", "talkgroupId", "unitRef") VALUES (1, ?, ?, ?, ?, ?, ?)`
+			args = []any{timestamp, timestamp, call.SiteRef, call.System.Id, call.Talkgroup.Id, unit.UnitRef}
+		}
+
+		if _, err = db.Exec(query, args...); err != nil {
+			return formatError(err, query)
+		}
+	}
+
+	return nil
+}
+
+// Search returns the units matching the search options, most recently seen
+// first, limited to the systems and talkgroups of the access if any.
+func (unitActivities *UnitActivities) Search(searchOptions *UnitActivitySearchOptions, access *Access, db Storage) (*UnitActivitySearchResults, error) {
+	var (
+		args      = []any{}
+		err       error
+		query     string
+		rows      *sql.Rows
+		scope     string
+		scopeArgs []any
+		where     = `s."systemRef" IS NOT NULL AND t."talkgroupRef" IS NOT NULL`
+	)
+
+	unitActivities.mutex.Lock()
+	defer unitActivities.mutex.Unlock()
+
+	formatError := errorFormatter("unitactivities", "search")
+
+	searchResults := &UnitActivitySearchResults{
+		Options: searchOptions,
+		Results: []*UnitActivity{},
+	}
+
+	if access != nil {
+		if scope, scopeArgs = access.Scope(); len(scope) > 0 {
+			where += fmt.Sprintf(" AND %s", scope)
+			args = append(args, scopeArgs...)
+		}
+	}
+
+	if searchOptions.System > 0 {
+		where += ` AND s."systemRef" = ?`
+		args = append(args, searchOptions.System)
+	}
+
+	if searchOptions.Unit > 0 {
+		where += ` AND ua."unitRef" = ?`
+		args = append(args, searchOptions.Unit)
+	}
+
+	if len(searchOptions.Search) > 0 {
+		search, searchArgs := unitActivities.searchScope(searchOptions)
+		where += fmt.Sprintf(" AND %s", search)
+		args = append(args, searchArgs...)
+	}
+
+	from := `FROM "unitActivities" AS ua LEFT JOIN "systems" AS s ON s."systemId" = ua."systemId" LEFT JOIN "talkgroups" AS t ON t."talkgroupId" = ua."talkgroupId"`
+
+	query = fmt.Sprintf(`SELECT COUNT(*) FROM (SELECT ua."systemId", ua."unitRef" %s WHERE %s GROUP BY ua."systemId", ua."unitRef") AS u`, from, where)
+	if err = db.QueryRow(query, args...).Scan(&searchResults.Count); err != nil && err != sql.ErrNoRows {
+		return nil, formatError(err, query)
+	}
+
+	query = fmt.Sprintf(`SELECT ua."systemId", s."systemRef", ua."unitRef", SUM(ua."callCount"), MIN(ua."firstTimestamp"), MAX(ua."lastTimestamp") %s WHERE %s GROUP BY ua."systemId", s."systemRef", ua."unitRef" ORDER BY MAX(ua."lastTimestamp") DESC LIMIT ? OFFSET ?`, from, where)
+	if rows, err = db.Query(query, append(args, searchOptions.Limit, searchOptions.Offset)...); err != nil {
+		return nil, formatError(err, query)
+	}
+
+	systemIds := []uint64{}
+
+	for rows.Next() {
+		var (
+			firstTimestamp int64
+			lastTimestamp  int64
+			systemId       uint64
+		)
+
+		unitActivity := &UnitActivity{
+			Sites:      []UnitActivityUsage{},
+			Talkgroups: []UnitActivityUsage{},
+		}
+
+		if err = rows.Scan(&systemId, &unitActivity.System, &unitActivity.Unit, &unitActivity.CallCount, &firstTimestamp, &lastTimestamp); err != nil {
+			break
+		}
+
+		unitActivity.FirstSeen = time.UnixMilli(firstTimestamp)
+		unitActivity.LastSeen = time.UnixMilli(lastTimestamp)
+
+		if system, ok := unitActivities.controller.Systems.GetSystemById(systemId); ok {
+			if unit, ok := system.Units.GetUnitByRef(unitActivity.Unit); ok {
+				unitActivity.Label = unit.Label
+			}
+		}
+
+		searchResults.Results = append(searchResults.Results, unitActivity)
+		systemIds = append(systemIds, systemId)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err, "")
+	}
+
+	for i, unitActivity := range searchResults.Results {
+		sites := map[uint]*UnitActivityUsage{}
+		talkgroups := map[uint]*UnitActivityUsage{}
+
+		where = `ua."systemId" = ? AND ua."unitRef" = ? AND t."talkgroupRef" IS NOT NULL`
+		args = []any{systemIds[i], unitActivity.Unit}
+		if len(scope) > 0 {
+			where += fmt.Sprintf(" AND %s", scope)
+			args = append(args, scopeArgs...)
+		}
+
+		query = fmt.Sprintf(`SELECT ua."siteRef", t."talkgroupRef", ua."callCount", ua."lastTimestamp" %s WHERE %s`, from, where)
+		if rows, err = db.Query(query, args...); err != nil {
+			return nil, formatError(err, query)
+		}
+
+		for rows.Next() {
+			var (
+				callCount     uint
+				lastTimestamp int64
+				siteRef       uint
+				talkgroupRef  uint
+			)
+
+			if err = rows.Scan(&siteRef, &talkgroupRef, &callCount, &lastTimestamp); err != nil {
+				break
+			}
+
+			lastSeen := time.UnixMilli(lastTimestamp)
+
+			addUnitActivityUsage(sites, siteRef, callCount, lastSeen)
+			addUnitActivityUsage(talkgroups, talkgroupRef, callCount, lastSeen)
+		}
+
+		rows.Close()
+
+		if err != nil {
+			return nil, formatError(err, "")
+		}
+
+		unitActivity.Sites = sortedUnitActivityUsages(sites)
+		unitActivity.Talkgroups = sortedUnitActivityUsages(talkgroups)
+	}
+
+	return searchResults, nil
+}
+
+// searchScope returns the sql condition, with its arguments, matching the
+// units whose ref is the searched number or whose label contains the searched
+// text.
+func (unitActivities *UnitActivities) searchScope(searchOptions *UnitActivitySearchOptions) (string, []any) {
+	var (
+		a    = []string{}
+		args = []any{}
+	)
+
+	if i, err := strconv.ParseUint(searchOptions.Search, 10, 32); err == nil {
+		a = append(a, `ua."unitRef" = ?`)
+		args = append(args, i)
+	}
+
+	search := strings.ToLower(searchOptions.Search)
+
+	unitActivities.controller.Systems.mutex.Lock()
+	systems := append([]*System{}, unitActivities.controller.Systems.List...)
+	unitActivities.controller.Systems.mutex.Unlock()
+
+	for _, system := range systems {
+		if searchOptions.System > 0 && system.SystemRef != searchOptions.System {
+			continue
+		}
+
+		system.Units.mutex.Lock()
+		for _, unit := range system.Units.List {
+			if !strings.Contains(strings.ToLower(unit.Label), search) {
+				continue
+			}
+			if unit.UnitFrom > 0 && unit.UnitTo > 0 {
+				a = append(a, `(ua."systemId" = ? AND ua."unitRef" BETWEEN ? AND ?)`)
+				args = append(args, system.Id, unit.UnitFrom, unit.UnitTo)
+			} else if unit.UnitRef > 0 {
+				a = append(a, `(ua."systemId" = ? AND ua."unitRef" = ?)`)
+				args = append(args, system.Id, unit.UnitRef)
+			}
+		}
+		system.Units.mutex.Unlock()
+	}
+
+	if len(a) == 0 {
+		return "1 = 0", nil
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(a, " OR ")), args
+}
+
+func addUnitActivityUsage(usages map[uint]*UnitActivityUsage, ref uint, callCount uint, lastSeen time.Time) {
+	if usage, ok := usages[ref]; ok {
+		usage.CallCount += callCount
+		if lastSeen.After(usage.LastSeen) {
+			usage.LastSeen = lastSeen
+		}
+	} else {
+		usages[ref] = &UnitActivityUsage{Id: ref, CallCount: callCount, LastSeen: lastSeen}
+	}
+}
+
+func sortedUnitActivityUsages(m map[uint]*UnitActivityUsage) []UnitActivityUsage {
+	usages := []UnitActivityUsage{}
+
+	for _, usage := range m {
+		usages = append(usages, *usage)
+	}
+
+	sort.Slice(usages, func(i int, j int) bool {
+		if usages[i].CallCount != usages[j].CallCount {
+			return usages[i].CallCount > usages[j].CallCount
+		}
+		return usages[i].Id < usages[j].Id
+	})
+
+	return usages
+}